@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/spf13/cobra"
+
+	"github.com/christian-nickerson/pangolin/control/internal/models"
+)
+
+var collectionHeader = []string{"NAME", "MODEL", "METRIC", "CHUNKING", "SHARDS", "DOCUMENTS", "VECTOR BYTES", "CREATED"}
+
+func newCollectionsCommand(flags *globals) *cobra.Command {
+	command := &cobra.Command{
+		Use:     "collections",
+		Aliases: []string{"collection"},
+		Short:   "Manage collections",
+	}
+
+	var spec models.Collection
+	var metric string
+	create := &cobra.Command{
+		Use:   "create <name>",
+		Short: "Create a collection",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			api, err := flags.client()
+			if err != nil {
+				return err
+			}
+			spec.Name, spec.Metric = args[0], models.Metric(metric)
+			collection, err := api.CreateCollection(cmd.Context(), spec)
+			if err != nil {
+				return err
+			}
+			return flags.print(os.Stdout, collection, collectionHeader, [][]string{collectionRow(*collection)})
+		},
+	}
+	create.Flags().StringVar(&spec.Model, "model", "", "embedding model")
+	create.Flags().StringVar(&metric, "metric", string(models.MetricCosine), "distance metric, cosine, dot or euclidean")
+	create.Flags().IntVar(&spec.ChunkSize, "chunk-size", 512, "chunk size in tokens")
+	create.Flags().IntVar(&spec.ChunkOverlap, "chunk-overlap", 64, "tokens shared by neighbouring chunks")
+	create.Flags().IntVar(&spec.Shards, "shards", 0, "shards across index nodes, zero keeps the collection on the control plane")
+	create.Flags().IntVar(&spec.Replicas, "replicas", 0, "replicas of each shard, the cluster default when zero")
+	create.MarkFlagRequired("model")
+
+	list := &cobra.Command{
+		Use:   "list",
+		Short: "List the collections the key may read",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			api, err := flags.client()
+			if err != nil {
+				return err
+			}
+			all, err := api.ListCollections(cmd.Context())
+			if err != nil {
+				return err
+			}
+			rows := make([][]string, len(all))
+			for i, collection := range all {
+				rows[i] = collectionRow(collection)
+			}
+			return flags.print(os.Stdout, all, collectionHeader, rows)
+		},
+	}
+
+	remove := &cobra.Command{
+		Use:   "delete <name>",
+		Short: "Delete a collection with its documents",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			api, err := flags.client()
+			if err != nil {
+				return err
+			}
+			if err := api.DeleteCollection(cmd.Context(), args[0]); err != nil {
+				return err
+			}
+			return flags.print(os.Stdout, map[string]string{"deleted": args[0]}, []string{"DELETED"}, [][]string{{args[0]}})
+		},
+	}
+
+	command.AddCommand(create, list, remove)
+	return command
+}
+
+func collectionRow(collection models.Collection) []string {
+	shards := "-"
+	if collection.Sharded() {
+		shards = fmt.Sprintf("%vx%v", collection.Shards, collection.ReplicationFactor())
+	}
+	return []string{
+		collection.Name,
+		collection.Model,
+		string(collection.Metric),
+		fmt.Sprintf("%v/%v", collection.ChunkSize, collection.ChunkOverlap),
+		shards,
+		strconv.FormatInt(collection.DocumentCount, 10),
+		strconv.FormatInt(collection.VectorBytes, 10),
+		collection.CreatedAt.Format("2006-01-02 15:04"),
+	}
+}
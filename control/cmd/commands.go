@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/goccy/go-json"
+	"github.com/spf13/cobra"
+
+	"github.com/christian-nickerson/pangolin/control/internal/client"
+	"github.com/christian-nickerson/pangolin/control/internal/configs"
+)
+
+// settings file read by every command
+const settingsFile = "settings.toml"
+
+// flags shared by commands calling the API
+type globals struct {
+	url    string
+	key    string
+	output string
+}
+
+// Build the pangolin command tree, serving the control plane when run
+// without a subcommand
+func newRootCommand() *cobra.Command {
+	var flags globals
+	root := &cobra.Command{
+		Use:           "pangolin",
+		Short:         "Pangolin vector search control plane",
+		Args:          cobra.NoArgs,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if flags.output != "table" && flags.output != "json" {
+				return fmt.Errorf("output must be table or json, got %q", flags.output)
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			serve(cmd.Context())
+			return nil
+		},
+	}
+	root.PersistentFlags().StringVar(&flags.url, "url", "", "API address, from settings.toml by default")
+	root.PersistentFlags().StringVar(&flags.key, "key", "", "API key, PANGOLIN_API_KEY or the admin key by default")
+	root.PersistentFlags().StringVarP(&flags.output, "output", "o", "table", "output format, table or json")
+
+	root.AddCommand(
+		newServeCommand(),
+		newConfigCommand(&flags),
+		newMigrateCommand(),
+		newCollectionsCommand(&flags),
+		newIngestCommand(&flags),
+		newSearchCommand(&flags),
+		newSnapshotCommand(&flags),
+		newKeysCommand(&flags),
+	)
+	return root
+}
+
+func newServeCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "serve",
+		Short: "Serve the control plane",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			serve(cmd.Context())
+			return nil
+		},
+	}
+}
+
+// create an API client from the flags, defaulting to the settings file
+func (g *globals) client() (*client.Client, error) {
+	address, key := g.url, g.key
+	if address == "" || key == "" {
+		settings, err := configs.Load(settingsFile)
+		if err != nil {
+			return nil, err
+		}
+		if address == "" {
+			address = client.Address(settings.Server.API)
+		}
+		if key == "" {
+			key = client.Key(settings.Auth)
+		}
+	}
+	return client.New(address, key), nil
+}
+
+// write value as indented JSON, or as a table of rows under header
+func (g *globals) print(w io.Writer, value any, header []string, rows [][]string) error {
+	if g.output == "json" {
+		data, err := json.MarshalIndent(value, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(data))
+		return err
+	}
+
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(table, strings.Join(row, "\t"))
+	}
+	return table.Flush()
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/christian-nickerson/pangolin/control/internal/configs"
+	"github.com/christian-nickerson/pangolin/control/internal/database"
+)
+
+// settings holding credentials, redacted when printed
+var secrets = []string{"admin_key", "api_key", "token", "password", "secret_key", "access_key"}
+
+func newConfigCommand(flags *globals) *cobra.Command {
+	config := &cobra.Command{
+		Use:   "config",
+		Short: "Check the settings file",
+	}
+
+	config.AddCommand(&cobra.Command{
+		Use:   "validate",
+		Short: "Validate settings.toml and environment overrides",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			settings, err := configs.Load(settingsFile)
+			if err != nil {
+				return err
+			}
+			if err := configs.Validate(settings); err != nil {
+				return fmt.Errorf("invalid settings\n%w", err)
+			}
+			fmt.Println("settings are valid")
+			return nil
+		},
+	})
+
+	var reveal bool
+	print := &cobra.Command{
+		Use:   "print",
+		Short: "Print the effective settings, with environment overrides applied",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if _, err := configs.Load(settingsFile); err != nil {
+				return err
+			}
+
+			keys := viper.AllKeys()
+			slices.Sort(keys)
+			values := map[string]any{}
+			rows := make([][]string, len(keys))
+			for i, key := range keys {
+				value := viper.Get(key)
+				if !reveal && slices.Contains(secrets, key[strings.LastIndex(key, ".")+1:]) && value != "" {
+					value = "********"
+				}
+				values[key] = value
+				rows[i] = []string{key, fmt.Sprint(value)}
+			}
+			return flags.print(os.Stdout, values, []string{"SETTING", "VALUE"}, rows)
+		},
+	}
+	print.Flags().BoolVar(&reveal, "reveal", false, "print credentials instead of redacting them")
+	config.AddCommand(print)
+
+	return config
+}
+
+func newMigrateCommand() *cobra.Command {
+	migrate := &cobra.Command{
+		Use:   "migrate",
+		Short: "Manage the metadata database schema",
+	}
+
+	migrate.AddCommand(&cobra.Command{
+		Use:   "up",
+		Short: "Create or update the metadata tables",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withDatabase(func() error {
+				if err := database.Migrate(); err != nil {
+					return err
+				}
+				fmt.Println("metadata tables migrated")
+				return nil
+			})
+		},
+	})
+
+	var confirm bool
+	down := &cobra.Command{
+		Use:   "down",
+		Short: "Drop the metadata tables, deleting all metadata",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !confirm {
+				return fmt.Errorf("dropping the metadata tables deletes all collections, documents and keys, pass --yes to confirm")
+			}
+			return withDatabase(func() error {
+				if err := database.Rollback(); err != nil {
+					return err
+				}
+				fmt.Println("metadata tables dropped")
+				return nil
+			})
+		},
+	}
+	down.Flags().BoolVar(&confirm, "yes", false, "confirm dropping the metadata tables")
+	migrate.AddCommand(down)
+
+	return migrate
+}
+
+// connect to the configured metadata database for the duration of run
+func withDatabase(run func() error) error {
+	settings, err := configs.Load(settingsFile)
+	if err != nil {
+		return err
+	}
+	if err := database.Connect(settings.Metadata.Database); err != nil {
+		return err
+	}
+	defer database.Close()
+
+	return run()
+}
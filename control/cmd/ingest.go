@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/spf13/cobra"
+
+	"github.com/christian-nickerson/pangolin/control/internal/client"
+)
+
+// upload is a file ingested as a document, with its job or failure
+type upload struct {
+	Path     string `json:"path"`
+	Document string `json:"document"`
+	Job      string `json:"job,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+func newIngestCommand(flags *globals) *cobra.Command {
+	var pattern string
+	var parallel int
+	command := &cobra.Command{
+		Use:   "ingest <collection> <dir-or-file>",
+		Short: "Upload files as documents, extracting their text on the server",
+		Long: `Upload a file, or the files below a directory matching --glob, as
+documents of a collection. Files below a directory are identified by their
+path relative to it, so ingesting the directory again updates them.`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if parallel < 1 {
+				return fmt.Errorf("parallel must be at least 1")
+			}
+			if _, err := filepath.Match(pattern, ""); err != nil {
+				return fmt.Errorf("invalid glob %q, %w", pattern, err)
+			}
+			api, err := flags.client()
+			if err != nil {
+				return err
+			}
+
+			files, err := findFiles(args[1], pattern)
+			if err != nil {
+				return err
+			}
+			if len(files) == 0 {
+				return fmt.Errorf("no files in %v match %q", args[1], pattern)
+			}
+
+			uploads := ingestFiles(cmd.Context(), api, args[0], files, parallel)
+			rows := make([][]string, len(uploads))
+			failed := 0
+			for i, upload := range uploads {
+				status := upload.Job
+				if upload.Error != "" {
+					status, failed = "failed: "+upload.Error, failed+1
+				}
+				rows[i] = []string{upload.Path, upload.Document, status}
+			}
+			if err := flags.print(os.Stdout, uploads, []string{"FILE", "DOCUMENT", "JOB"}, rows); err != nil {
+				return err
+			}
+			if failed > 0 {
+				return fmt.Errorf("%v of %v files failed to upload", failed, len(uploads))
+			}
+			return nil
+		},
+	}
+	command.Flags().StringVar(&pattern, "glob", "*", "file name pattern, or relative path pattern when it contains a /")
+	command.Flags().IntVarP(&parallel, "parallel", "p", 4, "files uploaded at once")
+	return command
+}
+
+// find the files to ingest at root, identified by their path relative
+// to root, or by name when root is a file
+func findFiles(root, pattern string) ([]upload, error) {
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []upload{{Path: root, Document: filepath.Base(root)}}, nil
+	}
+
+	var files []upload
+	err = filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		relative, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		relative = filepath.ToSlash(relative)
+
+		name := entry.Name()
+		if strings.Contains(pattern, "/") {
+			name = relative
+		}
+		if matched, _ := filepath.Match(pattern, name); matched {
+			files = append(files, upload{Path: path, Document: relative})
+		}
+		return nil
+	})
+	return files, err
+}
+
+// upload files with parallel workers, keeping the order of files
+func ingestFiles(ctx context.Context, api *client.Client, collection string, files []upload, parallel int) []upload {
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for range min(parallel, len(files)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				job, err := uploadFile(ctx, api, collection, files[i])
+				if err != nil {
+					files[i].Error = err.Error()
+				} else {
+					files[i].Job = job
+				}
+			}
+		}()
+	}
+
+	for i := range files {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+	return files
+}
+
+// upload a file, returning the id of the job ingesting it
+func uploadFile(ctx context.Context, api *client.Client, collection string, file upload) (string, error) {
+	reader, err := os.Open(file.Path)
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+
+	job, err := api.UploadDocument(ctx, collection, file.Document, filepath.Base(file.Path), reader)
+	if err != nil {
+		return "", err
+	}
+	return job.ID, nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/christian-nickerson/pangolin/control/internal/models"
+)
+
+var keyHeader = []string{"ID", "NAME", "PREFIX", "SCOPE", "COLLECTIONS"}
+
+func newKeysCommand(flags *globals) *cobra.Command {
+	command := &cobra.Command{
+		Use:     "keys",
+		Aliases: []string{"key"},
+		Short:   "Manage API keys, requires an admin key",
+	}
+
+	var scope string
+	var allowed []string
+	create := &cobra.Command{
+		Use:   "create <name>",
+		Short: "Create an API key, printing its secret once",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			api, err := flags.client()
+			if err != nil {
+				return err
+			}
+			key, secret, err := api.CreateKey(cmd.Context(), args[0], models.Scope(scope), allowed)
+			if err != nil {
+				return err
+			}
+
+			issued := struct {
+				*models.APIKey
+				Key string `json:"key"`
+			}{key, secret}
+			header := append(slices.Clone(keyHeader), "KEY")
+			return flags.print(os.Stdout, issued, header, [][]string{append(keyRow(key), secret)})
+		},
+	}
+	create.Flags().StringVar(&scope, "scope", string(models.ScopeRead), "access granted, read, write or admin")
+	create.Flags().StringSliceVar(&allowed, "collections", nil, "collections the key may access, all when empty")
+
+	revoke := &cobra.Command{
+		Use:   "revoke <id>",
+		Short: "Revoke an API key",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := strconv.ParseUint(args[0], 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid key id %q", args[0])
+			}
+			api, err := flags.client()
+			if err != nil {
+				return err
+			}
+			key, err := api.RevokeKey(cmd.Context(), uint(id))
+			if err != nil {
+				return err
+			}
+			return flags.print(os.Stdout, key, keyHeader, [][]string{keyRow(key)})
+		},
+	}
+
+	command.AddCommand(create, revoke)
+	return command
+}
+
+func keyRow(key *models.APIKey) []string {
+	collections := "all"
+	if len(key.Collections) > 0 {
+		collections = strings.Join(key.Collections, ",")
+	}
+	return []string{strconv.FormatUint(uint64(key.ID), 10), key.Name, key.Prefix, string(key.Scope), collections}
+}
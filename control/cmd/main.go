@@ -2,24 +2,88 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
+	"net"
 	"os"
 	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
 
 	"github.com/charmbracelet/log"
 	"github.com/goccy/go-json"
 	"github.com/gofiber/fiber/v2"
-	"github.com/gofiber/fiber/v2/middleware/healthcheck"
-	"github.com/gofiber/fiber/v2/middleware/logger"
 	"github.com/gofiber/fiber/v2/middleware/requestid"
+	"google.golang.org/grpc"
 
+	"github.com/christian-nickerson/pangolin/control/internal/auth"
+	"github.com/christian-nickerson/pangolin/control/internal/certs"
+	"github.com/christian-nickerson/pangolin/control/internal/collections"
 	"github.com/christian-nickerson/pangolin/control/internal/configs"
+	"github.com/christian-nickerson/pangolin/control/internal/consensus"
+	"github.com/christian-nickerson/pangolin/control/internal/database"
+	"github.com/christian-nickerson/pangolin/control/internal/documents"
+	embeddings "github.com/christian-nickerson/pangolin/control/internal/embedding"
+	"github.com/christian-nickerson/pangolin/control/internal/h2"
+	"github.com/christian-nickerson/pangolin/control/internal/index"
+	"github.com/christian-nickerson/pangolin/control/internal/indexnode"
+	"github.com/christian-nickerson/pangolin/control/internal/jobs"
+	"github.com/christian-nickerson/pangolin/control/internal/lifecycle"
 	"github.com/christian-nickerson/pangolin/control/internal/logging"
+	"github.com/christian-nickerson/pangolin/control/internal/mcp"
+	"github.com/christian-nickerson/pangolin/control/internal/metrics"
+	"github.com/christian-nickerson/pangolin/control/internal/models"
+	"github.com/christian-nickerson/pangolin/control/internal/namespaces"
+	"github.com/christian-nickerson/pangolin/control/internal/nodes"
+	"github.com/christian-nickerson/pangolin/control/internal/proto"
+	"github.com/christian-nickerson/pangolin/control/internal/ratelimit"
+	collectionroutes "github.com/christian-nickerson/pangolin/control/internal/routes/collections"
+	consensusroutes "github.com/christian-nickerson/pangolin/control/internal/routes/consensus"
+	documentroutes "github.com/christian-nickerson/pangolin/control/internal/routes/documents"
 	"github.com/christian-nickerson/pangolin/control/internal/routes/health"
+	jobroutes "github.com/christian-nickerson/pangolin/control/internal/routes/jobs"
+	"github.com/christian-nickerson/pangolin/control/internal/routes/keys"
+	mcproutes "github.com/christian-nickerson/pangolin/control/internal/routes/mcp"
+	namespaceroutes "github.com/christian-nickerson/pangolin/control/internal/routes/namespaces"
+	noderoutes "github.com/christian-nickerson/pangolin/control/internal/routes/nodes"
+	rebalanceroutes "github.com/christian-nickerson/pangolin/control/internal/routes/rebalance"
+	searchroutes "github.com/christian-nickerson/pangolin/control/internal/routes/search"
+	snapshotroutes "github.com/christian-nickerson/pangolin/control/internal/routes/snapshots"
+	"github.com/christian-nickerson/pangolin/control/internal/search"
+	"github.com/christian-nickerson/pangolin/control/internal/shards"
+	"github.com/christian-nickerson/pangolin/control/internal/storage"
+	"github.com/christian-nickerson/pangolin/control/internal/tracing"
 )
 
+// Open the API listener, serving TLS when configured. TLS clients may
+// negotiate HTTP/2 or HTTP/1.1.
+func listen(config configs.APIConfig) (net.Listener, error) {
+	address := net.JoinHostPort(config.Host, strconv.Itoa(config.Port))
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	if !config.TLS.Enabled {
+		return listener, nil
+	}
+
+	reloader, err := certs.NewReloader(config.TLS.CertFile, config.TLS.KeyFile, config.TLS.CAFile)
+	if err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	tlsConfig := reloader.ServerConfig()
+	tlsConfig.NextProtos = h2.Protocols
+	return tls.NewListener(listener, tlsConfig), nil
+}
+
 // Build & run control plane
-func startService(settings *configs.Settings) *fiber.App {
+func startService(settings *configs.Settings, listener net.Listener, readiness *health.Readiness, queue *jobs.Queue, store storage.BlobStore, registry *nodes.Registry, rebalancer *shards.Rebalancer, cluster *consensus.Cluster, agents *mcp.Server) *fiber.App {
+	api := settings.Server.API
 
 	// configure fiber app
 	app := fiber.New(fiber.Config{
@@ -27,17 +91,55 @@ func startService(settings *configs.Settings) *fiber.App {
 		JSONEncoder:           json.Marshal,
 		JSONDecoder:           json.Unmarshal,
 		DisableStartupMessage: true,
+		ErrorHandler:          models.ErrorHandler,
+		BodyLimit:             api.BodyLimit,
+		StreamRequestBody:     true,
+		ReadTimeout:           time.Duration(api.ReadTimeout) * time.Second,
+		WriteTimeout:          time.Duration(api.WriteTimeout) * time.Second,
+		IdleTimeout:           time.Duration(api.IdleTimeout) * time.Second,
 	})
 
-	// middleware and routes
+	// middleware and routes, bodies are limited by LimitBody as they are
+	// streamed past the server's limit
 	app.Use(requestid.New())
-	app.Use(logger.New(logging.LoggingConfig))
-	app.Use(healthcheck.New(health.HealthCheckConfig))
+	app.Use(models.LimitBody(api.BodyLimit, snapshotroutes.UploadPath))
+	app.Use(tracing.Middleware)
+	app.Use(logging.Middleware)
+	app.Use(metrics.Middleware)
+	app.Get("/metrics", metrics.Handler)
+	health.Register(app, readiness)
+	// followers serve reads of replicated metadata, documents, jobs,
+	// snapshots and rebalances are served by the leader
+	app.Use(consensus.Middleware(
+		"/collections", "/collections/:collection",
+		"/admin/namespaces", "/admin/namespaces/:namespace",
+		"/admin/keys", "/admin/cluster", "/admin/rebalance/plan",
+		"/nodes", "/nodes/:node",
+	))
+
+	// routes below require an api key, failed authentications are limited
+	// per client IP before keys are checked
+	limits := ratelimit.NewMemoryStore()
+	app.Use(ratelimit.Failures(settings.RateLimit, limits))
+	app.Use(auth.New(settings.Auth))
+	app.Use(ratelimit.New(settings.RateLimit, limits))
+	keys.Register(app)
+	namespaceroutes.Register(app)
+	collectionroutes.Register(app, store)
+	documentroutes.Register(app, queue, store)
+	jobroutes.Register(app, queue)
+	searchroutes.Register(app, embeddings.Inference)
+	snapshotroutes.Register(app, store, settings.Snapshots)
+	noderoutes.Register(app, registry)
+	rebalanceroutes.Register(app, rebalancer)
+	consensusroutes.Register(app, cluster)
+	if settings.MCP.Enabled {
+		mcproutes.Register(app, agents, settings.MCP.AllowedOrigins)
+	}
 
 	// start serving in new goroutine
 	go func() {
-		address := fmt.Sprintf("127.0.0.1:%v", settings.Server.API.Port)
-		if err := app.Listen(address); err != nil {
+		if err := h2.Serve(app, listener, api); err != nil {
 			log.Fatal(err.Error())
 		}
 	}()
@@ -45,29 +147,289 @@ func startService(settings *configs.Settings) *fiber.App {
 	return app
 }
 
-// Handle IO to service & run
+// Build readiness checks for dependencies the control plane cannot serve without
+func newReadiness(settings *configs.Settings) *health.Readiness {
+	readiness := health.NewReadiness(
+		time.Duration(settings.Health.Timeout)*time.Second,
+		time.Duration(settings.Health.CachePeriod)*time.Second,
+	)
+	readiness.Register("database", database.Ping)
+	readiness.Register("embeddings", embeddings.Health)
+	readiness.Register("index", index.Ready)
+	return readiness
+}
+
+// Handle interruptions & run the command line
 func main() {
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
 
-	// handle interruptions
-	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	if err := newRootCommand().ExecuteContext(ctx); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		cancel()
+		os.Exit(1)
+	}
+}
+
+// Build the control plane, serving until ctx is done then shutting down
+func serve(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
 	// Load dependent objects
-	settings, err := configs.Load("settings.toml")
+	settings, err := configs.Load(settingsFile)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	if err := configs.Validate(settings); err != nil {
+		log.Fatal("invalid settings", "err", err)
+	}
+
+	if err := logging.Setup(settings.Logging); err != nil {
+		log.Fatal(err.Error())
+	}
+
+	shutdownTracing, err := tracing.Setup(ctx, settings.Tracing, settings.Server.API.Name)
 	if err != nil {
 		log.Fatal(err.Error())
 	}
 
+	if err := database.Connect(settings.Metadata.Database); err != nil {
+		log.Fatal(err.Error())
+	}
+
+	if err := database.Migrate(); err != nil {
+		log.Fatal(err.Error())
+	}
+
+	if _, err := namespaces.EnsureDefault(ctx, settings.Namespaces.DefaultQuotas); err != nil {
+		log.Fatal(err.Error())
+	}
+
+	// highly available instances replicate cluster metadata through Raft,
+	// only the leader accepts writes
+	var cluster *consensus.Cluster
+	if settings.HA.Enabled {
+		cluster, err = consensus.Open(settings.HA)
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+		consensus.Setup(cluster)
+		log.Info("Joined control plane cluster", "id", settings.HA.ID, "peers", len(settings.HA.Peers))
+	}
+
+	if err := embeddings.Connect(settings.Server.Embeddings); err != nil {
+		log.Fatal(err.Error())
+	}
+
+	store, err := storage.New(settings.Storage)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+
+	queue := jobs.NewQueue(settings.Jobs, embeddings.Inference, store)
+
+	// index nodes join through the registry when clustering is enabled, and
+	// the shards of lost nodes are replicated again on the remaining nodes
+	registry := nodes.NewRegistry(settings.Cluster)
+	rebalancer := shards.NewRebalancer(settings.Cluster.Rebalance)
+	stopCluster := func(context.Context) error { return nil }
+	if settings.Cluster.Enabled {
+		shards.Setup(settings.Cluster, func(node *models.Node) (shards.Node, error) {
+			return indexnode.Dial(node.Address, settings.Cluster)
+		})
+		registry.OnLost(func(models.Node) { shards.Trigger() })
+
+		registryServer, registryListener, err := nodes.Serve(registry, settings.Cluster)
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+		log.Info("Serving node registry", "address", registryListener.Addr().String(), "tls", settings.Cluster.TLS.Enabled)
+
+		stopNode := func(context.Context) error { return nil }
+		if settings.Cluster.Node.Enabled {
+			if stopNode, err = serveNode(settings.Cluster); err != nil {
+				log.Fatal(err.Error())
+			}
+		}
+		stopCluster = func(ctx context.Context) error {
+			nodeErr := stopNode(ctx)
+			registryServer.GracefulStop()
+			return nodeErr
+		}
+	}
+
+	// indexing, ingestion, usage replication, membership sweeps, repairs
+	// and rebalances run on the leader alone. Indexes recover in the
+	// background on taking the lead, readiness fails until they are loaded.
+	lead := func(ctx context.Context) error {
+		all, err := collections.All(ctx)
+		if err != nil {
+			return err
+		}
+		index.Open(ctx, store, settings.Index, all)
+		if err := queue.Start(ctx); err != nil {
+			return err
+		}
+		documents.StartUsage()
+		if !settings.Cluster.Enabled {
+			return nil
+		}
+		return errors.Join(shards.Start(ctx), registry.Start(ctx))
+	}
+	stepDown := func(ctx context.Context) error {
+		err := errors.Join(queue.Stop(ctx), documents.StopUsage(ctx))
+		if settings.Cluster.Enabled {
+			err = errors.Join(err, rebalancer.Stop(ctx), registry.Stop(ctx), shards.Stop(ctx))
+		}
+		return errors.Join(err, index.Close(ctx))
+	}
+	if cluster == nil {
+		if err := lead(ctx); err != nil {
+			log.Fatal(err.Error())
+		}
+	} else {
+		cluster.OnLeadership(func(leading bool) {
+			var err error
+			if leading {
+				err = lead(ctx)
+			} else {
+				err = stepDown(ctx)
+			}
+			if err != nil {
+				log.Error("unable to change cluster leadership", "leading", leading, "err", err)
+			}
+		})
+	}
+
+	// a sample of searches is checked against a brute force search
+	search.StartRecall(settings.Search)
+
 	// start service and wait for signal
-	app := startService(&settings)
-	log.Infof("Started serving on http://127.0.0.1:%v\n", settings.Server.API.Port)
+	listener, err := listen(settings.Server.API)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+
+	readiness := newReadiness(&settings)
+	agents := mcp.NewServer(embeddings.Inference, queue)
+	app := startService(&settings, listener, readiness, queue, store, registry, rebalancer, cluster, agents)
+	log.Info("Started serving", "address", listener.Addr().String(), "tls", settings.Server.API.TLS.Enabled)
+
+	// agents launching pangolin talk MCP over stdio, it shuts down when
+	// they close stdin
+	if settings.MCP.Stdio {
+		token := settings.MCP.APIKey
+		if token == "" {
+			token = settings.Auth.AdminKey
+		}
+		key, namespace, err := auth.Resolve(ctx, settings.Auth, token, settings.MCP.Namespace)
+		if err != nil {
+			log.Fatal("unable to authenticate mcp stdio key", "err", err)
+		}
+		go func() {
+			if err := agents.ServeStdio(ctx, mcp.Session{Key: key, Namespace: namespace}, os.Stdin, os.Stdout); err != nil {
+				log.Error("mcp stdio transport failed", "err", err)
+			}
+			cancel()
+		}()
+		log.Info("Serving MCP on stdio", "namespace", namespace.Name)
+	}
+
+	// close in dependency order, producers before the connections they use
+	var teardown lifecycle.Teardown
+	teardown.Add("http server", app.ShutdownWithContext)
+	teardown.Add("recall sampling", search.StopRecall)
+	teardown.Add("leader workers", stepDown)
+	teardown.Add("node registry", stopCluster)
+	if cluster != nil {
+		teardown.Add("consensus", cluster.Stop)
+	}
+	teardown.Add("embedding connection", func(context.Context) error { return embeddings.Close() })
+	teardown.Add("database pool", func(context.Context) error { return database.Close() })
+	teardown.Add("tracing", shutdownTracing)
+
 	<-ctx.Done()
+	cancel()
+	shutdown(settings.Shutdown, readiness, &teardown)
+}
 
-	log.Info("Starting shutting down...")
+// serve an index node in process, registered with the local registry
+// until the returned stop function deregisters it
+func serveNode(config configs.Cluster) (func(context.Context) error, error) {
+	options, err := nodes.ServerOptions(config)
+	if err != nil {
+		return nil, err
+	}
+	address := net.JoinHostPort(config.Node.Host, strconv.Itoa(config.Node.Port))
+	host := indexnode.NewHost()
+	server, listener, err := indexnode.Serve(host, address, options...)
+	if err != nil {
+		return nil, err
+	}
 
-	// shutdown and close connections
-	if err := app.Shutdown(); err != nil {
-		log.Fatal(err.Error())
+	dialOptions, err := indexnode.DialOptions(config)
+	if err != nil {
+		server.Stop()
+		return nil, err
+	}
+	conn, err := grpc.NewClient(net.JoinHostPort(config.Host, strconv.Itoa(config.Port)), dialOptions...)
+	if err != nil {
+		server.Stop()
+		return nil, err
+	}
+
+	node := models.Node{ID: config.Node.ID, Address: listener.Addr().String(), MaxShards: config.Node.MaxShards}
+	agent := nodes.NewAgent(proto.NewRegistryClient(conn), node, host.Shards)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		agent.Run(ctx)
+		close(done)
+	}()
+	log.Info("Serving index node", "node_id", node.ID, "address", node.Address)
+
+	return func(ctx context.Context) error {
+		cancel()
+		select {
+		case <-done:
+		case <-ctx.Done():
+		}
+		server.GracefulStop()
+		return conn.Close()
+	}, nil
+}
+
+// Drain traffic then tear down, forcing exit on timeout or a second signal
+func shutdown(config configs.Shutdown, readiness *health.Readiness, teardown *lifecycle.Teardown) {
+	timeout := time.Duration(config.Timeout) * time.Second
+	force := time.AfterFunc(timeout, func() {
+		log.Error("Shutdown timed out, forcing exit", "timeout", timeout)
+		os.Exit(1)
+	})
+	defer force.Stop()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		log.Error("Received second signal, forcing exit")
+		os.Exit(1)
+	}()
+
+	// draining never takes more than half the timeout, leaving teardown
+	// time to flush indexes and close connections
+	drain := min(time.Duration(config.DrainPeriod)*time.Second, timeout/2)
+	log.Info("Starting shutting down, draining traffic...", "drain_period", drain)
+	readiness.Drain()
+	time.Sleep(drain)
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout-drain)
+	defer cancel()
+
+	if err := teardown.Run(ctx); err != nil {
+		log.Error("Pangolin shutdown with errors", "err", err)
+		os.Exit(1)
 	}
 
 	log.Info("Pangolin successfully shutdown.")
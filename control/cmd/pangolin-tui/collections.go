@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/charmbracelet/bubbles/table"
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/christian-nickerson/pangolin/control/internal/client"
+	"github.com/christian-nickerson/pangolin/control/internal/models"
+)
+
+type collectionsMsg struct {
+	collections []models.Collection
+	err         error
+}
+
+// names of the listed collections by id
+func (m collectionsMsg) names() map[uint]string {
+	names := make(map[uint]string, len(m.collections))
+	for _, collection := range m.collections {
+		names[collection.ID] = collection.Name
+	}
+	return names
+}
+
+// collectionsView lists the collections the key may read with their usage
+type collectionsView struct {
+	api   *client.Client
+	table table.Model
+	err   error
+}
+
+func newCollectionsView(api *client.Client) collectionsView {
+	return collectionsView{
+		api: api,
+		table: newTable([]table.Column{
+			{Title: "Name", Width: 24},
+			{Title: "Documents", Width: 10},
+			{Title: "Vectors", Width: 10},
+			{Title: "Model", Width: 24},
+			{Title: "Metric", Width: 10},
+			{Title: "Chunking", Width: 10},
+			{Title: "Shards", Width: 8},
+			{Title: "Created", Width: 12},
+		}),
+	}
+}
+
+func (v *collectionsView) load() tea.Cmd {
+	api := v.api
+	return fetch(func(ctx context.Context) tea.Msg {
+		all, err := api.ListCollections(ctx)
+		return collectionsMsg{collections: all, err: err}
+	})
+}
+
+func (v *collectionsView) resize(width, height int) {
+	v.table.SetWidth(width)
+	v.table.SetHeight(height - 1)
+}
+
+func (v collectionsView) update(msg tea.Msg) (collectionsView, tea.Cmd) {
+	switch msg := msg.(type) {
+	case collectionsMsg:
+		v.err = msg.err
+		rows := make([]table.Row, len(msg.collections))
+		for i, collection := range msg.collections {
+			shards := "-"
+			if collection.Sharded() {
+				shards = fmt.Sprintf("%v×%v", collection.Shards, collection.ReplicationFactor())
+			}
+			rows[i] = table.Row{
+				collection.Name,
+				strconv.FormatInt(collection.DocumentCount, 10),
+				formatBytes(collection.VectorBytes),
+				collection.Model,
+				string(collection.Metric),
+				fmt.Sprintf("%v/%v", collection.ChunkSize, collection.ChunkOverlap),
+				shards,
+				formatAge(collection.CreatedAt),
+			}
+		}
+		setRows(&v.table, rows)
+		return v, nil
+
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "r":
+			return v, v.load()
+		case "enter":
+			if row := v.table.SelectedRow(); row != nil {
+				return v, func() tea.Msg { return selectMsg{collection: row[0]} }
+			}
+			return v, nil
+		}
+	}
+
+	var cmd tea.Cmd
+	v.table, cmd = v.table.Update(msg)
+	return v, cmd
+}
+
+func (v collectionsView) view() string {
+	if v.err != nil {
+		return describe(v.err)
+	}
+	if len(v.table.Rows()) == 0 {
+		return helpStyle.Render("no collections, create one through the API")
+	}
+	return v.table.View()
+}
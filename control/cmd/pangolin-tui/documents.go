@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/table"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/christian-nickerson/pangolin/control/internal/client"
+	"github.com/christian-nickerson/pangolin/control/internal/models"
+)
+
+// documents listed per page
+const pageSize = 50
+
+type documentsMsg struct {
+	collection string
+	offset     int
+	documents  []models.Document
+	err        error
+}
+
+type chunksMsg struct {
+	collection string
+	document   models.Document
+	chunks     []models.Chunk
+	err        error
+}
+
+// documentsView pages through a collection's documents, showing the
+// metadata and chunks of the chosen document below
+type documentsView struct {
+	api        *client.Client
+	collection string
+	offset     int
+	documents  []models.Document
+	table      table.Model
+	detail     viewport.Model
+	width      int
+	err        error
+}
+
+func newDocumentsView(api *client.Client) documentsView {
+	return documentsView{
+		api: api,
+		table: newTable([]table.Column{
+			{Title: "ID", Width: 36},
+			{Title: "Version", Width: 8},
+			{Title: "Chunks", Width: 8},
+			{Title: "Vectors", Width: 10},
+			{Title: "Updated", Width: 12},
+		}),
+		detail: viewport.New(0, 0),
+	}
+}
+
+func (v *documentsView) load() tea.Cmd {
+	if v.collection == "" {
+		return nil
+	}
+	api, collection, offset := v.api, v.collection, v.offset
+	return fetch(func(ctx context.Context) tea.Msg {
+		all, err := api.ListDocuments(ctx, collection, pageSize, offset)
+		return documentsMsg{collection: collection, offset: offset, documents: all, err: err}
+	})
+}
+
+func (v *documentsView) loadChunks(document models.Document) tea.Cmd {
+	api, collection := v.api, v.collection
+	return fetch(func(ctx context.Context) tea.Msg {
+		chunks, err := api.DocumentChunks(ctx, collection, document.ExternalID)
+		return chunksMsg{collection: collection, document: document, chunks: chunks, err: err}
+	})
+}
+
+// split the height between the document list and the detail
+func (v *documentsView) resize(width, height int) {
+	v.width = width
+	list := max(height/2, 3)
+	v.table.SetWidth(width)
+	v.table.SetHeight(list - 1)
+	v.detail.Width, v.detail.Height = width, max(height-list-1, 1)
+}
+
+func (v documentsView) update(msg tea.Msg) (documentsView, tea.Cmd) {
+	switch msg := msg.(type) {
+	case selectMsg:
+		if msg.collection != v.collection {
+			v.collection, v.offset, v.documents = msg.collection, 0, nil
+			v.table.SetCursor(0)
+			v.detail.SetContent("")
+		}
+		return v, nil
+
+	case documentsMsg:
+		if msg.collection != v.collection || msg.offset != v.offset {
+			return v, nil
+		}
+		v.err, v.documents = msg.err, msg.documents
+		rows := make([]table.Row, len(msg.documents))
+		for i, document := range msg.documents {
+			rows[i] = table.Row{
+				document.ExternalID,
+				strconv.Itoa(document.Version),
+				strconv.Itoa(document.ChunkCount),
+				formatBytes(document.VectorBytes),
+				formatAge(document.UpdatedAt),
+			}
+		}
+		setRows(&v.table, rows)
+		return v, nil
+
+	case chunksMsg:
+		if msg.collection != v.collection {
+			return v, nil
+		}
+		if msg.err != nil {
+			v.detail.SetContent(describe(msg.err))
+		} else {
+			v.detail.SetContent(renderDocument(msg.document, msg.chunks, v.width))
+		}
+		v.detail.GotoTop()
+		return v, nil
+
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "r":
+			return v, v.load()
+		case "n":
+			if len(v.documents) == pageSize {
+				v.offset += pageSize
+				v.table.SetCursor(0)
+				return v, v.load()
+			}
+			return v, nil
+		case "p":
+			if v.offset > 0 {
+				v.offset = max(v.offset-pageSize, 0)
+				v.table.SetCursor(0)
+				return v, v.load()
+			}
+			return v, nil
+		case "enter":
+			if cursor := v.table.Cursor(); cursor < len(v.documents) {
+				return v, v.loadChunks(v.documents[cursor])
+			}
+			return v, nil
+		case "pgup", "pgdown":
+			var cmd tea.Cmd
+			v.detail, cmd = v.detail.Update(msg)
+			return v, cmd
+		}
+	}
+
+	var cmd tea.Cmd
+	v.table, cmd = v.table.Update(msg)
+	return v, cmd
+}
+
+func (v documentsView) view() string {
+	if v.collection == "" {
+		return helpStyle.Render("choose a collection on the collections tab")
+	}
+	if v.err != nil {
+		return describe(v.err)
+	}
+
+	title := labelStyle.Render(v.collection) + helpStyle.Render(fmt.Sprintf("  documents %v to %v", v.offset+1, v.offset+len(v.documents)))
+	if len(v.documents) == 0 {
+		return lipgloss.JoinVertical(lipgloss.Left, title, helpStyle.Render("no documents"))
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, title, v.table.View(), v.detail.View())
+}
+
+// render a document's metadata followed by its chunks
+func renderDocument(document models.Document, chunks []models.Chunk, width int) string {
+	var b strings.Builder
+	b.WriteString(labelStyle.Render(document.ExternalID))
+	fmt.Fprintf(&b, "  version %v, hash %.12s\n", document.Version, document.ContentHash)
+	keys := make([]string, 0, len(document.Metadata))
+	for key := range document.Metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(&b, "%v: %v\n", labelStyle.Render(key), document.Metadata[key])
+	}
+
+	text := lipgloss.NewStyle().Width(max(width-2, 10)).PaddingLeft(2)
+	for _, chunk := range chunks {
+		b.WriteString("\n" + helpStyle.Render(fmt.Sprintf("chunk %v", chunk.Position)) + "\n")
+		b.WriteString(text.Render(chunk.Text) + "\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/charmbracelet/bubbles/table"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/christian-nickerson/pangolin/control/internal/client"
+	"github.com/christian-nickerson/pangolin/control/internal/models"
+)
+
+// recent jobs listed
+const jobsLimit = 100
+
+type jobsMsg struct {
+	jobs []models.Job
+	err  error
+}
+
+// jobsView lists recent ingestion jobs and their progress, refreshed
+// while shown
+type jobsView struct {
+	api   *client.Client
+	jobs  []models.Job
+	names map[uint]string
+	table table.Model
+	err   error
+}
+
+func newJobsView(api *client.Client) jobsView {
+	return jobsView{
+		api: api,
+		table: newTable([]table.Column{
+			{Title: "Job", Width: 10},
+			{Title: "Collection", Width: 20},
+			{Title: "Status", Width: 10},
+			{Title: "Progress", Width: 10},
+			{Title: "Attempts", Width: 8},
+			{Title: "Created", Width: 10},
+			{Title: "Error", Width: 40},
+		}),
+	}
+}
+
+func (v *jobsView) load() tea.Cmd {
+	api := v.api
+	return fetch(func(ctx context.Context) tea.Msg {
+		jobs, err := api.ListJobs(ctx, jobsLimit)
+		return jobsMsg{jobs: jobs, err: err}
+	})
+}
+
+// leave room for the summary above the table
+func (v *jobsView) resize(width, height int) {
+	v.table.SetWidth(width)
+	v.table.SetHeight(max(height-3, 3))
+}
+
+func (v jobsView) update(msg tea.Msg) (jobsView, tea.Cmd) {
+	switch msg := msg.(type) {
+	case jobsMsg:
+		v.err = msg.err
+		if msg.err != nil {
+			return v, nil
+		}
+		v.jobs = msg.jobs
+		rows := make([]table.Row, len(msg.jobs))
+		for i, job := range msg.jobs {
+			collection, ok := v.names[job.CollectionID]
+			if !ok {
+				collection = "#" + strconv.FormatUint(uint64(job.CollectionID), 10)
+			}
+			rows[i] = table.Row{
+				job.ID[:min(8, len(job.ID))],
+				collection,
+				string(job.Status),
+				fmt.Sprintf("%v/%v", job.Completed, job.Total),
+				strconv.Itoa(job.Attempts),
+				formatAge(job.CreatedAt),
+				job.Error,
+			}
+		}
+		setRows(&v.table, rows)
+		return v, nil
+
+	case tea.KeyMsg:
+		if msg.String() == "r" {
+			return v, v.load()
+		}
+	}
+
+	var cmd tea.Cmd
+	v.table, cmd = v.table.Update(msg)
+	return v, cmd
+}
+
+func (v jobsView) view() string {
+	if v.err != nil {
+		return describe(v.err)
+	}
+	if len(v.jobs) == 0 {
+		return helpStyle.Render("no ingestion jobs")
+	}
+
+	counts := map[models.JobStatus]int{}
+	for _, job := range v.jobs {
+		counts[job.Status]++
+	}
+	summary := fmt.Sprintf("%v queued, %v running, %v succeeded, %v failed",
+		counts[models.JobQueued], counts[models.JobRunning], counts[models.JobSucceeded], counts[models.JobFailed])
+	return lipgloss.JoinVertical(lipgloss.Left, labelStyle.Render("Recent jobs")+helpStyle.Render("  "+summary), "", v.table.View())
+}
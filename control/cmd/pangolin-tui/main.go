@@ -0,0 +1,31 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/christian-nickerson/pangolin/control/internal/client"
+	"github.com/christian-nickerson/pangolin/control/internal/configs"
+)
+
+// Terminal UI for browsing, searching and monitoring a Pangolin instance
+// through its REST API
+func main() {
+	address, key := "http://127.0.0.1:3000", os.Getenv("PANGOLIN_API_KEY")
+	if settings, err := configs.Load("settings.toml"); err == nil {
+		address, key = client.Address(settings.Server.API), client.Key(settings.Auth)
+	}
+
+	flag.StringVar(&address, "url", address, "API address, from settings.toml when found")
+	flag.StringVar(&key, "key", key, "API key, PANGOLIN_API_KEY or the admin key by default")
+	flag.Parse()
+
+	program := tea.NewProgram(newModel(client.New(address, key), address), tea.WithAltScreen())
+	if _, err := program.Run(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
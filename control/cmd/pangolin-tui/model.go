@@ -0,0 +1,294 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/table"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/christian-nickerson/pangolin/control/internal/client"
+)
+
+const (
+	// deadline of each API call
+	requestTimeout = 10 * time.Second
+	// period live views are refreshed at
+	refreshInterval = 2 * time.Second
+)
+
+type tab int
+
+const (
+	collectionsTab tab = iota
+	documentsTab
+	searchTab
+	nodesTab
+	jobsTab
+)
+
+var tabNames = []string{"Collections", "Documents", "Search", "Nodes", "Jobs"}
+
+var (
+	titleStyle     = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("#F2A65A")).Padding(0, 1)
+	activeTabStyle = lipgloss.NewStyle().Bold(true).Underline(true).Padding(0, 1)
+	tabStyle       = lipgloss.NewStyle().Faint(true).Padding(0, 1)
+	helpStyle      = lipgloss.NewStyle().Faint(true)
+	errorStyle     = lipgloss.NewStyle().Foreground(lipgloss.Color("#E05A5A"))
+	labelStyle     = lipgloss.NewStyle().Bold(true)
+	matchStyle     = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("#1A1A1A")).Background(lipgloss.Color("#F2A65A"))
+	scoreStyle     = lipgloss.NewStyle().Foreground(lipgloss.Color("#6FB98F"))
+)
+
+// tickMsg refreshes the live views
+type tickMsg time.Time
+
+// selectMsg chooses the collection browsed and searched
+type selectMsg struct {
+	collection string
+}
+
+// model switches between tabs, each view loads its own data and keeps
+// it when another tab is shown
+type model struct {
+	api     *client.Client
+	address string
+	tab     tab
+	width   int
+	height  int
+
+	collections collectionsView
+	documents   documentsView
+	search      searchView
+	nodes       nodesView
+	jobs        jobsView
+}
+
+func newModel(api *client.Client, address string) model {
+	return model{
+		api:         api,
+		address:     address,
+		collections: newCollectionsView(api),
+		documents:   newDocumentsView(api),
+		search:      newSearchView(api),
+		nodes:       newNodesView(api),
+		jobs:        newJobsView(api),
+	}
+}
+
+func (m model) Init() tea.Cmd {
+	return tea.Batch(m.collections.load(), tick())
+}
+
+func tick() tea.Cmd {
+	return tea.Tick(refreshInterval, func(t time.Time) tea.Msg { return tickMsg(t) })
+}
+
+func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+		// header and footer take two lines each
+		width, height := msg.Width, max(msg.Height-4, 4)
+		m.collections.resize(width, height)
+		m.documents.resize(width, height)
+		m.search.resize(width, height)
+		m.nodes.resize(width, height)
+		m.jobs.resize(width, height)
+		return m, nil
+
+	case tea.KeyMsg:
+		if cmd, handled := m.global(msg); handled {
+			return m, cmd
+		}
+
+	case tickMsg:
+		var cmd tea.Cmd
+		switch m.tab {
+		case nodesTab:
+			cmd = m.nodes.load()
+		case jobsTab:
+			cmd = m.jobs.load()
+		}
+		return m, tea.Batch(cmd, tick())
+
+	case selectMsg:
+		m.documents.collection, m.search.collection = msg.collection, msg.collection
+		return m, m.show(documentsTab)
+
+	case collectionsMsg:
+		m.jobs.names = msg.names()
+	}
+
+	// data messages reach their view whichever tab is shown, keys reach
+	// the shown tab alone
+	var cmd tea.Cmd
+	_, isKey := msg.(tea.KeyMsg)
+	if !isKey || m.tab == collectionsTab {
+		m.collections, cmd = m.collections.update(msg)
+	}
+	cmds := []tea.Cmd{cmd}
+	if !isKey || m.tab == documentsTab {
+		m.documents, cmd = m.documents.update(msg)
+		cmds = append(cmds, cmd)
+	}
+	if !isKey || m.tab == searchTab {
+		m.search, cmd = m.search.update(msg)
+		cmds = append(cmds, cmd)
+	}
+	if !isKey || m.tab == nodesTab {
+		m.nodes, cmd = m.nodes.update(msg)
+		cmds = append(cmds, cmd)
+	}
+	if !isKey || m.tab == jobsTab {
+		m.jobs, cmd = m.jobs.update(msg)
+		cmds = append(cmds, cmd)
+	}
+	return m, tea.Batch(cmds...)
+}
+
+// handle keys acting across tabs, unless typing a search
+func (m *model) global(msg tea.KeyMsg) (tea.Cmd, bool) {
+	switch msg.String() {
+	case "ctrl+c":
+		return tea.Quit, true
+	case "tab":
+		return m.show((m.tab + 1) % tab(len(tabNames))), true
+	case "shift+tab":
+		return m.show((m.tab + tab(len(tabNames)) - 1) % tab(len(tabNames))), true
+	}
+	if m.tab == searchTab && m.search.typing() {
+		return nil, false
+	}
+
+	switch msg.String() {
+	case "q":
+		return tea.Quit, true
+	case "1", "2", "3", "4", "5":
+		return m.show(tab(msg.String()[0] - '1')), true
+	}
+	return nil, false
+}
+
+// switch tab, loading its data
+func (m *model) show(t tab) tea.Cmd {
+	m.tab = t
+	switch t {
+	case collectionsTab:
+		return m.collections.load()
+	case documentsTab:
+		return m.documents.load()
+	case searchTab:
+		return m.search.focus()
+	case nodesTab:
+		return m.nodes.load()
+	case jobsTab:
+		return m.jobs.load()
+	}
+	return nil
+}
+
+func (m model) View() string {
+	tabs := make([]string, len(tabNames))
+	for i, name := range tabNames {
+		label := fmt.Sprintf("%v %v", i+1, name)
+		if tab(i) == m.tab {
+			tabs[i] = activeTabStyle.Render(label)
+		} else {
+			tabs[i] = tabStyle.Render(label)
+		}
+	}
+	header := lipgloss.JoinHorizontal(lipgloss.Top, titleStyle.Render("Pangolin"), strings.Join(tabs, ""), helpStyle.Render(m.address))
+
+	var body, help string
+	switch m.tab {
+	case collectionsTab:
+		body, help = m.collections.view(), "enter browse • r refresh"
+	case documentsTab:
+		body, help = m.documents.view(), "enter chunks • n/p page • pgup/pgdown scroll • r refresh"
+	case searchTab:
+		body, help = m.search.view(), m.search.help()
+	case nodesTab:
+		body, help = m.nodes.view(), "live • r refresh"
+	case jobsTab:
+		body, help = m.jobs.view(), "live • r refresh"
+	}
+	footer := helpStyle.Render(help + " • tab/1-5 switch • q quit")
+
+	return lipgloss.JoinVertical(lipgloss.Left, header, "", lipgloss.NewStyle().Height(max(m.height-4, 0)).Render(body), "", footer)
+}
+
+// run an API call in the background with a deadline
+func fetch(call func(context.Context) tea.Msg) tea.Cmd {
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+		defer cancel()
+		return call(ctx)
+	}
+}
+
+// render an API error, explaining missing permissions
+func describe(err error) string {
+	var apiErr *client.Error
+	if errors.As(err, &apiErr) {
+		switch apiErr.Status {
+		case http.StatusUnauthorized:
+			return errorStyle.Render("unauthorized, set an api key with -key or PANGOLIN_API_KEY")
+		case http.StatusForbidden:
+			return errorStyle.Render("forbidden, " + apiErr.Message)
+		}
+	}
+	return errorStyle.Render(err.Error())
+}
+
+// build a table filling the view, with the cursor on the first row
+func newTable(columns []table.Column) table.Model {
+	styles := table.DefaultStyles()
+	styles.Header = styles.Header.Bold(true).BorderStyle(lipgloss.NormalBorder()).BorderBottom(true)
+	styles.Selected = styles.Selected.Foreground(lipgloss.Color("#1A1A1A")).Background(lipgloss.Color("#F2A65A"))
+	return table.New(table.WithColumns(columns), table.WithFocused(true), table.WithStyles(styles))
+}
+
+// replace a table's rows, keeping the cursor within them
+func setRows(t *table.Model, rows []table.Row) {
+	t.SetRows(rows)
+	if t.Cursor() >= len(rows) {
+		t.SetCursor(max(len(rows)-1, 0))
+	}
+}
+
+// format a size in bytes with a binary unit
+func formatBytes(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+// format how long ago a time was
+func formatAge(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	age := time.Since(t)
+	switch {
+	case age < time.Minute:
+		return fmt.Sprintf("%ds ago", int(age.Seconds()))
+	case age < time.Hour:
+		return fmt.Sprintf("%dm ago", int(age.Minutes()))
+	case age < 24*time.Hour:
+		return fmt.Sprintf("%dh ago", int(age.Hours()))
+	}
+	return t.Format(time.DateOnly)
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/table"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/christian-nickerson/pangolin/control/internal/client"
+	"github.com/christian-nickerson/pangolin/control/internal/consensus"
+	"github.com/christian-nickerson/pangolin/control/internal/models"
+)
+
+type nodesMsg struct {
+	nodes []models.Node
+	// cluster is nil when the control plane is not replicated
+	cluster *consensus.Status
+	err     error
+}
+
+// nodesView shows the control plane's leadership, the registered index
+// nodes and the shards hosted by the chosen node
+type nodesView struct {
+	api     *client.Client
+	nodes   []models.Node
+	cluster *consensus.Status
+	table   table.Model
+	err     error
+}
+
+func newNodesView(api *client.Client) nodesView {
+	return nodesView{
+		api: api,
+		table: newTable([]table.Column{
+			{Title: "Node", Width: 20},
+			{Title: "Address", Width: 24},
+			{Title: "Status", Width: 10},
+			{Title: "Shards", Width: 8},
+			{Title: "Vectors", Width: 10},
+			{Title: "Memory", Width: 10},
+			{Title: "Heartbeat", Width: 12},
+		}),
+	}
+}
+
+func (v *nodesView) load() tea.Cmd {
+	api := v.api
+	return fetch(func(ctx context.Context) tea.Msg {
+		nodes, err := api.ListNodes(ctx)
+		if err != nil {
+			return nodesMsg{err: err}
+		}
+
+		cluster, err := api.ClusterStatus(ctx)
+		var apiErr *client.Error
+		if errors.As(err, &apiErr) && apiErr.Status == http.StatusNotFound {
+			cluster, err = nil, nil
+		}
+		return nodesMsg{nodes: nodes, cluster: cluster, err: err}
+	})
+}
+
+// split the height between the node list and the shards of the chosen node
+func (v *nodesView) resize(width, height int) {
+	v.table.SetWidth(width)
+	v.table.SetHeight(max(height/2, 3))
+}
+
+func (v nodesView) update(msg tea.Msg) (nodesView, tea.Cmd) {
+	switch msg := msg.(type) {
+	case nodesMsg:
+		v.err = msg.err
+		if msg.err != nil {
+			return v, nil
+		}
+		v.nodes, v.cluster = msg.nodes, msg.cluster
+		rows := make([]table.Row, len(msg.nodes))
+		for i, node := range msg.nodes {
+			var vectors uint64
+			for _, shard := range node.Shards {
+				vectors += shard.Vectors
+			}
+			rows[i] = table.Row{
+				node.ID,
+				node.Address,
+				string(node.Status),
+				capacity(len(node.Shards), node.MaxShards),
+				strconv.FormatUint(vectors, 10),
+				formatBytes(int64(node.MemoryBytes)),
+				formatAge(node.LastHeartbeat),
+			}
+		}
+		setRows(&v.table, rows)
+		return v, nil
+
+	case tea.KeyMsg:
+		if msg.String() == "r" {
+			return v, v.load()
+		}
+	}
+
+	var cmd tea.Cmd
+	v.table, cmd = v.table.Update(msg)
+	return v, cmd
+}
+
+func (v nodesView) view() string {
+	if v.err != nil {
+		return describe(v.err)
+	}
+
+	sections := []string{v.clusterView(), ""}
+	if len(v.nodes) == 0 {
+		return lipgloss.JoinVertical(lipgloss.Left, append(sections, helpStyle.Render("no index nodes registered"))...)
+	}
+	sections = append(sections, v.table.View(), "")
+
+	if cursor := v.table.Cursor(); cursor < len(v.nodes) {
+		node := v.nodes[cursor]
+		var b strings.Builder
+		b.WriteString(labelStyle.Render("Shards on "+node.ID) + "\n")
+		if len(node.Shards) == 0 {
+			b.WriteString(helpStyle.Render("none"))
+		}
+		for _, shard := range node.Shards {
+			fmt.Fprintf(&b, "  collection %v shard %v  %v vectors\n", shard.CollectionID, shard.Shard, shard.Vectors)
+		}
+		sections = append(sections, b.String())
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, sections...)
+}
+
+// summarise control plane leadership
+func (v nodesView) clusterView() string {
+	if v.cluster == nil {
+		return labelStyle.Render("Control plane") + helpStyle.Render("  single instance")
+	}
+	leader := v.cluster.Leader
+	if leader == "" {
+		leader = errorStyle.Render("no leader")
+	}
+	return labelStyle.Render("Control plane") + fmt.Sprintf("  %v is %v, leader %v, %v members, applied %v",
+		v.cluster.ID, strings.ToLower(v.cluster.State), leader, len(v.cluster.Members), v.cluster.AppliedIndex)
+}
+
+// shards hosted out of the node's limit, if it has one
+func capacity(shards, limit int) string {
+	if limit == 0 {
+		return strconv.Itoa(shards)
+	}
+	return fmt.Sprintf("%v/%v", shards, limit)
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/christian-nickerson/pangolin/control/internal/client"
+	"github.com/christian-nickerson/pangolin/control/internal/search"
+)
+
+// results returned by a search, adjusted with + and -
+const (
+	defaultK = 10
+	maxK     = 100
+)
+
+type searchMsg struct {
+	collection string
+	query      string
+	response   *search.Response
+	err        error
+}
+
+// searchView searches the chosen collection, listing results with their
+// scores and the query's words highlighted in each chunk
+type searchView struct {
+	api        *client.Client
+	collection string
+	k          int
+	input      textinput.Model
+	results    viewport.Model
+	width      int
+	searching  bool
+}
+
+func newSearchView(api *client.Client) searchView {
+	input := textinput.New()
+	input.Placeholder = "search query"
+	input.Prompt = "› "
+	return searchView{api: api, k: defaultK, input: input, results: viewport.New(0, 0)}
+}
+
+// whether keys are typed into the query
+func (v *searchView) typing() bool {
+	return v.input.Focused()
+}
+
+func (v *searchView) focus() tea.Cmd {
+	return v.input.Focus()
+}
+
+func (v *searchView) resize(width, height int) {
+	v.width = width
+	v.input.Width = max(width-4, 10)
+	v.results.Width, v.results.Height = width, max(height-3, 1)
+}
+
+func (v *searchView) run() tea.Cmd {
+	query := strings.TrimSpace(v.input.Value())
+	if v.collection == "" || query == "" {
+		return nil
+	}
+	v.searching = true
+	api, collection, k := v.api, v.collection, v.k
+	return fetch(func(ctx context.Context) tea.Msg {
+		response, err := api.Search(ctx, collection, query, k)
+		return searchMsg{collection: collection, query: query, response: response, err: err}
+	})
+}
+
+func (v searchView) update(msg tea.Msg) (searchView, tea.Cmd) {
+	switch msg := msg.(type) {
+	case selectMsg:
+		if msg.collection != v.collection {
+			v.collection = msg.collection
+			v.results.SetContent("")
+		}
+		return v, nil
+
+	case searchMsg:
+		if msg.collection != v.collection {
+			return v, nil
+		}
+		v.searching = false
+		if msg.err != nil {
+			v.results.SetContent(describe(msg.err))
+		} else {
+			v.results.SetContent(renderResults(msg.response, msg.query, v.width))
+		}
+		v.results.GotoTop()
+		return v, nil
+
+	case tea.KeyMsg:
+		if v.input.Focused() {
+			switch msg.String() {
+			case "enter":
+				v.input.Blur()
+				return v, v.run()
+			case "esc":
+				v.input.Blur()
+				return v, nil
+			}
+			var cmd tea.Cmd
+			v.input, cmd = v.input.Update(msg)
+			return v, cmd
+		}
+
+		switch msg.String() {
+		case "/", "enter":
+			return v, v.input.Focus()
+		case "+":
+			v.k = min(v.k+5, maxK)
+			return v, v.run()
+		case "-":
+			v.k = max(v.k-5, 1)
+			return v, v.run()
+		}
+		var cmd tea.Cmd
+		v.results, cmd = v.results.Update(msg)
+		return v, cmd
+	}
+	return v, nil
+}
+
+func (v searchView) help() string {
+	if v.input.Focused() {
+		return "enter search • esc results"
+	}
+	return "/ query • +/- results • ↑/↓ scroll"
+}
+
+func (v searchView) view() string {
+	if v.collection == "" {
+		return helpStyle.Render("choose a collection on the collections tab")
+	}
+
+	status := fmt.Sprintf("  top %v", v.k)
+	if v.searching {
+		status += ", searching…"
+	}
+	title := labelStyle.Render(v.collection) + helpStyle.Render(status)
+	return lipgloss.JoinVertical(lipgloss.Left, title, v.input.View(), "", v.results.View())
+}
+
+// render results with their scores, highlighting the query's words
+func renderResults(response *search.Response, query string, width int) string {
+	if len(response.Results) == 0 {
+		return helpStyle.Render("no results")
+	}
+
+	var b strings.Builder
+	if response.Partial {
+		b.WriteString(errorStyle.Render("partial results, some shards did not answer") + "\n\n")
+	}
+	words := matcher(query)
+	text := lipgloss.NewStyle().Width(max(width-2, 10)).PaddingLeft(2)
+	for i, result := range response.Results {
+		fmt.Fprintf(&b, "%v %v %v\n",
+			labelStyle.Render(fmt.Sprintf("%2d.", i+1)),
+			scoreStyle.Render(fmt.Sprintf("%.4f", result.Score)),
+			helpStyle.Render(fmt.Sprintf("%v · chunk %v · v%v", result.DocumentID, result.Position, result.Version)),
+		)
+		chunk := result.Text
+		if words != nil {
+			chunk = words.ReplaceAllStringFunc(chunk, func(word string) string { return matchStyle.Render(word) })
+		}
+		b.WriteString(text.Render(chunk) + "\n\n")
+	}
+	return b.String()
+}
+
+// match the query's words in chunk text, ignoring case
+func matcher(query string) *regexp.Regexp {
+	var words []string
+	for _, word := range strings.FieldsFunc(query, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }) {
+		if len([]rune(word)) > 1 {
+			words = append(words, regexp.QuoteMeta(word))
+		}
+	}
+	if len(words) == 0 {
+		return nil
+	}
+	return regexp.MustCompile(`(?i)(` + strings.Join(words, "|") + `)`)
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// characters of chunk text shown in table output
+const excerptLength = 80
+
+func newSearchCommand(flags *globals) *cobra.Command {
+	var k int
+	command := &cobra.Command{
+		Use:   "search <collection> <query>",
+		Short: "Search a collection for the chunks nearest a query",
+		Args:  cobra.MinimumNArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			api, err := flags.client()
+			if err != nil {
+				return err
+			}
+			response, err := api.Search(cmd.Context(), args[0], strings.Join(args[1:], " "), k)
+			if err != nil {
+				return err
+			}
+
+			rows := make([][]string, len(response.Results))
+			for i, result := range response.Results {
+				rows[i] = []string{
+					strconv.Itoa(i + 1),
+					fmt.Sprintf("%.4f", result.Score),
+					result.DocumentID,
+					strconv.Itoa(result.Position),
+					excerpt(result.Text),
+				}
+			}
+			if response.Partial {
+				fmt.Fprintln(os.Stderr, "partial results, some shards did not answer")
+			}
+			return flags.print(os.Stdout, response, []string{"#", "SCORE", "DOCUMENT", "CHUNK", "TEXT"}, rows)
+		},
+	}
+	command.Flags().IntVarP(&k, "k", "k", 10, "results returned")
+	return command
+}
+
+// shorten text onto a single line
+func excerpt(text string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if runes := []rune(text); len(runes) > excerptLength {
+		return string(runes[:excerptLength-1]) + "…"
+	}
+	return text
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"os"
+	"strconv"
+
+	"github.com/spf13/cobra"
+)
+
+var snapshotHeader = []string{"SNAPSHOT", "COLLECTION", "DOCUMENTS", "CHUNKS", "FILE"}
+
+func newSnapshotCommand(flags *globals) *cobra.Command {
+	command := &cobra.Command{
+		Use:   "snapshot",
+		Short: "Back up and restore collections",
+	}
+
+	var output string
+	create := &cobra.Command{
+		Use:   "create <collection>",
+		Short: "Snapshot a collection on the server, then download the archive",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			api, err := flags.client()
+			if err != nil {
+				return err
+			}
+			collection := args[0]
+			if output == "" {
+				output = collection + ".tar.gz"
+			}
+
+			manifest, err := api.CreateSnapshot(cmd.Context(), collection)
+			if err != nil {
+				return err
+			}
+
+			file, err := os.Create(output)
+			if err != nil {
+				return err
+			}
+			if err := api.DownloadSnapshot(cmd.Context(), manifest.ID, file); err != nil {
+				file.Close()
+				return err
+			}
+			if err := file.Close(); err != nil {
+				return err
+			}
+
+			row := []string{manifest.ID, collection, strconv.Itoa(manifest.Documents), strconv.Itoa(manifest.Chunks), output}
+			return flags.print(os.Stdout, manifest, snapshotHeader, [][]string{row})
+		},
+	}
+	create.Flags().StringVarP(&output, "file", "f", "", "archive written, <collection>.tar.gz by default")
+
+	var name string
+	restore := &cobra.Command{
+		Use:   "restore <file>",
+		Short: "Upload an archive to the server, then restore it as a new collection",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			api, err := flags.client()
+			if err != nil {
+				return err
+			}
+
+			file, err := os.Open(args[0])
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+
+			manifest, err := api.UploadSnapshot(cmd.Context(), file)
+			if err != nil {
+				return err
+			}
+			collection, err := api.RestoreSnapshot(cmd.Context(), manifest.ID, name)
+			if err != nil {
+				return err
+			}
+			return flags.print(os.Stdout, collection, collectionHeader, [][]string{collectionRow(*collection)})
+		},
+	}
+	restore.Flags().StringVar(&name, "name", "", "collection name, the snapshotted name by default")
+
+	command.AddCommand(create, restore)
+	return command
+}
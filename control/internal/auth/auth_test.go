@@ -0,0 +1,155 @@
+package auth
+
+import (
+	"context"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/christian-nickerson/pangolin/control/internal/configs"
+	"github.com/christian-nickerson/pangolin/control/internal/database"
+	"github.com/christian-nickerson/pangolin/control/internal/models"
+	"github.com/christian-nickerson/pangolin/control/internal/namespaces"
+)
+
+type AuthSuite struct {
+	suite.Suite
+	app       *fiber.App
+	namespace *models.Namespace
+	ctx       context.Context
+}
+
+// set up a fresh database and app with read and write routes
+func (s *AuthSuite) SetupTest() {
+	s.ctx = context.Background()
+	config := configs.DatabaseConfig{Type: "sqlite", DBName: filepath.Join(s.T().TempDir(), "test")}
+	s.Require().NoError(database.Connect(config))
+	s.Require().NoError(database.Migrate())
+	namespace, err := namespaces.EnsureDefault(context.Background(), configs.Quotas{})
+	s.Require().NoError(err)
+	s.namespace = namespace
+
+	s.app = fiber.New(fiber.Config{ErrorHandler: models.ErrorHandler})
+	s.app.Get("/health", func(c *fiber.Ctx) error { return c.SendStatus(fiber.StatusOK) })
+	s.app.Use(New(configs.Auth{Enabled: true, AdminKey: "bootstrap"}))
+	s.app.Get("/collections/:collection", Require(models.ScopeRead), func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusOK)
+	})
+	s.app.Post("/collections/:collection", Require(models.ScopeWrite), func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusOK)
+	})
+}
+
+// send a request with an optional bearer key
+func (s *AuthSuite) request(method, path, key string) int {
+	request := httptest.NewRequest(method, path, nil)
+	if key != "" {
+		request.Header.Set("Authorization", "Bearer "+key)
+	}
+	response, err := s.app.Test(request)
+	s.Require().NoError(err)
+	return response.StatusCode
+}
+
+// Test routes registered before the middleware stay public
+func (s *AuthSuite) TestPublicRoute() {
+	s.Assert().Equal(200, s.request("GET", "/health", ""))
+}
+
+// Test missing and unknown keys are rejected
+func (s *AuthSuite) TestUnauthenticated() {
+	s.Assert().Equal(401, s.request("GET", "/collections/a", ""))
+	s.Assert().Equal(401, s.request("GET", "/collections/a", "pgl_00000000_secret"))
+	s.Assert().Equal(401, s.request("GET", "/collections/a", "not-a-key"))
+}
+
+// Test the bootstrap admin key is accepted
+func (s *AuthSuite) TestBootstrapKey() {
+	s.Assert().Equal(200, s.request("POST", "/collections/a", "bootstrap"))
+}
+
+// Test scopes restrict access to write routes
+func (s *AuthSuite) TestScopes() {
+	read, _, err := Create(s.ctx, s.namespace.ID, "reader", models.ScopeRead, nil)
+	s.Require().NoError(err)
+	write, _, err := Create(s.ctx, s.namespace.ID, "writer", models.ScopeWrite, nil)
+	s.Require().NoError(err)
+
+	s.Assert().Equal(200, s.request("GET", "/collections/a", read))
+	s.Assert().Equal(403, s.request("POST", "/collections/a", read))
+	s.Assert().Equal(200, s.request("GET", "/collections/a", write))
+	s.Assert().Equal(200, s.request("POST", "/collections/a", write))
+}
+
+// Test keys restricted to collections cannot access others
+func (s *AuthSuite) TestCollectionRestriction() {
+	key, _, err := Create(s.ctx, s.namespace.ID, "scoped", models.ScopeWrite, []string{"a"})
+	s.Require().NoError(err)
+
+	s.Assert().Equal(200, s.request("POST", "/collections/a", key))
+	s.Assert().Equal(403, s.request("POST", "/collections/b", key))
+}
+
+// Test revoked keys stop authenticating
+func (s *AuthSuite) TestRevoke() {
+	key, record, err := Create(s.ctx, s.namespace.ID, "revoked", models.ScopeRead, nil)
+	s.Require().NoError(err)
+
+	_, err = Revoke(s.ctx, s.namespace.ID, record.ID)
+	s.Require().NoError(err)
+	s.Assert().Equal(401, s.request("GET", "/collections/a", key))
+}
+
+// Test rotation replaces the secret and keeps the scope
+func (s *AuthSuite) TestRotate() {
+	old, record, err := Create(s.ctx, s.namespace.ID, "rotated", models.ScopeRead, nil)
+	s.Require().NoError(err)
+
+	rotated, updated, err := Rotate(s.ctx, s.namespace.ID, record.ID)
+	s.Require().NoError(err)
+	s.Assert().Equal(record.ID, updated.ID)
+	s.Assert().Equal(models.ScopeRead, updated.Scope)
+
+	s.Assert().Equal(401, s.request("GET", "/collections/a", old))
+	s.Assert().Equal(200, s.request("GET", "/collections/a", rotated))
+}
+
+// Test stored keys are never kept in plain text
+func (s *AuthSuite) TestHashedStorage() {
+	key, record, err := Create(s.ctx, s.namespace.ID, "hashed", models.ScopeRead, nil)
+	s.Require().NoError(err)
+
+	stored, err := Get(s.ctx, s.namespace.ID, record.ID)
+	s.Require().NoError(err)
+	s.Assert().NotContains(stored.Hash, key)
+	s.Assert().Equal(hash(key), stored.Hash)
+}
+
+// Test keys resolve outside of requests as they authenticate within them
+func (s *AuthSuite) TestResolve() {
+	config := configs.Auth{Enabled: true, AdminKey: "bootstrap"}
+	key, _, err := Create(s.ctx, s.namespace.ID, "agent", models.ScopeRead, nil)
+	s.Require().NoError(err)
+
+	record, namespace, err := Resolve(s.ctx, config, key, "")
+	s.Require().NoError(err)
+	s.Equal("agent", record.Name)
+	s.Equal(s.namespace.ID, namespace.ID)
+
+	_, _, err = Resolve(s.ctx, config, key, "elsewhere")
+	s.ErrorIs(err, ErrNamespaceDenied)
+	_, _, err = Resolve(s.ctx, config, "pgl_00000000_secret", "")
+	s.ErrorIs(err, ErrInvalidKey)
+
+	record, namespace, err = Resolve(s.ctx, config, "bootstrap", "")
+	s.Require().NoError(err)
+	s.Equal(unrestricted, record)
+	s.Equal(models.DefaultNamespace, namespace.Name)
+}
+
+func TestAuthSuite(t *testing.T) {
+	suite.Run(t, new(AuthSuite))
+}
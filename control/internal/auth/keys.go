@@ -0,0 +1,184 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/christian-nickerson/pangolin/control/internal/consensus"
+	"github.com/christian-nickerson/pangolin/control/internal/database"
+	"github.com/christian-nickerson/pangolin/control/internal/models"
+)
+
+// keys are formatted as pgl_<prefix>_<secret>, the prefix is stored
+// in plain text to look keys up and the whole key is stored hashed
+const keyPrefix = "pgl"
+
+var (
+	ErrInvalidKey      = errors.New("invalid api key")
+	ErrKeyNotFound     = errors.New("api key not found")
+	ErrNamespaceDenied = errors.New("api key is not bound to namespace")
+)
+
+// generate a new random key along with its lookup prefix
+func generate() (string, string, error) {
+	prefix := make([]byte, 4)
+	secret := make([]byte, 32)
+	if _, err := rand.Read(prefix); err != nil {
+		return "", "", err
+	}
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", err
+	}
+
+	lookup := hex.EncodeToString(prefix)
+	key := fmt.Sprintf("%v_%v_%v", keyPrefix, lookup, base64.RawURLEncoding.EncodeToString(secret))
+	return key, lookup, nil
+}
+
+// hash a key for storage, keys are random so a fast hash is sufficient
+func hash(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// extract the lookup prefix from a key
+func parse(key string) (string, bool) {
+	parts := strings.SplitN(key, "_", 3)
+	if len(parts) != 3 || parts[0] != keyPrefix || parts[1] == "" || parts[2] == "" {
+		return "", false
+	}
+	return parts[1], true
+}
+
+// Create stores a new key and returns it, the plain text key
+// is only available at creation or rotation
+func Create(ctx context.Context, namespaceID uint, name string, scope models.Scope, collections []string) (string, *models.APIKey, error) {
+	key, prefix, err := generate()
+	if err != nil {
+		return "", nil, err
+	}
+
+	record := &models.APIKey{
+		NamespaceID: namespaceID,
+		Name:        name,
+		Prefix:      prefix,
+		Hash:        hash(key),
+		Scope:       scope,
+		Collections: collections,
+	}
+	err = database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(record).Error; err != nil {
+			return err
+		}
+		return consensus.Publish(tx, record)
+	})
+	if err != nil {
+		return "", nil, err
+	}
+
+	return key, record, nil
+}
+
+// List returns all keys in a namespace, including revoked keys
+func List(ctx context.Context, namespaceID uint) ([]models.APIKey, error) {
+	var keys []models.APIKey
+	err := database.DB.WithContext(ctx).Where("namespace_id = ?", namespaceID).Order("id").Find(&keys).Error
+	return keys, err
+}
+
+// Get returns a key in a namespace by id
+func Get(ctx context.Context, namespaceID, id uint) (*models.APIKey, error) {
+	var key models.APIKey
+	err := database.DB.WithContext(ctx).Where("namespace_id = ?", namespaceID).First(&key, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrKeyNotFound
+	}
+	return &key, err
+}
+
+// Revoke disables a key, keeping the record for auditing
+func Revoke(ctx context.Context, namespaceID, id uint) (*models.APIKey, error) {
+	key, err := Get(ctx, namespaceID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if key.RevokedAt == nil {
+		now := time.Now()
+		key.RevokedAt = &now
+		if err := save(ctx, key); err != nil {
+			return nil, err
+		}
+	}
+
+	return key, nil
+}
+
+// Rotate replaces the secret of an active key, keeping its permissions
+func Rotate(ctx context.Context, namespaceID, id uint) (string, *models.APIKey, error) {
+	key, err := Get(ctx, namespaceID, id)
+	if err != nil {
+		return "", nil, err
+	}
+	if key.RevokedAt != nil {
+		return "", nil, ErrKeyNotFound
+	}
+
+	plain, prefix, err := generate()
+	if err != nil {
+		return "", nil, err
+	}
+
+	now := time.Now()
+	key.Prefix, key.Hash, key.RotatedAt = prefix, hash(plain), &now
+	if err := save(ctx, key); err != nil {
+		return "", nil, err
+	}
+
+	return plain, key, nil
+}
+
+// save a changed key, replicating it
+func save(ctx context.Context, key *models.APIKey) error {
+	return database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(key).Error; err != nil {
+			return err
+		}
+		return consensus.Publish(tx, key)
+	})
+}
+
+// Authenticate resolves a plain text key to its active stored key
+func Authenticate(ctx context.Context, key string) (*models.APIKey, error) {
+	prefix, ok := parse(key)
+	if !ok {
+		return nil, ErrInvalidKey
+	}
+
+	var record models.APIKey
+	err := database.DB.WithContext(ctx).
+		Where("prefix = ? AND revoked_at IS NULL", prefix).
+		First(&record).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrInvalidKey
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hash(key)), []byte(record.Hash)) != 1 {
+		return nil, ErrInvalidKey
+	}
+
+	return &record, nil
+}
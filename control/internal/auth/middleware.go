@@ -0,0 +1,200 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/christian-nickerson/pangolin/control/internal/configs"
+	"github.com/christian-nickerson/pangolin/control/internal/logging"
+	"github.com/christian-nickerson/pangolin/control/internal/models"
+	"github.com/christian-nickerson/pangolin/control/internal/namespaces"
+)
+
+// NamespaceHeader selects the namespace for unrestricted requests
+const NamespaceHeader = "X-Pangolin-Namespace"
+
+const (
+	keyLocal       = "apikey"
+	namespaceLocal = "namespace"
+)
+
+// unrestricted is used for the bootstrap key and when auth is disabled,
+// it is the only principal not bound to a namespace
+var unrestricted = &models.APIKey{Name: "unrestricted", Scope: models.ScopeAdmin}
+
+// New authenticates requests with an Authorization: Bearer API key and
+// resolves the namespace they act within. Routes registered before this
+// middleware, such as health checks, stay public.
+func New(config configs.Auth) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		key, err := authenticate(c, config)
+		if err != nil {
+			return err
+		}
+		c.Locals(keyLocal, key)
+
+		namespace, err := resolveNamespace(c, key)
+		if err != nil {
+			return err
+		}
+		c.Locals(namespaceLocal, namespace)
+
+		return c.Next()
+	}
+}
+
+// resolve the key for the request
+func authenticate(c *fiber.Ctx, config configs.Auth) (*models.APIKey, error) {
+	if !config.Enabled {
+		return unrestricted, nil
+	}
+
+	header := c.Get(fiber.HeaderAuthorization)
+	token, found := strings.CutPrefix(header, "Bearer ")
+	if !found || token == "" {
+		c.Set(fiber.HeaderWWWAuthenticate, "Bearer")
+		return nil, fiber.NewError(fiber.StatusUnauthorized, "missing bearer api key")
+	}
+
+	// bootstrap admin key, used to create namespaces and their first keys
+	if isAdminKey(config, token) {
+		return unrestricted, nil
+	}
+
+	key, err := Authenticate(c.UserContext(), token)
+	if errors.Is(err, ErrInvalidKey) {
+		c.Set(fiber.HeaderWWWAuthenticate, `Bearer error="invalid_token"`)
+		return nil, fiber.NewError(fiber.StatusUnauthorized, err.Error())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	logging.Ctx(c).Debug("authenticated", "key_id", key.ID)
+	return key, nil
+}
+
+// whether token is the configured bootstrap admin key
+func isAdminKey(config configs.Auth, token string) bool {
+	return config.AdminKey != "" && subtle.ConstantTimeCompare([]byte(token), []byte(config.AdminKey)) == 1
+}
+
+// Resolve authenticates a plain text key outside of a request, returning
+// the key and the namespace it acts within. As for requests, the admin key
+// and disabled auth act within requested or the default namespace, and
+// other keys within the namespace they are bound to.
+func Resolve(ctx context.Context, config configs.Auth, token, requested string) (*models.APIKey, *models.Namespace, error) {
+	key := unrestricted
+	if config.Enabled && !isAdminKey(config, token) {
+		var err error
+		if key, err = Authenticate(ctx, token); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if key != unrestricted {
+		namespace, err := namespaces.GetByID(ctx, key.NamespaceID)
+		if err != nil {
+			return nil, nil, err
+		}
+		if requested != "" && requested != namespace.Name {
+			return nil, nil, fmt.Errorf("%w %v", ErrNamespaceDenied, requested)
+		}
+		return key, namespace, nil
+	}
+
+	if requested == "" {
+		requested = models.DefaultNamespace
+	}
+	namespace, err := namespaces.Get(ctx, requested)
+	if err != nil {
+		return nil, nil, err
+	}
+	return key, namespace, nil
+}
+
+// resolve the namespace a key is bound to, or the one requested by the
+// unrestricted key, rejecting keys that ask for another namespace
+func resolveNamespace(c *fiber.Ctx, key *models.APIKey) (*models.Namespace, error) {
+	requested := c.Get(NamespaceHeader)
+
+	if key != unrestricted {
+		namespace, err := namespaces.GetByID(c.UserContext(), key.NamespaceID)
+		if err != nil {
+			return nil, err
+		}
+		if requested != "" && requested != namespace.Name {
+			return nil, fiber.NewError(fiber.StatusForbidden, ErrNamespaceDenied.Error()+" "+requested)
+		}
+		return namespace, nil
+	}
+
+	if requested == "" {
+		requested = models.DefaultNamespace
+	}
+	namespace, err := namespaces.Get(c.UserContext(), requested)
+	if errors.Is(err, namespaces.ErrNamespaceNotFound) {
+		return nil, fiber.NewError(fiber.StatusNotFound, err.Error())
+	}
+	return namespace, err
+}
+
+// Key returns the API key authenticated for the request
+func Key(c *fiber.Ctx) *models.APIKey {
+	if key, ok := c.Locals(keyLocal).(*models.APIKey); ok {
+		return key
+	}
+	return nil
+}
+
+// Namespace returns the namespace the request acts within
+func Namespace(c *fiber.Ctx) *models.Namespace {
+	if namespace, ok := c.Locals(namespaceLocal).(*models.Namespace); ok {
+		return namespace
+	}
+	return nil
+}
+
+// Require rejects requests whose key lacks scope, or access to
+// the collection named in the route
+func Require(scope models.Scope) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		key := Key(c)
+		if key == nil {
+			return fiber.NewError(fiber.StatusUnauthorized, "missing bearer api key")
+		}
+		if !key.Allows(scope, c.Params(models.CollectionParam)) {
+			return fiber.NewError(fiber.StatusForbidden, "api key lacks "+string(scope)+" permission")
+		}
+		return c.Next()
+	}
+}
+
+// RequireUnrestricted rejects requests whose key lacks scope or is
+// restricted to some collections, for routes acting across collections
+func RequireUnrestricted(scope models.Scope) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		key := Key(c)
+		if key == nil {
+			return fiber.NewError(fiber.StatusUnauthorized, "missing bearer api key")
+		}
+		if !key.Covers(scope, nil) {
+			return fiber.NewError(fiber.StatusForbidden, "api key lacks unrestricted "+string(scope)+" permission")
+		}
+		return c.Next()
+	}
+}
+
+// RequireCluster rejects requests not made with the unrestricted key,
+// used for operations spanning namespaces
+func RequireCluster(c *fiber.Ctx) error {
+	if Key(c) != unrestricted {
+		return fiber.NewError(fiber.StatusForbidden, "operation requires the cluster admin key")
+	}
+	return c.Next()
+}
@@ -0,0 +1,160 @@
+package certs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/charmbracelet/log"
+	"github.com/fsnotify/fsnotify"
+)
+
+var ErrMissingCertificate = errors.New("tls requires a cert_file and key_file")
+
+// Reloader holds a certificate key pair, and optionally a CA pool, that are
+// reloaded whenever the files change so certificates rotate without restart
+type Reloader struct {
+	certFile string
+	keyFile  string
+	caFile   string
+
+	mu   sync.RWMutex
+	cert *tls.Certificate
+	pool *x509.CertPool
+
+	watcher *fsnotify.Watcher
+}
+
+// NewReloader loads the files and starts watching them for changes.
+// The cert and key files are required, the CA file is optional.
+func NewReloader(certFile, keyFile, caFile string) (*Reloader, error) {
+	if certFile == "" || keyFile == "" {
+		return nil, ErrMissingCertificate
+	}
+	r := &Reloader{certFile: certFile, keyFile: keyFile, caFile: caFile}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("unable to watch certificates, %v", err)
+	}
+	r.watcher = watcher
+
+	// watch directories rather than files, so atomic replacement
+	// and symlink swaps, as done for mounted secrets, are seen
+	dirs := map[string]bool{}
+	for _, file := range r.files() {
+		dirs[filepath.Dir(file)] = true
+	}
+	for dir := range dirs {
+		if err := watcher.Add(dir); err != nil {
+			watcher.Close()
+			return nil, fmt.Errorf("unable to watch %v, %v", dir, err)
+		}
+	}
+
+	go r.watch()
+	return r, nil
+}
+
+// configured files
+func (r *Reloader) files() []string {
+	var files []string
+	for _, file := range []string{r.certFile, r.keyFile, r.caFile} {
+		if file != "" {
+			files = append(files, file)
+		}
+	}
+	return files
+}
+
+// Reload reads the files, keeping the previous certificates if they are invalid
+func (r *Reloader) Reload() error {
+	pair, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("unable to load certificate, %v", err)
+	}
+	cert := &pair
+
+	var pool *x509.CertPool
+	if r.caFile != "" {
+		pem, err := os.ReadFile(r.caFile)
+		if err != nil {
+			return fmt.Errorf("unable to read CA file, %v", err)
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificates found in CA file %v", r.caFile)
+		}
+	}
+
+	r.mu.Lock()
+	r.cert, r.pool = cert, pool
+	r.mu.Unlock()
+	return nil
+}
+
+// reload on any change in the watched directories
+func (r *Reloader) watch() {
+	for {
+		select {
+		case _, ok := <-r.watcher.Events:
+			if !ok {
+				return
+			}
+			if err := r.Reload(); err != nil {
+				log.Warn("certificate reload failed, keeping previous certificate", "err", err)
+			}
+		case err, ok := <-r.watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Warn("certificate watch failed", "err", err)
+		}
+	}
+}
+
+// Close stops watching for changes
+func (r *Reloader) Close() error {
+	return r.watcher.Close()
+}
+
+// Certificate returns the current certificate
+func (r *Reloader) Certificate() *tls.Certificate {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert
+}
+
+// Pool returns the current CA pool, nil when no CA file is set
+func (r *Reloader) Pool() *x509.CertPool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.pool
+}
+
+// ServerConfig serves the current certificate, requiring and verifying
+// client certificates against the CA pool when one is set. Protocols set
+// in the config's NextProtos are offered through ALPN.
+func (r *Reloader) ServerConfig() *tls.Config {
+	server := &tls.Config{MinVersion: tls.VersionTLS12}
+	server.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		config := &tls.Config{
+			MinVersion:   tls.VersionTLS12,
+			Certificates: []tls.Certificate{*r.Certificate()},
+			NextProtos:   server.NextProtos,
+		}
+		if pool := r.Pool(); pool != nil {
+			config.ClientCAs = pool
+			config.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+		return config, nil
+	}
+	return server
+}
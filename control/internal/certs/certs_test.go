@@ -0,0 +1,127 @@
+package certs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/christian-nickerson/pangolin/control/internal/certs/certstest"
+)
+
+// serve TLS on a local listener, completing handshakes for each connection
+func serve(t *testing.T, config *tls.Config) string {
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", config)
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.(*tls.Conn).Handshake()
+			conn.Close()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+// dial and return the serial of the certificate served
+func served(address string, config *tls.Config) (*big.Int, error) {
+	conn, err := tls.Dial("tcp", address, config)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return conn.ConnectionState().PeerCertificates[0].SerialNumber, nil
+}
+
+// assert certificates are reloaded when their files change
+func TestReload(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+
+	ca, caKey, caPEM, _ := certstest.Issue(t, "ca", nil, nil)
+	first, _, certPEM, keyPEM := certstest.Issue(t, "first", ca, caKey)
+	require.NoError(t, os.WriteFile(certFile, certPEM, 0o600))
+	require.NoError(t, os.WriteFile(keyFile, keyPEM, 0o600))
+
+	reloader, err := NewReloader(certFile, keyFile, "")
+	require.NoError(t, err)
+	defer reloader.Close()
+
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(caPEM)
+	client := &tls.Config{RootCAs: pool}
+	address := serve(t, reloader.ServerConfig())
+
+	serial, err := served(address, client)
+	require.NoError(t, err)
+	assert.Equal(t, first.SerialNumber, serial)
+
+	second, _, certPEM, keyPEM := certstest.Issue(t, "second", ca, caKey)
+	require.NoError(t, os.WriteFile(keyFile, keyPEM, 0o600))
+	require.NoError(t, os.WriteFile(certFile, certPEM, 0o600))
+
+	assert.Eventually(t, func() bool {
+		serial, err := served(address, client)
+		return err == nil && serial.Cmp(second.SerialNumber) == 0
+	}, 5*time.Second, 50*time.Millisecond)
+}
+
+// assert a CA file requires clients to present a verified certificate
+func TestMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile, caFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key"), filepath.Join(dir, "ca.crt")
+
+	ca, caKey, caPEM, _ := certstest.Issue(t, "ca", nil, nil)
+	_, _, certPEM, keyPEM := certstest.Issue(t, "server", ca, caKey)
+	_, _, clientCertPEM, clientKeyPEM := certstest.Issue(t, "client", ca, caKey)
+	require.NoError(t, os.WriteFile(certFile, certPEM, 0o600))
+	require.NoError(t, os.WriteFile(keyFile, keyPEM, 0o600))
+	require.NoError(t, os.WriteFile(caFile, caPEM, 0o600))
+
+	reloader, err := NewReloader(certFile, keyFile, caFile)
+	require.NoError(t, err)
+	defer reloader.Close()
+	address := serve(t, reloader.ServerConfig())
+
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(caPEM)
+	clientCert, err := tls.X509KeyPair(clientCertPEM, clientKeyPEM)
+	require.NoError(t, err)
+
+	_, err = served(address, &tls.Config{RootCAs: pool, Certificates: []tls.Certificate{clientCert}})
+	assert.NoError(t, err)
+
+	// TLS 1.3 reports client certificate failures on first read
+	conn, err := tls.Dial("tcp", address, &tls.Config{RootCAs: pool})
+	if err == nil {
+		_, err = conn.Read(make([]byte, 1))
+		conn.Close()
+	}
+	assert.Error(t, err)
+}
+
+// assert invalid files are rejected at start up
+func TestNewReloaderInvalid(t *testing.T) {
+	_, err := NewReloader(filepath.Join(t.TempDir(), "missing.crt"), "missing.key", "")
+	assert.Error(t, err)
+}
+
+// assert tls without a certificate is rejected at start up
+func TestNewReloaderMissing(t *testing.T) {
+	_, err := NewReloader("", "", "")
+	assert.ErrorIs(t, err, ErrMissingCertificate)
+
+	_, err = NewReloader("server.crt", "", "")
+	assert.ErrorIs(t, err, ErrMissingCertificate)
+}
@@ -0,0 +1,24 @@
+package chunking
+
+import "strings"
+
+// Split breaks text into chunks of up to size words, each starting overlap
+// words before the end of the previous chunk. Words approximate model tokens.
+func Split(text string, size, overlap int) []string {
+	words := strings.Fields(text)
+	if len(words) == 0 || size <= 0 {
+		return nil
+	}
+	if overlap < 0 || overlap >= size {
+		overlap = 0
+	}
+
+	var chunks []string
+	for start := 0; ; start += size - overlap {
+		end := min(start+size, len(words))
+		chunks = append(chunks, strings.Join(words[start:end], " "))
+		if end == len(words) {
+			return chunks
+		}
+	}
+}
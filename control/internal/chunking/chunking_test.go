@@ -0,0 +1,24 @@
+package chunking
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// assert text is split into overlapping windows of words
+func TestSplit(t *testing.T) {
+	chunks := Split("a b c d e f g", 3, 1)
+	assert.Equal(t, []string{"a b c", "c d e", "e f g"}, chunks)
+}
+
+// assert short and empty text are handled
+func TestSplitShort(t *testing.T) {
+	assert.Equal(t, []string{"a b"}, Split("  a \n b ", 3, 0))
+	assert.Nil(t, Split("   ", 3, 0))
+}
+
+// assert an overlap not smaller than the size is ignored
+func TestSplitInvalidOverlap(t *testing.T) {
+	assert.Equal(t, []string{"a b", "c"}, Split("a b c", 2, 2))
+}
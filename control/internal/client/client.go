@@ -0,0 +1,340 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-json"
+
+	"github.com/christian-nickerson/pangolin/control/internal/configs"
+	"github.com/christian-nickerson/pangolin/control/internal/consensus"
+	"github.com/christian-nickerson/pangolin/control/internal/models"
+	"github.com/christian-nickerson/pangolin/control/internal/search"
+	"github.com/christian-nickerson/pangolin/control/internal/snapshots"
+)
+
+// times a rate limited request is sent again, after the delay the server
+// asks for
+const rateLimitRetries = 5
+
+// Client calls the Pangolin REST API with an API key
+type Client struct {
+	baseURL string
+	key     string
+	http    *http.Client
+}
+
+// New creates a client for the API at baseURL, such as http://localhost:3000
+func New(baseURL, key string) *Client {
+	return &Client{baseURL: strings.TrimSuffix(baseURL, "/"), key: key, http: &http.Client{}}
+}
+
+// Address returns the URL of the configured API server
+func Address(config configs.APIConfig) string {
+	scheme := "http"
+	if config.TLS.Enabled {
+		scheme = "https"
+	}
+	return scheme + "://" + net.JoinHostPort(config.Host, strconv.Itoa(config.Port))
+}
+
+// Key returns the API key from PANGOLIN_API_KEY, falling back to the
+// configured admin key
+func Key(config configs.Auth) string {
+	if key := os.Getenv("PANGOLIN_API_KEY"); key != "" {
+		return key
+	}
+	return config.AdminKey
+}
+
+// Error is an unsuccessful API response
+type Error struct {
+	Status  int
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%v %v", e.Status, e.Message)
+}
+
+// send a request, decoding a JSON response into out when given
+func (c *Client) do(ctx context.Context, method, path, contentType string, body io.Reader, out any) error {
+	response, err := c.send(ctx, method, path, contentType, body)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(response.Body).Decode(out)
+}
+
+// send a request, returning an Error for unsuccessful responses. Rate
+// limited requests are sent again once the server allows.
+func (c *Client) send(ctx context.Context, method, path, contentType string, body io.Reader) (*http.Response, error) {
+	request, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if c.key != "" {
+		request.Header.Set("Authorization", "Bearer "+c.key)
+	}
+	if contentType != "" {
+		request.Header.Set("Content-Type", contentType)
+	}
+
+	response, err := c.http.Do(request)
+	for attempt := 0; err == nil && attempt < rateLimitRetries && retryable(request, response); attempt++ {
+		response.Body.Close()
+		if err := wait(ctx, retryAfter(response)); err != nil {
+			return nil, err
+		}
+		request = request.Clone(ctx)
+		if request.GetBody != nil {
+			if request.Body, err = request.GetBody(); err != nil {
+				return nil, err
+			}
+		}
+		response, err = c.http.Do(request)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode < 300 {
+		return response, nil
+	}
+	defer response.Body.Close()
+
+	var failure struct {
+		Error string `json:"error"`
+	}
+	data, _ := io.ReadAll(response.Body)
+	if json.Unmarshal(data, &failure) != nil || failure.Error == "" {
+		failure.Error = strings.TrimSpace(string(data))
+	}
+	return nil, &Error{Status: response.StatusCode, Message: failure.Error}
+}
+
+// whether a rate limited request can be sent again, which needs a body
+// that can be read again, such as a buffer
+func retryable(request *http.Request, response *http.Response) bool {
+	return response.StatusCode == http.StatusTooManyRequests && (request.Body == nil || request.GetBody != nil)
+}
+
+// the delay a rate limited response asks for, in seconds or until a date
+func retryAfter(response *http.Response) time.Duration {
+	header := response.Header.Get("Retry-After")
+	if seconds, err := strconv.Atoi(header); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(header); err == nil {
+		return time.Until(date)
+	}
+	return time.Second
+}
+
+// sleep for delay, returning early when ctx is done
+func wait(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// encode a JSON request body
+func jsonBody(body any) (io.Reader, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(data), nil
+}
+
+// ListCollections lists the collections the key may read
+func (c *Client) ListCollections(ctx context.Context) ([]models.Collection, error) {
+	var all []models.Collection
+	return all, c.do(ctx, http.MethodGet, "/collections", "", nil, &all)
+}
+
+// CreateCollection creates a collection from its name and settings
+func (c *Client) CreateCollection(ctx context.Context, collection models.Collection) (*models.Collection, error) {
+	body, err := jsonBody(map[string]any{
+		"name":          collection.Name,
+		"model":         collection.Model,
+		"chunk_size":    collection.ChunkSize,
+		"chunk_overlap": collection.ChunkOverlap,
+		"metric":        collection.Metric,
+		"shards":        collection.Shards,
+		"replicas":      collection.Replicas,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var created models.Collection
+	return &created, c.do(ctx, http.MethodPost, "/collections", "application/json", body, &created)
+}
+
+// DeleteCollection deletes a collection with its documents
+func (c *Client) DeleteCollection(ctx context.Context, collection string) error {
+	return c.do(ctx, http.MethodDelete, "/collections/"+url.PathEscape(collection), "", nil, nil)
+}
+
+// ListDocuments lists a page of a collection's documents ordered by id
+func (c *Client) ListDocuments(ctx context.Context, collection string, limit, offset int) ([]models.Document, error) {
+	query := url.Values{"limit": {strconv.Itoa(limit)}, "offset": {strconv.Itoa(offset)}}
+	var all []models.Document
+	return all, c.do(ctx, http.MethodGet, documentsPath(collection)+"?"+query.Encode(), "", nil, &all)
+}
+
+// GetDocument gets a document by id
+func (c *Client) GetDocument(ctx context.Context, collection, id string) (*models.Document, error) {
+	var document models.Document
+	return &document, c.do(ctx, http.MethodGet, documentsPath(collection)+"/"+url.PathEscape(id), "", nil, &document)
+}
+
+// DocumentChunks lists a document's chunks in position order
+func (c *Client) DocumentChunks(ctx context.Context, collection, id string) ([]models.Chunk, error) {
+	var chunks []models.Chunk
+	return chunks, c.do(ctx, http.MethodGet, documentsPath(collection)+"/"+url.PathEscape(id)+"/chunks", "", nil, &chunks)
+}
+
+// UploadDocument uploads a file for text extraction and ingestion as the
+// document id, the file name when id is empty
+func (c *Client) UploadDocument(ctx context.Context, collection, id, filename string, file io.Reader) (*models.Job, error) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	if id != "" {
+		if err := form.WriteField("id", id); err != nil {
+			return nil, err
+		}
+	}
+	part, err := form.CreateFormFile("file", filename)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(part, file); err != nil {
+		return nil, err
+	}
+	if err := form.Close(); err != nil {
+		return nil, err
+	}
+
+	var job models.Job
+	return &job, c.do(ctx, http.MethodPost, documentsPath(collection)+"/upload", form.FormDataContentType(), &body, &job)
+}
+
+func documentsPath(collection string) string {
+	return "/collections/" + url.PathEscape(collection) + "/documents"
+}
+
+// Search returns a collection's k chunks nearest to query
+func (c *Client) Search(ctx context.Context, collection, query string, k int) (*search.Response, error) {
+	body, err := jsonBody(map[string]any{"query": query, "k": k})
+	if err != nil {
+		return nil, err
+	}
+
+	var response search.Response
+	return &response, c.do(ctx, http.MethodPost, "/collections/"+url.PathEscape(collection)+"/search", "application/json", body, &response)
+}
+
+// ListJobs lists the most recent ingestion jobs
+func (c *Client) ListJobs(ctx context.Context, limit int) ([]models.Job, error) {
+	var all []models.Job
+	return all, c.do(ctx, http.MethodGet, "/jobs?limit="+strconv.Itoa(limit), "", nil, &all)
+}
+
+// ListNodes lists the registered index nodes, requires the cluster admin key
+func (c *Client) ListNodes(ctx context.Context) ([]models.Node, error) {
+	var all []models.Node
+	return all, c.do(ctx, http.MethodGet, "/nodes", "", nil, &all)
+}
+
+// ClusterStatus reports the control plane's Raft state, requires the
+// cluster admin key and fails with 404 when replication is disabled
+func (c *Client) ClusterStatus(ctx context.Context) (*consensus.Status, error) {
+	var status consensus.Status
+	return &status, c.do(ctx, http.MethodGet, "/admin/cluster", "", nil, &status)
+}
+
+// CreateKey creates an API key in the namespace of the calling admin key,
+// returning the key with its secret, which is only shown once
+func (c *Client) CreateKey(ctx context.Context, name string, scope models.Scope, collections []string) (*models.APIKey, string, error) {
+	body, err := jsonBody(map[string]any{"name": name, "scope": scope, "collections": collections})
+	if err != nil {
+		return nil, "", err
+	}
+
+	var issued struct {
+		models.APIKey
+		Key string `json:"key"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/admin/keys", "application/json", body, &issued); err != nil {
+		return nil, "", err
+	}
+	return &issued.APIKey, issued.Key, nil
+}
+
+// RevokeKey revokes an API key by id
+func (c *Client) RevokeKey(ctx context.Context, id uint) (*models.APIKey, error) {
+	var key models.APIKey
+	return &key, c.do(ctx, http.MethodDelete, "/admin/keys/"+strconv.FormatUint(uint64(id), 10), "", nil, &key)
+}
+
+// CreateSnapshot snapshots a collection on the server
+func (c *Client) CreateSnapshot(ctx context.Context, collection string) (*snapshots.Manifest, error) {
+	body, err := jsonBody(map[string]string{"collection": collection})
+	if err != nil {
+		return nil, err
+	}
+
+	var manifest snapshots.Manifest
+	return &manifest, c.do(ctx, http.MethodPost, "/admin/snapshots", "application/json", body, &manifest)
+}
+
+// DownloadSnapshot writes a snapshot's archive to w
+func (c *Client) DownloadSnapshot(ctx context.Context, id string, w io.Writer) error {
+	response, err := c.send(ctx, http.MethodGet, "/admin/snapshots/"+url.PathEscape(id), "", nil)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	_, err = io.Copy(w, response.Body)
+	return err
+}
+
+// UploadSnapshot sends an archive to the server, which verifies and keeps it
+func (c *Client) UploadSnapshot(ctx context.Context, archive io.Reader) (*snapshots.Manifest, error) {
+	var manifest snapshots.Manifest
+	return &manifest, c.do(ctx, http.MethodPost, "/admin/snapshots/upload", "application/gzip", archive, &manifest)
+}
+
+// RestoreSnapshot restores a snapshot into a new collection, named as
+// the snapshotted collection when name is empty
+func (c *Client) RestoreSnapshot(ctx context.Context, id, name string) (*models.Collection, error) {
+	body, err := jsonBody(map[string]string{"name": name})
+	if err != nil {
+		return nil, err
+	}
+
+	var collection models.Collection
+	return &collection, c.do(ctx, http.MethodPost, "/admin/snapshots/"+url.PathEscape(id)+"/restore", "application/json", body, &collection)
+}
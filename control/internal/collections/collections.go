@@ -0,0 +1,133 @@
+package collections
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/christian-nickerson/pangolin/control/internal/consensus"
+	"github.com/christian-nickerson/pangolin/control/internal/database"
+	"github.com/christian-nickerson/pangolin/control/internal/models"
+	"github.com/christian-nickerson/pangolin/control/internal/namespaces"
+	"github.com/christian-nickerson/pangolin/control/internal/shards"
+)
+
+var (
+	ErrCollectionNotFound = errors.New("collection not found")
+	ErrCollectionExists   = errors.New("collection already exists")
+)
+
+// BlobPrefix is the blob key prefix holding a collection's files
+func BlobPrefix(collectionID uint) string {
+	return "collections/" + strconv.FormatUint(uint64(collectionID), 10)
+}
+
+// Create stores a new collection in the namespace, enforcing its collection
+// quota. The shards of sharded collections are placed on index nodes.
+func Create(ctx context.Context, namespace *models.Namespace, collection *models.Collection) error {
+	if _, err := Get(ctx, namespace, collection.Name); err == nil {
+		return ErrCollectionExists
+	}
+	if err := namespaces.CheckCollections(ctx, namespace); err != nil {
+		return err
+	}
+
+	// restored collections arrive holding documents, which count too
+	collection.NamespaceID = namespace.ID
+	err := database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		requested := models.Usage{Collections: 1, Documents: collection.DocumentCount, VectorBytes: collection.VectorBytes}
+		if err := namespaces.Reserve(tx, namespace.ID, requested); err != nil {
+			return err
+		}
+		if err := tx.Create(collection).Error; err != nil {
+			return err
+		}
+		return consensus.Publish(tx, collection)
+	})
+	if err != nil {
+		return err
+	}
+
+	// a collection left without shards would count against the quota
+	if err := shards.Place(ctx, collection); err != nil {
+		if cleanup := Delete(ctx, collection); cleanup != nil {
+			err = errors.Join(err, fmt.Errorf("unable to remove collection %v without shards, %w", collection.Name, cleanup))
+		}
+		return err
+	}
+	return nil
+}
+
+// List returns all collections in the namespace
+func List(ctx context.Context, namespace *models.Namespace) ([]models.Collection, error) {
+	var collections []models.Collection
+	err := database.DB.WithContext(ctx).
+		Where("namespace_id = ?", namespace.ID).
+		Order("name").
+		Find(&collections).Error
+	return collections, err
+}
+
+// All returns the collections of every namespace
+func All(ctx context.Context) ([]models.Collection, error) {
+	var collections []models.Collection
+	err := database.DB.WithContext(ctx).Order("id").Find(&collections).Error
+	return collections, err
+}
+
+// Get returns a collection in the namespace by name
+func Get(ctx context.Context, namespace *models.Namespace, name string) (*models.Collection, error) {
+	var collection models.Collection
+	err := database.DB.WithContext(ctx).
+		Where("namespace_id = ? AND name = ?", namespace.ID, name).
+		First(&collection).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrCollectionNotFound
+	}
+	return &collection, err
+}
+
+// GetByID returns a collection in the namespace by id
+func GetByID(ctx context.Context, namespace *models.Namespace, id uint) (*models.Collection, error) {
+	var collection models.Collection
+	err := database.DB.WithContext(ctx).
+		Where("namespace_id = ? AND id = ?", namespace.ID, id).
+		First(&collection).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrCollectionNotFound
+	}
+	return &collection, err
+}
+
+// Delete removes a collection from the namespace along with its
+// documents, chunks, pending jobs and index or shards
+func Delete(ctx context.Context, collection *models.Collection) error {
+	err := database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, model := range []any{&models.Chunk{}, &models.Document{}} {
+			if err := tx.Where("collection_id = ?", collection.ID).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+
+		// running jobs fail on their next write, the collection is gone
+		err := tx.Model(&models.Job{}).
+			Where("collection_id = ? AND status = ?", collection.ID, models.JobQueued).
+			Updates(map[string]any{"status": models.JobCancelled, "finished_at": time.Now()}).Error
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Delete(collection).Error; err != nil {
+			return err
+		}
+		return consensus.Publish(tx, collection)
+	})
+	if err != nil {
+		return err
+	}
+	return shards.Drop(ctx, collection)
+}
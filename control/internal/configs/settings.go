@@ -10,13 +10,28 @@ import (
 
 // Settings configurations
 type Settings struct {
-	Server   Server   `mapstructure:"server"`
-	Metadata Metadata `mapstructure:"metadata"`
+	Server     Server     `mapstructure:"server"`
+	Metadata   Metadata   `mapstructure:"metadata"`
+	Health     Health     `mapstructure:"health"`
+	Tracing    Tracing    `mapstructure:"tracing"`
+	Logging    Logging    `mapstructure:"logging"`
+	Auth       Auth       `mapstructure:"auth"`
+	Namespaces Namespaces `mapstructure:"namespaces"`
+	RateLimit  RateLimit  `mapstructure:"rate_limit"`
+	Shutdown   Shutdown   `mapstructure:"shutdown"`
+	Jobs       Jobs       `mapstructure:"jobs"`
+	Storage    Storage    `mapstructure:"storage"`
+	Index      Index      `mapstructure:"index"`
+	Snapshots  Snapshots  `mapstructure:"snapshots"`
+	Search     Search     `mapstructure:"search"`
+	Cluster    Cluster    `mapstructure:"cluster"`
+	HA         HA         `mapstructure:"ha"`
+	MCP        MCP        `mapstructure:"mcp"`
 }
 
 type Server struct {
-	Embeddings ServerConfig `mapstructure:"embeddings"`
-	API        ServerConfig `mapstructure:"api"`
+	Embeddings EmbeddingsConfig `mapstructure:"embeddings"`
+	API        APIConfig        `mapstructure:"api"`
 }
 
 type Metadata struct {
@@ -26,9 +41,244 @@ type Metadata struct {
 // ServerConfig server configurations
 type ServerConfig struct {
 	Name string `mapstructure:"name"`
+	Host string `mapstructure:"host"`
 	Port int    `mapstructure:"port"`
 }
 
+// APIConfig HTTP server configurations, timeouts in seconds
+type APIConfig struct {
+	ServerConfig `mapstructure:",squash"`
+	BodyLimit    int       `mapstructure:"body_limit"`
+	ReadTimeout  int       `mapstructure:"read_timeout"`
+	WriteTimeout int       `mapstructure:"write_timeout"`
+	IdleTimeout  int       `mapstructure:"idle_timeout"`
+	TLS          TLSConfig `mapstructure:"tls"`
+}
+
+// TLSConfig certificate paths, setting a CA file enables mutual TLS
+type TLSConfig struct {
+	Enabled  bool   `mapstructure:"enabled"`
+	CertFile string `mapstructure:"cert_file"`
+	KeyFile  string `mapstructure:"key_file"`
+	CAFile   string `mapstructure:"ca_file"`
+}
+
+// EmbeddingsConfig model server connection configurations
+type EmbeddingsConfig struct {
+	ServerConfig `mapstructure:",squash"`
+	TLS          ChannelTLSConfig `mapstructure:"tls"`
+	Token        string           `mapstructure:"token"`
+}
+
+// ChannelTLSConfig gRPC transport security, mode is insecure, tls or mtls.
+// Each side sets its own certificate and the CA verifying its peer.
+type ChannelTLSConfig struct {
+	Mode       string `mapstructure:"mode"`
+	CAFile     string `mapstructure:"ca_file"`
+	CertFile   string `mapstructure:"cert_file"`
+	KeyFile    string `mapstructure:"key_file"`
+	ServerName string `mapstructure:"server_name"`
+}
+
+// Shutdown periods in seconds, readiness fails for the drain period before
+// the server stops, and the process exits if teardown exceeds the timeout
+type Shutdown struct {
+	DrainPeriod int `mapstructure:"drain_period"`
+	Timeout     int `mapstructure:"timeout"`
+}
+
+// Storage blob store for original documents, chunk payloads and index
+// segments, the backend is filesystem (rooted at path) or s3
+type Storage struct {
+	Backend string   `mapstructure:"backend"`
+	Path    string   `mapstructure:"path"`
+	S3      S3Config `mapstructure:"s3"`
+}
+
+// S3Config S3 compatible object store connection
+type S3Config struct {
+	Endpoint  string `mapstructure:"endpoint"`
+	Bucket    string `mapstructure:"bucket"`
+	Region    string `mapstructure:"region"`
+	AccessKey string `mapstructure:"access_key"`
+	SecretKey string `mapstructure:"secret_key"`
+	Secure    bool   `mapstructure:"secure"`
+}
+
+// Index persistence configurations, the flush interval in seconds. Logged
+// writes are flushed into a segment each interval, or once flush_entries
+// are pending, and segments are merged once there are more than max_segments.
+type Index struct {
+	FlushInterval int `mapstructure:"flush_interval"`
+	FlushEntries  int `mapstructure:"flush_entries"`
+	MaxSegments   int `mapstructure:"max_segments"`
+}
+
+// Snapshots archive limits in bytes. Uploaded archives may be at most
+// max_size, and the files of any archive restored or uploaded may expand
+// to at most max_expanded_size once decompressed.
+type Snapshots struct {
+	MaxSize         int64 `mapstructure:"max_size"`
+	MaxExpandedSize int64 `mapstructure:"max_expanded_size"`
+}
+
+// Search recall sampling, the fraction of searches checked in the
+// background against a brute force search of every vector
+type Search struct {
+	RecallSampleRate float64 `mapstructure:"recall_sample_rate"`
+}
+
+// Cluster index node membership configurations, durations in seconds.
+// Nodes register with the registry served on host and port, heartbeat each
+// interval, and are suspect after suspect_after and dead after dead_after
+// without a heartbeat. Setting a token requires nodes to present it.
+// Sharded searches wait shard_timeout for each shard, and fail unless at
+// least min_coverage of shards answer, otherwise results are partial.
+// Shards missing replicas are repaired when a node is lost, and each
+// repair_interval. Calls to index nodes time out after rpc_timeout and
+// transient failures are retried rpc_retries times.
+type Cluster struct {
+	Enabled           bool      `mapstructure:"enabled"`
+	Host              string    `mapstructure:"host"`
+	Port              int       `mapstructure:"port"`
+	Token             string    `mapstructure:"token"`
+	TLS               TLSConfig `mapstructure:"tls"`
+	HeartbeatInterval int       `mapstructure:"heartbeat_interval"`
+	SuspectAfter      int       `mapstructure:"suspect_after"`
+	DeadAfter         int       `mapstructure:"dead_after"`
+	ShardTimeout      int       `mapstructure:"shard_timeout"`
+	MinCoverage       float64   `mapstructure:"min_coverage"`
+	RepairInterval    int       `mapstructure:"repair_interval"`
+	RPCTimeout        int       `mapstructure:"rpc_timeout"`
+	RPCRetries        int       `mapstructure:"rpc_retries"`
+	Rebalance         Rebalance `mapstructure:"rebalance"`
+	Node              LocalNode `mapstructure:"node"`
+}
+
+// LocalNode is an index node run inside the control plane process, served
+// on host and port and registered as id hosting up to max_shards shards
+// (zero is unlimited)
+type LocalNode struct {
+	Enabled   bool   `mapstructure:"enabled"`
+	ID        string `mapstructure:"id"`
+	Host      string `mapstructure:"host"`
+	Port      int    `mapstructure:"port"`
+	MaxShards int    `mapstructure:"max_shards"`
+}
+
+// Rebalance shard move throttling, copying at most vectors_per_second
+// (zero is unlimited) and pausing for pause seconds between moves
+type Rebalance struct {
+	VectorsPerSecond int `mapstructure:"vectors_per_second"`
+	Pause            int `mapstructure:"pause"`
+}
+
+// HA control plane replication configurations, durations in seconds.
+// Each instance is the Raft voter id among peers, serving the transport on
+// host and port and keeping its log and snapshots in data_dir. Cluster
+// metadata changes commit within apply_timeout, and followers apply them
+// to their own database unless it is shared with the leader. Documents,
+// jobs and unsharded indexes are only written by the leader, so failover
+// requires every instance to share the database and blob store.
+type HA struct {
+	Enabled        bool   `mapstructure:"enabled"`
+	ID             string `mapstructure:"id"`
+	Host           string `mapstructure:"host"`
+	Port           int    `mapstructure:"port"`
+	DataDir        string `mapstructure:"data_dir"`
+	ApplyTimeout   int    `mapstructure:"apply_timeout"`
+	SharedDatabase bool   `mapstructure:"shared_database"`
+	Peers          []Peer `mapstructure:"peers"`
+}
+
+// MCP Model Context Protocol server configurations. The streamable HTTP
+// transport is served on the API and authenticates like any other route.
+// The stdio transport serves one agent on stdin and stdout, acting with
+// api_key within namespace, and shuts the process down when stdin closes.
+// Browsers may only reach the HTTP transport from loopback origins or those
+// in allowed_origins, so web pages cannot use DNS rebinding to call tools.
+type MCP struct {
+	Enabled        bool     `mapstructure:"enabled"`
+	Stdio          bool     `mapstructure:"stdio"`
+	APIKey         string   `mapstructure:"api_key"`
+	Namespace      string   `mapstructure:"namespace"`
+	AllowedOrigins []string `mapstructure:"allowed_origins"`
+}
+
+// Peer is a control plane instance, reached by other instances at its
+// Raft address and by clients at its API URL
+type Peer struct {
+	ID      string `mapstructure:"id"`
+	Address string `mapstructure:"address"`
+	API     string `mapstructure:"api"`
+}
+
+// Jobs ingestion worker pool configurations, durations in seconds.
+// Failed jobs retry after backoff, doubling up to max_backoff.
+type Jobs struct {
+	Workers      int `mapstructure:"workers"`
+	MaxAttempts  int `mapstructure:"max_attempts"`
+	Backoff      int `mapstructure:"backoff"`
+	MaxBackoff   int `mapstructure:"max_backoff"`
+	PollInterval int `mapstructure:"poll_interval"`
+	BatchSize    int `mapstructure:"batch_size"`
+}
+
+// Health readiness probe configurations, in seconds
+type Health struct {
+	Timeout     int `mapstructure:"timeout"`
+	CachePeriod int `mapstructure:"cache_period"`
+}
+
+// Auth API key configurations, the admin key bootstraps key creation
+type Auth struct {
+	Enabled  bool   `mapstructure:"enabled"`
+	AdminKey string `mapstructure:"admin_key"`
+}
+
+// Namespaces tenancy configurations
+type Namespaces struct {
+	DefaultQuotas Quotas `mapstructure:"default_quotas"`
+}
+
+// Quotas namespace limits, zero is unlimited
+type Quotas struct {
+	MaxCollections int64 `mapstructure:"max_collections"`
+	MaxDocuments   int64 `mapstructure:"max_documents"`
+	MaxVectorBytes int64 `mapstructure:"max_vector_bytes"`
+}
+
+// RateLimit token bucket budgets per API key or client IP, and of failed
+// authentications per client IP
+type RateLimit struct {
+	Enabled  bool   `mapstructure:"enabled"`
+	Read     Bucket `mapstructure:"read"`
+	Write    Bucket `mapstructure:"write"`
+	Failures Bucket `mapstructure:"failures"`
+}
+
+// Bucket refills at rate tokens per second, holding up to burst tokens
+type Bucket struct {
+	Rate  float64 `mapstructure:"rate"`
+	Burst int     `mapstructure:"burst"`
+}
+
+// Logging level and output format (text, json or logfmt)
+type Logging struct {
+	Level  string `mapstructure:"level"`
+	Format string `mapstructure:"format"`
+}
+
+// Tracing OpenTelemetry exporter configurations
+type Tracing struct {
+	Enabled     bool    `mapstructure:"enabled"`
+	Exporter    string  `mapstructure:"exporter"`
+	Endpoint    string  `mapstructure:"endpoint"`
+	Insecure    bool    `mapstructure:"insecure"`
+	File        string  `mapstructure:"file"`
+	SampleRatio float64 `mapstructure:"sample_ratio"`
+}
+
 type DatabaseConfig struct {
 	Type     string `mapstructure:"type"`
 	Host     string `mapstructure:"host"`
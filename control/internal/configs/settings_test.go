@@ -56,3 +56,27 @@ func TestFileNameSplitTOML(t *testing.T) {
 	assert.Equal(t, fileBase, "settings")
 	assert.Equal(t, fileType, "toml")
 }
+
+// assert the default settings are valid and invalid values are reported
+func TestValidate(t *testing.T) {
+	t.Setenv("PANGOLIN_AUTH__ADMIN_KEY", "admin")
+	settings, err := Load("settings.toml")
+	assert.NoError(t, err)
+	assert.NoError(t, Validate(settings))
+
+	settings.Server.API.Port = 0
+	settings.Metadata.Database.Type = "oracle"
+	settings.Shutdown.DrainPeriod = settings.Shutdown.Timeout
+	settings.Auth.AdminKey = ""
+	settings.Cluster.Enabled = true
+	settings.Cluster.HeartbeatInterval = 0
+	settings.HA.Enabled = true
+	settings.HA.SharedDatabase = false
+	err = Validate(settings)
+	assert.ErrorContains(t, err, "server.api.port")
+	assert.ErrorContains(t, err, "metadata.database.type")
+	assert.ErrorContains(t, err, "shutdown.drain_period")
+	assert.ErrorContains(t, err, "auth.admin_key")
+	assert.ErrorContains(t, err, "cluster.heartbeat_interval must be positive")
+	assert.ErrorContains(t, err, "ha.shared_database")
+}
@@ -0,0 +1,87 @@
+package configs
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// Validate checks settings for values the control plane cannot start
+// with, returning every problem found
+func Validate(settings Settings) error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+	port := func(name string, port int) {
+		check(port > 0 && port < 65536, "%v must be between 1 and 65535, got %v", name, port)
+	}
+	oneOf := func(name, value string, allowed ...string) {
+		check(slices.Contains(allowed, value), "%v must be one of %q, got %q", name, allowed, value)
+	}
+	tls := func(name string, config TLSConfig) {
+		check(!config.Enabled || (config.CertFile != "" && config.KeyFile != ""), "%v.cert_file and %v.key_file are required when enabled", name, name)
+	}
+
+	// servers
+	port("server.api.port", settings.Server.API.Port)
+	tls("server.api.tls", settings.Server.API.TLS)
+	port("server.embeddings.port", settings.Server.Embeddings.Port)
+	oneOf("server.embeddings.tls.mode", settings.Server.Embeddings.TLS.Mode, "", "insecure", "tls", "mtls")
+
+	// dependencies
+	database := settings.Metadata.Database
+	oneOf("metadata.database.type", database.Type, "sqlite", "postgres")
+	check(database.DBName != "", "metadata.database.dbname is required")
+	oneOf("storage.backend", settings.Storage.Backend, "filesystem", "s3")
+	check(settings.Storage.Backend != "s3" || settings.Storage.S3.Bucket != "", "storage.s3.bucket is required for the s3 backend")
+	oneOf("logging.format", settings.Logging.Format, "text", "json", "logfmt")
+	if settings.Tracing.Enabled {
+		oneOf("tracing.exporter", settings.Tracing.Exporter, "otlp", "stdout", "file")
+		check(settings.Tracing.SampleRatio >= 0 && settings.Tracing.SampleRatio <= 1, "tracing.sample_ratio must be between 0 and 1")
+	}
+
+	// behaviour
+	if settings.RateLimit.Enabled {
+		bucket := func(name string, bucket Bucket) {
+			check(bucket.Rate > 0, "rate_limit.%v.rate must be positive", name)
+			check(bucket.Burst >= 1, "rate_limit.%v.burst must be at least 1", name)
+		}
+		bucket("read", settings.RateLimit.Read)
+		bucket("write", settings.RateLimit.Write)
+		bucket("failures", settings.RateLimit.Failures)
+	}
+	check(!settings.Auth.Enabled || settings.Auth.AdminKey != "", "auth.admin_key is required when auth is enabled")
+	check(settings.Jobs.Workers > 0, "jobs.workers must be positive")
+	check(settings.Snapshots.MaxSize > 0, "snapshots.max_size must be positive")
+	check(settings.Snapshots.MaxExpandedSize > 0, "snapshots.max_expanded_size must be positive")
+	check(settings.Search.RecallSampleRate >= 0 && settings.Search.RecallSampleRate <= 1, "search.recall_sample_rate must be between 0 and 1")
+	check(settings.Shutdown.DrainPeriod < settings.Shutdown.Timeout, "shutdown.drain_period must be shorter than shutdown.timeout")
+
+	// clustering
+	if cluster := settings.Cluster; cluster.Enabled {
+		port("cluster.port", cluster.Port)
+		tls("cluster.tls", cluster.TLS)
+		check(cluster.HeartbeatInterval > 0, "cluster.heartbeat_interval must be positive")
+		check(cluster.HeartbeatInterval < cluster.SuspectAfter, "cluster.heartbeat_interval must be shorter than cluster.suspect_after")
+		check(cluster.ShardTimeout > 0, "cluster.shard_timeout must be positive")
+		check(cluster.RPCTimeout > 0, "cluster.rpc_timeout must be positive")
+		check(cluster.SuspectAfter < cluster.DeadAfter, "cluster.suspect_after must be shorter than cluster.dead_after")
+		check(cluster.MinCoverage >= 0 && cluster.MinCoverage <= 1, "cluster.min_coverage must be between 0 and 1")
+		if cluster.Node.Enabled {
+			port("cluster.node.port", cluster.Node.Port)
+			check(cluster.Node.ID != "", "cluster.node.id is required when the node is enabled")
+		}
+	}
+	if ha := settings.HA; ha.Enabled {
+		port("ha.port", ha.Port)
+		check(ha.ID != "", "ha.id is required when ha is enabled")
+		check(ha.DataDir != "", "ha.data_dir is required when ha is enabled")
+		check(ha.SharedDatabase, "ha.shared_database is required, failover needs every instance to share the database")
+		check(slices.ContainsFunc(ha.Peers, func(peer Peer) bool { return peer.ID == ha.ID }), "ha.peers must include ha.id %q", ha.ID)
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,434 @@
+package consensus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/gofiber/fiber/v2"
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/raft"
+	raftboltdb "github.com/hashicorp/raft-boltdb/v2"
+	"gorm.io/gorm"
+
+	"github.com/christian-nickerson/pangolin/control/internal/configs"
+	"github.com/christian-nickerson/pangolin/control/internal/database"
+)
+
+var (
+	ErrNotLeader = errors.New("not the cluster leader")
+	ErrNoLeader  = errors.New("no cluster leader elected")
+)
+
+// the cluster published to, without one this instance leads alone
+var active *Cluster
+
+// Cluster is this control plane instance's membership of a Raft group
+// replicating cluster metadata. The leader publishes changed rows before
+// committing them to its own database, and followers apply them to theirs
+// in commit order, so any follower can take over as leader.
+type Cluster struct {
+	config    configs.HA
+	raft      *raft.Raft
+	db        *gorm.DB
+	store     *raftboltdb.BoltStore
+	transport raft.Transport
+	notify    chan bool
+	done      chan struct{}
+
+	// publishing is serialised so rows commit in the order they are read
+	publishing sync.Mutex
+
+	mu       sync.Mutex
+	watchers []func(leading bool)
+}
+
+// Member is a voter in the cluster
+type Member struct {
+	ID      string `json:"id"`
+	Address string `json:"address"`
+	API     string `json:"api,omitempty"`
+}
+
+// Status is this instance's view of the cluster
+type Status struct {
+	ID           string   `json:"id"`
+	State        string   `json:"state"`
+	Leader       string   `json:"leader"`
+	LeaderAPI    string   `json:"leader_api,omitempty"`
+	AppliedIndex uint64   `json:"applied_index"`
+	Members      []Member `json:"members"`
+}
+
+// Setup publishes metadata changes through cluster
+func Setup(cluster *Cluster) {
+	active = cluster
+}
+
+// Open starts this instance's Raft member over TCP, keeping its log and
+// snapshots in the data directory. The first start of each peer
+// bootstraps the cluster from the configured peers.
+func Open(config configs.HA) (*Cluster, error) {
+	if err := os.MkdirAll(config.DataDir, 0o755); err != nil {
+		return nil, err
+	}
+
+	var advertise net.Addr
+	for _, peer := range config.Peers {
+		if peer.ID == config.ID {
+			address, err := net.ResolveTCPAddr("tcp", peer.Address)
+			if err != nil {
+				return nil, fmt.Errorf("unable to resolve peer %v, %w", peer.ID, err)
+			}
+			advertise = address
+		}
+	}
+	if advertise == nil {
+		return nil, fmt.Errorf("peers do not include this instance, %v", config.ID)
+	}
+
+	logger := raftLogger()
+	store, err := raftboltdb.NewBoltStore(filepath.Join(config.DataDir, "raft.db"))
+	if err != nil {
+		return nil, err
+	}
+	snapshots, err := raft.NewFileSnapshotStoreWithLogger(config.DataDir, 2, logger)
+	if err != nil {
+		store.Close()
+		return nil, err
+	}
+	bind := net.JoinHostPort(config.Host, strconv.Itoa(config.Port))
+	transport, err := raft.NewTCPTransportWithLogger(bind, advertise, 3, 10*time.Second, logger)
+	if err != nil {
+		store.Close()
+		return nil, err
+	}
+
+	cluster, err := open(config, raftConfig(config), database.DB, store, store, snapshots, transport)
+	if err != nil {
+		transport.Close()
+		store.Close()
+		return nil, err
+	}
+	cluster.store = store
+	return cluster, nil
+}
+
+// default Raft timings for this instance
+func raftConfig(config configs.HA) *raft.Config {
+	conf := raft.DefaultConfig()
+	conf.LocalID = raft.ServerID(config.ID)
+	conf.Logger = raftLogger()
+	return conf
+}
+
+// Raft logs warnings and errors through the service logger
+func raftLogger() hclog.Logger {
+	return hclog.New(&hclog.LoggerOptions{
+		Name:   "raft",
+		Level:  hclog.Warn,
+		Output: log.StandardLog(log.StandardLogOptions{ForceLevel: log.WarnLevel}).Writer(),
+	})
+}
+
+// start a Raft member applying committed metadata to db
+func open(config configs.HA, conf *raft.Config, db *gorm.DB, logs raft.LogStore, stable raft.StableStore, snapshots raft.SnapshotStore, transport raft.Transport) (*Cluster, error) {
+	c := &Cluster{config: config, db: db, transport: transport, notify: make(chan bool, 8), done: make(chan struct{})}
+
+	// the database already holds every change applied before a restart
+	conf.NoSnapshotRestoreOnStart = true
+	conf.NotifyCh = c.notify
+
+	existing, err := raft.HasExistingState(logs, stable, snapshots)
+	if err != nil {
+		return nil, err
+	}
+	c.raft, err = raft.NewRaft(conf, &fsm{id: config.ID, db: db, shared: config.SharedDatabase}, logs, stable, snapshots, transport)
+	if err != nil {
+		return nil, err
+	}
+
+	if !existing {
+		servers := make([]raft.Server, len(config.Peers))
+		for i, peer := range config.Peers {
+			servers[i] = raft.Server{ID: raft.ServerID(peer.ID), Address: raft.ServerAddress(peer.Address)}
+		}
+		err := c.raft.BootstrapCluster(raft.Configuration{Servers: servers}).Error()
+		if err != nil && !errors.Is(err, raft.ErrCantBootstrap) {
+			c.raft.Shutdown()
+			return nil, err
+		}
+	}
+
+	go c.watch()
+	return c, nil
+}
+
+// OnLeadership calls fn with true when this instance becomes leader,
+// once it has applied every committed change, and false when it is not
+func (c *Cluster) OnLeadership(fn func(leading bool)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.watchers = append(c.watchers, fn)
+}
+
+// notify leadership watchers of each change
+func (c *Cluster) watch() {
+	defer close(c.done)
+	for leading := range c.notify {
+		if leading {
+			if err := c.raft.Barrier(c.timeout()).Error(); err != nil {
+				log.Warn("unable to apply committed changes as leader", "err", err)
+				continue
+			}
+		}
+
+		log.Info("cluster leadership changed", "id", c.config.ID, "leading", leading)
+		c.mu.Lock()
+		watchers := c.watchers
+		c.mu.Unlock()
+		for _, fn := range watchers {
+			fn(leading)
+		}
+	}
+}
+
+// time allowed for a change to commit
+func (c *Cluster) timeout() time.Duration {
+	if c.config.ApplyTimeout <= 0 {
+		return 5 * time.Second
+	}
+	return time.Duration(c.config.ApplyTimeout) * time.Second
+}
+
+// Leading reports whether this instance is the leader
+func (c *Cluster) Leading() bool {
+	return c.raft.State() == raft.Leader
+}
+
+// Leader returns the id and API URL of the leader, empty when none is known
+func (c *Cluster) Leader() (string, string) {
+	_, id := c.raft.LeaderWithID()
+	for _, peer := range c.config.Peers {
+		if peer.ID == string(id) {
+			return peer.ID, peer.API
+		}
+	}
+	return string(id), ""
+}
+
+// Status returns this instance's view of the cluster
+func (c *Cluster) Status() (*Status, error) {
+	configuration := c.raft.GetConfiguration()
+	if err := configuration.Error(); err != nil {
+		return nil, err
+	}
+
+	apis := map[string]string{}
+	for _, peer := range c.config.Peers {
+		apis[peer.ID] = peer.API
+	}
+	status := &Status{ID: c.config.ID, State: strings.ToLower(c.raft.State().String()), AppliedIndex: c.raft.AppliedIndex()}
+	status.Leader, status.LeaderAPI = c.Leader()
+	for _, server := range configuration.Configuration().Servers {
+		id := string(server.ID)
+		status.Members = append(status.Members, Member{ID: id, Address: string(server.Address), API: apis[id]})
+	}
+	return status, nil
+}
+
+// Publish replicates the rows of records, found by their primary keys
+// through tx, to followers. Records no longer stored are deleted by
+// followers. Publishing within the transaction writing the rows rolls
+// back a change which cannot be replicated, so the leader never holds
+// metadata its followers lack.
+func (c *Cluster) Publish(tx *gorm.DB, records ...any) error {
+	c.publishing.Lock()
+	defer c.publishing.Unlock()
+
+	commands := make([]Command, 0, len(records))
+	for _, record := range records {
+		stmt := &gorm.Statement{DB: tx}
+		if err := stmt.Parse(record); err != nil {
+			return err
+		}
+		keys, err := primaryKeys(tx, record)
+		if err != nil {
+			return err
+		}
+
+		op, row := OpPut, reflect.New(reflect.TypeOf(record).Elem()).Interface()
+		err = tx.Unscoped().Where(keys).Take(row).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			op, row = OpDelete, record
+		} else if err != nil {
+			return err
+		}
+
+		data, err := encode(row)
+		if err != nil {
+			return err
+		}
+		commands = append(commands, Command{Origin: c.config.ID, Table: stmt.Schema.Table, Op: op, Row: data})
+	}
+	return c.apply(commands)
+}
+
+// PublishTable replicates every row of model's table, read through tx,
+// for changes to many rows at once
+func (c *Cluster) PublishTable(tx *gorm.DB, model any) error {
+	c.publishing.Lock()
+	defer c.publishing.Unlock()
+
+	stmt := &gorm.Statement{DB: tx}
+	if err := stmt.Parse(model); err != nil {
+		return err
+	}
+	rows := reflect.New(reflect.SliceOf(reflect.TypeOf(model).Elem()))
+	if err := tx.Unscoped().Find(rows.Interface()).Error; err != nil {
+		return err
+	}
+	data, err := encode(rows.Interface())
+	if err != nil {
+		return err
+	}
+	return c.apply([]Command{{Origin: c.config.ID, Table: stmt.Schema.Table, Op: OpReplace, Row: data}})
+}
+
+// commit commands to the log, waiting for them to apply
+func (c *Cluster) apply(commands []Command) error {
+	if !c.Leading() {
+		return ErrNotLeader
+	}
+	data, err := encode(commands)
+	if err != nil {
+		return err
+	}
+
+	future := c.raft.Apply(data, c.timeout())
+	if err := future.Error(); err != nil {
+		if errors.Is(err, raft.ErrNotLeader) || errors.Is(err, raft.ErrLeadershipLost) {
+			return ErrNotLeader
+		}
+		return err
+	}
+	if err, ok := future.Response().(error); ok {
+		return err
+	}
+	return nil
+}
+
+// Middleware serves reads of replicated metadata, at paths matching the
+// local route patterns, on any instance. Other requests made to a
+// follower are redirected to the leader, whose database alone holds
+// documents and jobs.
+func (c *Cluster) Middleware(local ...string) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		return c.route(ctx, local)
+	}
+}
+
+// serve a request locally or redirect it to the leader
+func (c *Cluster) route(ctx *fiber.Ctx, local []string) error {
+	switch ctx.Method() {
+	case fiber.MethodOptions:
+		return ctx.Next()
+	case fiber.MethodGet, fiber.MethodHead:
+		for _, pattern := range local {
+			if matches(pattern, ctx.Path()) {
+				return ctx.Next()
+			}
+		}
+	}
+	if c.Leading() {
+		return ctx.Next()
+	}
+
+	_, api := c.Leader()
+	if api == "" {
+		return fiber.NewError(fiber.StatusServiceUnavailable, ErrNoLeader.Error())
+	}
+	return ctx.Redirect(strings.TrimSuffix(api, "/")+ctx.OriginalURL(), fiber.StatusTemporaryRedirect)
+}
+
+// whether path matches a route pattern, whose ":name" segments match any
+// single segment
+func matches(pattern, path string) bool {
+	want := strings.Split(strings.Trim(pattern, "/"), "/")
+	got := strings.Split(strings.Trim(path, "/"), "/")
+	if len(want) != len(got) {
+		return false
+	}
+	for i := range want {
+		if !strings.HasPrefix(want[i], ":") && want[i] != got[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Stop leaves the cluster, waiting for Raft to shut down
+func (c *Cluster) Stop(ctx context.Context) error {
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- c.raft.Shutdown().Error()
+	}()
+
+	select {
+	case err := <-stopped:
+		close(c.notify)
+		<-c.done
+		if closer, ok := c.transport.(raft.WithClose); ok {
+			err = errors.Join(err, closer.Close())
+		}
+		if c.store != nil {
+			err = errors.Join(err, c.store.Close())
+		}
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Publish replicates records read through tx, the transaction writing
+// them, through the cluster when there is one
+func Publish(tx *gorm.DB, records ...any) error {
+	if active == nil {
+		return nil
+	}
+	return active.Publish(tx, records...)
+}
+
+// PublishTable replicates model's table read through tx through the
+// cluster, when there is one
+func PublishTable(tx *gorm.DB, model any) error {
+	if active == nil {
+		return nil
+	}
+	return active.PublishTable(tx, model)
+}
+
+// Leading reports whether this instance leads, always true without a cluster
+func Leading() bool {
+	return active == nil || active.Leading()
+}
+
+// Middleware redirects requests other than reads of the local route
+// patterns to the leader, when there is a cluster
+func Middleware(local ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if active == nil {
+			return c.Next()
+		}
+		return active.route(c, local)
+	}
+}
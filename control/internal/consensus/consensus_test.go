@@ -0,0 +1,307 @@
+package consensus
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/hashicorp/raft"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/gorm"
+
+	"github.com/christian-nickerson/pangolin/control/internal/configs"
+	"github.com/christian-nickerson/pangolin/control/internal/database"
+	"github.com/christian-nickerson/pangolin/control/internal/models"
+)
+
+type ConsensusSuite struct {
+	suite.Suite
+	clusters []*Cluster
+	dbs      []*gorm.DB
+}
+
+// start three instances, each with its own database
+func (s *ConsensusSuite) SetupTest() {
+	s.start(false)
+	s.T().Cleanup(func() {
+		for _, cluster := range s.clusters {
+			cluster.Stop(context.Background())
+		}
+	})
+}
+
+// start three instances over in-memory transports, sharing one database
+// when shared
+func (s *ConsensusSuite) start(shared bool) {
+	var peers []configs.Peer
+	var transports []*raft.InmemTransport
+	for i := 0; i < 3; i++ {
+		address, transport := raft.NewInmemTransport("")
+		transports = append(transports, transport)
+		peers = append(peers, configs.Peer{ID: fmt.Sprintf("control-%v", i), Address: string(address), API: fmt.Sprintf("http://control-%v:3000", i)})
+	}
+	for _, from := range transports {
+		for _, to := range transports {
+			from.Connect(to.LocalAddr(), to)
+		}
+	}
+
+	s.clusters, s.dbs = nil, nil
+	for i, peer := range peers {
+		if !shared || i == 0 {
+			config := configs.DatabaseConfig{Type: "sqlite", DBName: filepath.Join(s.T().TempDir(), "test")}
+			s.Require().NoError(database.Connect(config))
+			s.Require().NoError(database.Migrate())
+		}
+
+		ha := configs.HA{ID: peer.ID, ApplyTimeout: 5, SharedDatabase: shared, Peers: peers}
+		conf := raftConfig(ha)
+		conf.HeartbeatTimeout = 50 * time.Millisecond
+		conf.ElectionTimeout = 50 * time.Millisecond
+		conf.LeaderLeaseTimeout = 50 * time.Millisecond
+		conf.CommitTimeout = 5 * time.Millisecond
+		store := raft.NewInmemStore()
+		cluster, err := open(ha, conf, database.DB, store, store, raft.NewInmemSnapshotStore(), transports[i])
+		s.Require().NoError(err)
+		s.clusters = append(s.clusters, cluster)
+		s.dbs = append(s.dbs, database.DB)
+	}
+}
+
+// wait for a leader among running instances, returning its index
+func (s *ConsensusSuite) leader(running ...int) int {
+	leader := -1
+	s.Require().Eventually(func() bool {
+		for _, i := range running {
+			if s.clusters[i].Leading() {
+				leader = i
+				return true
+			}
+		}
+		return false
+	}, 5*time.Second, 10*time.Millisecond)
+	return leader
+}
+
+// wait for rows of model in the database of instance i to number n
+func (s *ConsensusSuite) eventuallyCount(i int, model any, n int64) {
+	s.Eventually(func() bool {
+		var count int64
+		s.dbs[i].Model(model).Count(&count)
+		return count == n
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+// Test published rows, including hidden columns, reach every follower
+func (s *ConsensusSuite) TestReplicate() {
+	leader := s.leader(0, 1, 2)
+	db := s.dbs[leader]
+
+	namespace := &models.Namespace{Name: "team"}
+	s.Require().NoError(db.Create(namespace).Error)
+	key := &models.APIKey{NamespaceID: namespace.ID, Name: "ci", Prefix: "pk_abc", Hash: "secret", Scope: models.ScopeRead}
+	s.Require().NoError(db.Create(key).Error)
+	collection := &models.Collection{NamespaceID: namespace.ID, Name: "docs", Model: "test", ChunkSize: 8, Metric: models.MetricDot, Shards: 2}
+	s.Require().NoError(db.Create(collection).Error)
+	node := &models.Node{ID: "node-0", Address: "node-0:50060", Status: models.NodeAlive, Shards: []models.HostedShard{{CollectionID: collection.ID, Vectors: 3}}}
+	s.Require().NoError(db.Create(node).Error)
+	placements := []*models.ShardPlacement{
+		{CollectionID: collection.ID, Shard: 0, NodeID: node.ID, State: models.ReplicaReady},
+		{CollectionID: collection.ID, Shard: 1, NodeID: node.ID, State: models.ReplicaReady},
+	}
+	s.Require().NoError(db.Create(placements).Error)
+	s.Require().NoError(s.clusters[leader].Publish(db, namespace, key, collection, node, placements[0], placements[1]))
+
+	for i := range s.clusters {
+		s.eventuallyCount(i, &models.ShardPlacement{}, 2)
+		var replica models.APIKey
+		s.Require().NoError(s.dbs[i].First(&replica, key.ID).Error)
+		s.Equal("secret", replica.Hash)
+		var replicated models.Node
+		s.Require().NoError(s.dbs[i].First(&replicated, "id = ?", node.ID).Error)
+		s.Equal(node.Shards, replicated.Shards)
+	}
+
+	// deleted rows, here the first shard of several, are deleted on followers
+	s.Require().NoError(db.Delete(placements[0]).Error)
+	s.Require().NoError(db.Model(node).Update("status", models.NodeDead).Error)
+	s.Require().NoError(s.clusters[leader].Publish(db, placements[0], node))
+	for i := range s.clusters {
+		s.eventuallyCount(i, &models.ShardPlacement{}, 1)
+		s.Eventually(func() bool {
+			var replicated models.Node
+			return s.dbs[i].First(&replicated, "id = ?", node.ID).Error == nil && replicated.Status == models.NodeDead
+		}, 5*time.Second, 10*time.Millisecond)
+	}
+
+	// whole tables are replaced
+	s.Require().NoError(db.Where("1 = 1").Delete(&models.ShardPlacement{}).Error)
+	s.Require().NoError(s.clusters[leader].PublishTable(db, &models.ShardPlacement{}))
+	for i := range s.clusters {
+		s.eventuallyCount(i, &models.ShardPlacement{}, 0)
+	}
+}
+
+// Test a new leader takes over when the leader stops, and only the leader publishes
+func (s *ConsensusSuite) TestFailover() {
+	ctx := context.Background()
+	leadership := make(chan bool, 16)
+	for _, cluster := range s.clusters {
+		cluster.OnLeadership(func(leading bool) { leadership <- leading })
+	}
+	leader := s.leader(0, 1, 2)
+	s.True(<-leadership)
+
+	var followers []int
+	for i := range s.clusters {
+		if i != leader {
+			followers = append(followers, i)
+		}
+	}
+	s.ErrorIs(s.clusters[followers[0]].Publish(s.dbs[followers[0]], &models.Namespace{ID: 1}), ErrNotLeader)
+
+	s.Require().NoError(s.clusters[leader].Stop(ctx))
+	next := s.leader(followers...)
+	s.NotEqual(leader, next)
+	s.Eventually(func() bool {
+		id, _ := s.clusters[followers[0]].Leader()
+		_, api := s.clusters[followers[1]].Leader()
+		return id == s.clusters[next].config.ID && api == s.clusters[next].config.Peers[next].API
+	}, 5*time.Second, 10*time.Millisecond)
+
+	namespace := &models.Namespace{Name: "after"}
+	s.Require().NoError(s.dbs[next].Create(namespace).Error)
+	s.Require().NoError(s.clusters[next].Publish(s.dbs[next], namespace))
+	for _, i := range followers {
+		s.eventuallyCount(i, &models.Namespace{}, 1)
+	}
+	s.clusters = append(s.clusters[:leader], s.clusters[leader+1:]...)
+}
+
+// Test changes which cannot be replicated are rolled back
+func (s *ConsensusSuite) TestRollback() {
+	leader := s.leader(0, 1, 2)
+	follower := (leader + 1) % 3
+
+	err := s.dbs[follower].Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&models.Namespace{Name: "orphan"}).Error; err != nil {
+			return err
+		}
+		return s.clusters[follower].Publish(tx, &models.Namespace{Name: "orphan"})
+	})
+	s.ErrorIs(err, ErrNotLeader)
+	var count int64
+	s.Require().NoError(s.dbs[follower].Model(&models.Namespace{}).Count(&count).Error)
+	s.Zero(count)
+}
+
+// Test a new leader sharing the database serves documents written by the last
+func (s *ConsensusSuite) TestSharedFailover() {
+	ctx := context.Background()
+	for _, cluster := range s.clusters {
+		s.Require().NoError(cluster.Stop(ctx))
+	}
+	s.start(true)
+	leader := s.leader(0, 1, 2)
+
+	collection := &models.Collection{Name: "docs", Model: "test", ChunkSize: 8, Metric: models.MetricDot}
+	err := s.dbs[leader].Transaction(func(tx *gorm.DB) error {
+		namespace := &models.Namespace{Name: "team"}
+		if err := tx.Create(namespace).Error; err != nil {
+			return err
+		}
+		collection.NamespaceID = namespace.ID
+		if err := tx.Create(collection).Error; err != nil {
+			return err
+		}
+		return s.clusters[leader].Publish(tx, namespace, collection)
+	})
+	s.Require().NoError(err)
+	s.Require().NoError(s.dbs[leader].Create(&models.Document{CollectionID: collection.ID, ExternalID: "a", ContentHash: "hash", Version: 1}).Error)
+
+	s.Require().NoError(s.clusters[leader].Stop(ctx))
+	var followers []int
+	for i := range s.clusters {
+		if i != leader {
+			followers = append(followers, i)
+		}
+	}
+	next := s.leader(followers...)
+
+	var document models.Document
+	s.Require().NoError(s.dbs[next].Where("collection_id = ? AND external_id = ?", collection.ID, "a").First(&document).Error)
+	s.Equal(1, document.Version)
+	s.Require().NoError(s.clusters[next].Publish(s.dbs[next], collection))
+	s.clusters = append(s.clusters[:leader], s.clusters[leader+1:]...)
+}
+
+// Test followers serve metadata reads and redirect other requests to the leader
+func (s *ConsensusSuite) TestMiddleware() {
+	leader := s.leader(0, 1, 2)
+	follower := (leader + 1) % 3
+	s.Eventually(func() bool {
+		id, _ := s.clusters[follower].Leader()
+		return id != ""
+	}, 5*time.Second, 10*time.Millisecond)
+
+	app := fiber.New()
+	app.Use(s.clusters[follower].Middleware("/collections", "/collections/:collection"))
+	ok := func(c *fiber.Ctx) error { return c.SendStatus(fiber.StatusOK) }
+	app.All("/collections", ok)
+	app.All("/collections/:collection", ok)
+	app.All("/collections/:collection/documents", ok)
+
+	response, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/collections", nil))
+	s.Require().NoError(err)
+	s.Equal(fiber.StatusOK, response.StatusCode)
+	response, err = app.Test(httptest.NewRequest(fiber.MethodGet, "/collections/docs/", nil))
+	s.Require().NoError(err)
+	s.Equal(fiber.StatusOK, response.StatusCode)
+
+	// documents are not replicated, so are only read on the leader
+	response, err = app.Test(httptest.NewRequest(fiber.MethodGet, "/collections/docs/documents", nil))
+	s.Require().NoError(err)
+	s.Equal(fiber.StatusTemporaryRedirect, response.StatusCode)
+	s.Equal(fmt.Sprintf("http://control-%v:3000/collections/docs/documents", leader), response.Header.Get("Location"))
+
+	response, err = app.Test(httptest.NewRequest(fiber.MethodPost, "/collections?wait=true", nil))
+	s.Require().NoError(err)
+	s.Equal(fiber.StatusTemporaryRedirect, response.StatusCode)
+	s.Equal(fmt.Sprintf("http://control-%v:3000/collections?wait=true", leader), response.Header.Get("Location"))
+}
+
+// Test snapshots restore every replicated table into another database
+func (s *ConsensusSuite) TestSnapshot() {
+	source, target := &fsm{id: "control-0", db: s.dbs[0]}, &fsm{id: "control-1", db: s.dbs[1]}
+	namespace := &models.Namespace{Name: "team"}
+	s.Require().NoError(source.db.Create(namespace).Error)
+	s.Require().NoError(source.db.Create(&models.Collection{NamespaceID: namespace.ID, Name: "docs", Model: "test", ChunkSize: 8, Metric: models.MetricDot}).Error)
+	s.Require().NoError(target.db.Create(&models.Namespace{Name: "stale"}).Error)
+
+	snap, err := source.Snapshot()
+	s.Require().NoError(err)
+	store := raft.NewInmemSnapshotStore()
+	sink, err := store.Create(raft.SnapshotVersionMax, 1, 1, raft.Configuration{}, 1, nil)
+	s.Require().NoError(err)
+	s.Require().NoError(snap.Persist(sink))
+
+	_, reader, err := store.Open(sink.ID())
+	s.Require().NoError(err)
+	s.Require().NoError(target.Restore(reader))
+
+	var namespaces []models.Namespace
+	s.Require().NoError(target.db.Find(&namespaces).Error)
+	s.Require().Len(namespaces, 1)
+	s.Equal("team", namespaces[0].Name)
+	var collection models.Collection
+	s.Require().NoError(target.db.First(&collection).Error)
+	s.Equal("docs", collection.Name)
+}
+
+func TestConsensusSuite(t *testing.T) {
+	suite.Run(t, new(ConsensusSuite))
+}
@@ -0,0 +1,244 @@
+package consensus
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"reflect"
+
+	"github.com/hashicorp/raft"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/christian-nickerson/pangolin/control/internal/models"
+)
+
+// replicated cluster metadata models, parents before their children
+var replicated = []any{
+	&models.Namespace{},
+	&models.APIKey{},
+	&models.Collection{},
+	&models.Node{},
+	&models.ShardPlacement{},
+}
+
+// Command is a replicated change to cluster metadata. Rows are gob encoded
+// as json tags hide some columns, such as key hashes.
+type Command struct {
+	// Origin is the instance whose database already holds the change
+	Origin string
+	Table  string
+	Op     Op
+	// Row of a put or delete, rows of a replace
+	Row []byte
+}
+
+// Op is how a command changes its table
+type Op int
+
+const (
+	// OpPut inserts or replaces a row
+	OpPut Op = iota
+	// OpDelete deletes a row by primary key
+	OpDelete
+	// OpReplace replaces every row of the table
+	OpReplace
+)
+
+// fsm applies committed commands to an instance's database
+type fsm struct {
+	id string
+	db *gorm.DB
+	// shared databases already hold every change the leader made
+	shared bool
+}
+
+// model returns the replicated model stored in table
+func model(db *gorm.DB, table string) (reflect.Type, error) {
+	for _, record := range replicated {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(record); err != nil {
+			return nil, err
+		}
+		if stmt.Schema.Table == table {
+			return reflect.TypeOf(record).Elem(), nil
+		}
+	}
+	return nil, fmt.Errorf("table %v is not replicated", table)
+}
+
+// Apply applies a committed log entry, returning any error as its response
+func (f *fsm) Apply(entry *raft.Log) any {
+	var commands []Command
+	if err := gob.NewDecoder(bytes.NewReader(entry.Data)).Decode(&commands); err != nil {
+		return err
+	}
+	if f.shared {
+		return nil
+	}
+
+	// the leader's own changes are held by the transaction publishing them
+	pending := commands[:0]
+	for _, command := range commands {
+		if command.Origin != f.id {
+			pending = append(pending, command)
+		}
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+
+	return f.db.Transaction(func(tx *gorm.DB) error {
+		for _, command := range pending {
+			if err := apply(tx, command); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// apply a command to a database
+func apply(tx *gorm.DB, command Command) error {
+	typ, err := model(tx, command.Table)
+	if err != nil {
+		return err
+	}
+
+	switch command.Op {
+	case OpPut:
+		row := reflect.New(typ).Interface()
+		if err := decode(command.Row, row); err != nil {
+			return err
+		}
+		return tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(row).Error
+	case OpDelete:
+		row := reflect.New(typ).Interface()
+		if err := decode(command.Row, row); err != nil {
+			return err
+		}
+		keys, err := primaryKeys(tx, row)
+		if err != nil {
+			return err
+		}
+		return tx.Unscoped().Where(keys).Delete(reflect.New(typ).Interface()).Error
+	case OpReplace:
+		rows := reflect.New(reflect.SliceOf(typ))
+		if err := decode(command.Row, rows.Interface()); err != nil {
+			return err
+		}
+		return replace(tx, typ, rows.Interface())
+	}
+	return fmt.Errorf("unknown command op %v", command.Op)
+}
+
+// replace every row of a table with rows, a pointer to a slice
+func replace(tx *gorm.DB, typ reflect.Type, rows any) error {
+	err := tx.Session(&gorm.Session{AllowGlobalUpdate: true}).Unscoped().Delete(reflect.New(typ).Interface()).Error
+	if err != nil {
+		return err
+	}
+	if reflect.ValueOf(rows).Elem().Len() == 0 {
+		return nil
+	}
+	return tx.CreateInBatches(rows, 100).Error
+}
+
+// primaryKeys returns the primary key columns and values of a row
+func primaryKeys(db *gorm.DB, row any) (map[string]any, error) {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(row); err != nil {
+		return nil, err
+	}
+
+	keys := map[string]any{}
+	value := reflect.ValueOf(row).Elem()
+	for _, field := range stmt.Schema.PrimaryFields {
+		keys[field.DBName], _ = field.ValueOf(context.Background(), value)
+	}
+	return keys, nil
+}
+
+// Snapshot captures every replicated table
+func (f *fsm) Snapshot() (raft.FSMSnapshot, error) {
+	var commands []Command
+	for _, record := range replicated {
+		typ := reflect.TypeOf(record).Elem()
+		rows := reflect.New(reflect.SliceOf(typ))
+		if err := f.db.Unscoped().Find(rows.Interface()).Error; err != nil {
+			return nil, err
+		}
+		data, err := encode(rows.Interface())
+		if err != nil {
+			return nil, err
+		}
+
+		stmt := &gorm.Statement{DB: f.db}
+		if err := stmt.Parse(record); err != nil {
+			return nil, err
+		}
+		commands = append(commands, Command{Table: stmt.Schema.Table, Op: OpReplace, Row: data})
+	}
+	return &snapshot{commands: commands}, nil
+}
+
+// Restore replaces every replicated table with a leader's snapshot
+func (f *fsm) Restore(reader io.ReadCloser) error {
+	defer reader.Close()
+
+	var commands []Command
+	if err := gob.NewDecoder(reader).Decode(&commands); err != nil {
+		return err
+	}
+	if f.shared {
+		return nil
+	}
+
+	return f.db.Transaction(func(tx *gorm.DB) error {
+		// children are emptied before their parents
+		for i := len(commands) - 1; i >= 0; i-- {
+			typ, err := model(tx, commands[i].Table)
+			if err != nil {
+				return err
+			}
+			if err := replace(tx, typ, reflect.New(reflect.SliceOf(typ)).Interface()); err != nil {
+				return err
+			}
+		}
+		for _, command := range commands {
+			if err := apply(tx, command); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// snapshot is the replicated tables as replace commands
+type snapshot struct {
+	commands []Command
+}
+
+func (s *snapshot) Persist(sink raft.SnapshotSink) error {
+	if err := gob.NewEncoder(sink).Encode(s.commands); err != nil {
+		sink.Cancel()
+		return err
+	}
+	return sink.Close()
+}
+
+func (s *snapshot) Release() {}
+
+// gob encode a value
+func encode(value any) ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(value)
+	return buf.Bytes(), err
+}
+
+// gob decode data into a pointer
+func decode(data []byte, out any) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(out)
+}
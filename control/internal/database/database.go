@@ -0,0 +1,73 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/christian-nickerson/pangolin/control/internal/configs"
+	"github.com/christian-nickerson/pangolin/control/internal/tracing"
+)
+
+var DB *gorm.DB
+
+// Connect opens the metadata database described by config
+func Connect(config configs.DatabaseConfig) error {
+	var dialector gorm.Dialector
+
+	switch config.Type {
+	case "sqlite":
+		dialector = sqlite.Open(fmt.Sprintf("%v.db", config.DBName))
+	case "postgres":
+		dialector = postgres.Open(fmt.Sprintf(
+			"host=%v port=%v user=%v password=%v dbname=%v sslmode=disable",
+			config.Host, config.Port, config.Username, config.Password, config.DBName,
+		))
+	default:
+		return fmt.Errorf("unsupported database type %q", config.Type)
+	}
+
+	db, err := gorm.Open(dialector, &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		return fmt.Errorf("unable to open %v database, %v", config.Type, err)
+	}
+
+	if err := db.Use(tracing.GormPlugin{}); err != nil {
+		return fmt.Errorf("unable to register database tracing, %v", err)
+	}
+
+	DB = db
+	return nil
+}
+
+// Ping checks the metadata database is reachable
+func Ping(ctx context.Context) error {
+	if DB == nil {
+		return fmt.Errorf("database not connected")
+	}
+
+	sqlDB, err := DB.DB()
+	if err != nil {
+		return err
+	}
+
+	return sqlDB.PingContext(ctx)
+}
+
+// Close releases the metadata database connection pool
+func Close() error {
+	if DB == nil {
+		return nil
+	}
+
+	sqlDB, err := DB.DB()
+	if err != nil {
+		return err
+	}
+
+	return sqlDB.Close()
+}
@@ -0,0 +1,39 @@
+package database
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/christian-nickerson/pangolin/control/internal/configs"
+	"github.com/christian-nickerson/pangolin/control/internal/models"
+)
+
+// assert a sqlite database can be connected to and pinged
+func TestConnectSQLite(t *testing.T) {
+	config := configs.DatabaseConfig{Type: "sqlite", DBName: filepath.Join(t.TempDir(), "test")}
+
+	assert.NoError(t, Connect(config))
+	assert.NoError(t, Ping(context.Background()))
+}
+
+// assert unknown database types are rejected
+func TestConnectUnsupported(t *testing.T) {
+	err := Connect(configs.DatabaseConfig{Type: "oracle"})
+	assert.Error(t, err)
+}
+
+// assert rolling back drops the tables created by migrating
+func TestRollback(t *testing.T) {
+	config := configs.DatabaseConfig{Type: "sqlite", DBName: filepath.Join(t.TempDir(), "test")}
+	assert.NoError(t, Connect(config))
+
+	assert.NoError(t, Migrate())
+	assert.True(t, DB.Migrator().HasTable(&models.Collection{}))
+
+	assert.NoError(t, Rollback())
+	assert.False(t, DB.Migrator().HasTable(&models.Collection{}))
+	assert.False(t, DB.Migrator().HasTable(&models.Namespace{}))
+}
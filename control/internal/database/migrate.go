@@ -0,0 +1,31 @@
+package database
+
+import (
+	"slices"
+
+	"github.com/christian-nickerson/pangolin/control/internal/models"
+)
+
+// metadata tables in dependency order, referenced tables first
+var tables = []any{
+	&models.Namespace{},
+	&models.Collection{},
+	&models.APIKey{},
+	&models.Document{},
+	&models.Chunk{},
+	&models.Job{},
+	&models.Node{},
+	&models.ShardPlacement{},
+}
+
+// Migrate creates or updates metadata tables for all models
+func Migrate() error {
+	return DB.AutoMigrate(tables...)
+}
+
+// Rollback drops the metadata tables of all models, deleting their rows
+func Rollback() error {
+	reversed := slices.Clone(tables)
+	slices.Reverse(reversed)
+	return DB.Migrator().DropTable(reversed...)
+}
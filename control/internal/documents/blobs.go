@@ -0,0 +1,63 @@
+package documents
+
+import (
+	"bytes"
+	"context"
+	"net/url"
+	"strings"
+
+	"github.com/goccy/go-json"
+
+	"github.com/christian-nickerson/pangolin/control/internal/collections"
+	"github.com/christian-nickerson/pangolin/control/internal/models"
+	"github.com/christian-nickerson/pangolin/control/internal/storage"
+)
+
+// Payload is the blob copy of a stored document and its chunks
+type Payload struct {
+	*models.Document
+	Chunks []models.Chunk `json:"chunks"`
+}
+
+// PayloadKey is the blob key of a document's chunk payload
+func PayloadKey(collectionID uint, id string) string {
+	return storage.Key(collections.BlobPrefix(collectionID), "documents", escape(id)+".json")
+}
+
+// OriginalKey is the blob key of the file a document was extracted from
+func OriginalKey(collectionID uint, id string) string {
+	return storage.Key(collections.BlobPrefix(collectionID), "originals", escape(id))
+}
+
+// escape an id into a single key segment, including leading dots
+// which blob keys reserve
+func escape(id string) string {
+	escaped := url.PathEscape(id)
+	if strings.HasPrefix(escaped, ".") {
+		escaped = "%2E" + escaped[1:]
+	}
+	return escaped
+}
+
+// SavePayload writes the chunk payload of a document applied by a plan
+func SavePayload(ctx context.Context, store storage.BlobStore, plan *Plan) error {
+	if plan.Document == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(Payload{Document: plan.Document, Chunks: plan.Rows})
+	if err != nil {
+		return err
+	}
+	key := PayloadKey(plan.Document.CollectionID, plan.Document.ExternalID)
+	_, err = store.Put(ctx, key, bytes.NewReader(data), int64(len(data)), storage.Condition{})
+	return err
+}
+
+// DeleteBlobs removes the payload and original file of a document
+func DeleteBlobs(ctx context.Context, store storage.BlobStore, collectionID uint, id string) error {
+	if err := store.Delete(ctx, PayloadKey(collectionID, id)); err != nil {
+		return err
+	}
+	return store.Delete(ctx, OriginalKey(collectionID, id))
+}
@@ -0,0 +1,149 @@
+package documents
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+
+	"github.com/charmbracelet/log"
+	"gorm.io/gorm"
+
+	"github.com/christian-nickerson/pangolin/control/internal/database"
+	"github.com/christian-nickerson/pangolin/control/internal/models"
+	"github.com/christian-nickerson/pangolin/control/internal/namespaces"
+	"github.com/christian-nickerson/pangolin/control/internal/shards"
+)
+
+var (
+	ErrDocumentNotFound = errors.New("document not found")
+	ErrVersionConflict  = errors.New("document version does not match")
+	ErrDuplicateID      = errors.New("document id given more than once")
+)
+
+// Hash returns the content hash of text
+func Hash(text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(sum[:])
+}
+
+// Get returns a document in the collection by external id
+func Get(ctx context.Context, collection *models.Collection, id string) (*models.Document, error) {
+	var document models.Document
+	err := database.DB.WithContext(ctx).
+		Where("collection_id = ? AND external_id = ?", collection.ID, id).
+		First(&document).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrDocumentNotFound
+	}
+	return &document, err
+}
+
+// List returns a page of documents in the collection ordered by external id
+func List(ctx context.Context, collection *models.Collection, limit, offset int) ([]models.Document, error) {
+	var documents []models.Document
+	err := database.DB.WithContext(ctx).
+		Where("collection_id = ?", collection.ID).
+		Order("external_id").
+		Limit(limit).
+		Offset(offset).
+		Find(&documents).Error
+	return documents, err
+}
+
+// Chunks returns the chunks of a document in position order
+func Chunks(ctx context.Context, document *models.Document) ([]models.Chunk, error) {
+	var chunks []models.Chunk
+	err := database.DB.WithContext(ctx).
+		Where("document_id = ?", document.ID).
+		Order("position").
+		Find(&chunks).Error
+	return chunks, err
+}
+
+// Delete removes a document's vectors from the index, then the document and
+// its chunks from the metadata database. A non-zero version must match the
+// stored version.
+func Delete(ctx context.Context, collection *models.Collection, id string, version int) (*models.Document, error) {
+	document, err := Get(ctx, collection, id)
+	if err != nil {
+		return nil, err
+	}
+	if version != 0 && document.Version != version {
+		return nil, ErrVersionConflict
+	}
+
+	chunks, err := Chunks(ctx, document)
+	if err != nil {
+		return nil, err
+	}
+
+	// vectors are removed first, a document left without them by a failed
+	// delete is marked for reindexing by its next upsert
+	deletes := make([]uint64, len(chunks))
+	for i, chunk := range chunks {
+		deletes[i] = uint64(chunk.ID)
+	}
+	batches := shards.Batches{}
+	batches.Add(shards.Key(collection, document.ExternalID), deletes, nil)
+	if err := shards.Write(ctx, collection, batches); err != nil {
+		markReindex(ctx, []uint{document.ID})
+		return nil, err
+	}
+
+	err = database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// guard on the version read above so a concurrent write wins, its
+		// chunks replace the chunks removed from the index
+		deleted := tx.Where("id = ? AND version = ?", document.ID, document.Version).Delete(&models.Document{})
+		if deleted.Error != nil {
+			return deleted.Error
+		}
+		if deleted.RowsAffected == 0 {
+			return ErrVersionConflict
+		}
+
+		if err := tx.Where("document_id = ?", document.ID).Delete(&models.Chunk{}).Error; err != nil {
+			return err
+		}
+		return adjustUsage(tx, collection, -1, -document.VectorBytes)
+	})
+	if err != nil {
+		if !errors.Is(err, ErrVersionConflict) {
+			markReindex(ctx, []uint{document.ID})
+		}
+		return nil, err
+	}
+	usageChanged(collection.ID)
+	return document, nil
+}
+
+// mark documents to be applied again by their next upsert, logging
+// failures as the write which failed is already being reported
+func markReindex(ctx context.Context, ids []uint) {
+	if len(ids) == 0 {
+		return
+	}
+	err := database.DB.WithContext(context.WithoutCancel(ctx)).
+		Model(&models.Document{}).
+		Where("id IN ?", ids).
+		Update("reindex", true).Error
+	if err != nil {
+		log.Error("unable to mark documents for reindexing", "ids", ids, "err", err)
+	}
+}
+
+// adjust the collection's document count and vector bytes, failing with a
+// QuotaError when an increase would exceed the namespace's quotas
+func adjustUsage(tx *gorm.DB, collection *models.Collection, documents, vectorBytes int64) error {
+	if documents == 0 && vectorBytes == 0 {
+		return nil
+	}
+	requested := models.Usage{Documents: documents, VectorBytes: vectorBytes}
+	if err := namespaces.Reserve(tx, collection.NamespaceID, requested); err != nil {
+		return err
+	}
+	return tx.Model(collection).UpdateColumns(map[string]any{
+		"document_count": gorm.Expr("document_count + ?", documents),
+		"vector_bytes":   gorm.Expr("vector_bytes + ?", vectorBytes),
+	}).Error
+}
@@ -0,0 +1,179 @@
+package documents
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/christian-nickerson/pangolin/control/internal/configs"
+	"github.com/christian-nickerson/pangolin/control/internal/database"
+	"github.com/christian-nickerson/pangolin/control/internal/index"
+	"github.com/christian-nickerson/pangolin/control/internal/models"
+)
+
+type DocumentsSuite struct {
+	suite.Suite
+	collection *models.Collection
+	embedded   int
+}
+
+// set up a database with a collection chunking every two words
+func (s *DocumentsSuite) SetupTest() {
+	config := configs.DatabaseConfig{Type: "sqlite", DBName: filepath.Join(s.T().TempDir(), "test")}
+	s.Require().NoError(database.Connect(config))
+	s.Require().NoError(database.Migrate())
+
+	namespace := &models.Namespace{Name: models.DefaultNamespace}
+	s.Require().NoError(database.DB.Create(namespace).Error)
+	s.collection = &models.Collection{NamespaceID: namespace.ID, Name: "docs", Model: "test", ChunkSize: 2, Metric: models.MetricCosine}
+	s.Require().NoError(database.DB.Create(s.collection).Error)
+	index.Drop(s.collection.ID)
+	s.embedded = 0
+}
+
+// prepare, embed and apply an upsert, counting chunks embedded
+func (s *DocumentsSuite) upsert(upsert Upsert) error {
+	ctx := context.Background()
+	plan, err := Prepare(ctx, s.collection, upsert)
+	if err != nil {
+		return err
+	}
+	for _, position := range plan.Pending() {
+		plan.Vectors[position] = models.Vector{float64(len(plan.Chunks[position])), 1}
+		s.embedded++
+	}
+	return Apply(ctx, s.collection, []*Plan{plan})
+}
+
+// reload the collection usage counters
+func (s *DocumentsSuite) usage() (int64, int64) {
+	var collection models.Collection
+	s.Require().NoError(database.DB.First(&collection, s.collection.ID).Error)
+	return collection.DocumentCount, collection.VectorBytes
+}
+
+// Test upserting unchanged text does not duplicate or re-embed chunks
+func (s *DocumentsSuite) TestUpsertIdempotent() {
+	upsert := Upsert{ID: "a", Text: "one two three four"}
+	s.Require().NoError(s.upsert(upsert))
+	s.Require().NoError(s.upsert(upsert))
+
+	document, err := Get(context.Background(), s.collection, "a")
+	s.Require().NoError(err)
+	s.Equal(1, document.Version)
+	s.Equal(Hash(upsert.Text), document.ContentHash)
+	s.Equal(2, s.embedded)
+
+	var chunks int64
+	s.Require().NoError(database.DB.Model(&models.Chunk{}).Count(&chunks).Error)
+	s.Equal(int64(2), chunks)
+	s.Equal(2, index.For(s.collection).Len())
+
+	documents, vectorBytes := s.usage()
+	s.Equal(int64(1), documents)
+	s.Equal(int64(2*16), vectorBytes)
+}
+
+// Test changed text re-embeds only the chunks that changed
+func (s *DocumentsSuite) TestUpsertChanged() {
+	s.Require().NoError(s.upsert(Upsert{ID: "a", Text: "one two three four"}))
+	s.Require().NoError(s.upsert(Upsert{ID: "a", Text: "one two three five six"}))
+
+	document, err := Get(context.Background(), s.collection, "a")
+	s.Require().NoError(err)
+	s.Equal(2, document.Version)
+	s.Equal(3, document.ChunkCount)
+	s.Equal(2+2, s.embedded)
+
+	chunks, err := Chunks(context.Background(), document)
+	s.Require().NoError(err)
+	s.Require().Len(chunks, 3)
+	s.Equal("three five", chunks[1].Text)
+	s.Equal(3, index.For(s.collection).Len())
+
+	documents, vectorBytes := s.usage()
+	s.Equal(int64(1), documents)
+	s.Equal(int64(3*16), vectorBytes)
+}
+
+// Test metadata changes bump the version without embedding
+func (s *DocumentsSuite) TestUpsertMetadata() {
+	s.Require().NoError(s.upsert(Upsert{ID: "a", Text: "one two"}))
+	s.Require().NoError(s.upsert(Upsert{ID: "a", Text: "one two", Metadata: map[string]string{"k": "v"}}))
+
+	document, err := Get(context.Background(), s.collection, "a")
+	s.Require().NoError(err)
+	s.Equal(2, document.Version)
+	s.Equal("v", document.Metadata["k"])
+	s.Equal(1, s.embedded)
+}
+
+// Test versioned upserts apply only against the matching version
+func (s *DocumentsSuite) TestUpsertVersion() {
+	s.ErrorIs(s.upsert(Upsert{ID: "a", Text: "one", Version: 1}), ErrVersionConflict)
+
+	s.Require().NoError(s.upsert(Upsert{ID: "a", Text: "one"}))
+	s.Require().NoError(s.upsert(Upsert{ID: "a", Text: "two", Version: 1}))
+	s.ErrorIs(s.upsert(Upsert{ID: "a", Text: "three", Version: 1}), ErrVersionConflict)
+
+	// a plan prepared against a version changed before it applies conflicts
+	ctx := context.Background()
+	stale, err := Prepare(ctx, s.collection, Upsert{ID: "a", Text: "four"})
+	s.Require().NoError(err)
+	s.Require().NoError(s.upsert(Upsert{ID: "a", Text: "five"}))
+	stale.Vectors[0] = models.Vector{1, 1}
+	s.ErrorIs(Apply(ctx, s.collection, []*Plan{stale}), ErrVersionConflict)
+}
+
+// Test delete removes chunks from metadata and the index
+func (s *DocumentsSuite) TestDelete() {
+	ctx := context.Background()
+	s.Require().NoError(s.upsert(Upsert{ID: "a", Text: "one two three"}))
+	s.Require().NoError(s.upsert(Upsert{ID: "b", Text: "four"}))
+
+	_, err := Delete(ctx, s.collection, "a", 2)
+	s.ErrorIs(err, ErrVersionConflict)
+
+	_, err = Delete(ctx, s.collection, "a", 1)
+	s.Require().NoError(err)
+	_, err = Get(ctx, s.collection, "a")
+	s.ErrorIs(err, ErrDocumentNotFound)
+	_, err = Delete(ctx, s.collection, "a", 0)
+	s.ErrorIs(err, ErrDocumentNotFound)
+
+	var chunks int64
+	s.Require().NoError(database.DB.Model(&models.Chunk{}).Count(&chunks).Error)
+	s.Equal(int64(1), chunks)
+	s.Equal(1, index.For(s.collection).Len())
+
+	documents, vectorBytes := s.usage()
+	s.Equal(int64(1), documents)
+	s.Equal(int64(16), vectorBytes)
+}
+
+// Test a document whose index write failed is applied again when unchanged
+func (s *DocumentsSuite) TestUpsertReindex() {
+	ctx := context.Background()
+	s.Require().NoError(s.upsert(Upsert{ID: "a", Text: "one two"}))
+
+	// vectors of the wrong dimensions fail the index write after commit
+	plan, err := Prepare(ctx, s.collection, Upsert{ID: "b", Text: "three four"})
+	s.Require().NoError(err)
+	plan.Vectors[0] = models.Vector{1, 1, 1}
+	s.Error(Apply(ctx, s.collection, []*Plan{plan}))
+	document, err := Get(ctx, s.collection, "b")
+	s.Require().NoError(err)
+	s.True(document.Reindex)
+
+	s.Require().NoError(s.upsert(Upsert{ID: "b", Text: "three four"}))
+	document, err = Get(ctx, s.collection, "b")
+	s.Require().NoError(err)
+	s.False(document.Reindex)
+	s.Equal(2, index.For(s.collection).Len())
+}
+
+func TestDocumentsSuite(t *testing.T) {
+	suite.Run(t, new(DocumentsSuite))
+}
@@ -0,0 +1,252 @@
+package documents
+
+import (
+	"context"
+	"errors"
+	"maps"
+
+	"github.com/charmbracelet/log"
+	"gorm.io/gorm"
+
+	"github.com/christian-nickerson/pangolin/control/internal/chunking"
+	"github.com/christian-nickerson/pangolin/control/internal/database"
+	"github.com/christian-nickerson/pangolin/control/internal/index"
+	"github.com/christian-nickerson/pangolin/control/internal/models"
+	"github.com/christian-nickerson/pangolin/control/internal/shards"
+)
+
+// Upsert is a document to create or replace by external id. A non-zero
+// version must match the stored version for the write to apply.
+type Upsert struct {
+	ID       string            `json:"id" validate:"omitempty,max=256"`
+	Text     string            `json:"text" validate:"required"`
+	Metadata map[string]string `json:"metadata"`
+	Version  int               `json:"version,omitempty" validate:"gte=0"`
+}
+
+// Plan is the work needed to apply an upsert. Chunks whose text is
+// unchanged keep their vectors, the rest need embedding before Apply.
+type Plan struct {
+	Upsert   Upsert
+	Existing *models.Document
+	Hash     string
+	Chunks   []string
+	Hashes   []string
+	Vectors  []models.Vector
+
+	// Document and Rows are the stored document and chunks, set by Apply
+	Document *models.Document
+	Rows     []models.Chunk
+
+	replaced []models.Chunk
+}
+
+// Prepare chunks an upsert and reuses the vectors of unchanged chunks
+func Prepare(ctx context.Context, collection *models.Collection, upsert Upsert) (*Plan, error) {
+	existing, err := Get(ctx, collection, upsert.ID)
+	if errors.Is(err, ErrDocumentNotFound) {
+		existing = nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if upsert.Version != 0 && (existing == nil || existing.Version != upsert.Version) {
+		return nil, ErrVersionConflict
+	}
+
+	plan := &Plan{
+		Upsert:   upsert,
+		Existing: existing,
+		Hash:     Hash(upsert.Text),
+		Chunks:   chunking.Split(upsert.Text, collection.ChunkSize, collection.ChunkOverlap),
+	}
+	if plan.Unchanged() {
+		return plan, nil
+	}
+
+	// index the vectors of the current chunks by content, chunks whose
+	// vectors cannot be read are embedded again
+	reusable := map[string]models.Vector{}
+	if existing != nil {
+		if plan.replaced, err = Chunks(ctx, existing); err != nil {
+			return nil, err
+		}
+		if err := plan.reuse(ctx, collection, reusable); err != nil {
+			log.Warn("unable to read document vectors, embedding every chunk", "document_id", upsert.ID, "err", err)
+		}
+	}
+
+	plan.Hashes = make([]string, len(plan.Chunks))
+	plan.Vectors = make([]models.Vector, len(plan.Chunks))
+	for i, text := range plan.Chunks {
+		plan.Hashes[i] = Hash(text)
+		plan.Vectors[i] = reusable[plan.Hashes[i]]
+	}
+	return plan, nil
+}
+
+// collect the vectors of the replaced chunks by hash, from the shard
+// holding the document
+func (p *Plan) reuse(ctx context.Context, collection *models.Collection, reusable map[string]models.Vector) error {
+	ids := make([]uint64, len(p.replaced))
+	hashes := make(map[uint64]string, len(p.replaced))
+	for i, chunk := range p.replaced {
+		ids[i] = uint64(chunk.ID)
+		hashes[ids[i]] = chunk.Hash
+	}
+	entries, err := shards.Get(ctx, collection, shards.Key(collection, p.Upsert.ID), ids)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		reusable[hashes[entry.ID]] = entry.Vector
+	}
+	return nil
+}
+
+// Unchanged reports whether the upsert matches the stored document
+func (p *Plan) Unchanged() bool {
+	return p.Existing != nil &&
+		!p.Existing.Reindex &&
+		p.Existing.ContentHash == p.Hash &&
+		maps.Equal(p.Existing.Metadata, p.Upsert.Metadata)
+}
+
+// Pending returns the positions of chunks that need embedding
+func (p *Plan) Pending() []int {
+	var pending []int
+	for i, vector := range p.Vectors {
+		if vector == nil {
+			pending = append(pending, i)
+		}
+	}
+	return pending
+}
+
+// Usage returns the change in documents and vector bytes the plan makes
+func (p *Plan) Usage() (documents, vectorBytes int64) {
+	if p.Unchanged() {
+		return 0, 0
+	}
+	if p.Existing == nil {
+		return 1, p.vectorBytes()
+	}
+	return 0, p.vectorBytes() - p.Existing.VectorBytes
+}
+
+// total bytes of the plan's vectors
+func (p *Plan) vectorBytes() int64 {
+	var total int64
+	for _, vector := range p.Vectors {
+		total += vector.Bytes()
+	}
+	return total
+}
+
+// Apply writes embedded plans in one transaction, then updates the index.
+// Documents changed since they were prepared fail with ErrVersionConflict,
+// documents whose index write fails are marked for reindexing.
+func Apply(ctx context.Context, collection *models.Collection, plans []*Plan) error {
+	err := database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var documents, vectorBytes int64
+
+		for _, plan := range plans {
+			if plan.Unchanged() {
+				continue
+			}
+
+			document, err := plan.write(tx, collection)
+			if err != nil {
+				return err
+			}
+
+			chunks := make([]models.Chunk, len(plan.Chunks))
+			for i, text := range plan.Chunks {
+				chunks[i] = models.Chunk{
+					CollectionID: collection.ID,
+					DocumentID:   document.ID,
+					Position:     i,
+					Hash:         plan.Hashes[i],
+					Text:         text,
+				}
+			}
+			if len(chunks) > 0 {
+				if err := tx.Create(&chunks).Error; err != nil {
+					return err
+				}
+			}
+
+			plan.Document, plan.Rows = document, chunks
+
+			changedDocuments, changedBytes := plan.Usage()
+			documents += changedDocuments
+			vectorBytes += changedBytes
+		}
+
+		return adjustUsage(tx, collection, documents, vectorBytes)
+	})
+	if err != nil {
+		return err
+	}
+	usageChanged(collection.ID)
+
+	// each document's chunks are written to the shard owning it
+	var written []uint
+	batches := shards.Batches{}
+	for _, plan := range plans {
+		if plan.Unchanged() {
+			continue
+		}
+		written = append(written, plan.Document.ID)
+
+		deletes := make([]uint64, len(plan.replaced))
+		for i, chunk := range plan.replaced {
+			deletes[i] = uint64(chunk.ID)
+		}
+		upserts := make([]index.Entry, len(plan.Rows))
+		for i, chunk := range plan.Rows {
+			upserts[i] = index.Entry{ID: uint64(chunk.ID), Vector: plan.Vectors[i]}
+		}
+		batches.Add(shards.Key(collection, plan.Upsert.ID), deletes, upserts)
+	}
+	if err := shards.Write(ctx, collection, batches); err != nil {
+		markReindex(ctx, written)
+		return err
+	}
+	return nil
+}
+
+// create or replace the document row, dropping the chunks it replaces
+func (p *Plan) write(tx *gorm.DB, collection *models.Collection) (*models.Document, error) {
+	document := &models.Document{
+		CollectionID: collection.ID,
+		ExternalID:   p.Upsert.ID,
+		ContentHash:  p.Hash,
+		Version:      1,
+		Metadata:     p.Upsert.Metadata,
+		ChunkCount:   len(p.Chunks),
+		VectorBytes:  p.vectorBytes(),
+	}
+	if p.Existing == nil {
+		return document, tx.Create(document).Error
+	}
+
+	document.ID = p.Existing.ID
+	document.Version = p.Existing.Version + 1
+	document.CreatedAt = p.Existing.CreatedAt
+
+	// guard on the prepared version so concurrent writers cannot both apply
+	updated := tx.Model(&models.Document{}).
+		Where("id = ? AND version = ?", p.Existing.ID, p.Existing.Version).
+		Select("ContentHash", "Version", "Metadata", "ChunkCount", "VectorBytes", "Reindex", "UpdatedAt").
+		Updates(document)
+	if updated.Error != nil {
+		return nil, updated.Error
+	}
+	if updated.RowsAffected == 0 {
+		return nil, ErrVersionConflict
+	}
+
+	err := tx.Where("document_id = ?", p.Existing.ID).Delete(&models.Chunk{}).Error
+	return document, err
+}
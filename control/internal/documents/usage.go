@@ -0,0 +1,93 @@
+package documents
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+
+	"github.com/christian-nickerson/pangolin/control/internal/consensus"
+	"github.com/christian-nickerson/pangolin/control/internal/database"
+	"github.com/christian-nickerson/pangolin/control/internal/models"
+)
+
+// interval between replicating changed usage counters
+const usageInterval = 5 * time.Second
+
+var (
+	usageMu    sync.Mutex
+	changed    = map[uint]bool{}
+	stopUsage  context.CancelFunc
+	publishing sync.WaitGroup
+)
+
+// StartUsage replicates the usage counters of collections written since
+// the last interval, rather than on every document write
+func StartUsage() {
+	var ctx context.Context
+	ctx, stopUsage = context.WithCancel(context.Background())
+	publishing.Add(1)
+	go func() {
+		defer publishing.Done()
+		ticker := time.NewTicker(usageInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			if err := PublishUsage(ctx); err != nil && ctx.Err() == nil {
+				log.Warn("unable to replicate collection usage", "err", err)
+			}
+		}
+	}()
+}
+
+// StopUsage stops replicating usage counters, publishing those pending
+// while this instance still leads
+func StopUsage(ctx context.Context) error {
+	if stopUsage == nil {
+		return nil
+	}
+	stopUsage()
+	publishing.Wait()
+	if !consensus.Leading() {
+		return nil
+	}
+	return PublishUsage(ctx)
+}
+
+// PublishUsage replicates the collections whose usage changed since the
+// last call, collections which fail are published again on the next
+func PublishUsage(ctx context.Context) error {
+	usageMu.Lock()
+	pending := changed
+	changed = map[uint]bool{}
+	usageMu.Unlock()
+	if len(pending) == 0 {
+		return nil
+	}
+
+	records := make([]any, 0, len(pending))
+	for id := range pending {
+		records = append(records, &models.Collection{ID: id})
+	}
+	err := consensus.Publish(database.DB.WithContext(ctx), records...)
+	if err != nil {
+		usageMu.Lock()
+		for id := range pending {
+			changed[id] = true
+		}
+		usageMu.Unlock()
+	}
+	return err
+}
+
+// record a change to a collection's usage counters
+func usageChanged(collectionID uint) {
+	usageMu.Lock()
+	defer usageMu.Unlock()
+	changed[collectionID] = true
+}
@@ -2,55 +2,109 @@ package embeddings
 
 import (
 	"context"
-	"log"
+	"fmt"
+	"net"
+	"strconv"
 	"time"
 
 	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 
+	"github.com/christian-nickerson/pangolin/control/internal/configs"
+	"github.com/christian-nickerson/pangolin/control/internal/metrics"
+	"github.com/christian-nickerson/pangolin/control/internal/models"
 	"github.com/christian-nickerson/pangolin/control/internal/proto"
+	"github.com/christian-nickerson/pangolin/control/internal/tracing"
 )
 
 var Client proto.EmbeddingsClient
 var Conn *grpc.ClientConn
 
-func Connect(address string) {
-	var err error
+// Connect creates the model server channel, secured as configured
+func Connect(config configs.EmbeddingsConfig) error {
+	options, err := dialOptions(config)
+	if err != nil {
+		return err
+	}
 
-	Conn, err = grpc.NewClient(address)
+	address := net.JoinHostPort(config.Host, strconv.Itoa(config.Port))
+	Conn, err = grpc.NewClient(address, append(options, tracing.GRPCDialOption())...)
 	if err != nil {
-		log.Fatal("Failed to connect to client:", err)
+		return fmt.Errorf("unable to connect to embedding server %v, %v", address, err)
 	}
 
 	Client = proto.NewEmbeddingsClient(Conn)
+	return nil
+}
+
+// Close closes the model server channel
+func Close() error {
+	if Conn == nil {
+		return nil
+	}
+	return Conn.Close()
 }
 
-func Inference(text *[]string, modelName string) []*proto.Vector {
+// Embedder embeds a batch of texts with the named model
+type Embedder func(ctx context.Context, texts []string, modelName string) ([]models.Vector, error)
+
+// Inference embeds texts on the model server, returning one vector per text
+func Inference(ctx context.Context, texts []string, modelName string) ([]models.Vector, error) {
 	// timeout after 5 mins
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Minute)
 	defer cancel()
 
 	// call model
+	start := time.Now()
 	response, err := Client.Inference(
 		ctx,
-		&proto.InferenceRequest{Text: *text, ModelName: modelName},
+		&proto.InferenceRequest{Text: texts, ModelName: modelName},
 	)
+	metrics.ObserveEmbedding(modelName, len(texts), time.Since(start), err)
 	if err != nil {
-		log.Fatalf("Client.Inference failed on model %v: %v", modelName, err)
+		return nil, fmt.Errorf("embedding inference failed on model %v, %w", modelName, err)
+	}
+
+	if len(response.Embeddings) != len(texts) {
+		return nil, fmt.Errorf("embedding server returned %v vectors for %v texts", len(response.Embeddings), len(texts))
 	}
 
-	return response.Embeddings
+	vectors := make([]models.Vector, len(response.Embeddings))
+	for i, embedding := range response.Embeddings {
+		vectors[i] = embedding.Components
+	}
+	return vectors, nil
 }
 
-func ModelList() []string {
+// ModelList returns the models served by the model server
+func ModelList(ctx context.Context) ([]string, error) {
 	// timeout after 5 seconds
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
 	// call model
 	response, err := Client.ModelList(ctx, &proto.ModelListRequest{})
 	if err != nil {
-		log.Fatalf("Client.ModelList failed: %v", err)
+		return nil, fmt.Errorf("embedding model list failed, %w", err)
+	}
+
+	return response.ModelNames, nil
+}
+
+// Health checks the embedding server reports SERVING over the gRPC health protocol
+func Health(ctx context.Context) error {
+	if Conn == nil {
+		return fmt.Errorf("embedding client not connected")
+	}
+
+	response, err := healthpb.NewHealthClient(Conn).Check(ctx, &healthpb.HealthCheckRequest{})
+	if err != nil {
+		return err
+	}
+
+	if response.Status != healthpb.HealthCheckResponse_SERVING {
+		return fmt.Errorf("embedding server status %v", response.Status)
 	}
 
-	return response.ModelNames
+	return nil
 }
@@ -0,0 +1,87 @@
+package embeddings
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+
+	"github.com/charmbracelet/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"github.com/christian-nickerson/pangolin/control/internal/certs"
+	"github.com/christian-nickerson/pangolin/control/internal/configs"
+)
+
+// transport security for the configured mode
+func transportCredentials(config configs.ChannelTLSConfig) (credentials.TransportCredentials, error) {
+	switch config.Mode {
+	case "", "insecure":
+		return insecure.NewCredentials(), nil
+	case "tls", "mtls":
+	default:
+		return nil, fmt.Errorf("unsupported embedding tls mode %q", config.Mode)
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12, ServerName: config.ServerName}
+
+	// verify the model server against the CA file, or system roots if unset
+	if config.CAFile != "" {
+		pem, err := os.ReadFile(config.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read embedding CA file, %v", err)
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %v", config.CAFile)
+		}
+	}
+
+	if config.Mode == "mtls" {
+		reloader, err := certs.NewReloader(config.CertFile, config.KeyFile, "")
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return reloader.Certificate(), nil
+		}
+	}
+
+	return credentials.NewTLS(tlsConfig), nil
+}
+
+// tokenCredentials sends a shared secret as bearer authorization on every RPC
+type tokenCredentials struct {
+	token  string
+	secure bool
+}
+
+func (t tokenCredentials) GetRequestMetadata(context.Context, ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + t.token}, nil
+}
+
+func (t tokenCredentials) RequireTransportSecurity() bool {
+	return t.secure
+}
+
+// dial options securing the channel as configured
+func dialOptions(config configs.EmbeddingsConfig) ([]grpc.DialOption, error) {
+	transport, err := transportCredentials(config.TLS)
+	if err != nil {
+		return nil, err
+	}
+	options := []grpc.DialOption{grpc.WithTransportCredentials(transport)}
+
+	if config.Token != "" {
+		secure := config.TLS.Mode == "tls" || config.TLS.Mode == "mtls"
+		if !secure {
+			log.Warn("embedding token is sent in plain text, enable tls to protect it")
+		}
+		options = append(options, grpc.WithPerRPCCredentials(tokenCredentials{token: config.Token, secure: secure}))
+	}
+
+	return options, nil
+}
@@ -0,0 +1,106 @@
+package embeddings
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/christian-nickerson/pangolin/control/internal/certs/certstest"
+	"github.com/christian-nickerson/pangolin/control/internal/configs"
+)
+
+// reject RPCs without the expected bearer token
+func tokenInterceptor(token string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		if values := md.Get("authorization"); len(values) == 0 || values[0] != "Bearer "+token {
+			return nil, status.Error(codes.Unauthenticated, "invalid token")
+		}
+		return handler(ctx, req)
+	}
+}
+
+// serve the gRPC health service, returning the port
+func serveHealth(t *testing.T, options ...grpc.ServerOption) int {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	server := grpc.NewServer(options...)
+	healthpb.RegisterHealthServer(server, health.NewServer())
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+// connect with config and check health
+func checkHealth(t *testing.T, config configs.EmbeddingsConfig) error {
+	require.NoError(t, Connect(config))
+	defer Conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	return Health(ctx)
+}
+
+// assert the shared token is sent on every RPC
+func TestTokenCredentials(t *testing.T) {
+	port := serveHealth(t, grpc.UnaryInterceptor(tokenInterceptor("secret")))
+	config := configs.EmbeddingsConfig{ServerConfig: configs.ServerConfig{Host: "127.0.0.1", Port: port}}
+
+	assert.Error(t, checkHealth(t, config))
+
+	config.Token = "secret"
+	assert.NoError(t, checkHealth(t, config))
+}
+
+// assert tls and mtls modes verify the model server and present a client certificate
+func TestTransportCredentials(t *testing.T) {
+	caFile, certFile, keyFile := certstest.WriteFiles(t, t.TempDir())
+	serverCert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	require.NoError(t, err)
+	pool := x509.NewCertPool()
+	pem, _ := os.ReadFile(caFile)
+	pool.AppendCertsFromPEM(pem)
+
+	tlsPort := serveHealth(t, grpc.Creds(credentials.NewTLS(&tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+	})))
+	mtlsPort := serveHealth(t, grpc.Creds(credentials.NewTLS(&tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientCAs:    pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	})))
+
+	config := func(port int, mode string) configs.EmbeddingsConfig {
+		return configs.EmbeddingsConfig{
+			ServerConfig: configs.ServerConfig{Host: "127.0.0.1", Port: port},
+			TLS:          configs.ChannelTLSConfig{Mode: mode, CAFile: caFile, CertFile: certFile, KeyFile: keyFile},
+		}
+	}
+
+	assert.NoError(t, checkHealth(t, config(tlsPort, "tls")))
+	assert.Error(t, checkHealth(t, config(tlsPort, "insecure")))
+	assert.Error(t, checkHealth(t, config(mtlsPort, "tls")), "mtls server should reject clients without certificates")
+	assert.NoError(t, checkHealth(t, config(mtlsPort, "mtls")))
+}
+
+// assert unknown modes are rejected
+func TestTransportCredentialsInvalidMode(t *testing.T) {
+	_, err := transportCredentials(configs.ChannelTLSConfig{Mode: "plaintext"})
+	assert.Error(t, err)
+}
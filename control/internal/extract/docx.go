@@ -0,0 +1,75 @@
+package extract
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// largest main body decompressed from a Word document, which is far
+// smaller compressed
+var maxDocumentXML uint64 = 64 << 20
+
+// DOCX extracts the paragraphs of a Word document's main body
+func DOCX(data []byte) (string, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", err
+	}
+
+	index := slices.IndexFunc(archive.File, func(file *zip.File) bool { return file.Name == "word/document.xml" })
+	if index < 0 {
+		return "", errors.New("archive has no word/document.xml")
+	}
+	document := archive.File[index]
+	if document.UncompressedSize64 > maxDocumentXML {
+		return "", fmt.Errorf("%w, word/document.xml expands to %v bytes", ErrTooLarge, document.UncompressedSize64)
+	}
+	body, err := document.Open()
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+
+	var text strings.Builder
+	decoder := xml.NewDecoder(io.LimitReader(body, int64(maxDocumentXML)))
+	inText := false
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+
+		switch element := token.(type) {
+		case xml.StartElement:
+			switch element.Name.Local {
+			case "t":
+				inText = true
+			case "tab":
+				text.WriteString("\t")
+			case "br", "cr":
+				text.WriteString("\n")
+			}
+		case xml.EndElement:
+			switch element.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				text.WriteString("\n\n")
+			}
+		case xml.CharData:
+			if inText {
+				text.Write(element)
+			}
+		}
+	}
+	return tidy(text.String()), nil
+}
@@ -0,0 +1,130 @@
+package extract
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/gabriel-vasile/mimetype"
+)
+
+const (
+	MIMEText     = "text/plain"
+	MIMEHTML     = "text/html"
+	MIMEMarkdown = "text/markdown"
+	MIMEPDF      = "application/pdf"
+	MIMEDOCX     = "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+)
+
+var (
+	ErrUnsupported = errors.New("unsupported content type")
+	ErrNoText      = errors.New("no text could be extracted")
+	ErrTooLarge    = errors.New("content expands beyond the extraction limit")
+)
+
+// Extractor converts file content of one MIME type to plain text
+type Extractor func(data []byte) (string, error)
+
+var (
+	mu         sync.RWMutex
+	extractors = map[string]Extractor{
+		MIMEText:     PlainText,
+		MIMEHTML:     HTML,
+		MIMEMarkdown: Markdown,
+		MIMEPDF:      PDF,
+		MIMEDOCX:     DOCX,
+	}
+)
+
+// content sniffing sees these as plain text, so trust the file extension
+var extensions = map[string]string{
+	".md":       MIMEMarkdown,
+	".markdown": MIMEMarkdown,
+	".htm":      MIMEHTML,
+	".html":     MIMEHTML,
+}
+
+// Register adds an extractor for a MIME type, replacing any existing one
+func Register(mime string, extractor Extractor) {
+	mu.Lock()
+	defer mu.Unlock()
+	extractors[mime] = extractor
+}
+
+// Detect sniffs the MIME type of data, using the file name extension to
+// tell apart text formats. Types without an extractor fall back to the
+// closest registered parent type, so any text format extracts as text.
+func Detect(data []byte, filename string) string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	detected := mimetype.Detect(data)
+	if detected.Is(MIMEText) {
+		if mime, ok := extensions[strings.ToLower(filepath.Ext(filename))]; ok {
+			return mime
+		}
+	}
+
+	for m := detected; m != nil; m = m.Parent() {
+		mime, _, _ := strings.Cut(m.String(), ";")
+		if _, ok := extractors[mime]; ok {
+			return mime
+		}
+	}
+	mime, _, _ := strings.Cut(detected.String(), ";")
+	return mime
+}
+
+// Extract returns the text of a file and the MIME type it was read as
+func Extract(data []byte, filename string) (string, string, error) {
+	mime := Detect(data, filename)
+
+	mu.RLock()
+	extractor, ok := extractors[mime]
+	mu.RUnlock()
+	if !ok {
+		return "", mime, fmt.Errorf("%w %v", ErrUnsupported, mime)
+	}
+
+	text, err := extractor(data)
+	if err != nil {
+		return "", mime, fmt.Errorf("unable to extract %v, %w", mime, err)
+	}
+
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return "", mime, ErrNoText
+	}
+	return text, mime, nil
+}
+
+// PlainText passes text through, replacing invalid UTF-8
+func PlainText(data []byte) (string, error) {
+	if utf8.Valid(data) {
+		return string(data), nil
+	}
+	return strings.ToValidUTF8(string(data), "�"), nil
+}
+
+// collapse runs of blank lines and trailing spaces left by extraction
+func tidy(text string) string {
+	lines := strings.Split(text, "\n")
+	kept := lines[:0]
+	blank := true
+	for _, line := range lines {
+		line = strings.TrimRight(line, " \t\r")
+		if line == "" {
+			if blank {
+				continue
+			}
+			blank = true
+		} else {
+			blank = false
+		}
+		kept = append(kept, line)
+	}
+	return strings.TrimSpace(strings.Join(kept, "\n"))
+}
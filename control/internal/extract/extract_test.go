@@ -0,0 +1,140 @@
+package extract
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const page = `<!DOCTYPE html>
+<html><head><title>Ignored</title><style>p { color: red }</style></head>
+<body>
+<nav><a href="/">Home</a> <a href="/about">About</a></nav>
+<main>
+  <h1>Pangolins</h1>
+  <p>Pangolins are <b>scaly</b> mammals.</p>
+  <script>track()</script>
+  <ul><li>Eat ants</li><li>Roll up</li></ul>
+</main>
+<footer>Copyright</footer>
+</body></html>`
+
+const markdown = "Pangolins\n=========\n\nThey are **scaly** and eat [ants](https://example.com).\n\n## Diet\n\n> Mostly `insects`.\n\n```\ncode stays\n```\n\n---\n![a pangolin](pangolin.png)\n"
+
+// assert html keeps main content and drops boilerplate
+func TestHTML(t *testing.T) {
+	text, mime, err := Extract([]byte(page), "page")
+	require.NoError(t, err)
+	assert.Equal(t, MIMEHTML, mime)
+	assert.Equal(t, "Pangolins\n\nPangolins are scaly mammals.\n\nEat ants\n\nRoll up", text)
+}
+
+// assert markdown keeps headings and strips inline formatting
+func TestMarkdown(t *testing.T) {
+	text, mime, err := Extract([]byte(markdown), "README.md")
+	require.NoError(t, err)
+	assert.Equal(t, MIMEMarkdown, mime)
+	assert.Equal(t, "# Pangolins\n\nThey are scaly and eat ants.\n\n## Diet\n\nMostly insects.\n\ncode stays\n\na pangolin", text)
+}
+
+// assert plain text passes through and other text types extract as text
+func TestPlainText(t *testing.T) {
+	text, mime, err := Extract([]byte("  just text\n"), "notes.txt")
+	require.NoError(t, err)
+	assert.Equal(t, MIMEText, mime)
+	assert.Equal(t, "just text", text)
+
+	_, mime, err = Extract([]byte("name,count\npangolin,8\n"), "data.csv")
+	require.NoError(t, err)
+	assert.Equal(t, MIMEText, mime)
+}
+
+// assert docx paragraphs are extracted in order
+func TestDOCX(t *testing.T) {
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	for _, part := range [][2]string{
+		{"[Content_Types].xml", `<?xml version="1.0"?><Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"><Override PartName="/word/document.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.document.main+xml"/></Types>`},
+		{"word/document.xml", `<?xml version="1.0"?><w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>` +
+			`<w:p><w:r><w:t>First</w:t></w:r><w:r><w:t xml:space="preserve"> paragraph</w:t></w:r></w:p>` +
+			`<w:p><w:r><w:t>Second</w:t><w:tab/><w:t>tabbed</w:t></w:r></w:p></w:body></w:document>`},
+	} {
+		file, err := archive.Create(part[0])
+		require.NoError(t, err)
+		_, err = file.Write([]byte(part[1]))
+		require.NoError(t, err)
+	}
+	require.NoError(t, archive.Close())
+
+	text, mime, err := Extract(buf.Bytes(), "report.docx")
+	require.NoError(t, err)
+	assert.Equal(t, MIMEDOCX, mime)
+	assert.Equal(t, "First paragraph\n\nSecond\ttabbed", text)
+
+	// bodies expanding beyond the limit are not decompressed
+	defer func(limit uint64) { maxDocumentXML = limit }(maxDocumentXML)
+	maxDocumentXML = 64
+	_, _, err = Extract(buf.Bytes(), "report.docx")
+	assert.ErrorIs(t, err, ErrTooLarge)
+}
+
+// assert pdf page text is extracted and malformed files error
+func TestPDF(t *testing.T) {
+	text, mime, err := Extract(minimalPDF("Hello pangolin"), "doc.pdf")
+	require.NoError(t, err)
+	assert.Equal(t, MIMEPDF, mime)
+	assert.Contains(t, text, "Hello pangolin")
+
+	_, err = PDF([]byte("%PDF-1.4 truncated"))
+	assert.Error(t, err)
+}
+
+// assert unregistered types are rejected until an extractor is registered
+func TestRegister(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	_, _, err := Extract(png, "image.png")
+	assert.ErrorIs(t, err, ErrUnsupported)
+
+	Register("image/png", func([]byte) (string, error) { return "caption", nil })
+	defer func() {
+		mu.Lock()
+		delete(extractors, "image/png")
+		mu.Unlock()
+	}()
+
+	text, _, err := Extract(png, "image.png")
+	require.NoError(t, err)
+	assert.Equal(t, "caption", text)
+}
+
+// build a single page pdf showing text
+func minimalPDF(text string) []byte {
+	content := fmt.Sprintf("BT /F1 12 Tf 72 712 Td (%v) Tj ET", text)
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Contents 4 0 R /Resources << /Font << /F1 5 0 R >> >> >>",
+		fmt.Sprintf("<< /Length %v >>\nstream\n%v\nendstream", len(content), content),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%v 0 obj\n%v\nendobj\n", i+1, object)
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %v\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %v /Root 1 0 R >>\nstartxref\n%v\n%%%%EOF\n", len(objects)+1, xref)
+	return buf.Bytes()
+}
@@ -0,0 +1,105 @@
+package extract
+
+import (
+	"bytes"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// elements holding boilerplate rather than content
+var boilerplate = map[atom.Atom]bool{
+	atom.Head: true, atom.Script: true, atom.Style: true, atom.Noscript: true,
+	atom.Template: true, atom.Svg: true, atom.Iframe: true, atom.Form: true,
+	atom.Nav: true, atom.Header: true, atom.Footer: true, atom.Aside: true,
+}
+
+// elements that break text into separate paragraphs
+var blocks = map[atom.Atom]bool{
+	atom.P: true, atom.Div: true, atom.Section: true, atom.Article: true, atom.Main: true,
+	atom.H1: true, atom.H2: true, atom.H3: true, atom.H4: true, atom.H5: true, atom.H6: true,
+	atom.Ul: true, atom.Ol: true, atom.Li: true, atom.Dl: true, atom.Dt: true, atom.Dd: true,
+	atom.Table: true, atom.Tr: true, atom.Blockquote: true, atom.Pre: true, atom.Figcaption: true,
+}
+
+// HTML extracts the readable text of a page, stripping navigation, scripts
+// and other boilerplate and preferring the main or article element
+func HTML(data []byte) (string, error) {
+	root, err := html.Parse(bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+
+	content := find(root, atom.Main)
+	if content == nil {
+		content = find(root, atom.Article)
+	}
+	if content == nil {
+		content = root
+	}
+
+	var text strings.Builder
+	walk(&text, content, false)
+	return tidy(text.String()), nil
+}
+
+// find the first element of type a
+func find(node *html.Node, a atom.Atom) *html.Node {
+	if node.Type == html.ElementNode && node.DataAtom == a {
+		return node
+	}
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		if found := find(child, a); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// write the text beneath node, preserving whitespace inside pre
+func walk(text *strings.Builder, node *html.Node, pre bool) {
+	switch node.Type {
+	case html.TextNode:
+		if pre {
+			text.WriteString(node.Data)
+		} else if words := strings.Fields(node.Data); len(words) > 0 {
+			if strings.TrimLeft(node.Data, " \t\n\r") != node.Data {
+				space(text)
+			}
+			text.WriteString(strings.Join(words, " "))
+			if strings.TrimRight(node.Data, " \t\n\r") != node.Data {
+				space(text)
+			}
+		}
+		return
+	case html.ElementNode:
+		if boilerplate[node.DataAtom] {
+			return
+		}
+		if node.DataAtom == atom.Br {
+			text.WriteString("\n")
+			return
+		}
+		pre = pre || node.DataAtom == atom.Pre
+	}
+
+	block := node.Type == html.ElementNode && blocks[node.DataAtom]
+	if block {
+		text.WriteString("\n\n")
+	}
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		walk(text, child, pre)
+	}
+	if block {
+		text.WriteString("\n\n")
+	}
+}
+
+// separate inline text with a space, unless already at a break
+func space(text *strings.Builder) {
+	written := text.String()
+	if len(written) > 0 && !strings.ContainsRune(" \n", rune(written[len(written)-1])) {
+		text.WriteString(" ")
+	}
+}
@@ -0,0 +1,96 @@
+package extract
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	atxHeading    = regexp.MustCompile(`^ {0,3}(#{1,6})\s+(.*?)(?:\s+#+)?\s*$`)
+	setextHeading = regexp.MustCompile(`^ {0,3}(=+|-+)\s*$`)
+	fence         = regexp.MustCompile("^ {0,3}(```|~~~)")
+	rule          = regexp.MustCompile(`^ {0,3}([-*_])(\s*[-*_]){2,}\s*$`)
+	quote         = regexp.MustCompile(`^ {0,3}>\s?`)
+	image         = regexp.MustCompile(`!\[([^\]]*)\]\([^)]*\)`)
+	link          = regexp.MustCompile(`\[([^\]]+)\](?:\([^)]*\)|\[[^\]]*\])`)
+	reference     = regexp.MustCompile(`^ {0,3}\[[^\]]+\]:\s+\S+`)
+	emphasis      = regexp.MustCompile(`(\*\*|__|\*|~~)(\S(?:.*?\S)?)(\*\*|__|\*|~~)`)
+	code          = regexp.MustCompile("`+([^`]+)`+")
+	tag           = regexp.MustCompile(`</?[a-zA-Z][^>]*>`)
+)
+
+// Markdown extracts text from markdown, keeping headings as "#" lines so
+// the section structure survives while inline formatting is stripped
+func Markdown(data []byte) (string, error) {
+	text, err := PlainText(data)
+	if err != nil {
+		return "", err
+	}
+
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	var out []string
+	fenced := ""
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+
+		// code blocks are kept verbatim without their fences
+		if match := fence.FindStringSubmatch(line); match != nil {
+			switch fenced {
+			case "":
+				fenced = match[1]
+				continue
+			case match[1]:
+				fenced = ""
+				continue
+			}
+		}
+		if fenced != "" {
+			out = append(out, line)
+			continue
+		}
+
+		if match := atxHeading.FindStringSubmatch(line); match != nil {
+			out = append(out, "", match[1]+" "+inline(match[2]), "")
+			continue
+		}
+		if i+1 < len(lines) && strings.TrimSpace(line) != "" {
+			if match := setextHeading.FindStringSubmatch(lines[i+1]); match != nil {
+				level := "#"
+				if match[1][0] == '-' {
+					level = "##"
+				}
+				out = append(out, "", level+" "+inline(strings.TrimSpace(line)), "")
+				i++
+				continue
+			}
+		}
+		if rule.MatchString(line) || reference.MatchString(line) {
+			out = append(out, "")
+			continue
+		}
+
+		out = append(out, inline(quote.ReplaceAllString(line, "")))
+	}
+	return tidy(strings.Join(out, "\n")), nil
+}
+
+// strip inline markdown and html, keeping the visible text
+func inline(line string) string {
+	line = image.ReplaceAllString(line, "$1")
+	line = link.ReplaceAllString(line, "$1")
+	line = code.ReplaceAllString(line, "$1")
+	line = tag.ReplaceAllString(line, "")
+	for {
+		stripped := emphasis.ReplaceAllStringFunc(line, func(match string) string {
+			parts := emphasis.FindStringSubmatch(match)
+			if parts[1] != parts[3] {
+				return match
+			}
+			return parts[2]
+		})
+		if stripped == line {
+			return line
+		}
+		line = stripped
+	}
+}
@@ -0,0 +1,45 @@
+package extract
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/ledongthuc/pdf"
+)
+
+// PDF extracts the text of each page, row by row. Malformed files can
+// panic inside the reader, which is returned as an error instead.
+func PDF(data []byte) (text string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("malformed pdf, %v", r)
+		}
+	}()
+
+	reader, err := pdf.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", err
+	}
+
+	var out strings.Builder
+	for n := 1; n <= reader.NumPage(); n++ {
+		page := reader.Page(n)
+		if page.V.IsNull() {
+			continue
+		}
+
+		rows, err := page.GetTextByRow()
+		if err != nil {
+			return "", err
+		}
+		for _, row := range rows {
+			for _, word := range row.Content {
+				out.WriteString(word.S)
+			}
+			out.WriteString("\n")
+		}
+		out.WriteString("\n")
+	}
+	return tidy(out.String()), nil
+}
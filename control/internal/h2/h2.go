@@ -0,0 +1,190 @@
+package h2
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
+	"golang.org/x/net/http2"
+
+	"github.com/christian-nickerson/pangolin/control/internal/configs"
+)
+
+// Protocols offered through ALPN on TLS listeners served by Serve
+var Protocols = []string{http2.NextProtoTLS, "http/1.1"}
+
+// time allowed for a TLS handshake when no read timeout is configured
+const handshakeTimeout = 10 * time.Second
+
+// Serve serves app on listener until it is closed. TLS connections which
+// negotiate HTTP/2 are served by an HTTP/2 server handing requests to app,
+// the rest are served by app itself as HTTP/1.1.
+func Serve(app *fiber.App, listener net.Listener, config configs.APIConfig) error {
+	base := &http.Server{
+		Handler:      Handler(app),
+		ReadTimeout:  time.Duration(config.ReadTimeout) * time.Second,
+		WriteTimeout: time.Duration(config.WriteTimeout) * time.Second,
+		IdleTimeout:  time.Duration(config.IdleTimeout) * time.Second,
+	}
+	server := &http2.Server{}
+	if err := http2.ConfigureServer(base, server); err != nil {
+		return err
+	}
+
+	split := &splitListener{
+		Listener:  listener,
+		conns:     make(chan net.Conn),
+		errs:      make(chan error),
+		closed:    make(chan struct{}),
+		handshake: base.ReadTimeout,
+		serve: func(conn net.Conn) {
+			server.ServeConn(conn, &http2.ServeConnOpts{BaseConfig: base, Handler: base.Handler})
+		},
+		shutdown: func() { base.Shutdown(context.Background()) },
+	}
+	if split.handshake <= 0 {
+		split.handshake = handshakeTimeout
+	}
+	go split.run()
+	return app.Listener(split)
+}
+
+// splitListener completes TLS handshakes, serving connections which
+// negotiate HTTP/2 itself and passing the rest to Accept
+type splitListener struct {
+	net.Listener
+	conns     chan net.Conn
+	errs      chan error
+	closed    chan struct{}
+	once      sync.Once
+	handshake time.Duration
+	serve     func(net.Conn)
+	shutdown  func()
+}
+
+func (l *splitListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case err := <-l.errs:
+		return nil, err
+	case <-l.closed:
+		return nil, net.ErrClosed
+	}
+}
+
+// Close stops accepting connections and asks HTTP/2 clients to go away
+// once their requests finish
+func (l *splitListener) Close() error {
+	l.once.Do(func() {
+		close(l.closed)
+		l.shutdown()
+	})
+	return l.Listener.Close()
+}
+
+// accept connections until the listener closes, passing errors to Accept
+func (l *splitListener) run() {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			select {
+			case l.errs <- err:
+			case <-l.closed:
+				return
+			}
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			continue
+		}
+		go l.route(conn)
+	}
+}
+
+// route a connection by the protocol it negotiated
+func (l *splitListener) route(conn net.Conn) {
+	if secure, ok := conn.(*tls.Conn); ok {
+		ctx, cancel := context.WithTimeout(context.Background(), l.handshake)
+		err := secure.HandshakeContext(ctx)
+		cancel()
+		if err != nil {
+			conn.Close()
+			return
+		}
+		if secure.ConnectionState().NegotiatedProtocol == http2.NextProtoTLS {
+			l.serve(conn)
+			return
+		}
+	}
+
+	select {
+	case l.conns <- conn:
+	case <-l.closed:
+		conn.Close()
+	}
+}
+
+// headers which only apply to an HTTP/1.1 connection
+var hopHeaders = []string{fiber.HeaderConnection, fiber.HeaderTransferEncoding, fiber.HeaderKeepAlive, fiber.HeaderUpgrade}
+
+// Handler hands net/http requests to app. Request bodies are streamed to
+// app as when it serves HTTP/1.1 itself, and response bodies are flushed
+// as they are written.
+func Handler(app *fiber.App) http.Handler {
+	handle := app.Handler()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request fasthttp.Request
+		request.Header.SetMethod(r.Method)
+		request.SetRequestURI(r.RequestURI)
+		request.SetHost(r.Host)
+		for key, values := range r.Header {
+			for _, value := range values {
+				request.Header.Add(key, value)
+			}
+		}
+
+		remote, err := net.ResolveTCPAddr("tcp", r.RemoteAddr)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var ctx fasthttp.RequestCtx
+		ctx.Init(&request, remote, nil)
+		if r.ContentLength != 0 {
+			ctx.Request.SetBodyStream(r.Body, int(r.ContentLength))
+		}
+		handle(&ctx)
+
+		header := w.Header()
+		ctx.Response.Header.VisitAll(func(key, value []byte) {
+			header.Add(string(key), string(value))
+		})
+		for _, key := range hopHeaders {
+			header.Del(key)
+		}
+		w.WriteHeader(ctx.Response.StatusCode())
+		if r.Method != http.MethodHead {
+			ctx.Response.BodyWriteTo(flushWriter{w})
+		}
+	})
+}
+
+// flushWriter flushes each write to the client
+type flushWriter struct {
+	http.ResponseWriter
+}
+
+func (w flushWriter) Write(p []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(p)
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	return n, err
+}
@@ -0,0 +1,83 @@
+package h2
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/christian-nickerson/pangolin/control/internal/certs"
+	"github.com/christian-nickerson/pangolin/control/internal/certs/certstest"
+	"github.com/christian-nickerson/pangolin/control/internal/configs"
+	"github.com/christian-nickerson/pangolin/control/internal/models"
+)
+
+// serve an app echoing request bodies over TLS, returning its address and
+// the CA pool verifying it
+func serve(t *testing.T) (string, *x509.CertPool) {
+	caFile, certFile, keyFile := certstest.WriteFiles(t, t.TempDir())
+	reloader, err := certs.NewReloader(certFile, keyFile, "")
+	require.NoError(t, err)
+	t.Cleanup(func() { reloader.Close() })
+
+	config := reloader.ServerConfig()
+	config.NextProtos = Protocols
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	app := fiber.New(fiber.Config{DisableStartupMessage: true, StreamRequestBody: true})
+	app.Use(models.LimitBody(8))
+	app.Post("/echo", func(c *fiber.Ctx) error {
+		return c.Send(c.Body())
+	})
+	go Serve(app, tls.NewListener(listener, config), configs.APIConfig{})
+	t.Cleanup(func() { app.Shutdown() })
+
+	caPEM, err := os.ReadFile(caFile)
+	require.NoError(t, err)
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(caPEM)
+	return listener.Addr().String(), pool
+}
+
+// post body to the echo route, returning the protocol, status and body
+func echo(t *testing.T, client *http.Client, address, body string) (int, int, string) {
+	response, err := client.Post("https://"+address+"/echo", "text/plain", strings.NewReader(body))
+	require.NoError(t, err)
+	defer response.Body.Close()
+	data, err := io.ReadAll(response.Body)
+	require.NoError(t, err)
+	return response.ProtoMajor, response.StatusCode, string(data)
+}
+
+// assert clients negotiating HTTP/2 and HTTP/1.1 are both served
+func TestServe(t *testing.T) {
+	address, pool := serve(t)
+
+	modern := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}, ForceAttemptHTTP2: true}}
+	proto, status, body := echo(t, modern, address, "hello")
+	assert.Equal(t, 2, proto)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "hello", body)
+
+	// bodies streamed over HTTP/2 are limited as any other
+	_, status, _ = echo(t, modern, address, "longer than the limit")
+	assert.Equal(t, http.StatusRequestEntityTooLarge, status)
+
+	legacy := &http.Client{Transport: &http.Transport{
+		TLSClientConfig: &tls.Config{RootCAs: pool},
+		TLSNextProto:    map[string]func(string, *tls.Conn) http.RoundTripper{},
+	}}
+	proto, status, body = echo(t, legacy, address, "hello")
+	assert.Equal(t, 1, proto)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "hello", body)
+}
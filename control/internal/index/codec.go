@@ -0,0 +1,142 @@
+package index
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"math"
+
+	"github.com/christian-nickerson/pangolin/control/internal/models"
+)
+
+// WAL entries and segments share one file format:
+//
+//	magic "PGIX" | version u8 | dims u32 | count u32
+//	count × (op u8 | id u64 | dims × f64 when op is upsert)
+//	crc32c u32 of everything before it
+//
+// all integers little endian
+const (
+	magic         = "PGIX"
+	formatVersion = 1
+	headerSize    = len(magic) + 1 + 4 + 4
+	checksumSize  = 4
+
+	opUpsert byte = 1
+	opDelete byte = 2
+)
+
+var (
+	ErrCorrupt = errors.New("corrupt index file")
+	castagnoli = crc32.MakeTable(crc32.Castagnoli)
+)
+
+// record is one index write, a nil vector deletes the id
+type record struct {
+	id     uint64
+	vector models.Vector
+}
+
+// encode records of dims dimensions
+func encode(dims int, records []record) []byte {
+	size := headerSize + checksumSize
+	for _, r := range records {
+		size += 1 + 8
+		if r.vector != nil {
+			size += 8 * dims
+		}
+	}
+
+	data := make([]byte, 0, size)
+	data = append(data, magic...)
+	data = append(data, formatVersion)
+	data = binary.LittleEndian.AppendUint32(data, uint32(dims))
+	data = binary.LittleEndian.AppendUint32(data, uint32(len(records)))
+	for _, r := range records {
+		if r.vector == nil {
+			data = append(data, opDelete)
+			data = binary.LittleEndian.AppendUint64(data, r.id)
+			continue
+		}
+		data = append(data, opUpsert)
+		data = binary.LittleEndian.AppendUint64(data, r.id)
+		for _, component := range r.vector {
+			data = binary.LittleEndian.AppendUint64(data, math.Float64bits(component))
+		}
+	}
+	return binary.LittleEndian.AppendUint32(data, crc32.Checksum(data, castagnoli))
+}
+
+// decode records, verifying the checksum before reading them
+func decode(data []byte) (int, []record, error) {
+	if len(data) < headerSize+checksumSize || string(data[:len(magic)]) != magic {
+		return 0, nil, fmt.Errorf("%w, bad header", ErrCorrupt)
+	}
+	body, sum := data[:len(data)-checksumSize], data[len(data)-checksumSize:]
+	if crc32.Checksum(body, castagnoli) != binary.LittleEndian.Uint32(sum) {
+		return 0, nil, fmt.Errorf("%w, checksum mismatch", ErrCorrupt)
+	}
+	if version := body[len(magic)]; version != formatVersion {
+		return 0, nil, fmt.Errorf("%w, unknown version %v", ErrCorrupt, version)
+	}
+
+	dims := int(binary.LittleEndian.Uint32(body[len(magic)+1:]))
+	count := int(binary.LittleEndian.Uint32(body[len(magic)+5:]))
+	records := make([]record, 0, count)
+	offset := headerSize
+	for n := 0; n < count; n++ {
+		if offset+9 > len(body) {
+			return 0, nil, fmt.Errorf("%w, truncated record", ErrCorrupt)
+		}
+		op, id := body[offset], binary.LittleEndian.Uint64(body[offset+1:])
+		offset += 9
+
+		switch op {
+		case opDelete:
+			records = append(records, record{id: id})
+		case opUpsert:
+			if offset+8*dims > len(body) {
+				return 0, nil, fmt.Errorf("%w, truncated vector", ErrCorrupt)
+			}
+			vector := make(models.Vector, dims)
+			for i := range vector {
+				vector[i] = math.Float64frombits(binary.LittleEndian.Uint64(body[offset:]))
+				offset += 8
+			}
+			records = append(records, record{id: id, vector: vector})
+		default:
+			return 0, nil, fmt.Errorf("%w, unknown op %v", ErrCorrupt, op)
+		}
+	}
+	if offset != len(body) {
+		return 0, nil, fmt.Errorf("%w, trailing bytes", ErrCorrupt)
+	}
+	return dims, records, nil
+}
+
+// Encode writes entries in the segment format, for files kept outside the index
+func Encode(dims int, entries []Entry) []byte {
+	records := make([]record, len(entries))
+	for i, entry := range entries {
+		records[i] = record{id: entry.ID, vector: entry.Vector}
+	}
+	return encode(dims, records)
+}
+
+// Decode reads entries written by Encode, verifying their checksum
+func Decode(data []byte) (int, []Entry, error) {
+	dims, records, err := decode(data)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	entries := make([]Entry, 0, len(records))
+	for _, r := range records {
+		if r.vector == nil {
+			return 0, nil, fmt.Errorf("%w, unexpected delete", ErrCorrupt)
+		}
+		entries = append(entries, Entry{ID: r.id, Vector: r.vector})
+	}
+	return dims, entries, nil
+}
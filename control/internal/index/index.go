@@ -0,0 +1,324 @@
+package index
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"sync"
+
+	"github.com/christian-nickerson/pangolin/control/internal/models"
+)
+
+var ErrDimensions = errors.New("vector dimensions do not match the index")
+
+// Hit is a search result, higher scores are closer. Euclidean
+// scores are negated distances so all metrics sort the same way.
+type Hit struct {
+	ID    uint64  `json:"id"`
+	Score float64 `json:"score"`
+}
+
+// Entry is a vector and the id it is stored under
+type Entry struct {
+	ID     uint64
+	Vector models.Vector
+}
+
+// Batch is a set of writes applied together, deletes before upserts
+type Batch struct {
+	Deletes []uint64
+	Upserts []Entry
+}
+
+// Index is an exact in-memory vector index over a single collection,
+// optionally persisted by a log
+type Index struct {
+	metric models.Metric
+	log    *Log
+
+	// writing orders batches so the log replays in the applied order
+	writing sync.Mutex
+
+	mu        sync.RWMutex
+	dims      int
+	positions map[uint64]int
+	ids       []uint64
+	vectors   []models.Vector
+}
+
+// New creates an empty index scoring with metric
+func New(metric models.Metric) *Index {
+	return &Index{metric: metric, positions: make(map[uint64]int)}
+}
+
+// Write logs a batch when the index is persisted, then applies it.
+// Batches with mismatched dimensions are rejected whole.
+func (i *Index) Write(ctx context.Context, batch Batch) error {
+	if len(batch.Deletes) == 0 && len(batch.Upserts) == 0 {
+		return nil
+	}
+
+	i.writing.Lock()
+	defer i.writing.Unlock()
+
+	dims := i.Dimensions()
+	for _, entry := range batch.Upserts {
+		if dims == 0 {
+			dims = len(entry.Vector)
+		}
+		if len(entry.Vector) != dims {
+			return fmt.Errorf("%w, vector has %v dimensions, index has %v", ErrDimensions, len(entry.Vector), dims)
+		}
+	}
+
+	records := make([]record, 0, len(batch.Deletes)+len(batch.Upserts))
+	for _, id := range batch.Deletes {
+		records = append(records, record{id: id})
+	}
+	for _, entry := range batch.Upserts {
+		records = append(records, record{id: entry.ID, vector: entry.Vector})
+	}
+
+	if i.log != nil {
+		if err := i.log.append(ctx, dims, records); err != nil {
+			return fmt.Errorf("unable to log index write, %w", err)
+		}
+	}
+	return i.replay(dims, records)
+}
+
+// replay applies logged records
+func (i *Index) replay(dims int, records []record) error {
+	for _, r := range records {
+		if r.vector == nil {
+			i.Delete(r.id)
+			continue
+		}
+		if err := i.Upsert(r.id, r.vector); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Flush writes logged batches into a segment, merging segments once
+// there are more than maxSegments. Memory only indexes have nothing to flush.
+func (i *Index) Flush(ctx context.Context, maxSegments int) error {
+	if i.log == nil {
+		return nil
+	}
+	if err := i.log.Flush(ctx, i.Dimensions()); err != nil {
+		return err
+	}
+	if maxSegments > 0 && i.log.Segments() > maxSegments {
+		return i.log.Compact(ctx)
+	}
+	return nil
+}
+
+// set the dimensions of an empty index
+func (i *Index) setDimensions(dims int) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.dims == 0 {
+		i.dims = dims
+	}
+}
+
+// Upsert inserts or replaces the vector for id. The first vector
+// fixes the dimensions of the index.
+func (i *Index) Upsert(id uint64, vector models.Vector) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if i.dims == 0 {
+		i.dims = len(vector)
+	}
+	if len(vector) != i.dims {
+		return fmt.Errorf("%w, vector has %v dimensions, index has %v", ErrDimensions, len(vector), i.dims)
+	}
+
+	if position, ok := i.positions[id]; ok {
+		i.vectors[position] = vector
+		return nil
+	}
+
+	i.positions[id] = len(i.ids)
+	i.ids = append(i.ids, id)
+	i.vectors = append(i.vectors, vector)
+	return nil
+}
+
+// Get returns the vector for id
+func (i *Index) Get(id uint64) (models.Vector, bool) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	position, ok := i.positions[id]
+	if !ok {
+		return nil, false
+	}
+	return i.vectors[position], true
+}
+
+// Entries returns a copy of every vector held and its id
+func (i *Index) Entries() []Entry {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	entries := make([]Entry, len(i.ids))
+	for position, id := range i.ids {
+		entries[position] = Entry{ID: id, Vector: i.vectors[position]}
+	}
+	return entries
+}
+
+// Delete removes the vector for id, reporting whether it was present
+func (i *Index) Delete(id uint64) bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	position, ok := i.positions[id]
+	if !ok {
+		return false
+	}
+
+	// move the last vector into the gap
+	last := len(i.ids) - 1
+	i.ids[position], i.vectors[position] = i.ids[last], i.vectors[last]
+	i.positions[i.ids[position]] = position
+	i.ids, i.vectors = i.ids[:last], i.vectors[:last]
+	delete(i.positions, id)
+	return true
+}
+
+// Search returns the k closest vectors to query, closest first
+func (i *Index) Search(query models.Vector, k int) ([]Hit, error) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	if len(i.ids) == 0 || k <= 0 {
+		return []Hit{}, nil
+	}
+	if len(query) != i.dims {
+		return nil, fmt.Errorf("%w, query has %v dimensions, index has %v", ErrDimensions, len(query), i.dims)
+	}
+
+	ranker := NewRanker(i.metric, query, k)
+	for position, vector := range i.vectors {
+		ranker.Add(i.ids[position], vector)
+	}
+	return ranker.Hits(), nil
+}
+
+// Ranker keeps the k closest to a query of the vectors it is shown, for
+// searching vectors held outside an index. A vector shown again under the
+// same id is ranked once.
+type Ranker struct {
+	query  models.Vector
+	score  func(models.Vector) float64
+	k      int
+	top    minHeap
+	ranked map[uint64]bool
+}
+
+// NewRanker ranks vectors by metric against query
+func NewRanker(metric models.Metric, query models.Vector, k int) *Ranker {
+	return &Ranker{query: query, score: scorer(metric, query), k: k, ranked: map[uint64]bool{}}
+}
+
+// Add scores the vector stored under id, skipping vectors whose
+// dimensions differ from the query's
+func (r *Ranker) Add(id uint64, vector models.Vector) {
+	if r.k <= 0 || len(vector) != len(r.query) {
+		return
+	}
+	hit := Hit{ID: id, Score: r.score(vector)}
+	if r.top.Len() == r.k && hit.Score <= r.top[0].Score || r.ranked[id] {
+		return
+	}
+
+	r.ranked[id] = true
+	if r.top.Len() < r.k {
+		heap.Push(&r.top, hit)
+		return
+	}
+	delete(r.ranked, r.top[0].ID)
+	r.top[0] = hit
+	heap.Fix(&r.top, 0)
+}
+
+// Hits returns the closest vectors shown, closest first
+func (r *Ranker) Hits() []Hit {
+	top := slices.Clone(r.top)
+	hits := make([]Hit, top.Len())
+	for n := len(hits) - 1; n >= 0; n-- {
+		hits[n] = heap.Pop(&top).(Hit)
+	}
+	return hits
+}
+
+// Len returns the number of vectors held
+func (i *Index) Len() int {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return len(i.ids)
+}
+
+// Dimensions returns the vector dimensions, zero until the first upsert
+func (i *Index) Dimensions() int {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.dims
+}
+
+// build a scoring function for the metric against query
+func scorer(metric models.Metric, query models.Vector) func(models.Vector) float64 {
+	switch metric {
+	case models.MetricDot:
+		return func(v models.Vector) float64 { return dot(query, v) }
+	case models.MetricEuclidean:
+		return func(v models.Vector) float64 {
+			var sum float64
+			for n := range query {
+				d := query[n] - v[n]
+				sum += d * d
+			}
+			return -math.Sqrt(sum)
+		}
+	default:
+		queryNorm := math.Sqrt(dot(query, query))
+		return func(v models.Vector) float64 {
+			norm := queryNorm * math.Sqrt(dot(v, v))
+			if norm == 0 {
+				return 0
+			}
+			return dot(query, v) / norm
+		}
+	}
+}
+
+func dot(a, b models.Vector) float64 {
+	var sum float64
+	for n := range a {
+		sum += a[n] * b[n]
+	}
+	return sum
+}
+
+// minHeap keeps the lowest scoring hit at the root
+type minHeap []Hit
+
+func (h minHeap) Len() int           { return len(h) }
+func (h minHeap) Less(a, b int) bool { return h[a].Score < h[b].Score }
+func (h minHeap) Swap(a, b int)      { h[a], h[b] = h[b], h[a] }
+func (h *minHeap) Push(x any)        { *h = append(*h, x.(Hit)) }
+func (h *minHeap) Pop() any {
+	old := *h
+	hit := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return hit
+}
@@ -0,0 +1,53 @@
+package index
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/christian-nickerson/pangolin/control/internal/models"
+)
+
+// assert search ranks the closest vectors first for each metric
+func TestSearch(t *testing.T) {
+	for _, metric := range []models.Metric{models.MetricCosine, models.MetricDot, models.MetricEuclidean} {
+		index := New(metric)
+		require.NoError(t, index.Upsert(1, models.Vector{1, 0}))
+		require.NoError(t, index.Upsert(2, models.Vector{0, 1}))
+		require.NoError(t, index.Upsert(3, models.Vector{0.9, 0.1}))
+
+		hits, err := index.Search(models.Vector{1, 0}, 2)
+		require.NoError(t, err)
+		require.Len(t, hits, 2, metric)
+		assert.Equal(t, uint64(1), hits[0].ID, metric)
+		assert.Equal(t, uint64(3), hits[1].ID, metric)
+	}
+}
+
+// assert vectors can be replaced and deleted
+func TestUpsertDelete(t *testing.T) {
+	index := New(models.MetricCosine)
+	require.NoError(t, index.Upsert(1, models.Vector{1, 0}))
+	require.NoError(t, index.Upsert(2, models.Vector{0, 1}))
+	require.NoError(t, index.Upsert(1, models.Vector{0, 1}))
+	assert.Equal(t, 2, index.Len())
+
+	assert.True(t, index.Delete(1))
+	assert.False(t, index.Delete(1))
+	assert.Equal(t, 1, index.Len())
+
+	hits, err := index.Search(models.Vector{0, 1}, 5)
+	require.NoError(t, err)
+	assert.Equal(t, []Hit{{ID: 2, Score: 1}}, hits)
+}
+
+// assert mismatched dimensions are rejected
+func TestDimensions(t *testing.T) {
+	index := New(models.MetricDot)
+	require.NoError(t, index.Upsert(1, models.Vector{1, 0}))
+	assert.Error(t, index.Upsert(2, models.Vector{1, 0, 0}))
+
+	_, err := index.Search(models.Vector{1}, 1)
+	assert.Error(t, err)
+}
@@ -0,0 +1,394 @@
+package index
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/goccy/go-json"
+
+	"github.com/christian-nickerson/pangolin/control/internal/models"
+	"github.com/christian-nickerson/pangolin/control/internal/storage"
+)
+
+var ErrConcurrentWriter = errors.New("index manifest changed by another writer")
+
+// manifest lists the segments holding an index, oldest first. WAL entries
+// up to the checkpoint are in segments and are skipped on recovery.
+type manifest struct {
+	Checkpoint uint64    `json:"checkpoint"`
+	Dimensions int       `json:"dimensions"`
+	Segments   []segment `json:"segments"`
+}
+
+// segment is an immutable file of the writes in a range of WAL entries
+type segment struct {
+	Key     string `json:"key"`
+	First   uint64 `json:"first"`
+	Last    uint64 `json:"last"`
+	Records int    `json:"records"`
+	Size    int64  `json:"size"`
+}
+
+// entry is a WAL entry not yet flushed to a segment
+type entry struct {
+	seq     uint64
+	records []record
+}
+
+// Log persists the writes to one index in a blob store. Each write is a
+// WAL entry blob, flushed periodically into segments which are merged
+// once there are too many.
+type Log struct {
+	store  storage.BlobStore
+	prefix string
+
+	// mu guards the WAL, appends are ordered by Index.Write
+	mu      sync.Mutex
+	next    uint64
+	pending []entry
+	// wake is signalled once flushAt entries are pending
+	flushAt int
+	wake    chan struct{}
+	// err is set when recovery fails, so writes cannot overwrite the
+	// entries that were not replayed
+	err error
+
+	// flushing guards the manifest and segments
+	flushing sync.Mutex
+	manifest manifest
+	etag     string
+	closed   bool
+}
+
+// newLog creates an empty log keeping blobs under prefix
+func newLog(store storage.BlobStore, prefix string) *Log {
+	return &Log{store: store, prefix: prefix, next: 1}
+}
+
+func (l *Log) manifestKey() string { return storage.Key(l.prefix, "manifest.json") }
+
+func (l *Log) walKey(seq uint64) string {
+	return storage.Key(l.prefix, "wal", fmt.Sprintf("%020d.log", seq))
+}
+
+func (l *Log) segmentKey(first, last uint64) string {
+	return storage.Key(l.prefix, "segments", fmt.Sprintf("%020d-%020d.seg", first, last))
+}
+
+// append writes records as the next WAL entry
+func (l *Log) append(ctx context.Context, dims int, records []record) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.err != nil {
+		return l.err
+	}
+
+	data := encode(dims, records)
+	// entries are never overwritten, a conflict means another writer
+	_, err := l.store.Put(ctx, l.walKey(l.next), bytes.NewReader(data), int64(len(data)), storage.Condition{IfNoneMatch: true})
+	if errors.Is(err, storage.ErrPreconditionFailed) {
+		return ErrConcurrentWriter
+	}
+	if err != nil {
+		return err
+	}
+
+	l.pending = append(l.pending, entry{seq: l.next, records: records})
+	l.next++
+	if l.flushAt > 0 && len(l.pending) >= l.flushAt {
+		select {
+		case l.wake <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// Pending returns the number of WAL entries not yet flushed
+func (l *Log) Pending() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.pending)
+}
+
+// Segments returns the number of segments in the manifest
+func (l *Log) Segments() int {
+	l.flushing.Lock()
+	defer l.flushing.Unlock()
+	return len(l.manifest.Segments)
+}
+
+// Flush writes pending WAL entries into a new segment, then removes them
+func (l *Log) Flush(ctx context.Context, dims int) error {
+	l.flushing.Lock()
+	defer l.flushing.Unlock()
+	if l.closed || l.err != nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	entries := l.pending
+	l.mu.Unlock()
+	if len(entries) == 0 {
+		return nil
+	}
+
+	var records []record
+	for _, entry := range entries {
+		records = append(records, entry.records...)
+	}
+	first, last := entries[0].seq, entries[len(entries)-1].seq
+	written, err := l.writeSegment(ctx, first, last, dims, latest(records, false))
+	if err != nil {
+		return err
+	}
+
+	next := l.manifest
+	next.Checkpoint, next.Dimensions = last, dims
+	next.Segments = append(append([]segment{}, l.manifest.Segments...), written)
+	if err := l.saveManifest(ctx, next); err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	l.pending = l.pending[len(entries):]
+	l.mu.Unlock()
+
+	for _, entry := range entries {
+		if err := l.store.Delete(ctx, l.walKey(entry.seq)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Compact merges every segment into one, dropping deleted vectors
+func (l *Log) Compact(ctx context.Context) error {
+	l.flushing.Lock()
+	defer l.flushing.Unlock()
+	if l.closed || l.err != nil || len(l.manifest.Segments) < 2 {
+		return nil
+	}
+
+	segments := l.manifest.Segments
+	var records []record
+	for _, segment := range segments {
+		_, segmentRecords, err := l.readSegment(ctx, segment)
+		if err != nil {
+			return err
+		}
+		records = append(records, segmentRecords...)
+	}
+
+	dims := l.manifest.Dimensions
+	written, err := l.writeSegment(ctx, segments[0].First, segments[len(segments)-1].Last, dims, latest(records, true))
+	if err != nil {
+		return err
+	}
+
+	next := l.manifest
+	next.Segments = []segment{written}
+	if err := l.saveManifest(ctx, next); err != nil {
+		return err
+	}
+
+	for _, segment := range segments {
+		if err := l.store.Delete(ctx, segment.Key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// close stops the log writing segments, waiting for a running flush
+func (l *Log) close() {
+	l.flushing.Lock()
+	defer l.flushing.Unlock()
+	l.closed = true
+}
+
+// recover loads segments then replays the WAL tail into index
+func (l *Log) recover(ctx context.Context, index *Index) error {
+	l.flushing.Lock()
+	defer l.flushing.Unlock()
+
+	data, etag, err := l.read(ctx, l.manifestKey())
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+	case err != nil:
+		return err
+	default:
+		if err := json.Unmarshal(data, &l.manifest); err != nil {
+			return fmt.Errorf("%w, manifest %v", ErrCorrupt, err)
+		}
+		l.etag = etag
+	}
+
+	if l.manifest.Dimensions > 0 {
+		index.setDimensions(l.manifest.Dimensions)
+	}
+	for _, segment := range l.manifest.Segments {
+		dims, records, err := l.readSegment(ctx, segment)
+		if err != nil {
+			return err
+		}
+		if err := index.replay(dims, records); err != nil {
+			return fmt.Errorf("segment %v, %w", segment.Key, err)
+		}
+	}
+
+	if err := l.cleanSegments(ctx); err != nil {
+		return err
+	}
+	return l.replayWAL(ctx, index)
+}
+
+// replay WAL entries after the checkpoint, deleting those before it
+func (l *Log) replayWAL(ctx context.Context, index *Index) error {
+	stored, err := l.store.List(ctx, storage.Key(l.prefix, "wal")+"/")
+	if err != nil {
+		return err
+	}
+
+	l.next = l.manifest.Checkpoint + 1
+	for _, blob := range stored {
+		seq, err := strconv.ParseUint(strings.TrimSuffix(blob.Key[strings.LastIndex(blob.Key, "/")+1:], ".log"), 10, 64)
+		if err != nil {
+			continue
+		}
+		if seq <= l.manifest.Checkpoint {
+			// flushed before a crash removed it
+			if err := l.store.Delete(ctx, blob.Key); err != nil {
+				return err
+			}
+			continue
+		}
+		if seq != l.next {
+			return fmt.Errorf("%w, wal entry %v missing", ErrCorrupt, l.next)
+		}
+
+		data, _, err := l.read(ctx, blob.Key)
+		if err != nil {
+			return err
+		}
+		dims, records, err := decode(data)
+		if err != nil {
+			return fmt.Errorf("wal entry %v, %w", seq, err)
+		}
+		if err := index.replay(dims, records); err != nil {
+			return fmt.Errorf("wal entry %v, %w", seq, err)
+		}
+
+		l.pending = append(l.pending, entry{seq: seq, records: records})
+		l.next++
+	}
+	return nil
+}
+
+// delete segments missing from the manifest, left by a crashed flush or compaction
+func (l *Log) cleanSegments(ctx context.Context) error {
+	stored, err := l.store.List(ctx, storage.Key(l.prefix, "segments")+"/")
+	if err != nil {
+		return err
+	}
+
+	listed := map[string]bool{}
+	for _, segment := range l.manifest.Segments {
+		listed[segment.Key] = true
+	}
+	for _, blob := range stored {
+		if !listed[blob.Key] {
+			if err := l.store.Delete(ctx, blob.Key); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// write records to a new segment covering WAL entries first to last
+func (l *Log) writeSegment(ctx context.Context, first, last uint64, dims int, records []record) (segment, error) {
+	data := encode(dims, records)
+	key := l.segmentKey(first, last)
+	if _, err := l.store.Put(ctx, key, bytes.NewReader(data), int64(len(data)), storage.Condition{}); err != nil {
+		return segment{}, err
+	}
+	return segment{Key: key, First: first, Last: last, Records: len(records), Size: int64(len(data))}, nil
+}
+
+// read and verify a segment listed in the manifest
+func (l *Log) readSegment(ctx context.Context, segment segment) (int, []record, error) {
+	data, _, err := l.read(ctx, segment.Key)
+	if err != nil {
+		return 0, nil, fmt.Errorf("segment %v, %w", segment.Key, err)
+	}
+	if int64(len(data)) != segment.Size {
+		return 0, nil, fmt.Errorf("segment %v, %w, size %v not %v", segment.Key, ErrCorrupt, len(data), segment.Size)
+	}
+
+	dims, records, err := decode(data)
+	if err != nil {
+		return 0, nil, fmt.Errorf("segment %v, %w", segment.Key, err)
+	}
+	return dims, records, nil
+}
+
+// replace the manifest, conditional on it being unchanged since read
+func (l *Log) saveManifest(ctx context.Context, next manifest) error {
+	data, err := json.Marshal(next)
+	if err != nil {
+		return err
+	}
+
+	condition := storage.Condition{IfMatch: l.etag, IfNoneMatch: l.etag == ""}
+	info, err := l.store.Put(ctx, l.manifestKey(), bytes.NewReader(data), int64(len(data)), condition)
+	if errors.Is(err, storage.ErrPreconditionFailed) {
+		return ErrConcurrentWriter
+	}
+	if err != nil {
+		return err
+	}
+
+	l.manifest, l.etag = next, info.ETag
+	return nil
+}
+
+// read a blob and its etag
+func (l *Log) read(ctx context.Context, key string) ([]byte, string, error) {
+	reader, info, err := l.store.Get(ctx, key)
+	if err != nil {
+		return nil, "", err
+	}
+	defer reader.Close()
+
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(reader); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), info.ETag, nil
+}
+
+// latest keeps the last write to each id, ordered by id. Deletes are
+// dropped when the records hold every earlier write.
+func latest(records []record, dropDeletes bool) []record {
+	last := make(map[uint64]models.Vector, len(records))
+	for _, r := range records {
+		last[r.id] = r.vector
+	}
+
+	merged := make([]record, 0, len(last))
+	for id, vector := range last {
+		if vector == nil && dropDeletes {
+			continue
+		}
+		merged = append(merged, record{id: id, vector: vector})
+	}
+	sort.Slice(merged, func(a, b int) bool { return merged[a].id < merged[b].id })
+	return merged
+}
@@ -0,0 +1,170 @@
+package index
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/christian-nickerson/pangolin/control/internal/configs"
+	"github.com/christian-nickerson/pangolin/control/internal/models"
+	"github.com/christian-nickerson/pangolin/control/internal/storage"
+)
+
+// open the index persisted in store, recovering what it holds
+func open(t *testing.T, store storage.BlobStore) *Index {
+	index := New(models.MetricCosine)
+	index.log = newLog(store, "index")
+	require.NoError(t, index.log.recover(context.Background(), index))
+	return index
+}
+
+func newStore(t *testing.T) storage.BlobStore {
+	store, err := storage.NewFilesystem(t.TempDir())
+	require.NoError(t, err)
+	return store
+}
+
+// list the keys under prefix
+func keys(t *testing.T, store storage.BlobStore, prefix string) []string {
+	blobs, err := store.List(context.Background(), prefix)
+	require.NoError(t, err)
+	keys := make([]string, len(blobs))
+	for i, blob := range blobs {
+		keys[i] = blob.Key
+	}
+	return keys
+}
+
+// assert records round trip and corruption is detected
+func TestCodec(t *testing.T) {
+	records := []record{{id: 1, vector: models.Vector{1, 0.5}}, {id: 2}}
+	data := encode(2, records)
+
+	dims, decoded, err := decode(data)
+	require.NoError(t, err)
+	assert.Equal(t, 2, dims)
+	assert.Equal(t, records, decoded)
+
+	data[len(data)/2] ^= 0xff
+	_, _, err = decode(data)
+	assert.ErrorIs(t, err, ErrCorrupt)
+	_, _, err = decode(data[:5])
+	assert.ErrorIs(t, err, ErrCorrupt)
+}
+
+// assert writes are recovered from the WAL before any flush
+func TestRecoverWAL(t *testing.T) {
+	ctx := context.Background()
+	store := newStore(t)
+	index := open(t, store)
+	require.NoError(t, index.Write(ctx, Batch{Upserts: []Entry{{1, models.Vector{1, 0}}, {2, models.Vector{0, 1}}}}))
+	require.NoError(t, index.Write(ctx, Batch{Deletes: []uint64{1}, Upserts: []Entry{{3, models.Vector{1, 1}}}}))
+	assert.Error(t, index.Write(ctx, Batch{Upserts: []Entry{{4, models.Vector{1, 0, 0}}}}))
+	assert.Len(t, keys(t, store, "index/wal/"), 2, "rejected batches are not logged")
+
+	recovered := open(t, store)
+	assert.Equal(t, 2, recovered.Len())
+	_, ok := recovered.Get(1)
+	assert.False(t, ok)
+	assert.Equal(t, 2, recovered.log.Pending())
+
+	// appends continue after the replayed entries
+	require.NoError(t, recovered.Write(ctx, Batch{Deletes: []uint64{2}}))
+	assert.Equal(t, 1, open(t, store).Len())
+}
+
+// assert flushes move the WAL into segments which compaction merges
+func TestFlushCompact(t *testing.T) {
+	ctx := context.Background()
+	store := newStore(t)
+	index := open(t, store)
+
+	require.NoError(t, index.Write(ctx, Batch{Upserts: []Entry{{1, models.Vector{1, 0}}, {2, models.Vector{0, 1}}}}))
+	require.NoError(t, index.Flush(ctx, 0))
+	assert.Empty(t, keys(t, store, "index/wal/"))
+	assert.Equal(t, 0, index.log.Pending())
+
+	require.NoError(t, index.Write(ctx, Batch{Deletes: []uint64{1}}))
+	require.NoError(t, index.Flush(ctx, 0))
+	assert.Equal(t, 2, index.log.Segments())
+
+	// the WAL tail after the last flush is replayed over the segments
+	require.NoError(t, index.Write(ctx, Batch{Upserts: []Entry{{3, models.Vector{1, 1}}}}))
+	recovered := open(t, store)
+	assert.Equal(t, 2, recovered.Len())
+	assert.Equal(t, 2, recovered.Dimensions())
+
+	require.NoError(t, index.Flush(ctx, 2))
+	assert.Equal(t, 1, index.log.Segments())
+	assert.Equal(t, []string{"index/segments/00000000000000000001-00000000000000000003.seg"}, keys(t, store, "index/segments/"))
+	assert.Equal(t, 2, index.log.manifest.Segments[0].Records, "deletes are dropped once merged")
+
+	recovered = open(t, store)
+	hits, err := recovered.Search(models.Vector{1, 1}, 5)
+	require.NoError(t, err)
+	require.Len(t, hits, 2)
+	assert.Equal(t, uint64(3), hits[0].ID)
+	assert.Equal(t, uint64(2), hits[1].ID)
+}
+
+// assert recovery removes blobs left by a crashed flush and fails on corrupt segments
+func TestRecoverCrash(t *testing.T) {
+	ctx := context.Background()
+	store := newStore(t)
+	index := open(t, store)
+	require.NoError(t, index.Write(ctx, Batch{Upserts: []Entry{{1, models.Vector{1, 0}}}}))
+	require.NoError(t, index.Flush(ctx, 0))
+
+	// an unlisted segment and a flushed WAL entry left behind
+	orphan := index.log.segmentKey(2, 2)
+	_, err := index.log.writeSegment(ctx, 2, 2, 2, []record{{id: 9, vector: models.Vector{0, 1}}})
+	require.NoError(t, err)
+	index.log.next = 1
+	require.NoError(t, index.log.append(ctx, 2, nil))
+
+	recovered := open(t, store)
+	assert.Equal(t, 1, recovered.Len())
+	assert.NotContains(t, keys(t, store, "index/segments/"), orphan)
+	assert.Empty(t, keys(t, store, "index/wal/00000000000000000001.log"))
+
+	segment := index.log.manifest.Segments[0]
+	data, err := storage.ReadAll(ctx, store, segment.Key)
+	require.NoError(t, err)
+	data[len(data)-1] ^= 0xff
+	_, err = store.Put(ctx, segment.Key, bytes.NewReader(data), int64(len(data)), storage.Condition{})
+	require.NoError(t, err)
+
+	corrupt := New(models.MetricCosine)
+	corrupt.log = newLog(store, "index")
+	assert.ErrorIs(t, corrupt.log.recover(ctx, corrupt), ErrCorrupt)
+}
+
+// assert the registry recovers collections before serving them and flushes on close
+func TestOpen(t *testing.T) {
+	ctx := context.Background()
+	store := newStore(t)
+	collection := models.Collection{ID: 7, Metric: models.MetricDot}
+	t.Cleanup(func() {
+		Drop(collection.ID)
+		mu.Lock()
+		blobs, stopFlusher = nil, nil
+		mu.Unlock()
+	})
+
+	seeded := New(models.MetricDot)
+	seeded.log = newLog(store, prefix(collection.ID))
+	require.NoError(t, seeded.Write(ctx, Batch{Upserts: []Entry{{1, models.Vector{1, 0}}}}))
+
+	Open(ctx, store, configs.Index{FlushInterval: 60}, []models.Collection{collection})
+	assert.Equal(t, 1, For(&collection).Len())
+	require.Eventually(t, func() bool { return Ready(ctx) == nil }, time.Second, 10*time.Millisecond)
+
+	require.NoError(t, For(&collection).Write(ctx, Batch{Upserts: []Entry{{2, models.Vector{0, 1}}}}))
+	require.NoError(t, Close(ctx))
+	assert.Empty(t, keys(t, store, prefix(collection.ID)+"/wal/"))
+	assert.Len(t, keys(t, store, prefix(collection.ID)+"/segments/"), 1)
+}
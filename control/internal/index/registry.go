@@ -0,0 +1,214 @@
+package index
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+
+	"github.com/christian-nickerson/pangolin/control/internal/configs"
+	"github.com/christian-nickerson/pangolin/control/internal/metrics"
+	"github.com/christian-nickerson/pangolin/control/internal/models"
+	"github.com/christian-nickerson/pangolin/control/internal/storage"
+)
+
+var ErrRecovering = errors.New("index recovery in progress")
+
+var (
+	mu      sync.Mutex
+	indexes = map[uint]*Index{}
+
+	// set by Open to persist indexes, memory only until then
+	blobs      storage.BlobStore
+	settings   configs.Index
+	loading    bool
+	recoverErr error
+	recovering sync.WaitGroup
+
+	wake        = make(chan struct{}, 1)
+	stopFlusher context.CancelFunc
+	flusher     sync.WaitGroup
+)
+
+// Open persists indexes in store. Collection indexes are recovered in the
+// background, with For waiting until they are loaded, and logged writes
+// are flushed into segments until Close. Opening again discards indexes
+// held in memory, recovering the writes of another instance since.
+func Open(ctx context.Context, store storage.BlobStore, config configs.Index, collections []models.Collection) {
+	recovering.Wait()
+	mu.Lock()
+	previous := indexes
+	indexes = map[uint]*Index{}
+	blobs, settings, loading = store, config, true
+	mu.Unlock()
+	for _, index := range previous {
+		if index.log != nil {
+			index.log.close()
+		}
+	}
+
+	recovering.Add(1)
+	go func() {
+		defer recovering.Done()
+		recoverAll(ctx, collections)
+	}()
+
+	var flushCtx context.Context
+	flushCtx, stopFlusher = context.WithCancel(context.Background())
+	flusher.Add(1)
+	go func() {
+		defer flusher.Done()
+		flushLoop(flushCtx)
+	}()
+}
+
+// Close stops background flushes then flushes every index
+func Close(ctx context.Context) error {
+	if stopFlusher == nil {
+		return nil
+	}
+	stopFlusher()
+	flusher.Wait()
+	return flushAll(ctx)
+}
+
+// Ready reports whether indexes have been recovered
+func Ready(context.Context) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if loading {
+		return ErrRecovering
+	}
+	return recoverErr
+}
+
+// For returns the index of a collection, creating it if needed
+func For(collection *models.Collection) *Index {
+	recovering.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	index, ok := indexes[collection.ID]
+	if !ok {
+		index = New(collection.Metric)
+		if blobs != nil {
+			index.log = newLog(blobs, prefix(collection.ID))
+			index.log.flushAt, index.log.wake = settings.FlushEntries, wake
+		}
+		indexes[collection.ID] = index
+	}
+	return index
+}
+
+// Drop discards the index of a collection, its blobs are deleted
+// with the rest of the collection's
+func Drop(collectionID uint) {
+	mu.Lock()
+	index, ok := indexes[collectionID]
+	delete(indexes, collectionID)
+	mu.Unlock()
+
+	if ok && index.log != nil {
+		index.log.close()
+	}
+	metrics.IndexSize.DeleteLabelValues(label(collectionID))
+}
+
+// Observe records the size of a collection's index
+func Observe(collectionID uint) {
+	mu.Lock()
+	index, ok := indexes[collectionID]
+	mu.Unlock()
+
+	if ok {
+		metrics.IndexSize.WithLabelValues(label(collectionID)).Set(float64(index.Len()))
+	}
+}
+
+// recover the index of each collection from its segments and WAL. A
+// collection that fails to recover rejects writes, and readiness fails.
+func recoverAll(ctx context.Context, collections []models.Collection) {
+	start := time.Now()
+	var failed error
+	for _, collection := range collections {
+		// sharded collections are indexed on index nodes
+		if collection.Sharded() {
+			continue
+		}
+
+		index := New(collection.Metric)
+		index.log = newLog(blobs, prefix(collection.ID))
+		index.log.flushAt, index.log.wake = settings.FlushEntries, wake
+
+		if err := index.log.recover(ctx, index); err != nil {
+			log.Error("Failed to recover index", "collection_id", collection.ID, "err", err)
+			index.log.err = err
+			failed = errors.Join(failed, err)
+		}
+
+		mu.Lock()
+		indexes[collection.ID] = index
+		mu.Unlock()
+		Observe(collection.ID)
+	}
+
+	mu.Lock()
+	loading, recoverErr = false, failed
+	mu.Unlock()
+	log.Info("Recovered indexes", "collections", len(collections), "duration", time.Since(start))
+}
+
+// flush indexes every flush interval, or sooner once a WAL is full
+func flushLoop(ctx context.Context) {
+	interval := time.Duration(settings.FlushInterval) * time.Second
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-wake:
+		}
+		if err := flushAll(ctx); err != nil {
+			log.Error("Failed to flush indexes", "err", err)
+		}
+	}
+}
+
+// flush every index with logged writes
+func flushAll(ctx context.Context) error {
+	mu.Lock()
+	flushing := make(map[uint]*Index, len(indexes))
+	for id, index := range indexes {
+		flushing[id] = index
+	}
+	mu.Unlock()
+
+	var errs error
+	for id, index := range flushing {
+		if err := index.Flush(ctx, settings.MaxSegments); err != nil {
+			errs = errors.Join(errs, err)
+			log.Warn("Failed to flush index", "collection_id", id, "err", err)
+		}
+	}
+	return errs
+}
+
+// prefix of a collection's index blobs, under the collection's blob prefix
+func prefix(collectionID uint) string {
+	return storage.Key("collections", label(collectionID), "index")
+}
+
+func label(collectionID uint) string {
+	return strconv.FormatUint(uint64(collectionID), 10)
+}
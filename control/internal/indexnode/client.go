@@ -0,0 +1,318 @@
+package indexnode
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+
+	"github.com/christian-nickerson/pangolin/control/internal/certs"
+	"github.com/christian-nickerson/pangolin/control/internal/configs"
+	"github.com/christian-nickerson/pangolin/control/internal/index"
+	"github.com/christian-nickerson/pangolin/control/internal/models"
+	"github.com/christian-nickerson/pangolin/control/internal/proto"
+	"github.com/christian-nickerson/pangolin/control/internal/tracing"
+)
+
+// backoff before the first retry, doubled on each retry after
+const retryBackoff = 100 * time.Millisecond
+
+// vector bytes sent per Upsert call, within gRPC's default 4 MiB limit
+const upsertBytes = 2 << 20
+
+// ids requested per Get call, keeping responses of large vectors within
+// gRPC's message size limit
+const getBatch = 256
+
+// Client calls an index node over gRPC. Every call has a deadline and is
+// retried on transient failures, which is safe as all calls are idempotent.
+type Client struct {
+	conn    *grpc.ClientConn
+	node    proto.IndexNodeClient
+	timeout time.Duration
+	retries int
+}
+
+// Dial creates a client of the index node at address, secured as the
+// cluster is configured
+func Dial(address string, config configs.Cluster) (*Client, error) {
+	options, err := DialOptions(config)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := grpc.NewClient(address, options...)
+	if err != nil {
+		return nil, err
+	}
+	return NewClient(conn, config), nil
+}
+
+// NewClient creates a client over an existing connection
+func NewClient(conn *grpc.ClientConn, config configs.Cluster) *Client {
+	return &Client{
+		conn:    conn,
+		node:    proto.NewIndexNodeClient(conn),
+		timeout: time.Duration(config.RPCTimeout) * time.Second,
+		retries: config.RPCRetries,
+	}
+}
+
+// DialOptions secure a channel to a cluster gRPC server with TLS and the
+// shared token when configured, and trace its calls
+func DialOptions(config configs.Cluster) ([]grpc.DialOption, error) {
+	transport := insecure.NewCredentials()
+	if config.TLS.Enabled {
+		reloader, err := certs.NewReloader(config.TLS.CertFile, config.TLS.KeyFile, config.TLS.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12, RootCAs: reloader.Pool()}
+		if config.TLS.CAFile != "" {
+			tlsConfig.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+				return reloader.Certificate(), nil
+			}
+		}
+		transport = credentials.NewTLS(tlsConfig)
+	}
+	options := []grpc.DialOption{grpc.WithTransportCredentials(transport), tracing.GRPCDialOption()}
+
+	if config.Token != "" {
+		if !config.TLS.Enabled {
+			log.Warn("cluster token is sent in plain text, enable tls to protect it")
+		}
+		options = append(options, grpc.WithPerRPCCredentials(tokenCredentials{token: config.Token, secure: config.TLS.Enabled}))
+	}
+	return options, nil
+}
+
+// tokenCredentials sends the shared cluster token as bearer authorization
+type tokenCredentials struct {
+	token  string
+	secure bool
+}
+
+func (t tokenCredentials) GetRequestMetadata(context.Context, ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + t.token}, nil
+}
+
+func (t tokenCredentials) RequireTransportSecurity() bool {
+	return t.secure
+}
+
+// Close closes the connection
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// CreateShard creates an empty shard scoring with metric
+func (c *Client) CreateShard(ctx context.Context, ref models.ShardRef, metric models.Metric) error {
+	return c.call(ctx, func(ctx context.Context) error {
+		_, err := c.node.CreateShard(ctx, &proto.CreateShardRequest{Shard: refToProto(ref), Metric: string(metric)})
+		return err
+	})
+}
+
+// DropShard discards a shard
+func (c *Client) DropShard(ctx context.Context, ref models.ShardRef) error {
+	return c.call(ctx, func(ctx context.Context) error {
+		_, err := c.node.DropShard(ctx, &proto.DropShardRequest{Shard: refToProto(ref)})
+		return err
+	})
+}
+
+// Write applies a batch to a shard, deletes before upserts
+func (c *Client) Write(ctx context.Context, ref models.ShardRef, batch index.Batch) error {
+	if len(batch.Deletes) > 0 {
+		err := c.call(ctx, func(ctx context.Context) error {
+			_, err := c.node.Delete(ctx, &proto.DeleteRequest{Shard: refToProto(ref), Ids: batch.Deletes})
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	// large batches, such as shard copies, are split across calls
+	for start := 0; start < len(batch.Upserts); {
+		var entries []*proto.Entry
+		size := 0
+		for _, entry := range batch.Upserts[start:] {
+			if len(entries) > 0 && size+len(entry.Vector)*8 > upsertBytes {
+				break
+			}
+			entries = append(entries, &proto.Entry{Id: entry.ID, Vector: entry.Vector})
+			size += len(entry.Vector) * 8
+		}
+		err := c.call(ctx, func(ctx context.Context) error {
+			_, err := c.node.Upsert(ctx, &proto.UpsertRequest{Shard: refToProto(ref), Entries: entries})
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		start += len(entries)
+	}
+	return nil
+}
+
+// Get returns a shard's vectors by id, omitting missing ids
+func (c *Client) Get(ctx context.Context, ref models.ShardRef, ids []uint64) ([]index.Entry, error) {
+	entries := make([]index.Entry, 0, len(ids))
+	for start := 0; start < len(ids); start += getBatch {
+		batch := ids[start:min(start+getBatch, len(ids))]
+		var response *proto.GetResponse
+		err := c.call(ctx, func(ctx context.Context) (err error) {
+			response, err = c.node.Get(ctx, &proto.GetRequest{Shard: refToProto(ref), Ids: batch})
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range response.Entries {
+			entries = append(entries, index.Entry{ID: entry.Id, Vector: entry.Vector})
+		}
+	}
+	return entries, nil
+}
+
+// Search returns a shard's k nearest vectors to query
+func (c *Client) Search(ctx context.Context, ref models.ShardRef, query models.Vector, k int) ([]index.Hit, error) {
+	var response *proto.SearchResponse
+	err := c.call(ctx, func(ctx context.Context) (err error) {
+		response, err = c.node.Search(ctx, &proto.SearchRequest{Shard: refToProto(ref), Query: query, K: uint32(k)})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	hits := make([]index.Hit, len(response.Hits))
+	for i, hit := range response.Hits {
+		hits[i] = index.Hit{ID: hit.Id, Score: hit.Score}
+	}
+	return hits, nil
+}
+
+// Segment streams a shard's vectors to fn in chunks. The stream has no
+// overall deadline, instead each chunk must arrive within the RPC timeout,
+// so a large shard can take as long as it needs while a stalled one
+// fails. A retried stream starts again, passing fn vectors seen before.
+func (c *Client) Segment(ctx context.Context, ref models.ShardRef, fn func([]index.Entry) error) error {
+	var failed error
+	err := c.retry(ctx, 0, func(ctx context.Context) error {
+		ctx, cancel := context.WithCancelCause(ctx)
+		defer cancel(nil)
+		stream, err := c.node.StreamSegment(ctx, &proto.StreamSegmentRequest{Shard: refToProto(ref)})
+		if err != nil {
+			return err
+		}
+
+		stalled := status.Error(codes.DeadlineExceeded, "no segment chunk within the rpc timeout")
+		for {
+			var idle *time.Timer
+			if c.timeout > 0 {
+				idle = time.AfterFunc(c.timeout, func() { cancel(stalled) })
+			}
+			chunk, err := stream.Recv()
+			if idle != nil && !idle.Stop() {
+				return stalled
+			}
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+
+			entries := make([]index.Entry, len(chunk.Entries))
+			for i, entry := range chunk.Entries {
+				entries[i] = index.Entry{ID: entry.Id, Vector: entry.Vector}
+			}
+			// a failure of fn ends the stream without a retry
+			if failed = fn(entries); failed != nil {
+				return nil
+			}
+		}
+	})
+	if failed != nil {
+		return failed
+	}
+	return err
+}
+
+// Stats reports the shards the node hosts
+func (c *Client) Stats(ctx context.Context) ([]models.HostedShard, error) {
+	var response *proto.StatsResponse
+	err := c.call(ctx, func(ctx context.Context) (err error) {
+		response, err = c.node.Stats(ctx, &proto.StatsRequest{})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	hosted := make([]models.HostedShard, len(response.Shards))
+	for i, shard := range response.Shards {
+		hosted[i] = models.HostedShard{CollectionID: uint(shard.CollectionId), Shard: int(shard.Shard), Vectors: shard.Vectors}
+	}
+	return hosted, nil
+}
+
+// call an RPC with a deadline per attempt, retrying transient failures
+// with backoff until the retries or ctx run out
+func (c *Client) call(ctx context.Context, rpc func(context.Context) error) error {
+	return c.retry(ctx, c.timeout, rpc)
+}
+
+// retry an RPC as call does, with a deadline per attempt when timeout is
+// positive
+func (c *Client) retry(ctx context.Context, timeout time.Duration, rpc func(context.Context) error) error {
+	backoff := retryBackoff
+	for attempt := 0; ; attempt++ {
+		err := attemptWithin(ctx, timeout, rpc)
+		if err == nil || attempt >= c.retries || !retryable(err) {
+			return clientError(err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func attemptWithin(ctx context.Context, timeout time.Duration, rpc func(context.Context) error) error {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return rpc(ctx)
+}
+
+// whether a failed call may succeed when tried again
+func retryable(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.Aborted, codes.DeadlineExceeded, codes.Internal:
+		return true
+	}
+	return false
+}
+
+// map gRPC status errors back onto host errors
+func clientError(err error) error {
+	if status.Code(err) == codes.NotFound {
+		return ErrShardNotFound
+	}
+	return err
+}
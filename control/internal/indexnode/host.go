@@ -0,0 +1,139 @@
+package indexnode
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+
+	"github.com/christian-nickerson/pangolin/control/internal/index"
+	"github.com/christian-nickerson/pangolin/control/internal/models"
+)
+
+var ErrShardNotFound = errors.New("shard not found")
+
+// Host keeps the shard indexes of an index node in memory, shards are
+// created and dropped by the control plane as it places them
+type Host struct {
+	mu     sync.RWMutex
+	shards map[models.ShardRef]*index.Index
+}
+
+// NewHost creates a host without shards
+func NewHost() *Host {
+	return &Host{shards: map[models.ShardRef]*index.Index{}}
+}
+
+// CreateShard creates an empty shard scoring with metric, keeping the
+// shard if it already exists
+func (h *Host) CreateShard(_ context.Context, ref models.ShardRef, metric models.Metric) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.shards[ref]; !ok {
+		h.shards[ref] = index.New(metric)
+	}
+	return nil
+}
+
+// DropShard discards a shard, dropping a missing shard is not an error
+func (h *Host) DropShard(_ context.Context, ref models.ShardRef) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	delete(h.shards, ref)
+	return nil
+}
+
+// Write applies a batch to a shard
+func (h *Host) Write(ctx context.Context, ref models.ShardRef, batch index.Batch) error {
+	shard, err := h.shard(ref)
+	if err != nil {
+		return err
+	}
+	return shard.Write(ctx, batch)
+}
+
+// Get returns a shard's vectors by id, omitting missing ids
+func (h *Host) Get(_ context.Context, ref models.ShardRef, ids []uint64) ([]index.Entry, error) {
+	shard, err := h.shard(ref)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]index.Entry, 0, len(ids))
+	for _, id := range ids {
+		if vector, ok := shard.Get(id); ok {
+			entries = append(entries, index.Entry{ID: id, Vector: vector})
+		}
+	}
+	return entries, nil
+}
+
+// Search returns a shard's k nearest vectors to query
+func (h *Host) Search(_ context.Context, ref models.ShardRef, query models.Vector, k int) ([]index.Hit, error) {
+	shard, err := h.shard(ref)
+	if err != nil {
+		return nil, err
+	}
+	return shard.Search(query, k)
+}
+
+// Segment passes a shard's vectors to fn in chunks of about chunkSize
+// vector bytes, for copying to another node
+func (h *Host) Segment(ctx context.Context, ref models.ShardRef, fn func([]index.Entry) error) error {
+	shard, err := h.shard(ref)
+	if err != nil {
+		return err
+	}
+
+	entries := shard.Entries()
+	for start := 0; start < len(entries); {
+		end, size := start, 0
+		for end < len(entries) && (end == start || size < chunkSize) {
+			size += 8 * len(entries[end].Vector)
+			end++
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(entries[start:end]); err != nil {
+			return err
+		}
+		start = end
+	}
+	return nil
+}
+
+// Shards reports the hosted shards and their sizes, for heartbeats
+func (h *Host) Shards() []models.HostedShard {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	hosted := make([]models.HostedShard, 0, len(h.shards))
+	for ref, shard := range h.shards {
+		hosted = append(hosted, models.HostedShard{
+			CollectionID: ref.CollectionID,
+			Shard:        ref.Shard,
+			Vectors:      uint64(shard.Len()),
+		})
+	}
+	sort.Slice(hosted, func(a, b int) bool {
+		if hosted[a].CollectionID != hosted[b].CollectionID {
+			return hosted[a].CollectionID < hosted[b].CollectionID
+		}
+		return hosted[a].Shard < hosted[b].Shard
+	})
+	return hosted
+}
+
+// look up a hosted shard
+func (h *Host) shard(ref models.ShardRef) (*index.Index, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	shard, ok := h.shards[ref]
+	if !ok {
+		return nil, ErrShardNotFound
+	}
+	return shard, nil
+}
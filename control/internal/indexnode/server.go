@@ -0,0 +1,195 @@
+package indexnode
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/christian-nickerson/pangolin/control/internal/index"
+	"github.com/christian-nickerson/pangolin/control/internal/models"
+	"github.com/christian-nickerson/pangolin/control/internal/proto"
+)
+
+// vector bytes streamed per segment chunk, within gRPC's default 4 MiB limit
+const chunkSize = 1 << 20
+
+// server adapts a host to the gRPC IndexNode service
+type server struct {
+	proto.UnimplementedIndexNodeServer
+	host *Host
+}
+
+// NewServer serves host's shards over the IndexNode service
+func NewServer(host *Host) proto.IndexNodeServer {
+	return &server{host: host}
+}
+
+// Serve starts an IndexNode gRPC server for host on address
+func Serve(host *Host, address string, options ...grpc.ServerOption) (*grpc.Server, net.Listener, error) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	grpcServer := grpc.NewServer(options...)
+	proto.RegisterIndexNodeServer(grpcServer, NewServer(host))
+	go grpcServer.Serve(listener)
+	return grpcServer, listener, nil
+}
+
+func (s *server) CreateShard(ctx context.Context, request *proto.CreateShardRequest) (*proto.CreateShardResponse, error) {
+	ref, err := refFromProto(request.Shard)
+	if err != nil {
+		return nil, err
+	}
+	metric := models.Metric(request.Metric)
+	switch metric {
+	case models.MetricCosine, models.MetricDot, models.MetricEuclidean:
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "unsupported metric %q", request.Metric)
+	}
+
+	if err := s.host.CreateShard(ctx, ref, metric); err != nil {
+		return nil, statusError(err)
+	}
+	return &proto.CreateShardResponse{}, nil
+}
+
+func (s *server) DropShard(ctx context.Context, request *proto.DropShardRequest) (*proto.DropShardResponse, error) {
+	ref, err := refFromProto(request.Shard)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.host.DropShard(ctx, ref); err != nil {
+		return nil, statusError(err)
+	}
+	return &proto.DropShardResponse{}, nil
+}
+
+func (s *server) Upsert(ctx context.Context, request *proto.UpsertRequest) (*proto.UpsertResponse, error) {
+	ref, err := refFromProto(request.Shard)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]index.Entry, len(request.Entries))
+	for i, entry := range request.Entries {
+		entries[i] = index.Entry{ID: entry.Id, Vector: entry.Vector}
+	}
+	if err := s.host.Write(ctx, ref, index.Batch{Upserts: entries}); err != nil {
+		return nil, statusError(err)
+	}
+	return &proto.UpsertResponse{}, nil
+}
+
+func (s *server) Delete(ctx context.Context, request *proto.DeleteRequest) (*proto.DeleteResponse, error) {
+	ref, err := refFromProto(request.Shard)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.host.Write(ctx, ref, index.Batch{Deletes: request.Ids}); err != nil {
+		return nil, statusError(err)
+	}
+	return &proto.DeleteResponse{}, nil
+}
+
+func (s *server) Get(ctx context.Context, request *proto.GetRequest) (*proto.GetResponse, error) {
+	ref, err := refFromProto(request.Shard)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := s.host.Get(ctx, ref, request.Ids)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	response := &proto.GetResponse{Entries: make([]*proto.Entry, len(entries))}
+	for i, entry := range entries {
+		response.Entries[i] = &proto.Entry{Id: entry.ID, Vector: entry.Vector}
+	}
+	return response, nil
+}
+
+func (s *server) Search(ctx context.Context, request *proto.SearchRequest) (*proto.SearchResponse, error) {
+	ref, err := refFromProto(request.Shard)
+	if err != nil {
+		return nil, err
+	}
+
+	hits, err := s.host.Search(ctx, ref, request.Query, int(request.K))
+	if err != nil {
+		return nil, statusError(err)
+	}
+	response := &proto.SearchResponse{Hits: make([]*proto.Hit, len(hits))}
+	for i, hit := range hits {
+		response.Hits[i] = &proto.Hit{Id: hit.ID, Score: hit.Score}
+	}
+	return response, nil
+}
+
+func (s *server) Stats(context.Context, *proto.StatsRequest) (*proto.StatsResponse, error) {
+	hosted := s.host.Shards()
+	response := &proto.StatsResponse{Shards: make([]*proto.ShardInfo, len(hosted))}
+	for i, shard := range hosted {
+		response.Shards[i] = &proto.ShardInfo{
+			CollectionId: uint64(shard.CollectionID),
+			Shard:        uint32(shard.Shard),
+			Vectors:      shard.Vectors,
+		}
+	}
+	return response, nil
+}
+
+func (s *server) StreamSegment(request *proto.StreamSegmentRequest, stream proto.IndexNode_StreamSegmentServer) error {
+	ref, err := refFromProto(request.Shard)
+	if err != nil {
+		return err
+	}
+
+	var sending error
+	err = s.host.Segment(stream.Context(), ref, func(entries []index.Entry) error {
+		chunk := &proto.SegmentChunk{Entries: make([]*proto.Entry, len(entries))}
+		for i, entry := range entries {
+			chunk.Entries[i] = &proto.Entry{Id: entry.ID, Vector: entry.Vector}
+		}
+		sending = stream.Send(chunk)
+		return sending
+	})
+	if sending != nil {
+		return sending
+	}
+	if err != nil {
+		return statusError(err)
+	}
+	return nil
+}
+
+// shard reference of a request, which must be given
+func refFromProto(ref *proto.ShardRef) (models.ShardRef, error) {
+	if ref == nil {
+		return models.ShardRef{}, status.Error(codes.InvalidArgument, "shard is required")
+	}
+	return models.ShardRef{CollectionID: uint(ref.CollectionId), Shard: int(ref.Shard)}, nil
+}
+
+func refToProto(ref models.ShardRef) *proto.ShardRef {
+	return &proto.ShardRef{CollectionId: uint64(ref.CollectionID), Shard: uint32(ref.Shard)}
+}
+
+// map host errors onto gRPC status errors, failures other than invalid
+// requests are internal and may succeed when tried again
+func statusError(err error) error {
+	switch {
+	case errors.Is(err, ErrShardNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, index.ErrDimensions):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return status.FromContextError(err).Err()
+	}
+	return status.Error(codes.Internal, err.Error())
+}
@@ -0,0 +1,214 @@
+package indexnode
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/christian-nickerson/pangolin/control/internal/configs"
+	"github.com/christian-nickerson/pangolin/control/internal/index"
+	"github.com/christian-nickerson/pangolin/control/internal/models"
+	"github.com/christian-nickerson/pangolin/control/internal/proto"
+)
+
+type ServerSuite struct {
+	suite.Suite
+	host   *Host
+	client *Client
+	// calls failed with Unavailable before the server answers
+	unavailable atomic.Int32
+	// pause before each streamed message is sent
+	pause atomic.Int64
+}
+
+// serve a host over an in-memory listener, failing calls while
+// unavailable is positive
+func (s *ServerSuite) SetupTest() {
+	s.host = NewHost()
+	s.unavailable.Store(0)
+	s.pause.Store(0)
+
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer(grpc.ChainUnaryInterceptor(
+		func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+			if s.unavailable.Add(-1) >= 0 {
+				return nil, status.Error(codes.Unavailable, "unavailable")
+			}
+			return handler(ctx, req)
+		},
+	), grpc.ChainStreamInterceptor(
+		func(server any, stream grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			return handler(server, &pausedStream{ServerStream: stream, pause: &s.pause})
+		},
+	))
+	proto.RegisterIndexNodeServer(server, NewServer(s.host))
+	go server.Serve(listener)
+	s.T().Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	s.Require().NoError(err)
+	s.client = NewClient(conn, configs.Cluster{RPCTimeout: 5, RPCRetries: 2})
+	s.T().Cleanup(func() { s.client.Close() })
+}
+
+// Test shards are created, written, read, searched and copied through the client
+func (s *ServerSuite) TestRoundTrip() {
+	ctx := context.Background()
+	ref := models.ShardRef{CollectionID: 1, Shard: 2}
+	s.Require().NoError(s.client.CreateShard(ctx, ref, models.MetricDot))
+
+	s.Require().NoError(s.client.Write(ctx, ref, index.Batch{Upserts: []index.Entry{
+		{ID: 1, Vector: models.Vector{1, 0}},
+		{ID: 2, Vector: models.Vector{0, 1}},
+		{ID: 3, Vector: models.Vector{1, 1}},
+	}}))
+	s.Require().NoError(s.client.Write(ctx, ref, index.Batch{Deletes: []uint64{3}}))
+
+	hits, err := s.client.Search(ctx, ref, models.Vector{1, 0}, 1)
+	s.Require().NoError(err)
+	s.Require().Len(hits, 1)
+	s.Equal(uint64(1), hits[0].ID)
+
+	entries, err := s.client.Get(ctx, ref, []uint64{1, 3})
+	s.Require().NoError(err)
+	s.Equal([]index.Entry{{ID: 1, Vector: models.Vector{1, 0}}}, entries)
+
+	segment, _ := s.segment(ref)
+	s.Equal([]index.Entry{{ID: 1, Vector: models.Vector{1, 0}}, {ID: 2, Vector: models.Vector{0, 1}}}, segment)
+
+	hosted, err := s.client.Stats(ctx)
+	s.Require().NoError(err)
+	s.Equal([]models.HostedShard{{CollectionID: 1, Shard: 2, Vectors: 2}}, hosted)
+
+	s.Require().NoError(s.client.DropShard(ctx, ref))
+	hosted, err = s.client.Stats(ctx)
+	s.Require().NoError(err)
+	s.Empty(hosted)
+}
+
+// stream a shard's vectors through the client, counting the chunks
+func (s *ServerSuite) segment(ref models.ShardRef) ([]index.Entry, int) {
+	var entries []index.Entry
+	var chunks int
+	s.Require().NoError(s.client.Segment(context.Background(), ref, func(chunk []index.Entry) error {
+		entries = append(entries, chunk...)
+		chunks++
+		return nil
+	}))
+	return entries, chunks
+}
+
+// Test writes and segments larger than a gRPC message are split across
+// calls and chunks
+func (s *ServerSuite) TestLargeWrite() {
+	ctx := context.Background()
+	ref := models.ShardRef{CollectionID: 1}
+	s.Require().NoError(s.client.CreateShard(ctx, ref, models.MetricDot))
+
+	// 1500 vectors of 768 dimensions are about 9 MiB
+	var batch index.Batch
+	for id := range 1500 {
+		batch.Upserts = append(batch.Upserts, index.Entry{ID: uint64(id), Vector: make(models.Vector, 768)})
+	}
+	s.Require().NoError(s.client.Write(ctx, ref, batch))
+
+	hosted, err := s.client.Stats(ctx)
+	s.Require().NoError(err)
+	s.Equal(uint64(1500), hosted[0].Vectors)
+
+	segment, chunks := s.segment(ref)
+	s.Len(segment, 1500)
+	s.Greater(chunks, 1)
+}
+
+// Test a segment streams for longer than the rpc timeout while chunks keep
+// arriving, and fails once a chunk takes longer
+func (s *ServerSuite) TestSlowSegment() {
+	ctx := context.Background()
+	ref := models.ShardRef{CollectionID: 1}
+	s.Require().NoError(s.client.CreateShard(ctx, ref, models.MetricDot))
+
+	// 400 vectors of 768 dimensions are three chunks
+	var batch index.Batch
+	for id := range 400 {
+		batch.Upserts = append(batch.Upserts, index.Entry{ID: uint64(id), Vector: make(models.Vector, 768)})
+	}
+	s.Require().NoError(s.client.Write(ctx, ref, batch))
+
+	client := NewClient(s.client.conn, configs.Cluster{RPCTimeout: 1})
+	var received int
+	s.pause.Store(int64(400 * time.Millisecond))
+	s.Require().NoError(client.Segment(ctx, ref, func(entries []index.Entry) error {
+		received += len(entries)
+		return nil
+	}))
+	s.Equal(400, received)
+
+	s.pause.Store(int64(1500 * time.Millisecond))
+	err := client.Segment(ctx, ref, func([]index.Entry) error { return nil })
+	s.Equal(codes.DeadlineExceeded, status.Code(err))
+}
+
+// Test missing shards and invalid requests are reported as such
+func (s *ServerSuite) TestErrors() {
+	ctx := context.Background()
+	ref := models.ShardRef{CollectionID: 1}
+
+	_, err := s.client.Search(ctx, ref, models.Vector{1}, 1)
+	s.ErrorIs(err, ErrShardNotFound)
+	err = s.client.Segment(ctx, ref, func([]index.Entry) error { return nil })
+	s.ErrorIs(err, ErrShardNotFound)
+
+	err = s.client.CreateShard(ctx, ref, "manhattan")
+	s.Equal(codes.InvalidArgument, status.Code(err))
+
+	s.Require().NoError(s.client.CreateShard(ctx, ref, models.MetricDot))
+	s.Require().NoError(s.client.Write(ctx, ref, index.Batch{Upserts: []index.Entry{{ID: 1, Vector: models.Vector{1}}}}))
+	err = s.client.Write(ctx, ref, index.Batch{Upserts: []index.Entry{{ID: 2, Vector: models.Vector{1, 0}}}})
+	s.Equal(codes.InvalidArgument, status.Code(err))
+
+	// other host failures may pass when tried again
+	s.Equal(codes.Internal, status.Code(statusError(errors.New("disk full"))))
+	s.True(retryable(statusError(errors.New("disk full"))))
+}
+
+// Test transient failures are retried until the retries run out
+func (s *ServerSuite) TestRetry() {
+	ctx := context.Background()
+	ref := models.ShardRef{CollectionID: 1}
+
+	s.unavailable.Store(2)
+	s.NoError(s.client.CreateShard(ctx, ref, models.MetricCosine))
+
+	s.unavailable.Store(3)
+	err := s.client.DropShard(ctx, ref)
+	s.Equal(codes.Unavailable, status.Code(err))
+}
+
+// pausedStream pauses before sending each message
+type pausedStream struct {
+	grpc.ServerStream
+	pause *atomic.Int64
+}
+
+func (p *pausedStream) SendMsg(m any) error {
+	time.Sleep(time.Duration(p.pause.Load()))
+	return p.ServerStream.SendMsg(m)
+}
+
+func TestServerSuite(t *testing.T) {
+	suite.Run(t, new(ServerSuite))
+}
@@ -0,0 +1,145 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/charmbracelet/log"
+	"github.com/goccy/go-json"
+	"gorm.io/gorm"
+
+	"github.com/christian-nickerson/pangolin/control/internal/collections"
+	"github.com/christian-nickerson/pangolin/control/internal/database"
+	"github.com/christian-nickerson/pangolin/control/internal/documents"
+	"github.com/christian-nickerson/pangolin/control/internal/index"
+	"github.com/christian-nickerson/pangolin/control/internal/models"
+	"github.com/christian-nickerson/pangolin/control/internal/namespaces"
+)
+
+// pending is a chunk awaiting embedding
+type pending struct {
+	plan     *documents.Plan
+	position int
+}
+
+// ingest upserts the job's documents, embedding only new or changed
+// chunks. Writes apply in one transaction so a retried job never leaves
+// partial documents behind.
+func (q *Queue) ingest(ctx context.Context, job *models.Job, logger *log.Logger) error {
+	var upserts []documents.Upsert
+	if err := json.Unmarshal(job.Payload, &upserts); err != nil {
+		return permanentError{fmt.Errorf("invalid job payload, %w", err)}
+	}
+
+	var collection models.Collection
+	err := database.DB.WithContext(ctx).First(&collection, job.CollectionID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return permanentError{collections.ErrCollectionNotFound}
+	}
+	if err != nil {
+		return err
+	}
+
+	namespace, err := namespaces.GetByID(ctx, job.NamespaceID)
+	if err != nil {
+		return err
+	}
+
+	// plan every document up front so progress has a known total
+	plans := make([]*documents.Plan, len(upserts))
+	var queue []pending
+	var texts []string
+	for i, upsert := range upserts {
+		plans[i], err = documents.Prepare(ctx, &collection, upsert)
+		if errors.Is(err, documents.ErrVersionConflict) {
+			return permanentError{fmt.Errorf("document %v, %w", upsert.ID, err)}
+		}
+		if err != nil {
+			return err
+		}
+
+		for _, position := range plans[i].Pending() {
+			queue = append(queue, pending{plans[i], position})
+			texts = append(texts, plans[i].Chunks[position])
+		}
+	}
+	if err := progress(ctx, job, 0, len(texts)); err != nil {
+		return err
+	}
+
+	vectors, err := q.embedAll(ctx, job, texts, collection.Model)
+	if err != nil {
+		return err
+	}
+	for i, chunk := range queue {
+		chunk.plan.Vectors[chunk.position] = vectors[i]
+	}
+
+	if err := check(ctx, namespace, &collection, plans); err != nil {
+		return permanentError{err}
+	}
+
+	// a concurrent write to the same document is retried against its new
+	// version, while concurrent writes filling the namespace fail the job
+	var quota *models.QuotaError
+	if err := documents.Apply(ctx, &collection, plans); errors.As(err, &quota) {
+		return permanentError{err}
+	} else if err != nil {
+		return err
+	}
+
+	// payloads are copies of committed rows, so a failed write is not retried
+	for _, plan := range plans {
+		if err := documents.SavePayload(ctx, q.store, plan); err != nil {
+			logger.Warn("failed to store document payload", "document_id", plan.Upsert.ID, "err", err)
+		}
+	}
+	return nil
+}
+
+// check the plans fit the namespace quotas and the collection's dimensions,
+// shards of sharded collections check dimensions as they are written
+func check(ctx context.Context, namespace *models.Namespace, collection *models.Collection, plans []*documents.Plan) error {
+	var added, vectorBytes int64
+	dims := 0
+	if !collection.Sharded() {
+		dims = index.For(collection).Dimensions()
+	}
+	for _, plan := range plans {
+		documents, bytes := plan.Usage()
+		added, vectorBytes = added+documents, vectorBytes+bytes
+
+		for _, vector := range plan.Vectors {
+			if dims == 0 {
+				dims = len(vector)
+			}
+			if len(vector) != dims {
+				return fmt.Errorf("model %v returned %v dimensions, collection has %v", collection.Model, len(vector), dims)
+			}
+		}
+	}
+	return namespaces.CheckWrite(ctx, namespace, added, max(vectorBytes, 0))
+}
+
+// embed texts in batches, recording progress after each batch
+func (q *Queue) embedAll(ctx context.Context, job *models.Job, texts []string, model string) ([]models.Vector, error) {
+	vectors := make([]models.Vector, 0, len(texts))
+	for start := 0; start < len(texts); start += q.config.BatchSize {
+		if err := context.Cause(ctx); err != nil {
+			return nil, err
+		}
+
+		batch := texts[start:min(start+q.config.BatchSize, len(texts))]
+		embedded, err := q.embed(ctx, batch, model)
+		if err != nil {
+			return nil, err
+		}
+		vectors = append(vectors, embedded...)
+
+		if err := progress(ctx, job, len(vectors), len(texts)); err != nil {
+			return nil, err
+		}
+	}
+	return vectors, nil
+}
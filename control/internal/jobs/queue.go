@@ -0,0 +1,352 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/goccy/go-json"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/christian-nickerson/pangolin/control/internal/configs"
+	"github.com/christian-nickerson/pangolin/control/internal/database"
+	"github.com/christian-nickerson/pangolin/control/internal/documents"
+	embeddings "github.com/christian-nickerson/pangolin/control/internal/embedding"
+	"github.com/christian-nickerson/pangolin/control/internal/models"
+	"github.com/christian-nickerson/pangolin/control/internal/namespaces"
+	"github.com/christian-nickerson/pangolin/control/internal/storage"
+)
+
+var (
+	ErrJobNotFound = errors.New("job not found")
+	ErrJobFinished = errors.New("job already finished")
+
+	errCancelled = errors.New("job cancelled")
+	errLostClaim = errors.New("job claimed by another worker")
+)
+
+// permanentError marks failures that retrying will not fix
+type permanentError struct{ err error }
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Queue persists ingestion jobs and processes them on a pool of workers
+type Queue struct {
+	config configs.Jobs
+	embed  embeddings.Embedder
+	store  storage.BlobStore
+	wake   chan struct{}
+
+	mu      sync.Mutex
+	running map[string]context.CancelCauseFunc
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+// NewQueue creates a queue embedding chunks with embed and keeping
+// chunk payloads in store
+func NewQueue(config configs.Jobs, embed embeddings.Embedder, store storage.BlobStore) *Queue {
+	return &Queue{
+		config:  config,
+		embed:   embed,
+		store:   store,
+		wake:    make(chan struct{}, 1),
+		running: make(map[string]context.CancelCauseFunc),
+	}
+}
+
+// Start requeues jobs left running by a previous process and starts the workers
+func (q *Queue) Start(ctx context.Context) error {
+	resumed := database.DB.WithContext(ctx).
+		Model(&models.Job{}).
+		Where("status = ?", models.JobRunning).
+		Updates(map[string]any{"status": models.JobQueued, "next_run_at": time.Now()})
+	if resumed.Error != nil {
+		return resumed.Error
+	}
+	if resumed.RowsAffected > 0 {
+		log.Info("resuming interrupted jobs", "jobs", resumed.RowsAffected)
+	}
+
+	workers, cancel := context.WithCancel(context.Background())
+	q.cancel = cancel
+	for n := 0; n < q.config.Workers; n++ {
+		q.wg.Add(1)
+		go q.work(workers)
+	}
+	return nil
+}
+
+// Stop interrupts running jobs, which are requeued to resume on
+// restart, and waits for the workers to exit
+func (q *Queue) Stop(ctx context.Context) error {
+	if q.cancel == nil {
+		return nil
+	}
+	q.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Submit persists an ingestion job upserting documents into the
+// collection and wakes a worker. Documents without an id are given one.
+func (q *Queue) Submit(ctx context.Context, namespace *models.Namespace, collection *models.Collection, upserts []documents.Upsert) (*models.Job, error) {
+	ids := make([]string, len(upserts))
+	seen := make(map[string]bool, len(upserts))
+	for i := range upserts {
+		if upserts[i].ID == "" {
+			upserts[i].ID = uuid.NewString()
+		}
+		if seen[upserts[i].ID] {
+			return nil, fmt.Errorf("%w, %v", documents.ErrDuplicateID, upserts[i].ID)
+		}
+		ids[i], seen[upserts[i].ID] = upserts[i].ID, true
+	}
+
+	// only documents not already stored count against the quota
+	var existing int64
+	err := database.DB.WithContext(ctx).
+		Model(&models.Document{}).
+		Where("collection_id = ? AND external_id IN ?", collection.ID, ids).
+		Count(&existing).Error
+	if err != nil {
+		return nil, err
+	}
+	if err := namespaces.CheckWrite(ctx, namespace, int64(len(ids))-existing, 0); err != nil {
+		return nil, err
+	}
+
+	payload, err := json.Marshal(upserts)
+	if err != nil {
+		return nil, err
+	}
+
+	job := &models.Job{
+		ID:           uuid.NewString(),
+		NamespaceID:  namespace.ID,
+		CollectionID: collection.ID,
+		Status:       models.JobQueued,
+		Documents:    ids,
+		Payload:      payload,
+		NextRunAt:    time.Now(),
+	}
+	if err := database.DB.WithContext(ctx).Create(job).Error; err != nil {
+		return nil, err
+	}
+
+	q.notify()
+	return job, nil
+}
+
+// wake an idle worker, if one is waiting
+func (q *Queue) notify() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Get returns a job in the namespace
+func (q *Queue) Get(ctx context.Context, namespace *models.Namespace, id string) (*models.Job, error) {
+	var job models.Job
+	err := database.DB.WithContext(ctx).
+		Where("namespace_id = ? AND id = ?", namespace.ID, id).
+		First(&job).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrJobNotFound
+	}
+	return &job, err
+}
+
+// List returns the most recent jobs in the namespace
+func (q *Queue) List(ctx context.Context, namespace *models.Namespace, limit int) ([]models.Job, error) {
+	var jobs []models.Job
+	err := database.DB.WithContext(ctx).
+		Where("namespace_id = ?", namespace.ID).
+		Order("created_at DESC").
+		Limit(limit).
+		Find(&jobs).Error
+	return jobs, err
+}
+
+// Cancel stops a job, queued jobs are cancelled immediately and
+// running jobs once their worker notices
+func (q *Queue) Cancel(ctx context.Context, namespace *models.Namespace, id string) (*models.Job, error) {
+	job, err := q.Get(ctx, namespace, id)
+	if err != nil {
+		return nil, err
+	}
+	if job.Status.Done() {
+		return job, ErrJobFinished
+	}
+
+	q.mu.Lock()
+	cancel, running := q.running[id]
+	q.mu.Unlock()
+
+	if running {
+		cancel(errCancelled)
+		return q.Get(ctx, namespace, id)
+	}
+
+	now := time.Now()
+	err = database.DB.WithContext(ctx).
+		Model(&models.Job{}).
+		Where("id = ? AND status IN ?", id, []models.JobStatus{models.JobQueued, models.JobRunning}).
+		Updates(map[string]any{"status": models.JobCancelled, "finished_at": &now}).Error
+	if err != nil {
+		return nil, err
+	}
+	return q.Get(ctx, namespace, id)
+}
+
+// claim and process jobs until stopped
+func (q *Queue) work(ctx context.Context) {
+	defer q.wg.Done()
+
+	poll := time.NewTicker(time.Duration(q.config.PollInterval) * time.Second)
+	defer poll.Stop()
+
+	for {
+		job, err := q.claim(ctx)
+		switch {
+		case errors.Is(err, errLostClaim):
+			continue
+		case err != nil && ctx.Err() == nil:
+			log.Error("failed to claim job", "err", err)
+		case job != nil:
+			q.run(ctx, job)
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-q.wake:
+		case <-poll.C:
+		}
+	}
+}
+
+// claim the oldest job due to run, nil when there is none
+func (q *Queue) claim(ctx context.Context) (*models.Job, error) {
+	var job models.Job
+	err := database.DB.WithContext(ctx).
+		Where("status = ? AND next_run_at <= ?", models.JobQueued, time.Now()).
+		Order("created_at").
+		First(&job).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// conditional update so only one worker claims the job
+	now := time.Now()
+	claimed := database.DB.WithContext(ctx).
+		Model(&models.Job{}).
+		Where("id = ? AND status = ?", job.ID, models.JobQueued).
+		Updates(map[string]any{
+			"status":     models.JobRunning,
+			"attempts":   gorm.Expr("attempts + 1"),
+			"completed":  0,
+			"started_at": &now,
+		})
+	if claimed.Error != nil {
+		return nil, claimed.Error
+	}
+	if claimed.RowsAffected == 0 {
+		return nil, errLostClaim
+	}
+
+	job.Status, job.Attempts, job.StartedAt = models.JobRunning, job.Attempts+1, &now
+	return &job, nil
+}
+
+// process a claimed job and record its outcome
+func (q *Queue) run(ctx context.Context, job *models.Job) {
+	jobCtx, cancel := context.WithCancelCause(ctx)
+	q.mu.Lock()
+	q.running[job.ID] = cancel
+	q.mu.Unlock()
+
+	defer func() {
+		q.mu.Lock()
+		delete(q.running, job.ID)
+		q.mu.Unlock()
+		cancel(nil)
+	}()
+
+	logger := log.With("job_id", job.ID, "attempt", job.Attempts)
+	logger.Info("job started")
+
+	err := q.ingest(jobCtx, job, logger)
+	q.finish(job, err, context.Cause(jobCtx), ctx.Err() != nil, logger)
+}
+
+// record the outcome of a job run, scheduling a retry for transient failures
+func (q *Queue) finish(job *models.Job, err, cause error, stopping bool, logger *log.Logger) {
+	now := time.Now()
+	updates := map[string]any{"error": ""}
+
+	switch {
+	case err == nil:
+		updates["status"], updates["finished_at"] = models.JobSucceeded, &now
+		logger.Info("job succeeded")
+	case errors.Is(cause, errCancelled):
+		updates["status"], updates["finished_at"] = models.JobCancelled, &now
+		logger.Info("job cancelled")
+	case stopping:
+		// interrupted by shutdown, resume without using an attempt
+		updates["status"], updates["next_run_at"] = models.JobQueued, now
+		updates["attempts"] = job.Attempts - 1
+		logger.Info("job interrupted, will resume on restart")
+	case errors.As(err, &permanentError{}) || job.Attempts >= q.config.MaxAttempts:
+		updates["status"], updates["finished_at"], updates["error"] = models.JobFailed, &now, err.Error()
+		logger.Error("job failed", "err", err)
+	default:
+		delay := q.backoff(job.Attempts)
+		updates["status"], updates["next_run_at"], updates["error"] = models.JobQueued, now.Add(delay), err.Error()
+		logger.Warn("job failed, retrying", "err", err, "delay", delay)
+	}
+
+	// record the outcome even though the job context has ended
+	if err := database.DB.Model(job).Updates(updates).Error; err != nil {
+		logger.Error("failed to record job outcome", "err", err)
+	}
+}
+
+// exponential backoff for the given attempt, capped at the maximum
+func (q *Queue) backoff(attempt int) time.Duration {
+	delay := time.Duration(q.config.Backoff) * time.Second
+	limit := time.Duration(q.config.MaxBackoff) * time.Second
+	for n := 1; n < attempt && delay < limit; n++ {
+		delay *= 2
+	}
+	return min(delay, limit)
+}
+
+// record embedding progress
+func progress(ctx context.Context, job *models.Job, completed, total int) error {
+	job.Completed, job.Total = completed, total
+	return database.DB.WithContext(ctx).
+		Model(job).
+		Updates(map[string]any{"completed": completed, "total": total}).Error
+}
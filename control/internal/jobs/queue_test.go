@@ -0,0 +1,277 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/christian-nickerson/pangolin/control/internal/configs"
+	"github.com/christian-nickerson/pangolin/control/internal/database"
+	"github.com/christian-nickerson/pangolin/control/internal/documents"
+	"github.com/christian-nickerson/pangolin/control/internal/index"
+	"github.com/christian-nickerson/pangolin/control/internal/models"
+	"github.com/christian-nickerson/pangolin/control/internal/namespaces"
+	"github.com/christian-nickerson/pangolin/control/internal/storage"
+)
+
+var testConfig = configs.Jobs{
+	Workers:      2,
+	MaxAttempts:  3,
+	PollInterval: 1,
+	BatchSize:    2,
+}
+
+// embed each text as its length, failing the first failures calls
+type fakeEmbedder struct {
+	calls    atomic.Int32
+	failures int32
+	block    bool
+}
+
+func (f *fakeEmbedder) embed(ctx context.Context, texts []string, _ string) ([]models.Vector, error) {
+	if f.calls.Add(1) <= f.failures {
+		return nil, errors.New("model server unavailable")
+	}
+	if f.block {
+		<-ctx.Done()
+		return nil, context.Cause(ctx)
+	}
+
+	vectors := make([]models.Vector, len(texts))
+	for i, text := range texts {
+		vectors[i] = models.Vector{float64(len(text)), 1}
+	}
+	return vectors, nil
+}
+
+type QueueSuite struct {
+	suite.Suite
+	namespace  *models.Namespace
+	collection *models.Collection
+	store      storage.BlobStore
+}
+
+// set up a database with a collection chunking every two words
+func (s *QueueSuite) SetupTest() {
+	config := configs.DatabaseConfig{Type: "sqlite", DBName: filepath.Join(s.T().TempDir(), "test")}
+	s.Require().NoError(database.Connect(config))
+	s.Require().NoError(database.Migrate())
+
+	var err error
+	s.namespace, err = namespaces.EnsureDefault(context.Background(), configs.Quotas{})
+	s.Require().NoError(err)
+
+	s.collection = &models.Collection{
+		NamespaceID: s.namespace.ID,
+		Name:        "docs",
+		Model:       "test",
+		ChunkSize:   2,
+		Metric:      models.MetricCosine,
+	}
+	s.Require().NoError(database.DB.Create(s.collection).Error)
+	index.Drop(s.collection.ID)
+
+	s.store, err = storage.NewFilesystem(s.T().TempDir())
+	s.Require().NoError(err)
+}
+
+// start a queue with embedder, stopping it when the test ends
+func (s *QueueSuite) start(config configs.Jobs, embedder *fakeEmbedder) *Queue {
+	queue := NewQueue(config, embedder.embed, s.store)
+	s.Require().NoError(queue.Start(context.Background()))
+	s.T().Cleanup(func() { queue.Stop(context.Background()) })
+	return queue
+}
+
+// wait for the job to reach status
+func (s *QueueSuite) waitFor(queue *Queue, id string, status models.JobStatus) *models.Job {
+	var job *models.Job
+	s.Require().Eventually(func() bool {
+		var err error
+		job, err = queue.Get(context.Background(), s.namespace, id)
+		return err == nil && job.Status == status
+	}, 5*time.Second, 10*time.Millisecond)
+	return job
+}
+
+// Test a job embeds every chunk and stores documents, chunks and vectors
+func (s *QueueSuite) TestIngest() {
+	queue := s.start(testConfig, &fakeEmbedder{})
+	upserts := []documents.Upsert{
+		{Text: "one two three four five", Metadata: map[string]string{"source": "a"}},
+		{Text: "six seven"},
+	}
+
+	job, err := queue.Submit(context.Background(), s.namespace, s.collection, upserts)
+	s.Require().NoError(err)
+	s.Equal(models.JobQueued, job.Status)
+
+	job = s.waitFor(queue, job.ID, models.JobSucceeded)
+	s.Equal(4, job.Total)
+	s.Equal(4, job.Completed)
+	s.Equal(1, job.Attempts)
+	s.NotNil(job.FinishedAt)
+
+	var chunks []models.Chunk
+	s.Require().NoError(database.DB.Order("id").Find(&chunks).Error)
+	s.Require().Len(chunks, 4)
+	s.Equal("five", chunks[2].Text)
+	s.Equal(4, index.For(s.collection).Len())
+
+	var collection models.Collection
+	s.Require().NoError(database.DB.First(&collection, s.collection.ID).Error)
+	s.Equal(int64(2), collection.DocumentCount)
+	s.Equal(int64(4*16), collection.VectorBytes)
+
+	data, err := storage.ReadAll(context.Background(), s.store, documents.PayloadKey(s.collection.ID, job.Documents[0]))
+	s.Require().NoError(err)
+	var payload documents.Payload
+	s.Require().NoError(json.Unmarshal(data, &payload))
+	s.Equal("a", payload.Metadata["source"])
+	s.Len(payload.Chunks, 3)
+}
+
+// Test submitted documents are given ids and stale versions fail the job
+func (s *QueueSuite) TestUpsertVersions() {
+	ctx := context.Background()
+	queue := s.start(testConfig, &fakeEmbedder{})
+
+	_, err := queue.Submit(ctx, s.namespace, s.collection, []documents.Upsert{{ID: "a", Text: "x"}, {ID: "a", Text: "y"}})
+	s.ErrorIs(err, documents.ErrDuplicateID)
+
+	job, err := queue.Submit(ctx, s.namespace, s.collection, []documents.Upsert{{ID: "a", Text: "first"}, {Text: "anonymous"}})
+	s.Require().NoError(err)
+	s.Require().Len(job.Documents, 2)
+	s.Equal("a", job.Documents[0])
+	s.NotEmpty(job.Documents[1])
+	s.waitFor(queue, job.ID, models.JobSucceeded)
+
+	stale, err := queue.Submit(ctx, s.namespace, s.collection, []documents.Upsert{{ID: "a", Text: "second", Version: 2}})
+	s.Require().NoError(err)
+	stale = s.waitFor(queue, stale.ID, models.JobFailed)
+	s.Equal(1, stale.Attempts)
+	s.Contains(stale.Error, documents.ErrVersionConflict.Error())
+}
+
+// Test transient failures retry until the job succeeds
+func (s *QueueSuite) TestRetry() {
+	embedder := &fakeEmbedder{failures: 1}
+	queue := s.start(testConfig, embedder)
+
+	job, err := queue.Submit(context.Background(), s.namespace, s.collection, []documents.Upsert{{Text: "retry me"}})
+	s.Require().NoError(err)
+
+	job = s.waitFor(queue, job.ID, models.JobSucceeded)
+	s.Equal(2, job.Attempts)
+	s.Empty(job.Error)
+}
+
+// Test jobs fail once they run out of attempts
+func (s *QueueSuite) TestFailure() {
+	queue := s.start(testConfig, &fakeEmbedder{failures: 100})
+
+	job, err := queue.Submit(context.Background(), s.namespace, s.collection, []documents.Upsert{{Text: "never works"}})
+	s.Require().NoError(err)
+
+	job = s.waitFor(queue, job.ID, models.JobFailed)
+	s.Equal(testConfig.MaxAttempts, job.Attempts)
+	s.Contains(job.Error, "model server unavailable")
+}
+
+// Test quota violations fail without retrying
+func (s *QueueSuite) TestQuotaIsPermanent() {
+	s.namespace.MaxVectorBytes = 1
+	s.Require().NoError(namespaces.UpdateQuotas(context.Background(), s.namespace))
+	queue := s.start(testConfig, &fakeEmbedder{})
+
+	job, err := queue.Submit(context.Background(), s.namespace, s.collection, []documents.Upsert{{Text: "too big"}})
+	s.Require().NoError(err)
+
+	job = s.waitFor(queue, job.ID, models.JobFailed)
+	s.Equal(1, job.Attempts)
+	s.Zero(index.For(s.collection).Len())
+}
+
+// Test queued and running jobs can be cancelled, finished jobs cannot
+func (s *QueueSuite) TestCancel() {
+	ctx := context.Background()
+	idle := NewQueue(testConfig, (&fakeEmbedder{}).embed, s.store)
+	queued, err := idle.Submit(ctx, s.namespace, s.collection, []documents.Upsert{{Text: "queued"}})
+	s.Require().NoError(err)
+
+	cancelled, err := idle.Cancel(ctx, s.namespace, queued.ID)
+	s.Require().NoError(err)
+	s.Equal(models.JobCancelled, cancelled.Status)
+
+	_, err = idle.Cancel(ctx, s.namespace, queued.ID)
+	s.ErrorIs(err, ErrJobFinished)
+
+	queue := s.start(testConfig, &fakeEmbedder{block: true})
+	running, err := queue.Submit(ctx, s.namespace, s.collection, []documents.Upsert{{Text: "running"}})
+	s.Require().NoError(err)
+	s.waitFor(queue, running.ID, models.JobRunning)
+
+	_, err = queue.Cancel(ctx, s.namespace, running.ID)
+	s.Require().NoError(err)
+	s.waitFor(queue, running.ID, models.JobCancelled)
+}
+
+// Test jobs interrupted by shutdown resume on the next start
+func (s *QueueSuite) TestResume() {
+	ctx := context.Background()
+	queue := NewQueue(testConfig, (&fakeEmbedder{block: true}).embed, s.store)
+	s.Require().NoError(queue.Start(ctx))
+
+	job, err := queue.Submit(ctx, s.namespace, s.collection, []documents.Upsert{{Text: "resume me"}})
+	s.Require().NoError(err)
+	s.waitFor(queue, job.ID, models.JobRunning)
+
+	s.Require().NoError(queue.Stop(ctx))
+	job = s.waitFor(queue, job.ID, models.JobQueued)
+	s.Equal(0, job.Attempts)
+
+	// a crash leaves the job running, which start also resumes
+	s.Require().NoError(database.DB.Model(job).Update("status", models.JobRunning).Error)
+
+	restarted := s.start(testConfig, &fakeEmbedder{})
+	job = s.waitFor(restarted, job.ID, models.JobSucceeded)
+	s.Equal(1, job.Attempts)
+}
+
+// Test jobs are only visible within their namespace
+func (s *QueueSuite) TestNamespaceIsolation() {
+	ctx := context.Background()
+	queue := NewQueue(testConfig, (&fakeEmbedder{}).embed, s.store)
+	job, err := queue.Submit(ctx, s.namespace, s.collection, []documents.Upsert{{Text: "mine"}})
+	s.Require().NoError(err)
+
+	other := &models.Namespace{Name: "other"}
+	s.Require().NoError(namespaces.Create(ctx, other))
+
+	_, err = queue.Get(ctx, other, job.ID)
+	s.ErrorIs(err, ErrJobNotFound)
+
+	listed, err := queue.List(ctx, other, 10)
+	s.Require().NoError(err)
+	s.Empty(listed)
+}
+
+func TestQueueSuite(t *testing.T) {
+	suite.Run(t, new(QueueSuite))
+}
+
+// assert backoff doubles per attempt up to the maximum
+func TestBackoff(t *testing.T) {
+	queue := NewQueue(configs.Jobs{Backoff: 2, MaxBackoff: 10}, nil, nil)
+	for attempt, want := range map[int]time.Duration{1: 2, 2: 4, 3: 8, 4: 10, 20: 10} {
+		if got := queue.backoff(attempt); got != want*time.Second {
+			t.Errorf("attempt %v backoff %v, want %v", attempt, got, want*time.Second)
+		}
+	}
+}
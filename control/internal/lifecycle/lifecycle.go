@@ -0,0 +1,47 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+type step struct {
+	name  string
+	close func(ctx context.Context) error
+}
+
+// Teardown closes resources in the order they are added, so
+// producers stop before the connections they depend on close
+type Teardown struct {
+	steps []step
+}
+
+// Add appends a named step to run on shutdown
+func (t *Teardown) Add(name string, close func(ctx context.Context) error) {
+	t.steps = append(t.steps, step{name: name, close: close})
+}
+
+// Run executes every step in order, continuing past failures, and
+// returns all step errors. Steps not started before ctx ends are skipped.
+func (t *Teardown) Run(ctx context.Context) error {
+	var errs []error
+	for _, step := range t.steps {
+		if ctx.Err() != nil {
+			errs = append(errs, fmt.Errorf("%v skipped, %v", step.name, ctx.Err()))
+			continue
+		}
+
+		start := time.Now()
+		if err := step.close(ctx); err != nil {
+			log.Error("shutdown step failed", "step", step.name, "err", err)
+			errs = append(errs, fmt.Errorf("%v, %v", step.name, err))
+			continue
+		}
+		log.Info("shutdown step complete", "step", step.name, "duration", time.Since(start))
+	}
+	return errors.Join(errs...)
+}
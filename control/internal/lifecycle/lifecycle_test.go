@@ -0,0 +1,36 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// assert steps run in order, continuing past failures
+func TestTeardownOrder(t *testing.T) {
+	var order []string
+	var teardown Teardown
+	teardown.Add("http", func(context.Context) error { order = append(order, "http"); return nil })
+	teardown.Add("workers", func(context.Context) error { order = append(order, "workers"); return errors.New("stuck") })
+	teardown.Add("database", func(context.Context) error { order = append(order, "database"); return nil })
+
+	err := teardown.Run(context.Background())
+	assert.ErrorContains(t, err, "workers, stuck")
+	assert.Equal(t, []string{"http", "workers", "database"}, order)
+}
+
+// assert steps are skipped once the deadline passes
+func TestTeardownDeadline(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	ran := false
+
+	var teardown Teardown
+	teardown.Add("slow", func(context.Context) error { cancel(); return nil })
+	teardown.Add("skipped", func(context.Context) error { ran = true; return nil })
+
+	err := teardown.Run(ctx)
+	assert.ErrorContains(t, err, "skipped skipped")
+	assert.False(t, ran)
+}
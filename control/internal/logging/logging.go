@@ -1,14 +1,98 @@
 package logging
 
 import (
+	"fmt"
+	"os"
 	"time"
 
-	"github.com/gofiber/fiber/v2/middleware/logger"
+	"github.com/charmbracelet/log"
+	"github.com/gofiber/fiber/v2"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/christian-nickerson/pangolin/control/internal/configs"
+	"github.com/christian-nickerson/pangolin/control/internal/models"
 )
 
-var LoggingConfig = logger.Config{
-	Format:       "[${time}] ${locals:requestid} ${status} - ${latency} - ${method} ${path} ${error}\n",
-	TimeFormat:   "15:04:05 15:04:05.000",
-	TimeInterval: time.Millisecond,
-	TimeZone:     "UTC",
+// Setup configures the default logger level and output format
+func Setup(config configs.Logging) error {
+	level, err := log.ParseLevel(config.Level)
+	if err != nil {
+		return fmt.Errorf("unable to parse log level, %v", err)
+	}
+
+	var formatter log.Formatter
+	switch config.Format {
+	case "text":
+		formatter = log.TextFormatter
+	case "json":
+		formatter = log.JSONFormatter
+	case "logfmt":
+		formatter = log.LogfmtFormatter
+	default:
+		return fmt.Errorf("unsupported log format %q", config.Format)
+	}
+
+	log.SetOutput(os.Stderr)
+	log.SetLevel(level)
+	log.SetFormatter(formatter)
+	log.SetReportTimestamp(true)
+	log.SetTimeFormat(time.RFC3339Nano)
+
+	return nil
+}
+
+// Ctx returns the request scoped logger, tagged with the collection
+// when the matched route has one
+func Ctx(c *fiber.Ctx) *log.Logger {
+	logger := log.FromContext(c.UserContext())
+	if collection := c.Params(models.CollectionParam); collection != "" {
+		logger = logger.With("collection_id", collection)
+	}
+	return logger
+}
+
+// Middleware attaches a logger carrying the request and trace ids to
+// the request context and writes an access log line once handled.
+// Must be registered after the requestid and tracing middleware.
+func Middleware(c *fiber.Ctx) error {
+	start := time.Now()
+
+	logger := log.Default()
+	if requestID, ok := c.Locals("requestid").(string); ok {
+		logger = logger.With("request_id", requestID)
+	}
+	if span := trace.SpanContextFromContext(c.UserContext()); span.HasTraceID() {
+		logger = logger.With("trace_id", span.TraceID().String())
+	}
+	c.SetUserContext(log.WithContext(c.UserContext(), logger))
+
+	err := c.Next()
+
+	status := c.Response().StatusCode()
+	if e, ok := err.(*fiber.Error); ok {
+		status = e.Code
+	}
+
+	fields := []interface{}{
+		"method", c.Method(),
+		"path", c.Path(),
+		"route", c.Route().Path,
+		"status", status,
+		"latency", time.Since(start),
+	}
+	if err != nil {
+		fields = append(fields, "err", err)
+	}
+
+	access := Ctx(c)
+	switch {
+	case status >= fiber.StatusInternalServerError:
+		access.Error("request", fields...)
+	case status >= fiber.StatusBadRequest:
+		access.Warn("request", fields...)
+	default:
+		access.Info("request", fields...)
+	}
+
+	return err
 }
@@ -0,0 +1,49 @@
+package logging
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/charmbracelet/log"
+	"github.com/goccy/go-json"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/christian-nickerson/pangolin/control/internal/configs"
+)
+
+// assert handler and access log lines carry request scoped fields
+func TestMiddlewareJSON(t *testing.T) {
+	require.NoError(t, Setup(configs.Logging{Level: "info", Format: "json"}))
+	var buffer bytes.Buffer
+	log.SetOutput(&buffer)
+
+	app := fiber.New()
+	app.Use(requestid.New())
+	app.Use(Middleware)
+	app.Get("/collections/:collection", func(c *fiber.Ctx) error {
+		Ctx(c).Info("handled")
+		return c.SendStatus(fiber.StatusOK)
+	})
+
+	response, _ := app.Test(httptest.NewRequest("GET", "/collections/abc", nil))
+	requestID := response.Header.Get(fiber.HeaderXRequestID)
+
+	lines := bytes.Split(bytes.TrimSpace(buffer.Bytes()), []byte("\n"))
+	require.Len(t, lines, 2)
+	for _, line := range lines {
+		var entry map[string]interface{}
+		require.NoError(t, json.Unmarshal(line, &entry))
+		assert.Equal(t, requestID, entry["request_id"])
+		assert.Equal(t, "abc", entry["collection_id"])
+	}
+}
+
+// assert invalid settings are rejected
+func TestSetupInvalid(t *testing.T) {
+	assert.Error(t, Setup(configs.Logging{Level: "loud", Format: "json"}))
+	assert.Error(t, Setup(configs.Logging{Level: "info", Format: "xml"}))
+}
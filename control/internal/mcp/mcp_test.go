@@ -0,0 +1,215 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/goccy/go-json"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/christian-nickerson/pangolin/control/internal/collections"
+	"github.com/christian-nickerson/pangolin/control/internal/configs"
+	"github.com/christian-nickerson/pangolin/control/internal/database"
+	"github.com/christian-nickerson/pangolin/control/internal/documents"
+	"github.com/christian-nickerson/pangolin/control/internal/index"
+	"github.com/christian-nickerson/pangolin/control/internal/jobs"
+	"github.com/christian-nickerson/pangolin/control/internal/models"
+	"github.com/christian-nickerson/pangolin/control/internal/namespaces"
+	"github.com/christian-nickerson/pangolin/control/internal/search"
+	"github.com/christian-nickerson/pangolin/control/internal/storage"
+)
+
+// embed every text onto the x axis
+func embedX(_ context.Context, texts []string, _ string) ([]models.Vector, error) {
+	vectors := make([]models.Vector, len(texts))
+	for i := range texts {
+		vectors[i] = models.Vector{1, 0}
+	}
+	return vectors, nil
+}
+
+type MCPSuite struct {
+	suite.Suite
+	server  *Server
+	session Session
+}
+
+// set up collections "docs", holding document "a", and "other"
+func (s *MCPSuite) SetupTest() {
+	ctx := context.Background()
+	config := configs.DatabaseConfig{Type: "sqlite", DBName: filepath.Join(s.T().TempDir(), "test")}
+	s.Require().NoError(database.Connect(config))
+	s.Require().NoError(database.Migrate())
+
+	namespace, err := namespaces.EnsureDefault(ctx, configs.Quotas{})
+	s.Require().NoError(err)
+	store, err := storage.NewFilesystem(s.T().TempDir())
+	s.Require().NoError(err)
+
+	for _, name := range []string{"docs", "other"} {
+		collection := &models.Collection{Name: name, Model: "test", ChunkSize: 2, Metric: models.MetricCosine}
+		s.Require().NoError(collections.Create(ctx, namespace, collection))
+		index.Drop(collection.ID)
+	}
+	collection, err := collections.Get(ctx, namespace, "docs")
+	s.Require().NoError(err)
+	plan, err := documents.Prepare(ctx, collection, documents.Upsert{ID: "a/1", Text: "one two three", Metadata: map[string]string{"source": "x"}})
+	s.Require().NoError(err)
+	for i := range plan.Chunks {
+		plan.Vectors[i] = models.Vector{1, float64(i)}
+	}
+	s.Require().NoError(documents.Apply(ctx, collection, []*documents.Plan{plan}))
+
+	s.server = NewServer(embedX, jobs.NewQueue(configs.Jobs{}, embedX, store))
+	s.session = Session{
+		Key:       &models.APIKey{Scope: models.ScopeWrite, Collections: []string{"docs"}},
+		Namespace: namespace,
+	}
+}
+
+// send a request, decoding its result into out and returning any error
+func (s *MCPSuite) request(method string, params any, out any) *rpcError {
+	data, err := json.Marshal(map[string]any{"jsonrpc": "2.0", "id": 1, "method": method, "params": params})
+	s.Require().NoError(err)
+
+	var answer struct {
+		ID     int             `json:"id"`
+		Result json.RawMessage `json:"result"`
+		Error  *rpcError       `json:"error"`
+	}
+	s.Require().NoError(json.Unmarshal(s.server.Handle(context.Background(), s.session, data), &answer))
+	s.Equal(1, answer.ID)
+	if answer.Error == nil && out != nil {
+		s.Require().NoError(json.Unmarshal(answer.Result, out))
+	}
+	return answer.Error
+}
+
+// call a tool, decoding its text content into out and returning whether
+// the tool failed
+func (s *MCPSuite) call(name string, arguments any, out any) bool {
+	var result callResult
+	s.Require().Nil(s.request("tools/call", map[string]any{"name": name, "arguments": arguments}, &result))
+	s.Require().Len(result.Content, 1)
+	if !result.IsError && out != nil {
+		s.Require().NoError(json.Unmarshal([]byte(result.Content[0].Text), out))
+	}
+	return result.IsError
+}
+
+// Test clients agree a protocol revision and discover the tools
+func (s *MCPSuite) TestInitialize() {
+	var initialized initializeResult
+	s.Require().Nil(s.request("initialize", map[string]any{"protocolVersion": "2025-03-26"}, &initialized))
+	s.Equal("2025-03-26", initialized.ProtocolVersion)
+	s.Equal("pangolin", initialized.ServerInfo.Name)
+
+	s.Require().Nil(s.request("initialize", map[string]any{"protocolVersion": "1999-01-01"}, &initialized))
+	s.Equal(protocolVersions[0], initialized.ProtocolVersion)
+
+	var listed toolsList
+	s.Require().Nil(s.request("tools/list", nil, &listed))
+	names := make([]string, len(listed.Tools))
+	for i, tool := range listed.Tools {
+		names[i] = tool.Name
+	}
+	s.Equal([]string{"search_collection", "add_document", "list_collections", "get_document"}, names)
+
+	s.Equal(codeMethodNotFound, s.request("prompts/list", nil, nil).Code)
+	s.Nil(s.server.Handle(context.Background(), s.session, []byte(`{"jsonrpc":"2.0","method":"notifications/initialized"}`)))
+}
+
+// Test tools act through the service layer within the key's permissions
+func (s *MCPSuite) TestTools() {
+	var listed []models.Collection
+	s.False(s.call("list_collections", nil, &listed))
+	s.Require().Len(listed, 1)
+	s.Equal("docs", listed[0].Name)
+
+	var response search.Response
+	s.False(s.call("search_collection", map[string]any{"collection": "docs", "query": "one", "k": 1}, &response))
+	s.Require().Len(response.Results, 1)
+	s.Equal("a/1", response.Results[0].DocumentID)
+
+	var document documentContent
+	s.False(s.call("get_document", map[string]any{"collection": "docs", "id": "a/1"}, &document))
+	s.Equal("x", document.Metadata["source"])
+	s.Require().Len(document.Chunks, 2)
+	s.Equal("three", document.Chunks[1].Text)
+
+	var job models.Job
+	s.False(s.call("add_document", map[string]any{"collection": "docs", "id": "b", "text": "four"}, &job))
+	s.Equal(models.JobQueued, job.Status)
+	s.Equal([]string{"b"}, job.Documents)
+
+	// failures are reported to the model as tool errors
+	s.True(s.call("get_document", map[string]any{"collection": "docs", "id": "missing"}, nil))
+	s.True(s.call("search_collection", map[string]any{"collection": "other", "query": "one"}, nil))
+
+	s.session.Key.Scope = models.ScopeRead
+	s.True(s.call("add_document", map[string]any{"collection": "docs", "text": "four"}, nil))
+
+	// invalid arguments and unknown tools are protocol errors
+	failure := s.request("tools/call", map[string]any{"name": "search_collection", "arguments": map[string]any{"collection": "docs"}}, nil)
+	s.Require().NotNil(failure)
+	s.Equal(codeInvalidParams, failure.Code)
+	s.Contains(failure.Message, "query")
+	s.Equal(codeInvalidParams, s.request("tools/call", map[string]any{"name": "drop_collection"}, nil).Code)
+}
+
+// Test collections and documents are readable as resources
+func (s *MCPSuite) TestResources() {
+	var listed resourcesList
+	s.Require().Nil(s.request("resources/list", nil, &listed))
+	s.Require().Len(listed.Resources, 1)
+	s.Equal("pangolin://collections/docs", listed.Resources[0].URI)
+
+	var read readResult
+	s.Require().Nil(s.request("resources/read", map[string]any{"uri": "pangolin://collections/docs"}, &read))
+	var collection collectionContents
+	s.Require().NoError(json.Unmarshal([]byte(read.Contents[0].Text), &collection))
+	s.Equal("docs", collection.Name)
+	s.Require().Len(collection.Documents, 1)
+
+	s.Require().Nil(s.request("resources/read", map[string]any{"uri": "pangolin://collections/docs/documents/a%2F1"}, &read))
+	var document documentContent
+	s.Require().NoError(json.Unmarshal([]byte(read.Contents[0].Text), &document))
+	s.Equal("a/1", document.ExternalID)
+
+	s.Equal(codeInvalidParams, s.request("resources/read", map[string]any{"uri": "pangolin://collections/other"}, nil).Code)
+
+	s.session.Key.Collections = nil
+	for _, uri := range []string{"pangolin://collections/missing", "pangolin://collections/docs/documents/b", "file:///etc/passwd"} {
+		s.Equal(codeResourceNotFound, s.request("resources/read", map[string]any{"uri": uri}, nil).Code, uri)
+	}
+}
+
+// Test the stdio transport answers each request on its own line
+func (s *MCPSuite) TestStdio() {
+	in := strings.NewReader(strings.Join([]string{
+		`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-06-18"}}`,
+		`{"jsonrpc":"2.0","method":"notifications/initialized"}`,
+		``,
+		`{"jsonrpc":"2.0","id":2,"method":"ping"}`,
+		`not json`,
+	}, "\n"))
+	var out bytes.Buffer
+	s.Require().NoError(s.server.ServeStdio(context.Background(), s.session, in, &out))
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	s.Require().Len(lines, 3)
+	s.Contains(lines[0], `"protocolVersion":"2025-06-18"`)
+	s.JSONEq(`{"jsonrpc":"2.0","id":2,"result":{}}`, lines[1])
+	var failed response
+	s.Require().NoError(json.Unmarshal([]byte(lines[2]), &failed))
+	s.Equal("null", string(failed.ID))
+	s.Require().NotNil(failed.Error)
+	s.Equal(codeParseError, failed.Error.Code)
+}
+
+func TestMCPSuite(t *testing.T) {
+	suite.Run(t, new(MCPSuite))
+}
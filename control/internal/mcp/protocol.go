@@ -0,0 +1,143 @@
+package mcp
+
+import (
+	"github.com/goccy/go-json"
+)
+
+// protocol revisions the server speaks, newest first
+var protocolVersions = []string{"2025-06-18", "2025-03-26", "2024-11-05"}
+
+const jsonrpcVersion = "2.0"
+
+// JSON-RPC and MCP error codes
+const (
+	codeParseError       = -32700
+	codeInvalidRequest   = -32600
+	codeMethodNotFound   = -32601
+	codeInvalidParams    = -32602
+	codeInternalError    = -32603
+	codeResourceNotFound = -32002
+)
+
+// request is a JSON-RPC request, or a notification when it has no id
+type request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+// rpcError is a JSON-RPC error returned in place of a result
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return e.Message
+}
+
+// initialize types
+type initializeParams struct {
+	ProtocolVersion string `json:"protocolVersion"`
+}
+
+type initializeResult struct {
+	ProtocolVersion string         `json:"protocolVersion"`
+	Capabilities    capabilities   `json:"capabilities"`
+	ServerInfo      implementation `json:"serverInfo"`
+	Instructions    string         `json:"instructions,omitempty"`
+}
+
+type capabilities struct {
+	Tools     listChanged `json:"tools"`
+	Resources listChanged `json:"resources"`
+}
+
+type listChanged struct {
+	ListChanged bool `json:"listChanged"`
+}
+
+type implementation struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// tool types
+type tool struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	InputSchema schema `json:"inputSchema"`
+}
+
+// schema is the JSON schema of tool arguments
+type schema struct {
+	Type                 string            `json:"type"`
+	Description          string            `json:"description,omitempty"`
+	Properties           map[string]schema `json:"properties,omitempty"`
+	Required             []string          `json:"required,omitempty"`
+	AdditionalProperties *schema           `json:"additionalProperties,omitempty"`
+}
+
+type toolsList struct {
+	Tools []tool `json:"tools"`
+}
+
+type callParams struct {
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+type callResult struct {
+	Content []content `json:"content"`
+	IsError bool      `json:"isError"`
+}
+
+type content struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// resource types
+type resource struct {
+	URI         string `json:"uri"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	MimeType    string `json:"mimeType,omitempty"`
+}
+
+type resourceTemplate struct {
+	URITemplate string `json:"uriTemplate"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	MimeType    string `json:"mimeType,omitempty"`
+}
+
+type resourcesList struct {
+	Resources []resource `json:"resources"`
+}
+
+type templatesList struct {
+	ResourceTemplates []resourceTemplate `json:"resourceTemplates"`
+}
+
+type readParams struct {
+	URI string `json:"uri"`
+}
+
+type readResult struct {
+	Contents []resourceContents `json:"contents"`
+}
+
+type resourceContents struct {
+	URI      string `json:"uri"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
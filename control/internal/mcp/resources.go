@@ -0,0 +1,121 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+
+	"github.com/goccy/go-json"
+
+	"github.com/christian-nickerson/pangolin/control/internal/collections"
+	"github.com/christian-nickerson/pangolin/control/internal/documents"
+	"github.com/christian-nickerson/pangolin/control/internal/models"
+)
+
+const (
+	collectionsURI = "pangolin://collections/"
+	documentsPath  = "/documents/"
+	jsonMimeType   = "application/json"
+
+	// documents listed in a collection's contents
+	contentsLimit = 100
+)
+
+var templates = []resourceTemplate{
+	{
+		URITemplate: collectionsURI + "{collection}",
+		Name:        "Collection",
+		Description: "A collection's settings and usage, and the first documents it holds",
+		MimeType:    jsonMimeType,
+	},
+	{
+		URITemplate: collectionsURI + "{collection}" + documentsPath + "{document}",
+		Name:        "Document",
+		Description: "A document's metadata and the text of its chunks",
+		MimeType:    jsonMimeType,
+	},
+}
+
+// collectionContents is a collection along with its first documents
+type collectionContents struct {
+	*models.Collection
+	Documents []models.Document `json:"documents"`
+}
+
+// list a resource for each collection the session may read, documents
+// are reached through the template
+func listResources(ctx context.Context, session Session) (*resourcesList, error) {
+	all, err := listCollections(ctx, session)
+	if err != nil {
+		return nil, err
+	}
+
+	resources := make([]resource, len(all))
+	for i, collection := range all {
+		resources[i] = resource{
+			URI:         collectionsURI + collection.Name,
+			Name:        collection.Name,
+			Description: "Collection " + collection.Name + " and the first documents it holds",
+			MimeType:    jsonMimeType,
+		}
+	}
+	return &resourcesList{Resources: resources}, nil
+}
+
+// read a collection or document resource as JSON
+func readResource(ctx context.Context, session Session, uri string) (*readResult, error) {
+	collection, document, err := parseURI(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	var contents any
+	if document == "" {
+		contents, err = readCollection(ctx, session, collection)
+	} else {
+		contents, err = getDocument(ctx, session, getArguments{Collection: collection, ID: document})
+	}
+	if errors.Is(err, collections.ErrCollectionNotFound) || errors.Is(err, documents.ErrDocumentNotFound) {
+		return nil, &rpcError{Code: codeResourceNotFound, Message: err.Error()}
+	}
+	if err != nil {
+		return nil, &rpcError{Code: codeInvalidParams, Message: err.Error()}
+	}
+
+	data, err := json.MarshalIndent(contents, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return &readResult{Contents: []resourceContents{{URI: uri, MimeType: jsonMimeType, Text: string(data)}}}, nil
+}
+
+func readCollection(ctx context.Context, session Session, name string) (*collectionContents, error) {
+	collection, err := lookup(ctx, session, models.ScopeRead, name)
+	if err != nil {
+		return nil, err
+	}
+	all, err := documents.List(ctx, collection, contentsLimit, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &collectionContents{Collection: collection, Documents: all}, nil
+}
+
+// split a resource uri into its collection and escaped document id
+func parseURI(uri string) (string, string, error) {
+	path, ok := strings.CutPrefix(uri, collectionsURI)
+	if !ok || path == "" {
+		return "", "", &rpcError{Code: codeResourceNotFound, Message: "unknown resource " + uri}
+	}
+
+	collection, document, found := strings.Cut(path, documentsPath)
+	if strings.Contains(collection, "/") || (found && document == "") {
+		return "", "", &rpcError{Code: codeResourceNotFound, Message: "unknown resource " + uri}
+	}
+	document, err := url.PathUnescape(document)
+	if err != nil {
+		return "", "", &rpcError{Code: codeInvalidParams, Message: "invalid resource uri, " + err.Error()}
+	}
+	return collection, document, nil
+}
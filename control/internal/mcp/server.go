@@ -0,0 +1,151 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"slices"
+
+	"github.com/charmbracelet/log"
+	"github.com/goccy/go-json"
+
+	embeddings "github.com/christian-nickerson/pangolin/control/internal/embedding"
+	"github.com/christian-nickerson/pangolin/control/internal/jobs"
+	"github.com/christian-nickerson/pangolin/control/internal/models"
+)
+
+const serverVersion = "0.0.1"
+
+const instructions = "Pangolin is a vector database. List collections to find what is stored, " +
+	"search a collection with a natural language query, and add documents to make them searchable."
+
+// Session is who an MCP client acts as, an API key within its namespace
+type Session struct {
+	Key       *models.APIKey
+	Namespace *models.Namespace
+}
+
+// Server answers MCP requests with the same services as the REST routes,
+// checking the session's key allows each tool call and resource read.
+// Transports pass it each message received along with the session.
+type Server struct {
+	embed embeddings.Embedder
+	queue *jobs.Queue
+}
+
+// NewServer creates a server embedding search queries with embed and
+// queueing added documents on queue
+func NewServer(embed embeddings.Embedder, queue *jobs.Queue) *Server {
+	return &Server{embed: embed, queue: queue}
+}
+
+// Handle answers a JSON-RPC message, returning nil when no answer is due
+// such as for notifications
+func (s *Server) Handle(ctx context.Context, session Session, message []byte) []byte {
+	var req request
+	if err := json.Unmarshal(message, &req); err != nil {
+		if trimmed := bytes.TrimSpace(message); len(trimmed) > 0 && trimmed[0] == '[' {
+			return reply(nil, nil, &rpcError{Code: codeInvalidRequest, Message: "batches are not supported"})
+		}
+		return reply(nil, nil, &rpcError{Code: codeParseError, Message: err.Error()})
+	}
+	if req.JSONRPC != jsonrpcVersion || req.Method == "" {
+		// answers to server requests are not expected, as none are sent
+		if req.Method == "" && len(req.ID) > 0 {
+			return nil
+		}
+		return reply(req.ID, nil, &rpcError{Code: codeInvalidRequest, Message: "invalid json-rpc request"})
+	}
+
+	result, err := s.dispatch(ctx, session, req)
+	if len(req.ID) == 0 {
+		return nil
+	}
+
+	var rpcErr *rpcError
+	if err != nil && !errors.As(err, &rpcErr) {
+		log.Error("mcp request failed", "method", req.Method, "err", err)
+		rpcErr = &rpcError{Code: codeInternalError, Message: err.Error()}
+	}
+	return reply(req.ID, result, rpcErr)
+}
+
+// encode a response, ids are null when the request's is unknown
+func reply(id json.RawMessage, result any, err *rpcError) []byte {
+	if len(id) == 0 {
+		id = json.RawMessage("null")
+	}
+	if err != nil {
+		result = nil
+	} else if result == nil {
+		result = struct{}{}
+	}
+
+	data, marshalErr := json.Marshal(response{JSONRPC: jsonrpcVersion, ID: id, Result: result, Error: err})
+	if marshalErr != nil {
+		data, _ = json.Marshal(response{JSONRPC: jsonrpcVersion, ID: id, Error: &rpcError{Code: codeInternalError, Message: marshalErr.Error()}})
+	}
+	return data
+}
+
+func (s *Server) dispatch(ctx context.Context, session Session, req request) (any, error) {
+	switch req.Method {
+	case "initialize":
+		var params initializeParams
+		if err := decodeParams(req.Params, &params); err != nil {
+			return nil, err
+		}
+		return initialize(params), nil
+	case "ping", "notifications/initialized", "notifications/cancelled":
+		return nil, nil
+	case "tools/list":
+		return toolsList{Tools: tools}, nil
+	case "tools/call":
+		var params callParams
+		if err := decodeParams(req.Params, &params); err != nil {
+			return nil, err
+		}
+		return s.call(ctx, session, params)
+	case "resources/list":
+		return listResources(ctx, session)
+	case "resources/templates/list":
+		return templatesList{ResourceTemplates: templates}, nil
+	case "resources/read":
+		var params readParams
+		if err := decodeParams(req.Params, &params); err != nil {
+			return nil, err
+		}
+		return readResource(ctx, session, params.URI)
+	}
+	return nil, &rpcError{Code: codeMethodNotFound, Message: "method not found: " + req.Method}
+}
+
+// agree on the client's protocol revision when supported, otherwise
+// offer the newest and let the client decide
+func initialize(params initializeParams) initializeResult {
+	version := protocolVersions[0]
+	if Supports(params.ProtocolVersion) {
+		version = params.ProtocolVersion
+	}
+	return initializeResult{
+		ProtocolVersion: version,
+		ServerInfo:      implementation{Name: "pangolin", Version: serverVersion},
+		Instructions:    instructions,
+	}
+}
+
+// Supports reports whether version is a protocol revision the server speaks
+func Supports(version string) bool {
+	return slices.Contains(protocolVersions, version)
+}
+
+// decode request params, which may be omitted
+func decodeParams(params json.RawMessage, out any) error {
+	if len(params) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(params, out); err != nil {
+		return &rpcError{Code: codeInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
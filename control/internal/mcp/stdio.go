@@ -0,0 +1,36 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+)
+
+// largest message read from a stdio client
+const maxMessage = 16 << 20
+
+// ServeStdio serves a client sending newline delimited messages on in and
+// reading answers from out, until in closes or ctx is cancelled. Messages
+// are answered in order, one at a time.
+func (s *Server) ServeStdio(ctx context.Context, session Session, in io.Reader, out io.Writer) error {
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 0, 64<<10), maxMessage)
+
+	for scanner.Scan() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		message := bytes.TrimSpace(scanner.Bytes())
+		if len(message) == 0 {
+			continue
+		}
+
+		if answer := s.Handle(ctx, session, message); answer != nil {
+			if _, err := out.Write(append(answer, '\n')); err != nil {
+				return err
+			}
+		}
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,212 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/goccy/go-json"
+
+	"github.com/christian-nickerson/pangolin/control/internal/collections"
+	"github.com/christian-nickerson/pangolin/control/internal/documents"
+	"github.com/christian-nickerson/pangolin/control/internal/models"
+	"github.com/christian-nickerson/pangolin/control/internal/search"
+)
+
+var collectionArgument = schema{Type: "string", Description: "Name of the collection"}
+
+var tools = []tool{
+	{
+		Name:        "search_collection",
+		Description: "Search a collection for the chunks of documents nearest in meaning to a natural language query.",
+		InputSchema: schema{
+			Type: "object",
+			Properties: map[string]schema{
+				"collection": collectionArgument,
+				"query":      {Type: "string", Description: "Natural language query"},
+				"k":          {Type: "integer", Description: "Number of results, 1 to 100, 10 by default"},
+			},
+			Required: []string{"collection", "query"},
+		},
+	},
+	{
+		Name: "add_document",
+		Description: "Add a document to a collection, or replace the document with the same id. " +
+			"Documents are embedded in the background and become searchable once the returned job succeeds.",
+		InputSchema: schema{
+			Type: "object",
+			Properties: map[string]schema{
+				"collection": collectionArgument,
+				"id":         {Type: "string", Description: "Document id, generated when omitted"},
+				"text":       {Type: "string", Description: "Text of the document"},
+				"metadata": {
+					Type:                 "object",
+					Description:          "String metadata returned with search results",
+					AdditionalProperties: &schema{Type: "string"},
+				},
+			},
+			Required: []string{"collection", "text"},
+		},
+	},
+	{
+		Name:        "list_collections",
+		Description: "List the collections in the namespace which may be read.",
+		InputSchema: schema{Type: "object"},
+	},
+	{
+		Name:        "get_document",
+		Description: "Get a document in a collection by id, with the text of its chunks in order.",
+		InputSchema: schema{
+			Type: "object",
+			Properties: map[string]schema{
+				"collection": collectionArgument,
+				"id":         {Type: "string", Description: "Document id"},
+			},
+			Required: []string{"collection", "id"},
+		},
+	},
+}
+
+type searchArguments struct {
+	Collection string `json:"collection" validate:"required"`
+	Query      string `json:"query" validate:"required"`
+	K          int    `json:"k" validate:"omitempty,min=1,max=100"`
+}
+
+type addArguments struct {
+	Collection string            `json:"collection" validate:"required"`
+	ID         string            `json:"id" validate:"omitempty,max=256"`
+	Text       string            `json:"text" validate:"required"`
+	Metadata   map[string]string `json:"metadata"`
+}
+
+type getArguments struct {
+	Collection string `json:"collection" validate:"required"`
+	ID         string `json:"id" validate:"required"`
+}
+
+// documentContent is a document along with the text of its chunks
+type documentContent struct {
+	*models.Document
+	Chunks []models.Chunk `json:"chunks"`
+}
+
+// call a tool, failures of the tool itself are reported in the result so
+// the model can see and act on them
+func (s *Server) call(ctx context.Context, session Session, params callParams) (*callResult, error) {
+	var (
+		result any
+		err    error
+	)
+	switch params.Name {
+	case "search_collection":
+		args := searchArguments{K: 10}
+		if err := decodeArguments(params.Arguments, &args); err != nil {
+			return nil, err
+		}
+		result, err = s.search(ctx, session, args)
+	case "add_document":
+		var args addArguments
+		if err := decodeArguments(params.Arguments, &args); err != nil {
+			return nil, err
+		}
+		result, err = s.add(ctx, session, args)
+	case "list_collections":
+		result, err = listCollections(ctx, session)
+	case "get_document":
+		var args getArguments
+		if err := decodeArguments(params.Arguments, &args); err != nil {
+			return nil, err
+		}
+		result, err = getDocument(ctx, session, args)
+	default:
+		return nil, &rpcError{Code: codeInvalidParams, Message: "unknown tool: " + params.Name}
+	}
+
+	if err != nil {
+		return &callResult{Content: []content{{Type: "text", Text: err.Error()}}, IsError: true}, nil
+	}
+	data, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return &callResult{Content: []content{{Type: "text", Text: string(data)}}}, nil
+}
+
+// decode and validate tool arguments
+func decodeArguments(arguments json.RawMessage, out any) error {
+	if len(arguments) > 0 {
+		if err := json.Unmarshal(arguments, out); err != nil {
+			return &rpcError{Code: codeInvalidParams, Message: err.Error()}
+		}
+	}
+
+	err := models.Validator.Struct(out)
+	var fieldErrors validator.ValidationErrors
+	if errors.As(err, &fieldErrors) {
+		invalid := make([]string, len(fieldErrors))
+		for i, field := range fieldErrors {
+			invalid[i] = strings.ToLower(field.Field()) + " failed " + field.Tag()
+		}
+		return &rpcError{Code: codeInvalidParams, Message: "invalid arguments, " + strings.Join(invalid, ", ")}
+	}
+	if err != nil {
+		return &rpcError{Code: codeInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
+
+// look up a collection the session's key has scope on
+func lookup(ctx context.Context, session Session, scope models.Scope, name string) (*models.Collection, error) {
+	if !session.Key.Allows(scope, name) {
+		return nil, fmt.Errorf("api key lacks %v permission on collection %v", scope, name)
+	}
+	return collections.Get(ctx, session.Namespace, name)
+}
+
+func (s *Server) search(ctx context.Context, session Session, args searchArguments) (*search.Response, error) {
+	collection, err := lookup(ctx, session, models.ScopeRead, args.Collection)
+	if err != nil {
+		return nil, err
+	}
+	return search.Search(ctx, s.embed, collection, args.Query, args.K)
+}
+
+func (s *Server) add(ctx context.Context, session Session, args addArguments) (*models.Job, error) {
+	collection, err := lookup(ctx, session, models.ScopeWrite, args.Collection)
+	if err != nil {
+		return nil, err
+	}
+	upsert := documents.Upsert{ID: args.ID, Text: args.Text, Metadata: args.Metadata}
+	return s.queue.Submit(ctx, session.Namespace, collection, []documents.Upsert{upsert})
+}
+
+// collections in the namespace the session's key may read
+func listCollections(ctx context.Context, session Session) ([]models.Collection, error) {
+	all, err := collections.List(ctx, session.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(all, func(collection models.Collection) bool {
+		return !session.Key.Allows(models.ScopeRead, collection.Name)
+	}), nil
+}
+
+func getDocument(ctx context.Context, session Session, args getArguments) (*documentContent, error) {
+	collection, err := lookup(ctx, session, models.ScopeRead, args.Collection)
+	if err != nil {
+		return nil, err
+	}
+	document, err := documents.Get(ctx, collection, args.ID)
+	if err != nil {
+		return nil, err
+	}
+	chunks, err := documents.Chunks(ctx, document)
+	if err != nil {
+		return nil, err
+	}
+	return &documentContent{Document: document, Chunks: chunks}, nil
+}
@@ -0,0 +1,125 @@
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "pangolin"
+
+// Registry holds every control plane metric along with Go runtime stats
+var Registry = prometheus.NewRegistry()
+
+var (
+	HTTPRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests handled, by method, route and status.",
+	}, []string{"method", "route", "status"})
+
+	HTTPLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency, by method, route and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	EmbeddingLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "embedding_request_duration_seconds",
+		Help:      "Embedding RPC latency, by model.",
+		Buckets:   prometheus.ExponentialBuckets(0.005, 2, 14),
+	}, []string{"model"})
+
+	EmbeddingErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "embedding_errors_total",
+		Help:      "Failed embedding RPCs, by model.",
+	}, []string{"model"})
+
+	EmbeddingBatchSize = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "embedding_batch_size",
+		Help:      "Number of texts sent per embedding RPC, by model.",
+		Buckets:   prometheus.ExponentialBuckets(1, 2, 11),
+	}, []string{"model"})
+
+	IndexSize = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "index_vectors",
+		Help:      "Vectors held in the index, by collection.",
+	}, []string{"collection"})
+
+	SearchLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "search_duration_seconds",
+		Help:      "Index search latency, by collection.",
+		Buckets:   prometheus.ExponentialBuckets(0.0005, 2, 14),
+	}, []string{"collection"})
+
+	SearchRecall = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "search_recall",
+		Help:      "Recall of sampled searches against a brute force search, by collection.",
+		Buckets:   prometheus.LinearBuckets(0.5, 0.05, 11),
+	}, []string{"collection"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequests,
+		HTTPLatency,
+		EmbeddingLatency,
+		EmbeddingErrors,
+		EmbeddingBatchSize,
+		IndexSize,
+		SearchLatency,
+		SearchRecall,
+	)
+}
+
+// Middleware records request counts and latency by matched route
+func Middleware(c *fiber.Ctx) error {
+	start := time.Now()
+	err := c.Next()
+
+	// let the error handler resolve the final status code
+	status := c.Response().StatusCode()
+	if err != nil {
+		if e, ok := err.(*fiber.Error); ok {
+			status = e.Code
+		} else {
+			status = fiber.StatusInternalServerError
+		}
+	}
+
+	labels := prometheus.Labels{
+		"method": c.Method(),
+		"route":  c.Route().Path,
+		"status": strconv.Itoa(status),
+	}
+	HTTPRequests.With(labels).Inc()
+	HTTPLatency.With(labels).Observe(time.Since(start).Seconds())
+
+	return err
+}
+
+// Handler serves metrics in the Prometheus exposition format
+var Handler = adaptor.HTTPHandler(promhttp.HandlerFor(Registry, promhttp.HandlerOpts{}))
+
+// ObserveEmbedding records the outcome of a single embedding RPC
+func ObserveEmbedding(model string, batchSize int, duration time.Duration, err error) {
+	EmbeddingLatency.WithLabelValues(model).Observe(duration.Seconds())
+	EmbeddingBatchSize.WithLabelValues(model).Observe(float64(batchSize))
+	if err != nil {
+		EmbeddingErrors.WithLabelValues(model).Inc()
+	}
+}
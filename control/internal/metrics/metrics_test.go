@@ -0,0 +1,43 @@
+package metrics
+
+import (
+	"errors"
+	"io"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// assert requests are counted by matched route and status
+func TestMiddleware(t *testing.T) {
+	app := fiber.New()
+	app.Use(Middleware)
+	app.Get("/metrics", Handler)
+	app.Get("/collections/:id", func(c *fiber.Ctx) error { return c.SendStatus(fiber.StatusNoContent) })
+
+	before := testutil.ToFloat64(HTTPRequests.WithLabelValues("GET", "/collections/:id", "204"))
+	response, _ := app.Test(httptest.NewRequest("GET", "/collections/1", nil))
+	assert.Equal(t, 204, response.StatusCode)
+
+	after := testutil.ToFloat64(HTTPRequests.WithLabelValues("GET", "/collections/:id", "204"))
+	assert.Equal(t, before+1, after)
+
+	// metrics endpoint exposes runtime and http metrics
+	response, _ = app.Test(httptest.NewRequest("GET", "/metrics", nil))
+	body, _ := io.ReadAll(response.Body)
+	assert.Equal(t, 200, response.StatusCode)
+	assert.Contains(t, string(body), "pangolin_http_requests_total")
+	assert.Contains(t, string(body), "go_goroutines")
+}
+
+// assert embedding errors are counted per model
+func TestObserveEmbedding(t *testing.T) {
+	ObserveEmbedding("test-model", 8, time.Millisecond, nil)
+	ObserveEmbedding("test-model", 8, time.Millisecond, errors.New("failed"))
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(EmbeddingErrors.WithLabelValues("test-model")))
+}
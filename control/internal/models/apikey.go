@@ -0,0 +1,69 @@
+package models
+
+import (
+	"slices"
+	"time"
+)
+
+// Scope grants a level of access to an API key. Each scope
+// implies those below it: admin > write > read.
+type Scope string
+
+const (
+	ScopeRead  Scope = "read"
+	ScopeWrite Scope = "write"
+	ScopeAdmin Scope = "admin"
+)
+
+var scopeRank = map[Scope]int{ScopeRead: 1, ScopeWrite: 2, ScopeAdmin: 3}
+
+// Includes reports whether the scope grants required
+func (s Scope) Includes(required Scope) bool {
+	return scopeRank[s] >= scopeRank[required]
+}
+
+// APIKey is a hashed API key and the permissions it grants
+type APIKey struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	NamespaceID uint       `gorm:"index;not null" json:"namespace_id"`
+	Name        string     `gorm:"not null" json:"name"`
+	Prefix      string     `gorm:"uniqueIndex;not null" json:"prefix"`
+	Hash        string     `gorm:"not null" json:"-"`
+	Scope       Scope      `gorm:"not null" json:"scope"`
+	Collections []string   `gorm:"serializer:json" json:"collections"`
+	CreatedAt   time.Time  `json:"created_at"`
+	RotatedAt   *time.Time `json:"rotated_at,omitempty"`
+	RevokedAt   *time.Time `json:"revoked_at,omitempty"`
+}
+
+// Allows reports whether the key grants scope, and access to the
+// collection when one is given. Keys without collections may access all.
+func (k *APIKey) Allows(scope Scope, collection string) bool {
+	if !k.Scope.Includes(scope) {
+		return false
+	}
+	if collection == "" || len(k.Collections) == 0 {
+		return true
+	}
+	return slices.Contains(k.Collections, collection)
+}
+
+// Covers reports whether the key grants at least scope over collections,
+// so a key holder cannot issue keys beyond their own access
+func (k *APIKey) Covers(scope Scope, collections []string) bool {
+	if !k.Scope.Includes(scope) {
+		return false
+	}
+	if len(k.Collections) == 0 {
+		return true
+	}
+	if len(collections) == 0 {
+		return false
+	}
+	for _, collection := range collections {
+		if !slices.Contains(k.Collections, collection) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"io"
+	"slices"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// LimitBody buffers request bodies of at most limit bytes, rejecting
+// larger bodies. Requests to unlimited paths are passed on unread, so
+// their routes can stream bodies of any size when the server streams
+// request bodies.
+func LimitBody(limit int, unlimited ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		request := c.Request()
+		if !request.IsBodyStream() || slices.Contains(unlimited, c.Path()) {
+			return c.Next()
+		}
+		if request.Header.ContentLength() > limit {
+			return fiber.ErrRequestEntityTooLarge
+		}
+
+		// chunked bodies have no length until read
+		body, err := io.ReadAll(io.LimitReader(c.Context().RequestBodyStream(), int64(limit)+1))
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+		if len(body) > limit {
+			return fiber.ErrRequestEntityTooLarge
+		}
+		request.SetBody(body)
+		return c.Next()
+	}
+}
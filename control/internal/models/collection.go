@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+// Metric is the distance metric used to search a collection
+type Metric string
+
+const (
+	MetricCosine    Metric = "cosine"
+	MetricDot       Metric = "dot"
+	MetricEuclidean Metric = "euclidean"
+)
+
+// Collection is a set of documents sharing an embedding model,
+// chunking and distance metric, unique by name within a namespace.
+// Collections with shards are split across index nodes by document id,
+// each shard kept on replicas nodes, otherwise they are indexed by the
+// control plane.
+type Collection struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	NamespaceID   uint      `gorm:"uniqueIndex:idx_collection_name;not null" json:"namespace_id"`
+	Name          string    `gorm:"uniqueIndex:idx_collection_name;not null" json:"name"`
+	Model         string    `gorm:"not null" json:"model"`
+	ChunkSize     int       `gorm:"not null" json:"chunk_size"`
+	ChunkOverlap  int       `json:"chunk_overlap"`
+	Metric        Metric    `gorm:"not null" json:"metric"`
+	Shards        int       `json:"shards"`
+	Replicas      int       `json:"replicas"`
+	DocumentCount int64     `json:"document_count"`
+	VectorBytes   int64     `json:"vector_bytes"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// Sharded reports whether the collection is indexed on index nodes
+func (c *Collection) Sharded() bool {
+	return c.Shards > 0
+}
+
+// ReplicationFactor is the number of nodes each shard is kept on
+func (c *Collection) ReplicationFactor() int {
+	return max(c.Replicas, 1)
+}
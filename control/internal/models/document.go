@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+// Document is a piece of text written to a collection, stored as chunks.
+// Documents are addressed by an external id unique within the collection,
+// and the version increments on every change for optimistic concurrency.
+type Document struct {
+	ID           uint              `gorm:"primaryKey" json:"-"`
+	CollectionID uint              `gorm:"uniqueIndex:idx_document_external;not null" json:"collection_id"`
+	ExternalID   string            `gorm:"uniqueIndex:idx_document_external;not null" json:"id"`
+	ContentHash  string            `gorm:"not null" json:"content_hash"`
+	Version      int               `gorm:"not null" json:"version"`
+	Metadata     map[string]string `gorm:"serializer:json" json:"metadata,omitempty"`
+	ChunkCount   int               `json:"chunk_count"`
+	VectorBytes  int64             `json:"vector_bytes"`
+	CreatedAt    time.Time         `json:"created_at"`
+	UpdatedAt    time.Time         `json:"updated_at"`
+
+	// Reindex marks a document whose index write failed after its rows
+	// changed, its next upsert is applied even if unchanged
+	Reindex bool `gorm:"not null;default:false" json:"-"`
+}
+
+// Chunk is an embedded section of a document, its vector lives in the index
+// keyed by chunk id. The hash lets unchanged chunks keep their vectors.
+type Chunk struct {
+	ID           uint   `gorm:"primaryKey" json:"id"`
+	CollectionID uint   `gorm:"index;not null" json:"-"`
+	DocumentID   uint   `gorm:"index;not null" json:"-"`
+	Position     int    `json:"position"`
+	Hash         string `gorm:"not null" json:"hash"`
+	Text         string `gorm:"not null" json:"text"`
+}
@@ -0,0 +1,76 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+)
+
+// CollectionParam is the route parameter identifying a collection
+const CollectionParam = "collection"
+
+// ValidationError lists the fields that failed validation
+type ValidationError []*IError
+
+func (e ValidationError) Error() string {
+	return fmt.Sprintf("%v fields failed validation", len(e))
+}
+
+// build a ValidationError from validator errors
+func validationError(err error) error {
+	var fieldErrors validator.ValidationErrors
+	if !errors.As(err, &fieldErrors) {
+		return fiber.NewError(fiber.StatusUnprocessableEntity, err.Error())
+	}
+
+	var errs ValidationError
+	for _, err := range fieldErrors {
+		errs = append(errs, &IError{Field: err.Field(), Tag: err.Tag(), Value: err.Param()})
+	}
+	return errs
+}
+
+// BindBody parses and validates the request body into out
+func BindBody(c *fiber.Ctx, out interface{}) error {
+	if err := c.BodyParser(out); err != nil {
+		return fiber.NewError(fiber.StatusUnprocessableEntity, err.Error())
+	}
+	if err := Validator.Struct(out); err != nil {
+		return validationError(err)
+	}
+	return nil
+}
+
+// BindQuery parses and validates query parameters into out
+func BindQuery(c *fiber.Ctx, out interface{}) error {
+	if err := c.QueryParser(out); err != nil {
+		return fiber.NewError(fiber.StatusUnprocessableEntity, err.Error())
+	}
+	if err := Validator.Struct(out); err != nil {
+		return validationError(err)
+	}
+	return nil
+}
+
+// ErrorHandler renders handler errors as JSON responses
+func ErrorHandler(c *fiber.Ctx, err error) error {
+	var errs ValidationError
+	if errors.As(err, &errs) {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(errs)
+	}
+
+	var quota *QuotaError
+	if errors.As(err, &quota) {
+		return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{"error": err.Error(), "quota": quota})
+	}
+
+	code := fiber.StatusInternalServerError
+	var e *fiber.Error
+	if errors.As(err, &e) {
+		code = e.Code
+	}
+
+	return c.Status(code).JSON(fiber.Map{"error": err.Error()})
+}
@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+// JobStatus is the lifecycle state of a background job
+type JobStatus string
+
+const (
+	JobQueued    JobStatus = "queued"
+	JobRunning   JobStatus = "running"
+	JobSucceeded JobStatus = "succeeded"
+	JobFailed    JobStatus = "failed"
+	JobCancelled JobStatus = "cancelled"
+)
+
+// Done reports whether the job has finished and will not run again
+func (s JobStatus) Done() bool {
+	return s == JobSucceeded || s == JobFailed || s == JobCancelled
+}
+
+// Job is a persisted ingestion request processed by the worker pool,
+// progress counts chunks embedded out of the total to embed
+type Job struct {
+	ID           string     `gorm:"primaryKey" json:"id"`
+	NamespaceID  uint       `gorm:"index;not null" json:"namespace_id"`
+	CollectionID uint       `gorm:"index;not null" json:"collection_id"`
+	Status       JobStatus  `gorm:"index;not null" json:"status"`
+	Total        int        `json:"total"`
+	Completed    int        `json:"completed"`
+	Attempts     int        `json:"attempts"`
+	Error        string     `json:"error,omitempty"`
+	Documents    []string   `gorm:"serializer:json" json:"documents"`
+	Payload      []byte     `json:"-"`
+	NextRunAt    time.Time  `gorm:"index" json:"next_run_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+	StartedAt    *time.Time `json:"started_at,omitempty"`
+	FinishedAt   *time.Time `json:"finished_at,omitempty"`
+}
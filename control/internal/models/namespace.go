@@ -0,0 +1,40 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// DefaultNamespace is used when auth is disabled or the
+// unrestricted key does not name a namespace
+const DefaultNamespace = "default"
+
+// Namespace isolates a tenant's collections and keys, each
+// quota of zero is unlimited
+type Namespace struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	Name           string    `gorm:"uniqueIndex;not null" json:"name"`
+	MaxCollections int64     `json:"max_collections"`
+	MaxDocuments   int64     `json:"max_documents"`
+	MaxVectorBytes int64     `json:"max_vector_bytes"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// Usage totals resources held by a namespace
+type Usage struct {
+	Collections int64 `json:"collections"`
+	Documents   int64 `json:"documents"`
+	VectorBytes int64 `json:"vector_bytes"`
+}
+
+// QuotaError reports a write that would exceed a namespace quota
+type QuotaError struct {
+	Quota     string `json:"quota"`
+	Limit     int64  `json:"limit"`
+	Usage     int64  `json:"usage"`
+	Requested int64  `json:"requested"`
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("%v quota exceeded, %v of %v used", e.Quota, e.Usage, e.Limit)
+}
@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// NodeStatus is the membership state of an index node
+type NodeStatus string
+
+const (
+	NodeAlive   NodeStatus = "alive"
+	NodeSuspect NodeStatus = "suspect"
+	NodeDead    NodeStatus = "dead"
+	NodeLeft    NodeStatus = "left"
+)
+
+// HostedShard is a collection shard reported by the node hosting it
+type HostedShard struct {
+	CollectionID uint   `json:"collection_id"`
+	Shard        int    `json:"shard"`
+	Vectors      uint64 `json:"vectors"`
+}
+
+// Node is an index node registered with the control plane, reachable at
+// its gRPC address. Capacity is reported by the node, zero is unknown.
+type Node struct {
+	ID            string        `gorm:"primaryKey" json:"id"`
+	Address       string        `gorm:"not null" json:"address"`
+	MaxShards     int           `json:"max_shards"`
+	MemoryBytes   uint64        `json:"memory_bytes"`
+	DiskBytes     uint64        `json:"disk_bytes"`
+	Shards        []HostedShard `gorm:"serializer:json" json:"shards"`
+	Status        NodeStatus    `gorm:"index;not null" json:"status"`
+	RegisteredAt  time.Time     `json:"registered_at"`
+	LastHeartbeat time.Time     `json:"last_heartbeat"`
+	UpdatedAt     time.Time     `json:"updated_at"`
+}
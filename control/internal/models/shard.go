@@ -0,0 +1,44 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// ShardRef identifies one shard of a collection
+type ShardRef struct {
+	CollectionID uint `json:"collection_id"`
+	Shard        int  `json:"shard"`
+}
+
+func (r ShardRef) String() string {
+	return fmt.Sprintf("%v/%v", r.CollectionID, r.Shard)
+}
+
+// ReplicaState is the state of a shard replica on a node
+type ReplicaState string
+
+const (
+	// ReplicaReady replicas hold every acknowledged write and serve reads
+	ReplicaReady ReplicaState = "ready"
+	// ReplicaSyncing replicas receive writes while being copied to
+	ReplicaSyncing ReplicaState = "syncing"
+	// ReplicaStale replicas missed a write and must be copied again
+	ReplicaStale ReplicaState = "stale"
+)
+
+// ShardPlacement assigns a replica of a collection shard to the index
+// node hosting it
+type ShardPlacement struct {
+	CollectionID uint         `gorm:"primaryKey;autoIncrement:false" json:"collection_id"`
+	Shard        int          `gorm:"primaryKey;autoIncrement:false" json:"shard"`
+	NodeID       string       `gorm:"primaryKey;index" json:"node_id"`
+	State        ReplicaState `gorm:"not null;default:ready" json:"state"`
+	CreatedAt    time.Time    `json:"created_at"`
+	UpdatedAt    time.Time    `json:"updated_at"`
+}
+
+// Ref returns the shard the placement hosts
+func (p *ShardPlacement) Ref() ShardRef {
+	return ShardRef{CollectionID: p.CollectionID, Shard: p.Shard}
+}
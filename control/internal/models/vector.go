@@ -6,3 +6,8 @@ type Vector []float64
 func (x Vector) Length() int {
 	return len(x)
 }
+
+// Bytes returns the storage size of the vector components
+func (x Vector) Bytes() int64 {
+	return int64(len(x)) * 8
+}
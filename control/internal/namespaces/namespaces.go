@@ -0,0 +1,200 @@
+package namespaces
+
+import (
+	"context"
+	"errors"
+
+	"gorm.io/gorm"
+
+	"github.com/christian-nickerson/pangolin/control/internal/configs"
+	"github.com/christian-nickerson/pangolin/control/internal/consensus"
+	"github.com/christian-nickerson/pangolin/control/internal/database"
+	"github.com/christian-nickerson/pangolin/control/internal/models"
+)
+
+var (
+	ErrNamespaceNotFound = errors.New("namespace not found")
+	ErrNamespaceExists   = errors.New("namespace already exists")
+	ErrNamespaceNotEmpty = errors.New("namespace still has collections")
+)
+
+// EnsureDefault creates the default namespace with default quotas if missing
+func EnsureDefault(ctx context.Context, quotas configs.Quotas) (*models.Namespace, error) {
+	namespace := models.Namespace{
+		Name:           models.DefaultNamespace,
+		MaxCollections: quotas.MaxCollections,
+		MaxDocuments:   quotas.MaxDocuments,
+		MaxVectorBytes: quotas.MaxVectorBytes,
+	}
+	err := database.DB.WithContext(ctx).
+		Where(models.Namespace{Name: models.DefaultNamespace}).
+		FirstOrCreate(&namespace).Error
+	return &namespace, err
+}
+
+// Create stores a new namespace
+func Create(ctx context.Context, namespace *models.Namespace) error {
+	if _, err := Get(ctx, namespace.Name); err == nil {
+		return ErrNamespaceExists
+	}
+	return database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(namespace).Error; err != nil {
+			return err
+		}
+		return consensus.Publish(tx, namespace)
+	})
+}
+
+// List returns all namespaces
+func List(ctx context.Context) ([]models.Namespace, error) {
+	var namespaces []models.Namespace
+	err := database.DB.WithContext(ctx).Order("name").Find(&namespaces).Error
+	return namespaces, err
+}
+
+// Get returns a namespace by name
+func Get(ctx context.Context, name string) (*models.Namespace, error) {
+	var namespace models.Namespace
+	err := database.DB.WithContext(ctx).Where("name = ?", name).First(&namespace).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNamespaceNotFound
+	}
+	return &namespace, err
+}
+
+// GetByID returns a namespace by id
+func GetByID(ctx context.Context, id uint) (*models.Namespace, error) {
+	var namespace models.Namespace
+	err := database.DB.WithContext(ctx).First(&namespace, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNamespaceNotFound
+	}
+	return &namespace, err
+}
+
+// UpdateQuotas replaces the quotas of a namespace
+func UpdateQuotas(ctx context.Context, namespace *models.Namespace) error {
+	return database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(namespace).
+			Select("MaxCollections", "MaxDocuments", "MaxVectorBytes").
+			Updates(namespace).Error
+		if err != nil {
+			return err
+		}
+		return consensus.Publish(tx, namespace)
+	})
+}
+
+// Delete removes an empty namespace along with its keys
+func Delete(ctx context.Context, namespace *models.Namespace) error {
+	usage, err := GetUsage(ctx, namespace)
+	if err != nil {
+		return err
+	}
+	if usage.Collections > 0 {
+		return ErrNamespaceNotEmpty
+	}
+
+	return database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("namespace_id = ?", namespace.ID).Delete(&models.APIKey{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(namespace).Error; err != nil {
+			return err
+		}
+		if err := consensus.PublishTable(tx, &models.APIKey{}); err != nil {
+			return err
+		}
+		return consensus.Publish(tx, namespace)
+	})
+}
+
+// GetUsage totals the collections, documents and vector bytes held by a namespace
+func GetUsage(ctx context.Context, namespace *models.Namespace) (models.Usage, error) {
+	return usage(database.DB.WithContext(ctx), namespace.ID)
+}
+
+func usage(db *gorm.DB, namespaceID uint) (models.Usage, error) {
+	var usage models.Usage
+	err := db.
+		Model(&models.Collection{}).
+		Select("COUNT(*) AS collections, COALESCE(SUM(document_count), 0) AS documents, COALESCE(SUM(vector_bytes), 0) AS vector_bytes").
+		Where("namespace_id = ?", namespaceID).
+		Scan(&usage).Error
+	return usage, err
+}
+
+// CheckCollections returns a QuotaError if another collection would exceed
+// quota. It rejects writes early, Reserve enforces quotas as they apply.
+func CheckCollections(ctx context.Context, namespace *models.Namespace) error {
+	usage, err := GetUsage(ctx, namespace)
+	if err != nil {
+		return err
+	}
+	return check("max_collections", namespace.MaxCollections, usage.Collections, 1)
+}
+
+// CheckWrite returns a QuotaError if writing the given number of documents
+// and vector bytes would exceed quota. It rejects writes early, Reserve
+// enforces quotas as they apply.
+func CheckWrite(ctx context.Context, namespace *models.Namespace, documents, vectorBytes int64) error {
+	usage, err := GetUsage(ctx, namespace)
+	if err != nil {
+		return err
+	}
+	return checkUsage(namespace, usage, models.Usage{Documents: documents, VectorBytes: vectorBytes})
+}
+
+// Reserve returns a QuotaError if adding requested to the namespace's usage
+// would exceed quota. It locks the namespace row until tx ends, so writes
+// checking quotas in their transaction apply one at a time per namespace
+// and cannot exceed quotas together.
+func Reserve(tx *gorm.DB, namespaceID uint, requested models.Usage) error {
+	if requested.Collections <= 0 && requested.Documents <= 0 && requested.VectorBytes <= 0 {
+		return nil
+	}
+
+	locked := tx.Model(&models.Namespace{}).Where("id = ?", namespaceID).UpdateColumn("id", gorm.Expr("id"))
+	if locked.Error != nil {
+		return locked.Error
+	}
+	if locked.RowsAffected == 0 {
+		return ErrNamespaceNotFound
+	}
+
+	var namespace models.Namespace
+	if err := tx.First(&namespace, namespaceID).Error; err != nil {
+		return err
+	}
+	current, err := usage(tx, namespaceID)
+	if err != nil {
+		return err
+	}
+	return checkUsage(&namespace, current, requested)
+}
+
+// compare each quota against usage and the requested increase
+func checkUsage(namespace *models.Namespace, usage, requested models.Usage) error {
+	if requested.Collections > 0 {
+		if err := check("max_collections", namespace.MaxCollections, usage.Collections, requested.Collections); err != nil {
+			return err
+		}
+	}
+	if requested.Documents > 0 {
+		if err := check("max_documents", namespace.MaxDocuments, usage.Documents, requested.Documents); err != nil {
+			return err
+		}
+	}
+	if requested.VectorBytes > 0 {
+		return check("max_vector_bytes", namespace.MaxVectorBytes, usage.VectorBytes, requested.VectorBytes)
+	}
+	return nil
+}
+
+// compare usage against a limit, where zero is unlimited
+func check(quota string, limit, usage, requested int64) error {
+	if limit == 0 || usage+requested <= limit {
+		return nil
+	}
+	return &models.QuotaError{Quota: quota, Limit: limit, Usage: usage, Requested: requested}
+}
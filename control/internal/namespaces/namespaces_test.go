@@ -0,0 +1,94 @@
+package namespaces
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/christian-nickerson/pangolin/control/internal/configs"
+	"github.com/christian-nickerson/pangolin/control/internal/database"
+	"github.com/christian-nickerson/pangolin/control/internal/models"
+)
+
+// assert document and storage quotas are enforced against collection usage
+func TestCheckWrite(t *testing.T) {
+	ctx := context.Background()
+	config := configs.DatabaseConfig{Type: "sqlite", DBName: filepath.Join(t.TempDir(), "test")}
+	require.NoError(t, database.Connect(config))
+	require.NoError(t, database.Migrate())
+
+	namespace := &models.Namespace{Name: "quota", MaxDocuments: 10, MaxVectorBytes: 1024}
+	require.NoError(t, Create(ctx, namespace))
+	require.NoError(t, database.DB.Create(&models.Collection{
+		NamespaceID:   namespace.ID,
+		Name:          "docs",
+		Model:         "model",
+		ChunkSize:     256,
+		Metric:        models.MetricCosine,
+		DocumentCount: 8,
+		VectorBytes:   512,
+	}).Error)
+
+	assert.NoError(t, CheckWrite(ctx, namespace, 2, 512))
+
+	var quota *models.QuotaError
+	require.True(t, errors.As(CheckWrite(ctx, namespace, 3, 0), &quota))
+	assert.Equal(t, "max_documents", quota.Quota)
+
+	require.True(t, errors.As(CheckWrite(ctx, namespace, 1, 513), &quota))
+	assert.Equal(t, "max_vector_bytes", quota.Quota)
+
+	// zero quotas are unlimited
+	unlimited := &models.Namespace{Name: "unlimited"}
+	require.NoError(t, Create(ctx, unlimited))
+	assert.NoError(t, CheckWrite(ctx, unlimited, 1<<40, 1<<40))
+}
+
+// assert reservations see usage committed by earlier transactions and
+// decreases are always allowed
+func TestReserve(t *testing.T) {
+	ctx := context.Background()
+	config := configs.DatabaseConfig{Type: "sqlite", DBName: filepath.Join(t.TempDir(), "test")}
+	require.NoError(t, database.Connect(config))
+	require.NoError(t, database.Migrate())
+
+	namespace := &models.Namespace{Name: "quota", MaxCollections: 1, MaxDocuments: 10}
+	require.NoError(t, Create(ctx, namespace))
+	collection := &models.Collection{NamespaceID: namespace.ID, Name: "docs", Model: "model", ChunkSize: 256, Metric: models.MetricCosine}
+
+	write := func(documents int64) error {
+		return database.DB.Transaction(func(tx *gorm.DB) error {
+			if err := Reserve(tx, namespace.ID, models.Usage{Documents: documents}); err != nil {
+				return err
+			}
+			return tx.Model(collection).UpdateColumn("document_count", gorm.Expr("document_count + ?", documents)).Error
+		})
+	}
+
+	require.NoError(t, database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := Reserve(tx, namespace.ID, models.Usage{Collections: 1}); err != nil {
+			return err
+		}
+		return tx.Create(collection).Error
+	}))
+	assert.NoError(t, write(6))
+
+	var quota *models.QuotaError
+	require.True(t, errors.As(write(6), &quota))
+	assert.Equal(t, "max_documents", quota.Quota)
+	assert.Equal(t, int64(6), quota.Usage)
+
+	assert.NoError(t, write(-6))
+	assert.NoError(t, write(10))
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		return Reserve(tx, namespace.ID, models.Usage{Collections: 1})
+	})
+	require.True(t, errors.As(err, &quota))
+	assert.Equal(t, "max_collections", quota.Quota)
+}
@@ -0,0 +1,103 @@
+package nodes
+
+import (
+	"context"
+	"time"
+
+	"github.com/charmbracelet/log"
+
+	"github.com/christian-nickerson/pangolin/control/internal/models"
+	"github.com/christian-nickerson/pangolin/control/internal/proto"
+)
+
+// retry period for an agent which has not registered yet
+const registerRetry = time.Second
+
+// Agent keeps an index node registered with the control plane, heartbeating
+// with the shards it hosts and registering again when the registry has
+// declared it dead or lost it
+type Agent struct {
+	client proto.RegistryClient
+	node   models.Node
+	shards func() []models.HostedShard
+}
+
+// NewAgent creates an agent registering node, reporting the shards
+// returned by shards on every beat
+func NewAgent(client proto.RegistryClient, node models.Node, shards func() []models.HostedShard) *Agent {
+	return &Agent{client: client, node: node, shards: shards}
+}
+
+// Run registers then heartbeats until ctx is cancelled, deregistering on
+// the way out. Failed calls are retried on the next beat.
+func (a *Agent) Run(ctx context.Context) {
+	interval, registered := registerRetry, false
+
+	for {
+		if registered {
+			registered = a.heartbeat(ctx)
+		}
+		if !registered {
+			if beat, err := a.register(ctx); err == nil {
+				interval, registered = beat, true
+			} else if ctx.Err() == nil {
+				log.Warn("node registration failed", "node_id", a.node.ID, "err", err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			a.deregister(ctx)
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// register the node, returning the heartbeat interval
+func (a *Agent) register(ctx context.Context) (time.Duration, error) {
+	response, err := a.client.Register(ctx, &proto.RegisterRequest{
+		NodeId:  a.node.ID,
+		Address: a.node.Address,
+		Capacity: &proto.Capacity{
+			MaxShards:   uint32(a.node.MaxShards),
+			MemoryBytes: a.node.MemoryBytes,
+			DiskBytes:   a.node.DiskBytes,
+		},
+		Shards: shardsToProto(a.shards()),
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	interval := time.Duration(response.HeartbeatInterval) * time.Second
+	if interval <= 0 {
+		interval = registerRetry
+	}
+	return interval, nil
+}
+
+// heartbeat, reporting whether the node is still registered
+func (a *Agent) heartbeat(ctx context.Context) bool {
+	response, err := a.client.Heartbeat(ctx, &proto.HeartbeatRequest{
+		NodeId: a.node.ID,
+		Shards: shardsToProto(a.shards()),
+	})
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Warn("node heartbeat failed", "node_id", a.node.ID, "err", err)
+		}
+		return true
+	}
+	return response.Registered
+}
+
+// deregister with a short deadline of its own, ctx is already done
+func (a *Agent) deregister(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+
+	if _, err := a.client.Deregister(ctx, &proto.DeregisterRequest{NodeId: a.node.ID}); err != nil {
+		log.Warn("node deregistration failed", "node_id", a.node.ID, "err", err)
+	}
+}
@@ -0,0 +1,259 @@
+package nodes
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"gorm.io/gorm"
+
+	"github.com/christian-nickerson/pangolin/control/internal/configs"
+	"github.com/christian-nickerson/pangolin/control/internal/consensus"
+	"github.com/christian-nickerson/pangolin/control/internal/database"
+	"github.com/christian-nickerson/pangolin/control/internal/models"
+)
+
+var ErrNodeNotFound = errors.New("node not found")
+
+// Registry tracks index node membership in the metadata database. Nodes
+// are alive while they heartbeat, suspect once they miss beats for the
+// suspect period and dead after the dead period, after which they must
+// register again.
+type Registry struct {
+	config configs.Cluster
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu   sync.Mutex
+	lost []func(node models.Node)
+}
+
+// NewRegistry creates a registry timing heartbeats as configured
+func NewRegistry(config configs.Cluster) *Registry {
+	return &Registry{config: config}
+}
+
+// OnLost calls fn whenever a node is declared dead or leaves the cluster
+func (r *Registry) OnLost(fn func(node models.Node)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lost = append(r.lost, fn)
+}
+
+// notify lost node watchers
+func (r *Registry) notifyLost(node models.Node) {
+	r.mu.Lock()
+	watchers := r.lost
+	r.mu.Unlock()
+
+	for _, fn := range watchers {
+		fn(node)
+	}
+}
+
+// HeartbeatInterval is the period nodes are asked to heartbeat at
+func (r *Registry) HeartbeatInterval() time.Duration {
+	return time.Duration(r.config.HeartbeatInterval) * time.Second
+}
+
+// Start restarts the heartbeat clock of live nodes, which could not
+// reach a stopped control plane, and starts sweeping for missed beats
+func (r *Registry) Start(ctx context.Context) error {
+	var resumed int64
+	err := database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Node{}).
+			Where("status IN ?", []models.NodeStatus{models.NodeAlive, models.NodeSuspect}).
+			Update("last_heartbeat", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+		resumed = result.RowsAffected
+		return consensus.PublishTable(tx, &models.Node{})
+	})
+	if err != nil {
+		return err
+	}
+	if resumed > 0 {
+		log.Info("awaiting heartbeats from registered nodes", "nodes", resumed)
+	}
+
+	sweeper, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+	r.wg.Add(1)
+	go r.sweepLoop(sweeper)
+	return nil
+}
+
+// Stop stops sweeping and waits for the sweeper to exit
+func (r *Registry) Stop(ctx context.Context) error {
+	if r.cancel == nil {
+		return nil
+	}
+	r.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// sweep each heartbeat interval until cancelled
+func (r *Registry) sweepLoop(ctx context.Context) {
+	defer r.wg.Done()
+	ticker := time.NewTicker(r.HeartbeatInterval())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if err := r.Sweep(ctx, now); err != nil && ctx.Err() == nil {
+				log.Error("node sweep failed", "err", err)
+			}
+		}
+	}
+}
+
+// Register records a node as alive with its address, capacity and
+// shards, replacing any previous registration of the same id
+func (r *Registry) Register(ctx context.Context, node *models.Node) error {
+	now := time.Now()
+	node.Status = models.NodeAlive
+	node.RegisteredAt, node.LastHeartbeat = now, now
+	err := database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(node).Error; err != nil {
+			return err
+		}
+		return consensus.Publish(tx, node)
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Info("node registered", "node_id", node.ID, "address", node.Address, "shards", len(node.Shards))
+	return nil
+}
+
+// Heartbeat marks a node alive with the shards it now hosts. Nodes that
+// are unknown, dead or have left are not found and must register again.
+func (r *Registry) Heartbeat(ctx context.Context, id string, shards []models.HostedShard) error {
+	if shards == nil {
+		shards = []models.HostedShard{}
+	}
+
+	return database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Node{}).
+			Where("id = ? AND status IN ?", id, []models.NodeStatus{models.NodeAlive, models.NodeSuspect}).
+			Select("status", "shards", "last_heartbeat").
+			Updates(&models.Node{Status: models.NodeAlive, Shards: shards, LastHeartbeat: time.Now()})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrNodeNotFound
+		}
+		return consensus.Publish(tx, &models.Node{ID: id})
+	})
+}
+
+// Deregister marks a node as having left the cluster
+func (r *Registry) Deregister(ctx context.Context, id string) error {
+	err := database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Node{}).
+			Where("id = ?", id).
+			Update("status", models.NodeLeft)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrNodeNotFound
+		}
+		return consensus.Publish(tx, &models.Node{ID: id})
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Info("node deregistered", "node_id", id)
+	r.notifyLost(models.Node{ID: id, Status: models.NodeLeft})
+	return nil
+}
+
+// Sweep marks nodes suspect or dead by the time since their last
+// heartbeat at now
+func (r *Registry) Sweep(ctx context.Context, now time.Time) error {
+	suspectAt := now.Add(-time.Duration(r.config.SuspectAfter) * time.Second)
+	deadAt := now.Add(-time.Duration(r.config.DeadAfter) * time.Second)
+
+	var late []models.Node
+	err := database.DB.WithContext(ctx).
+		Where("status IN ? AND last_heartbeat < ?", []models.NodeStatus{models.NodeAlive, models.NodeSuspect}, suspectAt).
+		Find(&late).Error
+	if err != nil {
+		return err
+	}
+
+	for _, node := range late {
+		status, cutoff := models.NodeSuspect, suspectAt
+		if node.LastHeartbeat.Before(deadAt) {
+			status, cutoff = models.NodeDead, deadAt
+		}
+		if status == node.Status {
+			continue
+		}
+
+		// a heartbeat since the read keeps the node alive
+		var marked bool
+		err := database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			result := tx.Model(&models.Node{}).
+				Where("id = ? AND status = ? AND last_heartbeat < ?", node.ID, node.Status, cutoff).
+				Update("status", status)
+			if result.Error != nil || result.RowsAffected == 0 {
+				return result.Error
+			}
+			marked = true
+			return consensus.Publish(tx, &node)
+		})
+		if err != nil {
+			return err
+		}
+		if !marked {
+			continue
+		}
+
+		log.Warn("node missed heartbeats", "node_id", node.ID, "status", status, "last_heartbeat", node.LastHeartbeat)
+		if status == models.NodeDead {
+			node.Status = status
+			r.notifyLost(node)
+		}
+	}
+	return nil
+}
+
+// List returns all registered nodes, including those dead or left
+func (r *Registry) List(ctx context.Context) ([]models.Node, error) {
+	var nodes []models.Node
+	err := database.DB.WithContext(ctx).Order("id").Find(&nodes).Error
+	return nodes, err
+}
+
+// Get returns a registered node by id
+func (r *Registry) Get(ctx context.Context, id string) (*models.Node, error) {
+	var node models.Node
+	err := database.DB.WithContext(ctx).Where("id = ?", id).First(&node).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNodeNotFound
+	}
+	return &node, err
+}
@@ -0,0 +1,143 @@
+package nodes
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/christian-nickerson/pangolin/control/internal/configs"
+	"github.com/christian-nickerson/pangolin/control/internal/database"
+	"github.com/christian-nickerson/pangolin/control/internal/models"
+	"github.com/christian-nickerson/pangolin/control/internal/proto"
+)
+
+type RegistrySuite struct {
+	suite.Suite
+	registry *Registry
+	client   proto.RegistryClient
+}
+
+// serve a registry requiring a token on a local port
+func (s *RegistrySuite) SetupTest() {
+	config := configs.DatabaseConfig{Type: "sqlite", DBName: filepath.Join(s.T().TempDir(), "test")}
+	s.Require().NoError(database.Connect(config))
+	s.Require().NoError(database.Migrate())
+
+	cluster := configs.Cluster{Host: "127.0.0.1", Token: "secret", HeartbeatInterval: 1, SuspectAfter: 3, DeadAfter: 10}
+	s.registry = NewRegistry(cluster)
+	server, listener, err := Serve(s.registry, cluster)
+	s.Require().NoError(err)
+	s.T().Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient(listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	s.Require().NoError(err)
+	s.T().Cleanup(func() { conn.Close() })
+	s.client = proto.NewRegistryClient(conn)
+}
+
+// context presenting the cluster token
+func (s *RegistrySuite) authorized() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	return metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer secret"), cancel
+}
+
+// run a fake node hosting one shard, returning a function stopping it
+func (s *RegistrySuite) startNode(id string) func() {
+	ctx, cancel := s.authorized()
+	node := models.Node{ID: id, Address: id + ":50060", MaxShards: 4}
+	shards := func() []models.HostedShard {
+		return []models.HostedShard{{CollectionID: 1, Shard: 0, Vectors: 10}}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		NewAgent(s.client, node, shards).Run(ctx)
+		close(done)
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// wait for a node to reach status
+func (s *RegistrySuite) eventually(id string, expected models.NodeStatus) {
+	s.Eventually(func() bool {
+		node, err := s.registry.Get(context.Background(), id)
+		return err == nil && node.Status == expected
+	}, 5*time.Second, 50*time.Millisecond, "node %v not %v", id, expected)
+}
+
+// Test fake nodes register with their shards and leave on shutdown
+func (s *RegistrySuite) TestAgents() {
+	stops := make([]func(), 3)
+	for i := range stops {
+		stops[i] = s.startNode(fmt.Sprintf("node-%v", i))
+	}
+	for i := range stops {
+		s.eventually(fmt.Sprintf("node-%v", i), models.NodeAlive)
+	}
+
+	all, err := s.registry.List(context.Background())
+	s.Require().NoError(err)
+	s.Require().Len(all, 3)
+	s.Equal("node-0:50060", all[0].Address)
+	s.Equal(4, all[0].MaxShards)
+	s.Equal([]models.HostedShard{{CollectionID: 1, Shard: 0, Vectors: 10}}, all[0].Shards)
+
+	stops[0]()
+	s.eventually("node-0", models.NodeLeft)
+	for _, stop := range stops[1:] {
+		stop()
+	}
+}
+
+// Test nodes missing heartbeats turn suspect then dead, and must register again
+func (s *RegistrySuite) TestSweep() {
+	ctx := context.Background()
+	var lost []string
+	s.registry.OnLost(func(node models.Node) { lost = append(lost, node.ID) })
+	s.Require().NoError(s.registry.Register(ctx, &models.Node{ID: "a", Address: "a:50060"}))
+
+	s.Require().NoError(s.registry.Sweep(ctx, time.Now().Add(4*time.Second)))
+	s.eventually("a", models.NodeSuspect)
+	s.Require().NoError(s.registry.Heartbeat(ctx, "a", nil))
+	s.eventually("a", models.NodeAlive)
+
+	s.Require().NoError(s.registry.Sweep(ctx, time.Now().Add(11*time.Second)))
+	s.eventually("a", models.NodeDead)
+	s.Equal([]string{"a"}, lost)
+	s.ErrorIs(s.registry.Heartbeat(ctx, "a", nil), ErrNodeNotFound)
+
+	// an agent told it is unregistered registers again
+	stop := s.startNode("a")
+	defer stop()
+	s.eventually("a", models.NodeAlive)
+}
+
+// Test the registry rejects nodes without the cluster token
+func (s *RegistrySuite) TestToken() {
+	_, err := s.client.Register(context.Background(), &proto.RegisterRequest{NodeId: "a", Address: "a:50060"})
+	s.Equal(codes.Unauthenticated, status.Code(err))
+
+	ctx, cancel := s.authorized()
+	defer cancel()
+	_, err = s.client.Register(ctx, &proto.RegisterRequest{NodeId: "a"})
+	s.Equal(codes.InvalidArgument, status.Code(err))
+	response, err := s.client.Heartbeat(ctx, &proto.HeartbeatRequest{NodeId: "unknown"})
+	s.Require().NoError(err)
+	s.False(response.Registered)
+}
+
+func TestRegistrySuite(t *testing.T) {
+	suite.Run(t, new(RegistrySuite))
+}
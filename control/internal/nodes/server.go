@@ -0,0 +1,175 @@
+package nodes
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net"
+	"strconv"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/christian-nickerson/pangolin/control/internal/certs"
+	"github.com/christian-nickerson/pangolin/control/internal/configs"
+	"github.com/christian-nickerson/pangolin/control/internal/consensus"
+	"github.com/christian-nickerson/pangolin/control/internal/models"
+	"github.com/christian-nickerson/pangolin/control/internal/proto"
+	"github.com/christian-nickerson/pangolin/control/internal/tracing"
+)
+
+// server adapts the registry to the gRPC Registry service
+type server struct {
+	proto.UnimplementedRegistryServer
+	registry *Registry
+}
+
+// Serve starts the registry gRPC server on the configured address,
+// securing it with TLS and the shared token when configured
+func Serve(registry *Registry, config configs.Cluster) (*grpc.Server, net.Listener, error) {
+	options, err := ServerOptions(config)
+	if err != nil {
+		return nil, nil, err
+	}
+	options = append(options, grpc.ChainUnaryInterceptor(leaderInterceptor))
+
+	listener, err := net.Listen("tcp", net.JoinHostPort(config.Host, strconv.Itoa(config.Port)))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	grpcServer := grpc.NewServer(options...)
+	proto.RegisterRegistryServer(grpcServer, &server{registry: registry})
+	go grpcServer.Serve(listener)
+	return grpcServer, listener, nil
+}
+
+// ServerOptions secures a cluster gRPC server with TLS and the shared token
+// when configured, and traces its calls
+func ServerOptions(config configs.Cluster) ([]grpc.ServerOption, error) {
+	options := []grpc.ServerOption{tracing.GRPCServerOption()}
+	if config.Token != "" {
+		options = append(options,
+			grpc.ChainUnaryInterceptor(tokenInterceptor(config.Token)),
+			grpc.ChainStreamInterceptor(streamTokenInterceptor(config.Token)),
+		)
+	}
+	if config.TLS.Enabled {
+		reloader, err := certs.NewReloader(config.TLS.CertFile, config.TLS.KeyFile, config.TLS.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, grpc.Creds(credentials.NewTLS(reloader.ServerConfig())))
+	}
+	return options, nil
+}
+
+// reject RPCs without the shared token as bearer authorization
+func tokenInterceptor(token string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if err := checkToken(ctx, token); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// reject streams without the shared token as bearer authorization
+func streamTokenInterceptor(token string) grpc.StreamServerInterceptor {
+	return func(srv any, stream grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := checkToken(stream.Context(), token); err != nil {
+			return err
+		}
+		return handler(srv, stream)
+	}
+}
+
+func checkToken(ctx context.Context, token string) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get("authorization")
+	if len(values) == 0 || subtle.ConstantTimeCompare([]byte(values[0]), []byte("Bearer "+token)) != 1 {
+		return status.Error(codes.Unauthenticated, "invalid cluster token")
+	}
+	return nil
+}
+
+// reject RPCs on followers, only the leader's registry tracks membership
+func leaderInterceptor(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if !consensus.Leading() {
+		return nil, status.Error(codes.Unavailable, consensus.ErrNotLeader.Error())
+	}
+	return handler(ctx, req)
+}
+
+func (s *server) Register(ctx context.Context, request *proto.RegisterRequest) (*proto.RegisterResponse, error) {
+	if request.NodeId == "" || request.Address == "" {
+		return nil, status.Error(codes.InvalidArgument, "node id and address are required")
+	}
+
+	node := &models.Node{
+		ID:      request.NodeId,
+		Address: request.Address,
+		Shards:  shardsFromProto(request.Shards),
+	}
+	if capacity := request.Capacity; capacity != nil {
+		node.MaxShards = int(capacity.MaxShards)
+		node.MemoryBytes = capacity.MemoryBytes
+		node.DiskBytes = capacity.DiskBytes
+	}
+
+	if err := s.registry.Register(ctx, node); err != nil {
+		return nil, err
+	}
+	return &proto.RegisterResponse{HeartbeatInterval: uint32(s.registry.config.HeartbeatInterval)}, nil
+}
+
+func (s *server) Heartbeat(ctx context.Context, request *proto.HeartbeatRequest) (*proto.HeartbeatResponse, error) {
+	err := s.registry.Heartbeat(ctx, request.NodeId, shardsFromProto(request.Shards))
+	if errors.Is(err, ErrNodeNotFound) {
+		return &proto.HeartbeatResponse{Registered: false}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &proto.HeartbeatResponse{Registered: true}, nil
+}
+
+func (s *server) Deregister(ctx context.Context, request *proto.DeregisterRequest) (*proto.DeregisterResponse, error) {
+	err := s.registry.Deregister(ctx, request.NodeId)
+	if errors.Is(err, ErrNodeNotFound) {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &proto.DeregisterResponse{}, nil
+}
+
+// convert reported shards to the model
+func shardsFromProto(shards []*proto.ShardInfo) []models.HostedShard {
+	hosted := make([]models.HostedShard, len(shards))
+	for i, shard := range shards {
+		hosted[i] = models.HostedShard{
+			CollectionID: uint(shard.CollectionId),
+			Shard:        int(shard.Shard),
+			Vectors:      shard.Vectors,
+		}
+	}
+	return hosted
+}
+
+// convert hosted shards to their wire form
+func shardsToProto(shards []models.HostedShard) []*proto.ShardInfo {
+	infos := make([]*proto.ShardInfo, len(shards))
+	for i, shard := range shards {
+		infos[i] = &proto.ShardInfo{
+			CollectionId: uint64(shard.CollectionID),
+			Shard:        uint32(shard.Shard),
+			Vectors:      shard.Vectors,
+		}
+	}
+	return infos
+}
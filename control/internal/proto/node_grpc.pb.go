@@ -0,0 +1,408 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             v3.12.4
+// source: node.proto
+
+package proto
+
+import (
+	context "context"
+
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	IndexNode_CreateShard_FullMethodName   = "/NodeService.IndexNode/CreateShard"
+	IndexNode_DropShard_FullMethodName     = "/NodeService.IndexNode/DropShard"
+	IndexNode_Upsert_FullMethodName        = "/NodeService.IndexNode/Upsert"
+	IndexNode_Delete_FullMethodName        = "/NodeService.IndexNode/Delete"
+	IndexNode_Get_FullMethodName           = "/NodeService.IndexNode/Get"
+	IndexNode_Search_FullMethodName        = "/NodeService.IndexNode/Search"
+	IndexNode_Stats_FullMethodName         = "/NodeService.IndexNode/Stats"
+	IndexNode_StreamSegment_FullMethodName = "/NodeService.IndexNode/StreamSegment"
+)
+
+// IndexNodeClient is the client API for IndexNode service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type IndexNodeClient interface {
+	// Create an empty shard, keeping the shard if it already exists
+	CreateShard(ctx context.Context, in *CreateShardRequest, opts ...grpc.CallOption) (*CreateShardResponse, error)
+	// Drop a shard, dropping a missing shard is not an error
+	DropShard(ctx context.Context, in *DropShardRequest, opts ...grpc.CallOption) (*DropShardResponse, error)
+	// Upsert vectors into a shard
+	Upsert(ctx context.Context, in *UpsertRequest, opts ...grpc.CallOption) (*UpsertResponse, error)
+	// Delete vectors from a shard by id
+	Delete(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*DeleteResponse, error)
+	// Get vectors from a shard by id, omitting missing ids
+	Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*GetResponse, error)
+	// Search a shard for the nearest vectors to a query
+	Search(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*SearchResponse, error)
+	// Stats of the shards a node hosts
+	Stats(ctx context.Context, in *StatsRequest, opts ...grpc.CallOption) (*StatsResponse, error)
+	// Stream a shard's vectors in chunks, for copying it to another node
+	StreamSegment(ctx context.Context, in *StreamSegmentRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[SegmentChunk], error)
+}
+
+type indexNodeClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewIndexNodeClient(cc grpc.ClientConnInterface) IndexNodeClient {
+	return &indexNodeClient{cc}
+}
+
+func (c *indexNodeClient) CreateShard(ctx context.Context, in *CreateShardRequest, opts ...grpc.CallOption) (*CreateShardResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CreateShardResponse)
+	err := c.cc.Invoke(ctx, IndexNode_CreateShard_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *indexNodeClient) DropShard(ctx context.Context, in *DropShardRequest, opts ...grpc.CallOption) (*DropShardResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DropShardResponse)
+	err := c.cc.Invoke(ctx, IndexNode_DropShard_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *indexNodeClient) Upsert(ctx context.Context, in *UpsertRequest, opts ...grpc.CallOption) (*UpsertResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(UpsertResponse)
+	err := c.cc.Invoke(ctx, IndexNode_Upsert_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *indexNodeClient) Delete(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*DeleteResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeleteResponse)
+	err := c.cc.Invoke(ctx, IndexNode_Delete_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *indexNodeClient) Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*GetResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetResponse)
+	err := c.cc.Invoke(ctx, IndexNode_Get_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *indexNodeClient) Search(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*SearchResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SearchResponse)
+	err := c.cc.Invoke(ctx, IndexNode_Search_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *indexNodeClient) Stats(ctx context.Context, in *StatsRequest, opts ...grpc.CallOption) (*StatsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(StatsResponse)
+	err := c.cc.Invoke(ctx, IndexNode_Stats_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *indexNodeClient) StreamSegment(ctx context.Context, in *StreamSegmentRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[SegmentChunk], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &IndexNode_ServiceDesc.Streams[0], IndexNode_StreamSegment_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[StreamSegmentRequest, SegmentChunk]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type IndexNode_StreamSegmentClient = grpc.ServerStreamingClient[SegmentChunk]
+
+// IndexNodeServer is the server API for IndexNode service.
+// All implementations must embed UnimplementedIndexNodeServer
+// for forward compatibility.
+type IndexNodeServer interface {
+	// Create an empty shard, keeping the shard if it already exists
+	CreateShard(context.Context, *CreateShardRequest) (*CreateShardResponse, error)
+	// Drop a shard, dropping a missing shard is not an error
+	DropShard(context.Context, *DropShardRequest) (*DropShardResponse, error)
+	// Upsert vectors into a shard
+	Upsert(context.Context, *UpsertRequest) (*UpsertResponse, error)
+	// Delete vectors from a shard by id
+	Delete(context.Context, *DeleteRequest) (*DeleteResponse, error)
+	// Get vectors from a shard by id, omitting missing ids
+	Get(context.Context, *GetRequest) (*GetResponse, error)
+	// Search a shard for the nearest vectors to a query
+	Search(context.Context, *SearchRequest) (*SearchResponse, error)
+	// Stats of the shards a node hosts
+	Stats(context.Context, *StatsRequest) (*StatsResponse, error)
+	// Stream a shard's vectors in chunks, for copying it to another node
+	StreamSegment(*StreamSegmentRequest, grpc.ServerStreamingServer[SegmentChunk]) error
+	mustEmbedUnimplementedIndexNodeServer()
+}
+
+// UnimplementedIndexNodeServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedIndexNodeServer struct{}
+
+func (UnimplementedIndexNodeServer) CreateShard(context.Context, *CreateShardRequest) (*CreateShardResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateShard not implemented")
+}
+func (UnimplementedIndexNodeServer) DropShard(context.Context, *DropShardRequest) (*DropShardResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DropShard not implemented")
+}
+func (UnimplementedIndexNodeServer) Upsert(context.Context, *UpsertRequest) (*UpsertResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Upsert not implemented")
+}
+func (UnimplementedIndexNodeServer) Delete(context.Context, *DeleteRequest) (*DeleteResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Delete not implemented")
+}
+func (UnimplementedIndexNodeServer) Get(context.Context, *GetRequest) (*GetResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Get not implemented")
+}
+func (UnimplementedIndexNodeServer) Search(context.Context, *SearchRequest) (*SearchResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Search not implemented")
+}
+func (UnimplementedIndexNodeServer) Stats(context.Context, *StatsRequest) (*StatsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Stats not implemented")
+}
+func (UnimplementedIndexNodeServer) StreamSegment(*StreamSegmentRequest, grpc.ServerStreamingServer[SegmentChunk]) error {
+	return status.Errorf(codes.Unimplemented, "method StreamSegment not implemented")
+}
+func (UnimplementedIndexNodeServer) mustEmbedUnimplementedIndexNodeServer() {}
+func (UnimplementedIndexNodeServer) testEmbeddedByValue()                   {}
+
+// UnsafeIndexNodeServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to IndexNodeServer will
+// result in compilation errors.
+type UnsafeIndexNodeServer interface {
+	mustEmbedUnimplementedIndexNodeServer()
+}
+
+func RegisterIndexNodeServer(s grpc.ServiceRegistrar, srv IndexNodeServer) {
+	// If the following call pancis, it indicates UnimplementedIndexNodeServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&IndexNode_ServiceDesc, srv)
+}
+
+func _IndexNode_CreateShard_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateShardRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IndexNodeServer).CreateShard(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: IndexNode_CreateShard_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IndexNodeServer).CreateShard(ctx, req.(*CreateShardRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _IndexNode_DropShard_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DropShardRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IndexNodeServer).DropShard(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: IndexNode_DropShard_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IndexNodeServer).DropShard(ctx, req.(*DropShardRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _IndexNode_Upsert_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpsertRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IndexNodeServer).Upsert(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: IndexNode_Upsert_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IndexNodeServer).Upsert(ctx, req.(*UpsertRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _IndexNode_Delete_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IndexNodeServer).Delete(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: IndexNode_Delete_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IndexNodeServer).Delete(ctx, req.(*DeleteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _IndexNode_Get_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IndexNodeServer).Get(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: IndexNode_Get_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IndexNodeServer).Get(ctx, req.(*GetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _IndexNode_Search_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SearchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IndexNodeServer).Search(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: IndexNode_Search_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IndexNodeServer).Search(ctx, req.(*SearchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _IndexNode_Stats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StatsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IndexNodeServer).Stats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: IndexNode_Stats_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IndexNodeServer).Stats(ctx, req.(*StatsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _IndexNode_StreamSegment_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamSegmentRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(IndexNodeServer).StreamSegment(m, &grpc.GenericServerStream[StreamSegmentRequest, SegmentChunk]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type IndexNode_StreamSegmentServer = grpc.ServerStreamingServer[SegmentChunk]
+
+// IndexNode_ServiceDesc is the grpc.ServiceDesc for IndexNode service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var IndexNode_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "NodeService.IndexNode",
+	HandlerType: (*IndexNodeServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "CreateShard",
+			Handler:    _IndexNode_CreateShard_Handler,
+		},
+		{
+			MethodName: "DropShard",
+			Handler:    _IndexNode_DropShard_Handler,
+		},
+		{
+			MethodName: "Upsert",
+			Handler:    _IndexNode_Upsert_Handler,
+		},
+		{
+			MethodName: "Delete",
+			Handler:    _IndexNode_Delete_Handler,
+		},
+		{
+			MethodName: "Get",
+			Handler:    _IndexNode_Get_Handler,
+		},
+		{
+			MethodName: "Search",
+			Handler:    _IndexNode_Search_Handler,
+		},
+		{
+			MethodName: "Stats",
+			Handler:    _IndexNode_Stats_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamSegment",
+			Handler:       _IndexNode_StreamSegment_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "node.proto",
+}
@@ -0,0 +1,102 @@
+package ratelimit
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/christian-nickerson/pangolin/control/internal/auth"
+	"github.com/christian-nickerson/pangolin/control/internal/configs"
+	"github.com/christian-nickerson/pangolin/control/internal/logging"
+)
+
+// New limits requests per API key, or per client IP when the request has
+// no stored key, with separate budgets for read and embedding heavy write
+// routes. Must be registered after the auth middleware.
+func New(config configs.RateLimit, store Store) fiber.Handler {
+	read := Limit{Rate: config.Read.Rate, Burst: config.Read.Burst}
+	write := Limit{Rate: config.Write.Rate, Burst: config.Write.Burst}
+
+	return func(c *fiber.Ctx) error {
+		if !config.Enabled {
+			return c.Next()
+		}
+
+		class, limit := "read", read
+		switch c.Method() {
+		case fiber.MethodGet, fiber.MethodHead, fiber.MethodOptions:
+		default:
+			class, limit = "write", write
+		}
+
+		result, err := store.Take(c.UserContext(), class+":"+identity(c), limit, time.Now())
+		if err != nil {
+			// fail open, a broken store should not take the API down
+			logging.Ctx(c).Error("rate limit store failed", "err", err)
+			return c.Next()
+		}
+
+		c.Set("RateLimit-Limit", strconv.Itoa(limit.Burst))
+		c.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		c.Set("RateLimit-Reset", ceilSeconds(result.Reset))
+		c.Set("RateLimit-Policy", fmt.Sprintf("%v;w=%v", limit.Burst, ceilSeconds(seconds(float64(limit.Burst)/limit.Rate))))
+
+		if !result.Allowed {
+			c.Set(fiber.HeaderRetryAfter, ceilSeconds(result.RetryAfter))
+			return fiber.NewError(fiber.StatusTooManyRequests, class+" rate limit exceeded")
+		}
+
+		return c.Next()
+	}
+}
+
+// Failures limits failed authentications per client IP, so api keys cannot
+// be guessed at the rate of other requests. Requests from an IP are refused
+// while its budget is spent, and each failure is charged even when
+// concurrent failures overdraw the budget. Must be registered before the
+// auth middleware.
+func Failures(config configs.RateLimit, store Store) fiber.Handler {
+	limit := Limit{Rate: config.Failures.Rate, Burst: config.Failures.Burst}
+
+	return func(c *fiber.Ctx) error {
+		if !config.Enabled {
+			return c.Next()
+		}
+
+		key := "failures:ip:" + c.IP()
+		result, err := store.Peek(c.UserContext(), key, limit, time.Now())
+		if err != nil {
+			logging.Ctx(c).Error("rate limit store failed", "err", err)
+			return c.Next()
+		}
+		if !result.Allowed {
+			c.Set(fiber.HeaderRetryAfter, ceilSeconds(result.RetryAfter))
+			return fiber.NewError(fiber.StatusTooManyRequests, "too many failed authentications")
+		}
+
+		err = c.Next()
+		var failure *fiber.Error
+		if errors.As(err, &failure) && failure.Code == fiber.StatusUnauthorized {
+			if _, chargeErr := store.Charge(c.UserContext(), key, limit, time.Now()); chargeErr != nil {
+				logging.Ctx(c).Error("rate limit store failed", "err", chargeErr)
+			}
+		}
+		return err
+	}
+}
+
+// identify the caller by stored key, falling back to client IP
+func identity(c *fiber.Ctx) string {
+	if key := auth.Key(c); key != nil && key.ID != 0 {
+		return "key:" + strconv.FormatUint(uint64(key.ID), 10)
+	}
+	return "ip:" + c.IP()
+}
+
+func ceilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
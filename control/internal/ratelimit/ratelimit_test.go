@@ -0,0 +1,147 @@
+package ratelimit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/christian-nickerson/pangolin/control/internal/configs"
+)
+
+// assert buckets refill over time and report retry delays
+func TestMemoryStore(t *testing.T) {
+	store := NewMemoryStore()
+	limit := Limit{Rate: 1, Burst: 2}
+	now := time.Now()
+	ctx := context.Background()
+
+	first, _ := store.Take(ctx, "a", limit, now)
+	second, _ := store.Take(ctx, "a", limit, now)
+	denied, _ := store.Take(ctx, "a", limit, now)
+	assert.True(t, first.Allowed)
+	assert.True(t, second.Allowed)
+	assert.False(t, denied.Allowed)
+	assert.Equal(t, time.Second, denied.RetryAfter)
+
+	// other keys have their own bucket
+	other, _ := store.Take(ctx, "b", limit, now)
+	assert.True(t, other.Allowed)
+
+	refilled, _ := store.Take(ctx, "a", limit, now.Add(time.Second))
+	assert.True(t, refilled.Allowed)
+}
+
+// assert sweeps keep buckets still refilling under their own limit
+func TestMemoryStoreSweep(t *testing.T) {
+	store := NewMemoryStore()
+	read := Limit{Rate: 50, Burst: 100}
+	write := Limit{Rate: 5, Burst: 20}
+	now := time.Now()
+	ctx := context.Background()
+
+	for range write.Burst {
+		store.Take(ctx, "write", write, now)
+	}
+
+	// a read sweep after the read refill horizon but before the write one
+	later := now.Add(3 * time.Second)
+	for range sweepInterval - write.Burst - 1 {
+		store.Take(ctx, "read", read, later)
+	}
+	store.Take(ctx, "read", read, later)
+
+	result, _ := store.Take(ctx, "write", write, later)
+	assert.Equal(t, 14, result.Remaining, "write bucket must not come back full")
+
+	// buckets that refilled are dropped
+	store.takes = sweepInterval - 1
+	store.Take(ctx, "read", read, later.Add(time.Minute))
+	assert.Len(t, store.buckets, 1)
+}
+
+// assert read and write routes have separate budgets and denied
+// requests carry rate limit headers
+func TestMiddleware(t *testing.T) {
+	config := configs.RateLimit{
+		Enabled: true,
+		Read:    configs.Bucket{Rate: 0.1, Burst: 2},
+		Write:   configs.Bucket{Rate: 0.1, Burst: 1},
+	}
+	app := fiber.New()
+	app.Use(New(config, NewMemoryStore()))
+	app.Get("/", func(c *fiber.Ctx) error { return c.SendStatus(fiber.StatusOK) })
+	app.Post("/", func(c *fiber.Ctx) error { return c.SendStatus(fiber.StatusOK) })
+
+	write, _ := app.Test(httptest.NewRequest("POST", "/", nil))
+	assert.Equal(t, 200, write.StatusCode)
+	assert.Equal(t, "1", write.Header.Get("RateLimit-Limit"))
+	assert.Equal(t, "0", write.Header.Get("RateLimit-Remaining"))
+
+	write, _ = app.Test(httptest.NewRequest("POST", "/", nil))
+	assert.Equal(t, 429, write.StatusCode)
+	assert.Equal(t, "10", write.Header.Get("Retry-After"))
+
+	// reads are unaffected by the exhausted write budget
+	read, _ := app.Test(httptest.NewRequest("GET", "/", nil))
+	assert.Equal(t, 200, read.StatusCode)
+	assert.Equal(t, "1", read.Header.Get("RateLimit-Remaining"))
+}
+
+// assert peeking takes no token and charges overdraw the bucket
+func TestMemoryStoreCharge(t *testing.T) {
+	store := NewMemoryStore()
+	limit := Limit{Rate: 1, Burst: 1}
+	now := time.Now()
+	ctx := context.Background()
+
+	peeked, _ := store.Peek(ctx, "a", limit, now)
+	assert.True(t, peeked.Allowed)
+	charged, _ := store.Charge(ctx, "a", limit, now)
+	assert.True(t, charged.Allowed)
+	overdrawn, _ := store.Charge(ctx, "a", limit, now)
+	assert.False(t, overdrawn.Allowed)
+
+	// the debt refills before a token is available again
+	peeked, _ = store.Peek(ctx, "a", limit, now.Add(time.Second))
+	assert.False(t, peeked.Allowed)
+	assert.Equal(t, time.Second, peeked.RetryAfter)
+	peeked, _ = store.Peek(ctx, "a", limit, now.Add(2*time.Second))
+	assert.True(t, peeked.Allowed)
+}
+
+// assert failed authentications are limited per IP before keys are
+// checked, and authenticated requests are not charged
+func TestFailures(t *testing.T) {
+	config := configs.RateLimit{Enabled: true, Failures: configs.Bucket{Rate: 0.1, Burst: 2}}
+	app := fiber.New()
+	app.Use(Failures(config, NewMemoryStore()))
+	app.Get("/", func(c *fiber.Ctx) error {
+		if c.Get(fiber.HeaderAuthorization) != "Bearer valid" {
+			return fiber.NewError(fiber.StatusUnauthorized, "invalid api key")
+		}
+		return c.SendStatus(fiber.StatusOK)
+	})
+	request := func(token string) *http.Response {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set(fiber.HeaderAuthorization, "Bearer "+token)
+		response, err := app.Test(req)
+		assert.NoError(t, err)
+		return response
+	}
+
+	for range 3 {
+		assert.Equal(t, 200, request("valid").StatusCode)
+	}
+	assert.Equal(t, 401, request("guess").StatusCode)
+	assert.Equal(t, 401, request("guess").StatusCode)
+
+	// once the budget is spent even a valid key is refused
+	denied := request("valid")
+	assert.Equal(t, 429, denied.StatusCode)
+	assert.Equal(t, "10", denied.Header.Get("Retry-After"))
+}
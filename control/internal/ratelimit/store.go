@@ -0,0 +1,132 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// Limit is a token bucket refilling at Rate tokens per second up to Burst,
+// Rate must be positive
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Result is the outcome of taking a token from a bucket
+type Result struct {
+	Allowed    bool
+	Remaining  int
+	Reset      time.Duration // until the bucket is full again
+	RetryAfter time.Duration // until a token is available, when denied
+}
+
+// Store holds token buckets. MemoryStore keeps state for a single node,
+// implementations backed by shared storage can limit across nodes.
+type Store interface {
+	Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error)
+	// Peek reports whether a token is available without taking it
+	Peek(ctx context.Context, key string, limit Limit, now time.Time) (Result, error)
+	// Charge takes a token even when none is available, leaving the
+	// bucket in debt until it refills
+	Charge(ctx context.Context, key string, limit Limit, now time.Time) (Result, error)
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	full    time.Time // when the bucket refills completely under its limit
+}
+
+// MemoryStore is an in-process Store
+type MemoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	takes   int
+}
+
+// sweep idle buckets every so many takes to bound memory
+const sweepInterval = 4096
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: make(map[string]*bucket)}
+}
+
+// Take refills the bucket for the time elapsed and takes a token if one is available
+func (s *MemoryStore) Take(_ context.Context, key string, limit Limit, now time.Time) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.refill(key, limit, now)
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+	return b.result(limit, now, allowed), nil
+}
+
+// Peek refills the bucket for the time elapsed and reports whether a token is available
+func (s *MemoryStore) Peek(_ context.Context, key string, limit Limit, now time.Time) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.refill(key, limit, now)
+	return b.result(limit, now, b.tokens >= 1), nil
+}
+
+// Charge refills the bucket for the time elapsed and takes a token, which
+// may leave the bucket below empty
+func (s *MemoryStore) Charge(_ context.Context, key string, limit Limit, now time.Time) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.refill(key, limit, now)
+	allowed := b.tokens >= 1
+	b.tokens--
+	return b.result(limit, now, allowed), nil
+}
+
+// the bucket for key refilled for the time elapsed, sweeping idle buckets
+// every so often
+func (s *MemoryStore) refill(key string, limit Limit, now time.Time) *bucket {
+	s.takes++
+	if s.takes%sweepInterval == 0 {
+		s.sweep(now)
+	}
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Burst), updated: now}
+		s.buckets[key] = b
+	}
+
+	elapsed := now.Sub(b.updated).Seconds()
+	b.tokens = math.Min(float64(limit.Burst), b.tokens+elapsed*limit.Rate)
+	b.updated = now
+	return b
+}
+
+// the state of a bucket after a take, allowed or not
+func (b *bucket) result(limit Limit, now time.Time, allowed bool) Result {
+	result := Result{Allowed: allowed, Remaining: max(int(b.tokens), 0)}
+	if !allowed {
+		result.RetryAfter = seconds((1 - b.tokens) / limit.Rate)
+	}
+	result.Reset = seconds((float64(limit.Burst) - b.tokens) / limit.Rate)
+	b.full = now.Add(result.Reset)
+	return result
+}
+
+// drop buckets that have refilled completely, each under its own limit
+func (s *MemoryStore) sweep(now time.Time) {
+	for key, b := range s.buckets {
+		if !now.Before(b.full) {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
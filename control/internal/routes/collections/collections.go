@@ -0,0 +1,117 @@
+package collections
+
+import (
+	"errors"
+	"slices"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/christian-nickerson/pangolin/control/internal/auth"
+	"github.com/christian-nickerson/pangolin/control/internal/collections"
+	"github.com/christian-nickerson/pangolin/control/internal/logging"
+	"github.com/christian-nickerson/pangolin/control/internal/models"
+	"github.com/christian-nickerson/pangolin/control/internal/shards"
+	"github.com/christian-nickerson/pangolin/control/internal/storage"
+)
+
+type createRequest struct {
+	Name         string        `json:"name" validate:"required,max=64,hostname_rfc1123"`
+	Model        string        `json:"model" validate:"required"`
+	ChunkSize    int           `json:"chunk_size" validate:"required,gt=0"`
+	ChunkOverlap int           `json:"chunk_overlap" validate:"gte=0,ltfield=ChunkSize"`
+	Metric       models.Metric `json:"metric" validate:"required,oneof=cosine dot euclidean"`
+	Shards       int           `json:"shards" validate:"gte=0,lte=256"`
+	Replicas     int           `json:"replicas" validate:"gte=0,lte=5"`
+}
+
+// Register mounts collection routes, scoped to the namespace of the request.
+// Deleting a collection also deletes its files from store.
+func Register(router fiber.Router, store storage.BlobStore) {
+	router.Get("/collections", auth.Require(models.ScopeRead), list)
+	router.Post("/collections", auth.Require(models.ScopeWrite), create)
+	router.Get("/collections/:collection", auth.Require(models.ScopeRead), get)
+	router.Delete("/collections/:collection", auth.Require(models.ScopeWrite), remove(store))
+}
+
+func list(c *fiber.Ctx) error {
+	all, err := collections.List(c.UserContext(), auth.Namespace(c))
+	if err != nil {
+		return err
+	}
+
+	// only list collections the key may read
+	key := auth.Key(c)
+	all = slices.DeleteFunc(all, func(collection models.Collection) bool {
+		return !key.Allows(models.ScopeRead, collection.Name)
+	})
+
+	return c.JSON(all)
+}
+
+func create(c *fiber.Ctx) error {
+	var body createRequest
+	if err := models.BindBody(c, &body); err != nil {
+		return err
+	}
+	if !auth.Key(c).Allows(models.ScopeWrite, body.Name) {
+		return fiber.NewError(fiber.StatusForbidden, "api key lacks write permission")
+	}
+
+	collection := &models.Collection{
+		Name:         body.Name,
+		Model:        body.Model,
+		ChunkSize:    body.ChunkSize,
+		ChunkOverlap: body.ChunkOverlap,
+		Metric:       body.Metric,
+		Shards:       body.Shards,
+		Replicas:     body.Replicas,
+	}
+	if err := collections.Create(c.UserContext(), auth.Namespace(c), collection); err != nil {
+		return collectionError(err)
+	}
+
+	logging.Ctx(c).Info("collection created", "collection", collection.Name)
+	return c.Status(fiber.StatusCreated).JSON(collection)
+}
+
+func get(c *fiber.Ctx) error {
+	collection, err := collections.Get(c.UserContext(), auth.Namespace(c), c.Params(models.CollectionParam))
+	if err != nil {
+		return collectionError(err)
+	}
+	return c.JSON(collection)
+}
+
+func remove(store storage.BlobStore) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		collection, err := collections.Get(c.UserContext(), auth.Namespace(c), c.Params(models.CollectionParam))
+		if err != nil {
+			return collectionError(err)
+		}
+
+		if err := collections.Delete(c.UserContext(), collection); err != nil {
+			return err
+		}
+
+		logger := logging.Ctx(c)
+		if err := storage.DeletePrefix(c.UserContext(), store, collections.BlobPrefix(collection.ID)+"/"); err != nil {
+			logger.Warn("failed to delete collection blobs", "err", err)
+		}
+
+		logger.Info("collection deleted")
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+}
+
+// map service errors onto HTTP errors
+func collectionError(err error) error {
+	switch {
+	case errors.Is(err, collections.ErrCollectionNotFound):
+		return fiber.NewError(fiber.StatusNotFound, err.Error())
+	case errors.Is(err, collections.ErrCollectionExists):
+		return fiber.NewError(fiber.StatusConflict, err.Error())
+	case errors.Is(err, shards.ErrNoNodes), errors.Is(err, shards.ErrNoTransport):
+		return fiber.NewError(fiber.StatusServiceUnavailable, err.Error())
+	}
+	return err
+}
@@ -0,0 +1,157 @@
+package collections
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/goccy/go-json"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/christian-nickerson/pangolin/control/internal/auth"
+	"github.com/christian-nickerson/pangolin/control/internal/collections"
+	"github.com/christian-nickerson/pangolin/control/internal/configs"
+	"github.com/christian-nickerson/pangolin/control/internal/database"
+	"github.com/christian-nickerson/pangolin/control/internal/models"
+	"github.com/christian-nickerson/pangolin/control/internal/namespaces"
+	"github.com/christian-nickerson/pangolin/control/internal/storage"
+)
+
+const collectionBody = `{"name":"%v","model":"all-MiniLM-L6-v2","chunk_size":256,"metric":"cosine"}`
+
+type CollectionsSuite struct {
+	suite.Suite
+	app     *fiber.App
+	teamA   string
+	teamB   string
+	readerA string
+	store   storage.BlobStore
+}
+
+// set up a database with two namespaces, each with a key
+func (s *CollectionsSuite) SetupTest() {
+	ctx := context.Background()
+	config := configs.DatabaseConfig{Type: "sqlite", DBName: filepath.Join(s.T().TempDir(), "test")}
+	s.Require().NoError(database.Connect(config))
+	s.Require().NoError(database.Migrate())
+
+	a := &models.Namespace{Name: "team-a", MaxCollections: 1}
+	b := &models.Namespace{Name: "team-b"}
+	s.Require().NoError(namespaces.Create(ctx, a))
+	s.Require().NoError(namespaces.Create(ctx, b))
+
+	var err error
+	s.teamA, _, err = auth.Create(ctx, a.ID, "a", models.ScopeWrite, nil)
+	s.Require().NoError(err)
+	s.teamB, _, err = auth.Create(ctx, b.ID, "b", models.ScopeWrite, nil)
+	s.Require().NoError(err)
+	s.readerA, _, err = auth.Create(ctx, a.ID, "reader", models.ScopeRead, []string{"other"})
+	s.Require().NoError(err)
+
+	s.store, err = storage.NewFilesystem(s.T().TempDir())
+	s.Require().NoError(err)
+
+	s.app = fiber.New(fiber.Config{ErrorHandler: models.ErrorHandler})
+	s.app.Use(auth.New(configs.Auth{Enabled: true}))
+	Register(s.app, s.store)
+}
+
+// send a request authenticated with key, decoding the response into out
+func (s *CollectionsSuite) request(method, path, key, body string, out interface{}) int {
+	request := httptest.NewRequest(method, path, strings.NewReader(body))
+	request.Header.Set("Authorization", "Bearer "+key)
+	request.Header.Set("Content-Type", "application/json")
+	response, err := s.app.Test(request)
+	s.Require().NoError(err)
+	if out != nil {
+		s.Require().NoError(json.NewDecoder(response.Body).Decode(out))
+	}
+	return response.StatusCode
+}
+
+// create a collection, returning the response status
+func (s *CollectionsSuite) create(key, name string) int {
+	return s.request("POST", "/collections", key, fmt.Sprintf(collectionBody, name), nil)
+}
+
+// Test collections are only visible within their namespace
+func (s *CollectionsSuite) TestNamespaceIsolation() {
+	s.Require().Equal(201, s.create(s.teamA, "docs"))
+
+	var listed []models.Collection
+	s.request("GET", "/collections", s.teamB, "", &listed)
+	s.Assert().Empty(listed)
+	s.Assert().Equal(404, s.request("GET", "/collections/docs", s.teamB, "", nil))
+
+	// the same name may be reused in another namespace
+	s.Assert().Equal(201, s.create(s.teamB, "docs"))
+}
+
+// Test keys cannot select another namespace
+func (s *CollectionsSuite) TestNamespaceHeaderMismatch() {
+	request := httptest.NewRequest("GET", "/collections", nil)
+	request.Header.Set("Authorization", "Bearer "+s.teamA)
+	request.Header.Set(auth.NamespaceHeader, "team-b")
+	response, _ := s.app.Test(request)
+	s.Assert().Equal(403, response.StatusCode)
+}
+
+// Test collection quota returns 429 with quota details
+func (s *CollectionsSuite) TestCollectionQuota() {
+	s.Require().Equal(201, s.create(s.teamA, "docs"))
+
+	var body struct {
+		Quota models.QuotaError `json:"quota"`
+	}
+	status := s.request("POST", "/collections", s.teamA, fmt.Sprintf(collectionBody, "more"), &body)
+	s.Require().Equal(429, status)
+	s.Assert().Equal("max_collections", body.Quota.Quota)
+	s.Assert().Equal(int64(1), body.Quota.Limit)
+}
+
+// Test collection restricted keys only see their collections
+func (s *CollectionsSuite) TestRestrictedKey() {
+	s.Require().Equal(201, s.create(s.teamA, "docs"))
+
+	var listed []models.Collection
+	s.request("GET", "/collections", s.readerA, "", &listed)
+	s.Assert().Empty(listed)
+	s.Assert().Equal(403, s.request("GET", "/collections/docs", s.readerA, "", nil))
+	s.Assert().Equal(403, s.create(s.readerA, "other"))
+}
+
+// Test duplicate and invalid collections are rejected
+func (s *CollectionsSuite) TestCreateConflicts() {
+	s.Require().Equal(201, s.create(s.teamB, "docs"))
+	s.Assert().Equal(409, s.create(s.teamB, "docs"))
+	s.Assert().Equal(422, s.create(s.teamB, "Not Valid"))
+}
+
+// Test deleting a collection removes its blobs and no others
+func (s *CollectionsSuite) TestDeleteBlobs() {
+	ctx := context.Background()
+	var created models.Collection
+	s.Require().Equal(201, s.request("POST", "/collections", s.teamA, fmt.Sprintf(collectionBody, "docs"), &created))
+
+	for _, key := range []string{
+		storage.Key(collections.BlobPrefix(created.ID), "documents", "a.json"),
+		storage.Key(collections.BlobPrefix(created.ID+1), "documents", "a.json"),
+	} {
+		_, err := s.store.Put(ctx, key, strings.NewReader("{}"), 2, storage.Condition{})
+		s.Require().NoError(err)
+	}
+
+	s.Require().Equal(204, s.request("DELETE", "/collections/docs", s.teamA, "", nil))
+	blobs, err := s.store.List(ctx, "")
+	s.Require().NoError(err)
+	s.Require().Len(blobs, 1)
+	s.Equal(storage.Key(collections.BlobPrefix(created.ID+1), "documents", "a.json"), blobs[0].Key)
+}
+
+func TestCollectionsSuite(t *testing.T) {
+	suite.Run(t, new(CollectionsSuite))
+}
@@ -0,0 +1,29 @@
+package consensus
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/christian-nickerson/pangolin/control/internal/auth"
+	"github.com/christian-nickerson/pangolin/control/internal/consensus"
+)
+
+// Register mounts control plane cluster routes, restricted to the cluster
+// admin. Without a cluster this instance runs alone.
+func Register(router fiber.Router, cluster *consensus.Cluster) {
+	group := router.Group("/admin/cluster", auth.RequireCluster)
+	group.Get("/", status(cluster))
+}
+
+func status(cluster *consensus.Cluster) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if cluster == nil {
+			return fiber.NewError(fiber.StatusNotFound, "high availability is not enabled")
+		}
+
+		status, err := cluster.Status()
+		if err != nil {
+			return err
+		}
+		return c.JSON(status)
+	}
+}
@@ -0,0 +1,346 @@
+package documents
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"maps"
+	"mime/multipart"
+	"strconv"
+	"strings"
+
+	"github.com/goccy/go-json"
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/christian-nickerson/pangolin/control/internal/auth"
+	"github.com/christian-nickerson/pangolin/control/internal/collections"
+	"github.com/christian-nickerson/pangolin/control/internal/documents"
+	"github.com/christian-nickerson/pangolin/control/internal/extract"
+	"github.com/christian-nickerson/pangolin/control/internal/jobs"
+	"github.com/christian-nickerson/pangolin/control/internal/logging"
+	"github.com/christian-nickerson/pangolin/control/internal/models"
+	"github.com/christian-nickerson/pangolin/control/internal/storage"
+)
+
+const documentParam = "document"
+
+type ingestRequest struct {
+	Documents []documents.Upsert `json:"documents" validate:"required,min=1,max=1000,dive"`
+}
+
+type putRequest struct {
+	Text     string            `json:"text" validate:"required"`
+	Metadata map[string]string `json:"metadata"`
+}
+
+type listQuery struct {
+	Limit  int `query:"limit" validate:"omitempty,min=1,max=1000"`
+	Offset int `query:"offset" validate:"gte=0"`
+}
+
+// Register mounts document routes. Writes are upserts by document id,
+// embedded in the background by the queue, and single document routes
+// honour If-Match against the document version. Uploaded files are kept
+// in store alongside the chunk payloads.
+func Register(router fiber.Router, queue *jobs.Queue, store storage.BlobStore) {
+	group := router.Group("/collections/:collection/documents")
+	group.Get("/", auth.Require(models.ScopeRead), list)
+	group.Post("/", auth.Require(models.ScopeWrite), ingest(queue))
+	group.Post("/upload", auth.Require(models.ScopeWrite), upload(queue, store))
+	group.Get("/:document", auth.Require(models.ScopeRead), get)
+	group.Get("/:document/chunks", auth.Require(models.ScopeRead), chunks)
+	group.Put("/:document", auth.Require(models.ScopeWrite), put(queue))
+	group.Delete("/:document", auth.Require(models.ScopeWrite), remove(store))
+}
+
+func ingest(queue *jobs.Queue) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var body ingestRequest
+		if err := models.BindBody(c, &body); err != nil {
+			return err
+		}
+
+		collection, err := lookup(c)
+		if err != nil {
+			return err
+		}
+
+		return submit(c, queue, collection, body.Documents)
+	}
+}
+
+// upload extracts the text of multipart files, each file becomes a
+// document with the form id, or its file name when uploading several
+func upload(queue *jobs.Queue, store storage.BlobStore) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		form, err := c.MultipartForm()
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "expected a multipart form")
+		}
+
+		files := form.File["file"]
+		if len(files) == 0 {
+			return models.ValidationError{{Field: "file", Tag: "required"}}
+		}
+		if len(files) > 1000 {
+			return models.ValidationError{{Field: "file", Tag: "max", Value: "1000"}}
+		}
+		id := c.FormValue("id")
+		if id != "" && len(files) > 1 {
+			return fiber.NewError(fiber.StatusBadRequest, "id can only be given for a single file")
+		}
+
+		metadata := map[string]string{}
+		if raw := c.FormValue("metadata"); raw != "" {
+			if err := json.Unmarshal([]byte(raw), &metadata); err != nil {
+				return fiber.NewError(fiber.StatusBadRequest, "metadata must be a json object of strings")
+			}
+		}
+
+		collection, err := lookup(c)
+		if err != nil {
+			return err
+		}
+
+		// every file is extracted before any is queued or stored, so a
+		// rejected file leaves no job and no originals behind
+		upserts := make([]documents.Upsert, len(files))
+		originals := make([][]byte, len(files))
+		for i, file := range files {
+			originals[i], err = readFile(file)
+			if err != nil {
+				return err
+			}
+
+			upserts[i], err = extractFile(file.Filename, originals[i], metadata)
+			if err != nil {
+				return err
+			}
+			if id != "" {
+				upserts[i].ID = id
+			}
+		}
+
+		job, err := enqueue(c, queue, collection, upserts)
+		if err != nil {
+			return err
+		}
+
+		// originals are kept for reference only, the job is queued either way
+		for i, upsert := range upserts {
+			key := documents.OriginalKey(collection.ID, upsert.ID)
+			if _, err := store.Put(c.UserContext(), key, bytes.NewReader(originals[i]), int64(len(originals[i])), storage.Condition{}); err != nil {
+				logging.Ctx(c).Warn("failed to store original file", "document_id", upsert.ID, "err", err)
+			}
+		}
+		return accepted(c, job, len(upserts))
+	}
+}
+
+// read an uploaded file
+func readFile(file *multipart.FileHeader) ([]byte, error) {
+	reader, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
+
+// extract a file into an upsert keyed by its file name
+func extractFile(filename string, data []byte, metadata map[string]string) (documents.Upsert, error) {
+	text, mime, err := extract.Extract(data, filename)
+	switch {
+	case errors.Is(err, extract.ErrUnsupported):
+		return documents.Upsert{}, fiber.NewError(fiber.StatusUnsupportedMediaType, filename+", "+err.Error())
+	case err != nil:
+		return documents.Upsert{}, fiber.NewError(fiber.StatusUnprocessableEntity, filename+", "+err.Error())
+	}
+
+	upsert := documents.Upsert{ID: filename, Text: text, Metadata: maps.Clone(metadata)}
+	upsert.Metadata["filename"] = filename
+	upsert.Metadata["content_type"] = mime
+	return upsert, nil
+}
+
+func put(queue *jobs.Queue) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var body putRequest
+		if err := models.BindBody(c, &body); err != nil {
+			return err
+		}
+
+		collection, err := lookup(c)
+		if err != nil {
+			return err
+		}
+
+		upsert := documents.Upsert{ID: c.Params(documentParam), Text: body.Text, Metadata: body.Metadata}
+		version, wildcard, err := ifMatch(c)
+		if err != nil {
+			return err
+		}
+
+		// fail fast on a stale version, the job checks again when it applies
+		if version != 0 || wildcard {
+			current, err := documents.Get(c.UserContext(), collection, upsert.ID)
+			if errors.Is(err, documents.ErrDocumentNotFound) || (err == nil && !wildcard && current.Version != version) {
+				return fiber.NewError(fiber.StatusPreconditionFailed, documents.ErrVersionConflict.Error())
+			}
+			if err != nil {
+				return err
+			}
+			upsert.Version = current.Version
+		}
+
+		return submit(c, queue, collection, []documents.Upsert{upsert})
+	}
+}
+
+// queue an ingestion job, responding with the job to poll
+func submit(c *fiber.Ctx, queue *jobs.Queue, collection *models.Collection, upserts []documents.Upsert) error {
+	job, err := enqueue(c, queue, collection, upserts)
+	if err != nil {
+		return err
+	}
+	return accepted(c, job, len(upserts))
+}
+
+// queue an ingestion job
+func enqueue(c *fiber.Ctx, queue *jobs.Queue, collection *models.Collection, upserts []documents.Upsert) (*models.Job, error) {
+	job, err := queue.Submit(c.UserContext(), auth.Namespace(c), collection, upserts)
+	if errors.Is(err, documents.ErrDuplicateID) {
+		return nil, fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	return job, err
+}
+
+// respond with a queued job to poll
+func accepted(c *fiber.Ctx, job *models.Job, documents int) error {
+	logging.Ctx(c).Info("ingestion job queued", "job_id", job.ID, "documents", documents)
+	c.Location("/jobs/" + job.ID)
+	return c.Status(fiber.StatusAccepted).JSON(job)
+}
+
+func list(c *fiber.Ctx) error {
+	query := listQuery{Limit: 100}
+	if err := models.BindQuery(c, &query); err != nil {
+		return err
+	}
+
+	collection, err := lookup(c)
+	if err != nil {
+		return err
+	}
+
+	all, err := documents.List(c.UserContext(), collection, query.Limit, query.Offset)
+	if err != nil {
+		return err
+	}
+	return c.JSON(all)
+}
+
+func get(c *fiber.Ctx) error {
+	collection, err := lookup(c)
+	if err != nil {
+		return err
+	}
+
+	document, err := documents.Get(c.UserContext(), collection, c.Params(documentParam))
+	if err != nil {
+		return documentError(err)
+	}
+
+	c.Set(fiber.HeaderETag, etag(document.Version))
+	return c.JSON(document)
+}
+
+func chunks(c *fiber.Ctx) error {
+	collection, err := lookup(c)
+	if err != nil {
+		return err
+	}
+
+	document, err := documents.Get(c.UserContext(), collection, c.Params(documentParam))
+	if err != nil {
+		return documentError(err)
+	}
+
+	all, err := documents.Chunks(c.UserContext(), document)
+	if err != nil {
+		return err
+	}
+
+	c.Set(fiber.HeaderETag, etag(document.Version))
+	return c.JSON(all)
+}
+
+func remove(store storage.BlobStore) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		collection, err := lookup(c)
+		if err != nil {
+			return err
+		}
+
+		version, _, err := ifMatch(c)
+		if err != nil {
+			return err
+		}
+
+		document, err := documents.Delete(c.UserContext(), collection, c.Params(documentParam), version)
+		if err != nil {
+			return documentError(err)
+		}
+
+		logger := logging.Ctx(c)
+		if err := documents.DeleteBlobs(c.UserContext(), store, collection.ID, document.ExternalID); err != nil {
+			logger.Warn("failed to delete document blobs", "document_id", document.ExternalID, "err", err)
+		}
+
+		logger.Info("document deleted", "document_id", document.ExternalID, "version", document.Version)
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+}
+
+// look up the collection named in the route
+func lookup(c *fiber.Ctx) (*models.Collection, error) {
+	collection, err := collections.Get(c.UserContext(), auth.Namespace(c), c.Params(models.CollectionParam))
+	if errors.Is(err, collections.ErrCollectionNotFound) {
+		return nil, fiber.NewError(fiber.StatusNotFound, err.Error())
+	}
+	return collection, err
+}
+
+// format a document version as an entity tag
+func etag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// parse If-Match into the expected version, zero when absent
+func ifMatch(c *fiber.Ctx) (version int, wildcard bool, err error) {
+	header := strings.TrimSpace(c.Get(fiber.HeaderIfMatch))
+	if header == "" {
+		return 0, false, nil
+	}
+	if header == "*" {
+		return 0, true, nil
+	}
+
+	tag := strings.Trim(strings.TrimPrefix(header, "W/"), `"`)
+	version, err = strconv.Atoi(tag)
+	if err != nil || version <= 0 {
+		return 0, false, fiber.NewError(fiber.StatusBadRequest, "If-Match must be a document version entity tag")
+	}
+	return version, false, nil
+}
+
+// map service errors onto HTTP errors
+func documentError(err error) error {
+	switch {
+	case errors.Is(err, documents.ErrDocumentNotFound):
+		return fiber.NewError(fiber.StatusNotFound, err.Error())
+	case errors.Is(err, documents.ErrVersionConflict):
+		return fiber.NewError(fiber.StatusPreconditionFailed, err.Error())
+	}
+	return err
+}
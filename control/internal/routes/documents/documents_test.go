@@ -0,0 +1,181 @@
+package documents
+
+import (
+	"bytes"
+	"context"
+	"mime/multipart"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/goccy/go-json"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/christian-nickerson/pangolin/control/internal/auth"
+	"github.com/christian-nickerson/pangolin/control/internal/configs"
+	"github.com/christian-nickerson/pangolin/control/internal/database"
+	"github.com/christian-nickerson/pangolin/control/internal/documents"
+	"github.com/christian-nickerson/pangolin/control/internal/index"
+	"github.com/christian-nickerson/pangolin/control/internal/jobs"
+	"github.com/christian-nickerson/pangolin/control/internal/models"
+	"github.com/christian-nickerson/pangolin/control/internal/namespaces"
+	"github.com/christian-nickerson/pangolin/control/internal/storage"
+)
+
+type DocumentsSuite struct {
+	suite.Suite
+	app        *fiber.App
+	store      storage.BlobStore
+	collection *models.Collection
+}
+
+// set up a collection holding document "a" at version 1, with a
+// queue that is never started so submitted jobs stay queued
+func (s *DocumentsSuite) SetupTest() {
+	ctx := context.Background()
+	config := configs.DatabaseConfig{Type: "sqlite", DBName: filepath.Join(s.T().TempDir(), "test")}
+	s.Require().NoError(database.Connect(config))
+	s.Require().NoError(database.Migrate())
+
+	namespace, err := namespaces.EnsureDefault(ctx, configs.Quotas{})
+	s.Require().NoError(err)
+	s.collection = &models.Collection{NamespaceID: namespace.ID, Name: "docs", Model: "test", ChunkSize: 8, Metric: models.MetricCosine}
+	s.Require().NoError(database.DB.Create(s.collection).Error)
+	index.Drop(s.collection.ID)
+
+	s.store, err = storage.NewFilesystem(s.T().TempDir())
+	s.Require().NoError(err)
+
+	plan, err := documents.Prepare(ctx, s.collection, documents.Upsert{ID: "a", Text: "hello"})
+	s.Require().NoError(err)
+	plan.Vectors[0] = models.Vector{1, 0}
+	s.Require().NoError(documents.Apply(ctx, s.collection, []*documents.Plan{plan}))
+	s.Require().NoError(documents.SavePayload(ctx, s.store, plan))
+
+	s.app = fiber.New(fiber.Config{ErrorHandler: models.ErrorHandler})
+	s.app.Use(auth.New(configs.Auth{Enabled: false}))
+	Register(s.app, jobs.NewQueue(configs.Jobs{}, nil, s.store), s.store)
+}
+
+// send a request with an optional If-Match header, returning the status and ETag
+func (s *DocumentsSuite) send(method, path, ifMatch, body string) (int, string) {
+	request := httptest.NewRequest(method, path, strings.NewReader(body))
+	request.Header.Set("Content-Type", "application/json")
+	if ifMatch != "" {
+		request.Header.Set("If-Match", ifMatch)
+	}
+	response, err := s.app.Test(request)
+	s.Require().NoError(err)
+	return response.StatusCode, response.Header.Get("ETag")
+}
+
+// Test documents report their version as an entity tag
+func (s *DocumentsSuite) TestGet() {
+	status, etag := s.send("GET", "/collections/docs/documents/a", "", "")
+	s.Equal(200, status)
+	s.Equal(`"1"`, etag)
+
+	status, _ = s.send("GET", "/collections/docs/documents/missing", "", "")
+	s.Equal(404, status)
+}
+
+// Test replacing a document checks If-Match before queueing
+func (s *DocumentsSuite) TestPut() {
+	body := `{"text":"updated"}`
+	status, _ := s.send("PUT", "/collections/docs/documents/a", `"2"`, body)
+	s.Equal(412, status)
+	status, _ = s.send("PUT", "/collections/docs/documents/new", "*", body)
+	s.Equal(412, status)
+	status, _ = s.send("PUT", "/collections/docs/documents/a", "nonsense", body)
+	s.Equal(400, status)
+
+	status, _ = s.send("PUT", "/collections/docs/documents/a", `W/"1"`, body)
+	s.Equal(202, status)
+	status, _ = s.send("PUT", "/collections/docs/documents/new", "", body)
+	s.Equal(202, status)
+}
+
+// Test deleting a document honours If-Match and removes its payload
+func (s *DocumentsSuite) TestDelete() {
+	status, _ := s.send("DELETE", "/collections/docs/documents/a", `"2"`, "")
+	s.Equal(412, status)
+	status, _ = s.send("DELETE", "/collections/docs/documents/a", `"1"`, "")
+	s.Equal(204, status)
+	status, _ = s.send("DELETE", "/collections/docs/documents/a", "", "")
+	s.Equal(404, status)
+
+	_, err := s.store.Stat(context.Background(), documents.PayloadKey(s.collection.ID, "a"))
+	s.ErrorIs(err, storage.ErrNotFound)
+}
+
+// upload files as a multipart form, returning the status and queued job
+func (s *DocumentsSuite) upload(fields map[string]string, files map[string]string) (int, models.Job) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	for name, value := range fields {
+		s.Require().NoError(form.WriteField(name, value))
+	}
+	for name, content := range files {
+		part, err := form.CreateFormFile("file", name)
+		s.Require().NoError(err)
+		_, err = part.Write([]byte(content))
+		s.Require().NoError(err)
+	}
+	s.Require().NoError(form.Close())
+
+	request := httptest.NewRequest("POST", "/collections/docs/documents/upload", &body)
+	request.Header.Set("Content-Type", form.FormDataContentType())
+	response, err := s.app.Test(request)
+	s.Require().NoError(err)
+
+	var job models.Job
+	if response.StatusCode == 202 {
+		s.Require().NoError(json.NewDecoder(response.Body).Decode(&job))
+	}
+	return response.StatusCode, job
+}
+
+// Test uploaded files are extracted into documents named after the file
+func (s *DocumentsSuite) TestUpload() {
+	status, job := s.upload(nil, map[string]string{
+		"page.html": "<html><body><p>Hello</p></body></html>",
+		"notes.md":  "# Notes\n\nSome *text*",
+	})
+	s.Require().Equal(202, status)
+	s.ElementsMatch([]string{"page.html", "notes.md"}, job.Documents)
+
+	var stored models.Job
+	s.Require().NoError(database.DB.First(&stored, "id = ?", job.ID).Error)
+	var upserts []documents.Upsert
+	s.Require().NoError(json.Unmarshal(stored.Payload, &upserts))
+	for _, upsert := range upserts {
+		if upsert.ID == "notes.md" {
+			s.Equal("# Notes\n\nSome text", upsert.Text)
+			s.Equal("text/markdown", upsert.Metadata["content_type"])
+		}
+	}
+
+	status, job = s.upload(map[string]string{"id": "a", "metadata": `{"team":"x"}`}, map[string]string{"a.txt": "replacement"})
+	s.Require().Equal(202, status)
+	s.Equal([]string{"a"}, job.Documents)
+
+	original, err := storage.ReadAll(context.Background(), s.store, documents.OriginalKey(s.collection.ID, "a"))
+	s.Require().NoError(err)
+	s.Equal("replacement", string(original))
+
+	// an unsupported file rejects the upload before any original is stored
+	status, _ = s.upload(nil, map[string]string{"c.txt": "three", "image.png": "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"})
+	s.Equal(415, status)
+	_, err = s.store.Stat(context.Background(), documents.OriginalKey(s.collection.ID, "c.txt"))
+	s.ErrorIs(err, storage.ErrNotFound)
+	status, _ = s.upload(map[string]string{"id": "a"}, map[string]string{"a.txt": "one", "b.txt": "two"})
+	s.Equal(400, status)
+	status, _ = s.upload(nil, nil)
+	s.Equal(422, status)
+}
+
+func TestDocumentsSuite(t *testing.T) {
+	suite.Run(t, new(DocumentsSuite))
+}
@@ -5,13 +5,32 @@ import (
 	"github.com/gofiber/fiber/v2/middleware/healthcheck"
 )
 
+const (
+	LivenessEndpoint  = "/health"
+	ReadinessEndpoint = "/ready"
+)
+
 func healthCheckProbe(*fiber.Ctx) bool { return true }
 
-func readinessProbe(*fiber.Ctx) bool { return true }
+// skip the middleware for verbose readiness requests so they
+// fall through to the JSON breakdown route
+func verboseReadiness(c *fiber.Ctx) bool {
+	return c.Path() == ReadinessEndpoint && c.QueryBool("verbose")
+}
+
+// HealthCheckConfig builds the liveness and readiness probe configuration
+func HealthCheckConfig(readiness *Readiness) healthcheck.Config {
+	return healthcheck.Config{
+		Next:              verboseReadiness,
+		LivenessProbe:     healthCheckProbe,
+		ReadinessProbe:    readiness.Probe,
+		LivenessEndpoint:  LivenessEndpoint,
+		ReadinessEndpoint: ReadinessEndpoint,
+	}
+}
 
-var HealthCheckConfig = healthcheck.Config{
-	LivenessProbe:     healthCheckProbe,
-	ReadinessProbe:    readinessProbe,
-	LivenessEndpoint:  "/health",
-	ReadinessEndpoint: "/ready",
+// Register mounts health check endpoints onto the app
+func Register(app *fiber.App, readiness *Readiness) {
+	app.Use(healthcheck.New(HealthCheckConfig(readiness)))
+	app.Get(ReadinessEndpoint, readiness.Verbose)
 }
@@ -1,23 +1,29 @@
 package health
 
 import (
+	"context"
+	"errors"
 	"net/http/httptest"
 	"testing"
+	"time"
 
+	"github.com/goccy/go-json"
 	"github.com/gofiber/fiber/v2"
-	"github.com/gofiber/fiber/v2/middleware/healthcheck"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
 )
 
 type HealthCheckSuite struct {
 	suite.Suite
-	app *fiber.App
+	app       *fiber.App
+	readiness *Readiness
 }
 
 // set up app
 func (s *HealthCheckSuite) SetupTest() {
 	s.app = fiber.New()
-	s.app.Use(healthcheck.New(HealthCheckConfig))
+	s.readiness = NewReadiness(50*time.Millisecond, 0)
+	Register(s.app, s.readiness)
 }
 
 // shutdown app
@@ -41,6 +47,77 @@ func (s *HealthCheckSuite) TestReadyEndpoint() {
 	s.Assert().Equal(200, response.StatusCode)
 }
 
+// Test ready endpoint fails when a dependency is down
+func (s *HealthCheckSuite) TestReadyEndpointDependencyDown() {
+	s.readiness.Register("database", func(context.Context) error { return errors.New("down") })
+
+	request := httptest.NewRequest("GET", "/ready", nil)
+	response, _ := s.app.Test(request)
+
+	s.Assert().Equal(503, response.StatusCode)
+}
+
+// Test ready endpoint fails when a dependency exceeds the timeout
+func (s *HealthCheckSuite) TestReadyEndpointDependencyTimeout() {
+	s.readiness.Register("embeddings", func(ctx context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	})
+
+	request := httptest.NewRequest("GET", "/ready", nil)
+	response, _ := s.app.Test(request)
+
+	s.Assert().Equal(503, response.StatusCode)
+}
+
+// Test verbose ready endpoint returns a breakdown per dependency
+func (s *HealthCheckSuite) TestReadyEndpointVerbose() {
+	s.readiness.Register("database", func(context.Context) error { return nil })
+	s.readiness.Register("embeddings", func(context.Context) error { return errors.New("down") })
+
+	request := httptest.NewRequest("GET", "/ready?verbose=1", nil)
+	response, _ := s.app.Test(request)
+	s.Assert().Equal(503, response.StatusCode)
+
+	var body struct {
+		Ready        bool     `json:"ready"`
+		Dependencies []Result `json:"dependencies"`
+	}
+	s.Require().NoError(json.NewDecoder(response.Body).Decode(&body))
+	s.Assert().False(body.Ready)
+	s.Require().Len(body.Dependencies, 2)
+	s.Assert().Equal("database", body.Dependencies[0].Name)
+	s.Assert().True(body.Dependencies[0].Ready)
+	s.Assert().Equal("embeddings", body.Dependencies[1].Name)
+	s.Assert().Equal("down", body.Dependencies[1].Error)
+}
+
+// Test ready endpoint fails once draining while liveness still passes
+func (s *HealthCheckSuite) TestReadyEndpointDraining() {
+	s.readiness.Drain()
+
+	response, _ := s.app.Test(httptest.NewRequest("GET", "/ready", nil))
+	s.Assert().Equal(503, response.StatusCode)
+
+	response, _ = s.app.Test(httptest.NewRequest("GET", "/health", nil))
+	s.Assert().Equal(200, response.StatusCode)
+}
+
 func TestHealthCheckSuite(t *testing.T) {
 	suite.Run(t, new(HealthCheckSuite))
 }
+
+// Test check results are reused within the cache period
+func TestReadinessCache(t *testing.T) {
+	readiness := NewReadiness(time.Second, time.Minute)
+	calls := 0
+	readiness.Register("database", func(context.Context) error {
+		calls++
+		return nil
+	})
+
+	readiness.Status()
+	readiness.Status()
+
+	assert.Equal(t, 1, calls, "checks should be cached between probes")
+}
@@ -0,0 +1,138 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Check returns an error when a dependency is not ready to serve traffic
+type Check func(ctx context.Context) error
+
+// Result is the outcome of a single dependency check
+type Result struct {
+	Name    string `json:"name"`
+	Ready   bool   `json:"ready"`
+	Error   string `json:"error,omitempty"`
+	Latency string `json:"latency,omitempty"`
+}
+
+// Readiness runs registered dependency checks, caching
+// results so frequent probes do not hammer dependencies
+type Readiness struct {
+	timeout     time.Duration
+	cachePeriod time.Duration
+	draining    atomic.Bool
+
+	mu        sync.Mutex
+	names     []string
+	checks    map[string]Check
+	ready     bool
+	results   []Result
+	checkedAt time.Time
+}
+
+// NewReadiness creates a readiness prober where each check is
+// bounded by timeout and results are reused for cachePeriod
+func NewReadiness(timeout, cachePeriod time.Duration) *Readiness {
+	return &Readiness{
+		timeout:     timeout,
+		cachePeriod: cachePeriod,
+		checks:      make(map[string]Check),
+	}
+}
+
+// Register adds a named dependency check, replacing any with the same name
+func (r *Readiness) Register(name string, check Check) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.checks[name]; !ok {
+		r.names = append(r.names, name)
+	}
+	r.checks[name] = check
+	r.checkedAt = time.Time{}
+}
+
+// Drain marks the service as not ready, so load balancers stop
+// routing new traffic while in-flight requests finish
+func (r *Readiness) Drain() {
+	r.draining.Store(true)
+}
+
+// Status returns overall readiness and a per dependency breakdown
+func (r *Readiness) Status() (bool, []Result) {
+	if r.draining.Load() {
+		return false, []Result{{Name: "shutdown", Error: "draining"}}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.checkedAt.IsZero() && time.Since(r.checkedAt) < r.cachePeriod {
+		return r.ready, r.results
+	}
+
+	results := make([]Result, len(r.names))
+	var wg sync.WaitGroup
+	for i, name := range r.names {
+		wg.Add(1)
+		go func(i int, name string, check Check) {
+			defer wg.Done()
+			results[i] = r.run(name, check)
+		}(i, name, r.checks[name])
+	}
+	wg.Wait()
+
+	ready := true
+	for _, result := range results {
+		ready = ready && result.Ready
+	}
+
+	r.ready, r.results, r.checkedAt = ready, results, time.Now()
+	return r.ready, r.results
+}
+
+// run a single check within the configured timeout
+func (r *Readiness) run(name string, check Check) Result {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	start := time.Now()
+	errs := make(chan error, 1)
+	go func() { errs <- check(ctx) }()
+
+	var err error
+	select {
+	case err = <-errs:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	result := Result{Name: name, Ready: err == nil, Latency: time.Since(start).String()}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// Probe reports overall readiness for the healthcheck middleware
+func (r *Readiness) Probe(*fiber.Ctx) bool {
+	ready, _ := r.Status()
+	return ready
+}
+
+// Verbose returns a JSON breakdown of each dependency check
+func (r *Readiness) Verbose(c *fiber.Ctx) error {
+	ready, results := r.Status()
+
+	status := fiber.StatusOK
+	if !ready {
+		status = fiber.StatusServiceUnavailable
+	}
+
+	return c.Status(status).JSON(fiber.Map{"ready": ready, "dependencies": results})
+}
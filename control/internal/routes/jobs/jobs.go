@@ -0,0 +1,110 @@
+package jobs
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/christian-nickerson/pangolin/control/internal/auth"
+	"github.com/christian-nickerson/pangolin/control/internal/collections"
+	"github.com/christian-nickerson/pangolin/control/internal/jobs"
+	"github.com/christian-nickerson/pangolin/control/internal/logging"
+	"github.com/christian-nickerson/pangolin/control/internal/models"
+)
+
+type listQuery struct {
+	Limit int `query:"limit" validate:"omitempty,min=1,max=1000"`
+}
+
+// Register mounts job status routes, scoped to the namespace of the request
+func Register(router fiber.Router, queue *jobs.Queue) {
+	group := router.Group("/jobs")
+	group.Get("/", auth.Require(models.ScopeRead), list(queue))
+	group.Get("/:id", auth.Require(models.ScopeRead), get(queue))
+	group.Post("/:id/cancel", auth.Require(models.ScopeWrite), cancel(queue))
+}
+
+func list(queue *jobs.Queue) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		query := listQuery{Limit: 100}
+		if err := models.BindQuery(c, &query); err != nil {
+			return err
+		}
+
+		all, err := queue.List(c.UserContext(), auth.Namespace(c), query.Limit)
+		if err != nil {
+			return err
+		}
+
+		// only list jobs on collections the key may read
+		visible := make([]models.Job, 0, len(all))
+		for _, job := range all {
+			if allowed(c, &job, models.ScopeRead) == nil {
+				visible = append(visible, job)
+			}
+		}
+		return c.JSON(visible)
+	}
+}
+
+func get(queue *jobs.Queue) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		job, err := queue.Get(c.UserContext(), auth.Namespace(c), c.Params("id"))
+		if err != nil {
+			return jobError(err)
+		}
+		if err := allowed(c, job, models.ScopeRead); err != nil {
+			return err
+		}
+		return c.JSON(job)
+	}
+}
+
+func cancel(queue *jobs.Queue) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		job, err := queue.Get(c.UserContext(), auth.Namespace(c), c.Params("id"))
+		if err != nil {
+			return jobError(err)
+		}
+		if err := allowed(c, job, models.ScopeWrite); err != nil {
+			return err
+		}
+
+		job, err = queue.Cancel(c.UserContext(), auth.Namespace(c), job.ID)
+		if err != nil {
+			return jobError(err)
+		}
+
+		logging.Ctx(c).Info("job cancelled", "job_id", job.ID)
+		return c.JSON(job)
+	}
+}
+
+// check the key may act on the job's collection, jobs on deleted
+// collections are only visible to unrestricted keys
+func allowed(c *fiber.Ctx, job *models.Job, scope models.Scope) error {
+	key := auth.Key(c)
+	if len(key.Collections) == 0 {
+		return nil
+	}
+
+	collection, err := collections.GetByID(c.UserContext(), auth.Namespace(c), job.CollectionID)
+	if err == nil && key.Allows(scope, collection.Name) {
+		return nil
+	}
+	if err != nil && !errors.Is(err, collections.ErrCollectionNotFound) {
+		return err
+	}
+	return fiber.NewError(fiber.StatusForbidden, "api key lacks "+string(scope)+" permission")
+}
+
+// map service errors onto HTTP errors
+func jobError(err error) error {
+	switch {
+	case errors.Is(err, jobs.ErrJobNotFound):
+		return fiber.NewError(fiber.StatusNotFound, err.Error())
+	case errors.Is(err, jobs.ErrJobFinished):
+		return fiber.NewError(fiber.StatusConflict, err.Error())
+	}
+	return err
+}
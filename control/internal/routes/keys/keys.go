@@ -0,0 +1,98 @@
+package keys
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/christian-nickerson/pangolin/control/internal/auth"
+	"github.com/christian-nickerson/pangolin/control/internal/logging"
+	"github.com/christian-nickerson/pangolin/control/internal/models"
+)
+
+type createRequest struct {
+	Name        string       `json:"name" validate:"required,max=128"`
+	Scope       models.Scope `json:"scope" validate:"required,oneof=read write admin"`
+	Collections []string     `json:"collections" validate:"dive,required"`
+}
+
+// issuedKey is returned once when a key is created or rotated
+type issuedKey struct {
+	*models.APIKey
+	Key string `json:"key"`
+}
+
+// Register mounts admin key management routes, keys are
+// managed within the namespace of the request by admin keys
+// not restricted to some collections
+func Register(router fiber.Router) {
+	keys := router.Group("/admin/keys", auth.RequireUnrestricted(models.ScopeAdmin))
+	keys.Get("/", list)
+	keys.Post("/", create)
+	keys.Delete("/:id", revoke)
+	keys.Post("/:id/rotate", rotate)
+}
+
+func list(c *fiber.Ctx) error {
+	keys, err := auth.List(c.UserContext(), auth.Namespace(c).ID)
+	if err != nil {
+		return err
+	}
+	return c.JSON(keys)
+}
+
+func create(c *fiber.Ctx) error {
+	var body createRequest
+	if err := models.BindBody(c, &body); err != nil {
+		return err
+	}
+	if !auth.Key(c).Covers(body.Scope, body.Collections) {
+		return fiber.NewError(fiber.StatusForbidden, "api key cannot grant more access than it holds")
+	}
+
+	plain, key, err := auth.Create(c.UserContext(), auth.Namespace(c).ID, body.Name, body.Scope, body.Collections)
+	if err != nil {
+		return err
+	}
+
+	logging.Ctx(c).Info("api key created", "key_id", key.ID, "scope", key.Scope)
+	return c.Status(fiber.StatusCreated).JSON(issuedKey{APIKey: key, Key: plain})
+}
+
+func revoke(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid key id")
+	}
+
+	key, err := auth.Revoke(c.UserContext(), auth.Namespace(c).ID, uint(id))
+	if err != nil {
+		return keyError(err)
+	}
+
+	logging.Ctx(c).Info("api key revoked", "key_id", key.ID)
+	return c.JSON(key)
+}
+
+func rotate(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid key id")
+	}
+
+	plain, key, err := auth.Rotate(c.UserContext(), auth.Namespace(c).ID, uint(id))
+	if err != nil {
+		return keyError(err)
+	}
+
+	logging.Ctx(c).Info("api key rotated", "key_id", key.ID)
+	return c.JSON(issuedKey{APIKey: key, Key: plain})
+}
+
+// map service errors onto HTTP errors
+func keyError(err error) error {
+	if errors.Is(err, auth.ErrKeyNotFound) {
+		return fiber.NewError(fiber.StatusNotFound, err.Error())
+	}
+	return err
+}
@@ -0,0 +1,113 @@
+package keys
+
+import (
+	"context"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/goccy/go-json"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/christian-nickerson/pangolin/control/internal/auth"
+	"github.com/christian-nickerson/pangolin/control/internal/configs"
+	"github.com/christian-nickerson/pangolin/control/internal/database"
+	"github.com/christian-nickerson/pangolin/control/internal/models"
+	"github.com/christian-nickerson/pangolin/control/internal/namespaces"
+)
+
+type KeysSuite struct {
+	suite.Suite
+	app       *fiber.App
+	namespace *models.Namespace
+}
+
+// set up a fresh database and app
+func (s *KeysSuite) SetupTest() {
+	config := configs.DatabaseConfig{Type: "sqlite", DBName: filepath.Join(s.T().TempDir(), "test")}
+	s.Require().NoError(database.Connect(config))
+	s.Require().NoError(database.Migrate())
+	namespace, err := namespaces.EnsureDefault(context.Background(), configs.Quotas{})
+	s.Require().NoError(err)
+	s.namespace = namespace
+
+	s.app = fiber.New(fiber.Config{ErrorHandler: models.ErrorHandler})
+	s.app.Use(auth.New(configs.Auth{Enabled: true, AdminKey: "bootstrap"}))
+	Register(s.app)
+}
+
+// send a request authenticated with key, decoding the response into out
+func (s *KeysSuite) request(method, path, key, body string, out interface{}) int {
+	request := httptest.NewRequest(method, path, strings.NewReader(body))
+	request.Header.Set("Authorization", "Bearer "+key)
+	request.Header.Set("Content-Type", "application/json")
+	response, err := s.app.Test(request)
+	s.Require().NoError(err)
+	if out != nil {
+		s.Require().NoError(json.NewDecoder(response.Body).Decode(out))
+	}
+	return response.StatusCode
+}
+
+// Test keys can be created, rotated and revoked through the admin routes
+func (s *KeysSuite) TestLifecycle() {
+	var created issuedKey
+	status := s.request("POST", "/admin/keys", "bootstrap", `{"name":"ops","scope":"admin"}`, &created)
+	s.Require().Equal(201, status)
+	s.Assert().NotEmpty(created.Key)
+
+	// new admin key can manage keys
+	var keys []models.APIKey
+	s.Assert().Equal(200, s.request("GET", "/admin/keys", created.Key, "", &keys))
+	s.Assert().Len(keys, 1)
+
+	var rotated issuedKey
+	s.Assert().Equal(200, s.request("POST", "/admin/keys/1/rotate", "bootstrap", "", &rotated))
+	s.Assert().NotEqual(created.Key, rotated.Key)
+	s.Assert().Equal(401, s.request("GET", "/admin/keys", created.Key, "", nil))
+
+	s.Assert().Equal(200, s.request("DELETE", "/admin/keys/1", "bootstrap", "", nil))
+	s.Assert().Equal(401, s.request("GET", "/admin/keys", rotated.Key, "", nil))
+	s.Assert().Equal(404, s.request("DELETE", "/admin/keys/9", "bootstrap", "", nil))
+}
+
+// Test non admin keys cannot manage keys
+func (s *KeysSuite) TestRequiresAdmin() {
+	key, _, err := auth.Create(context.Background(), s.namespace.ID, "reader", models.ScopeRead, nil)
+	s.Require().NoError(err)
+	s.Assert().Equal(403, s.request("GET", "/admin/keys", key, "", nil))
+}
+
+// Test admin keys restricted to some collections cannot manage keys
+func (s *KeysSuite) TestRequiresUnrestricted() {
+	key, _, err := auth.Create(context.Background(), s.namespace.ID, "docs admin", models.ScopeAdmin, []string{"docs"})
+	s.Require().NoError(err)
+	s.Assert().Equal(403, s.request("GET", "/admin/keys", key, "", nil))
+	s.Assert().Equal(403, s.request("POST", "/admin/keys", key, `{"name":"escalated","scope":"admin"}`, nil))
+	s.Assert().Equal(403, s.request("POST", "/admin/keys/1/rotate", key, "", nil))
+}
+
+// Test keys cannot be issued beyond the access of the issuing key
+func (s *KeysSuite) TestCoversIssued() {
+	restricted := &models.APIKey{Scope: models.ScopeWrite, Collections: []string{"docs", "notes"}}
+	s.Assert().True(restricted.Covers(models.ScopeRead, []string{"docs"}))
+	s.Assert().True(restricted.Covers(models.ScopeWrite, []string{"docs", "notes"}))
+	s.Assert().False(restricted.Covers(models.ScopeAdmin, []string{"docs"}))
+	s.Assert().False(restricted.Covers(models.ScopeRead, []string{"docs", "other"}))
+	s.Assert().False(restricted.Covers(models.ScopeRead, nil))
+
+	unrestricted := &models.APIKey{Scope: models.ScopeAdmin}
+	s.Assert().True(unrestricted.Covers(models.ScopeAdmin, nil))
+	s.Assert().True(unrestricted.Covers(models.ScopeRead, []string{"docs"}))
+}
+
+// Test invalid bodies are rejected
+func (s *KeysSuite) TestCreateValidation() {
+	s.Assert().Equal(422, s.request("POST", "/admin/keys", "bootstrap", `{"name":"ops","scope":"root"}`, nil))
+}
+
+func TestKeysSuite(t *testing.T) {
+	suite.Run(t, new(KeysSuite))
+}
@@ -0,0 +1,70 @@
+package mcp
+
+import (
+	"net"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/christian-nickerson/pangolin/control/internal/auth"
+	"github.com/christian-nickerson/pangolin/control/internal/mcp"
+)
+
+const protocolHeader = "Mcp-Protocol-Version"
+
+// Register mounts the MCP streamable HTTP transport at /mcp. Each POST is a
+// JSON-RPC message answered in the response, acting as the request's API key
+// within its namespace. Sessions and server sent event streams are not
+// offered, so GET and DELETE are not allowed. Requests from browsers must
+// come from a loopback origin or one of allowed.
+func Register(router fiber.Router, server *mcp.Server, allowed []string) {
+	router.Post("/mcp", handle(server, allowed))
+	router.Get("/mcp", notAllowed)
+	router.Delete("/mcp", notAllowed)
+}
+
+func handle(server *mcp.Server, allowed []string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if origin := c.Get(fiber.HeaderOrigin); origin != "" && !allowedOrigin(origin, allowed) {
+			return fiber.NewError(fiber.StatusForbidden, "origin "+origin+" is not allowed")
+		}
+		if version := c.Get(protocolHeader); version != "" && !mcp.Supports(version) {
+			return fiber.NewError(fiber.StatusBadRequest, "unsupported mcp protocol version "+version)
+		}
+
+		session := mcp.Session{Key: auth.Key(c), Namespace: auth.Namespace(c)}
+		answer := server.Handle(c.UserContext(), session, c.Body())
+		if answer == nil {
+			return c.SendStatus(fiber.StatusAccepted)
+		}
+
+		c.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+		return c.Send(answer)
+	}
+}
+
+func notAllowed(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderAllow, fiber.MethodPost)
+	return fiber.NewError(fiber.StatusMethodNotAllowed, "mcp event streams are not offered")
+}
+
+// whether a browser at origin may call tools. A page whose name rebinds to
+// this instance keeps its own origin, so only loopback origins and those
+// configured are trusted.
+func allowedOrigin(origin string, allowed []string) bool {
+	if slices.Contains(allowed, "*") || slices.Contains(allowed, strings.TrimSuffix(origin, "/")) {
+		return true
+	}
+	parsed, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	host := parsed.Hostname()
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
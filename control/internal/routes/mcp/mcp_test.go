@@ -0,0 +1,103 @@
+package mcp
+
+import (
+	"context"
+	"io"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/christian-nickerson/pangolin/control/internal/auth"
+	"github.com/christian-nickerson/pangolin/control/internal/collections"
+	"github.com/christian-nickerson/pangolin/control/internal/configs"
+	"github.com/christian-nickerson/pangolin/control/internal/database"
+	"github.com/christian-nickerson/pangolin/control/internal/index"
+	"github.com/christian-nickerson/pangolin/control/internal/mcp"
+	"github.com/christian-nickerson/pangolin/control/internal/models"
+	"github.com/christian-nickerson/pangolin/control/internal/namespaces"
+)
+
+type MCPSuite struct {
+	suite.Suite
+	app *fiber.App
+	key string
+}
+
+// set up an app serving MCP, with a key reading collection "docs"
+func (s *MCPSuite) SetupTest() {
+	ctx := context.Background()
+	config := configs.DatabaseConfig{Type: "sqlite", DBName: filepath.Join(s.T().TempDir(), "test")}
+	s.Require().NoError(database.Connect(config))
+	s.Require().NoError(database.Migrate())
+	namespace, err := namespaces.EnsureDefault(ctx, configs.Quotas{})
+	s.Require().NoError(err)
+
+	for _, name := range []string{"docs", "other"} {
+		collection := &models.Collection{Name: name, Model: "test", ChunkSize: 8, Metric: models.MetricCosine}
+		s.Require().NoError(collections.Create(ctx, namespace, collection))
+		index.Drop(collection.ID)
+	}
+	s.key, _, err = auth.Create(ctx, namespace.ID, "agent", models.ScopeRead, []string{"docs"})
+	s.Require().NoError(err)
+
+	s.app = fiber.New(fiber.Config{ErrorHandler: models.ErrorHandler})
+	s.app.Use(auth.New(configs.Auth{Enabled: true, AdminKey: "bootstrap"}))
+	Register(s.app, mcp.NewServer(nil, nil), []string{"https://app.example.com"})
+}
+
+// send a message authenticated with key, returning the status and body
+func (s *MCPSuite) request(method, key, body string, headers ...string) (int, string) {
+	request := httptest.NewRequest(method, "/mcp", strings.NewReader(body))
+	request.Header.Set("Authorization", "Bearer "+key)
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("Accept", "application/json, text/event-stream")
+	for i := 0; i+1 < len(headers); i += 2 {
+		request.Header.Set(headers[i], headers[i+1])
+	}
+	response, err := s.app.Test(request)
+	s.Require().NoError(err)
+	data, err := io.ReadAll(response.Body)
+	s.Require().NoError(err)
+	return response.StatusCode, string(data)
+}
+
+// Test messages are answered as the request's key
+func (s *MCPSuite) TestTransport() {
+	status, body := s.request("POST", s.key, `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"list_collections"}}`)
+	s.Equal(200, status)
+	s.Contains(body, `\"name\": \"docs\"`)
+	s.NotContains(body, `\"name\": \"other\"`)
+
+	status, _ = s.request("POST", s.key, `{"jsonrpc":"2.0","method":"notifications/initialized"}`)
+	s.Equal(202, status)
+
+	status, _ = s.request("POST", s.key, `{"jsonrpc":"2.0","id":1,"method":"ping"}`, "MCP-Protocol-Version", "1999-01-01")
+	s.Equal(400, status)
+
+	status, _ = s.request("GET", s.key, "")
+	s.Equal(405, status)
+
+	status, _ = s.request("POST", "pgl_wrong_key", `{"jsonrpc":"2.0","id":1,"method":"ping"}`)
+	s.Equal(401, status)
+}
+
+// Test browsers are only served from loopback or allowed origins
+func (s *MCPSuite) TestOrigin() {
+	ping := `{"jsonrpc":"2.0","id":1,"method":"ping"}`
+	for _, origin := range []string{"http://localhost:5173", "http://127.0.0.1:3000", "http://[::1]", "https://app.example.com"} {
+		status, _ := s.request("POST", s.key, ping, "Origin", origin)
+		s.Equal(200, status, origin)
+	}
+	for _, origin := range []string{"http://evil.example.com:3000", "https://app.example.com.evil.com", "null"} {
+		status, _ := s.request("POST", s.key, ping, "Origin", origin)
+		s.Equal(403, status, origin)
+	}
+}
+
+func TestMCPSuite(t *testing.T) {
+	suite.Run(t, new(MCPSuite))
+}
@@ -0,0 +1,133 @@
+package namespaces
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/christian-nickerson/pangolin/control/internal/auth"
+	"github.com/christian-nickerson/pangolin/control/internal/logging"
+	"github.com/christian-nickerson/pangolin/control/internal/models"
+	"github.com/christian-nickerson/pangolin/control/internal/namespaces"
+)
+
+const namespaceParam = "namespace"
+
+type quotasRequest struct {
+	MaxCollections int64 `json:"max_collections" validate:"gte=0"`
+	MaxDocuments   int64 `json:"max_documents" validate:"gte=0"`
+	MaxVectorBytes int64 `json:"max_vector_bytes" validate:"gte=0"`
+}
+
+type createRequest struct {
+	Name string `json:"name" validate:"required,max=64,hostname_rfc1123"`
+	quotasRequest
+}
+
+// namespaceUsage reports a namespace with its current usage
+type namespaceUsage struct {
+	*models.Namespace
+	Usage models.Usage `json:"usage"`
+}
+
+// Register mounts namespace management routes, restricted to the cluster admin
+func Register(router fiber.Router) {
+	group := router.Group("/admin/namespaces", auth.RequireCluster)
+	group.Get("/", list)
+	group.Post("/", create)
+	group.Get("/:namespace", get)
+	group.Put("/:namespace/quotas", updateQuotas)
+	group.Delete("/:namespace", remove)
+}
+
+func list(c *fiber.Ctx) error {
+	all, err := namespaces.List(c.UserContext())
+	if err != nil {
+		return err
+	}
+	return c.JSON(all)
+}
+
+func create(c *fiber.Ctx) error {
+	var body createRequest
+	if err := models.BindBody(c, &body); err != nil {
+		return err
+	}
+
+	namespace := &models.Namespace{
+		Name:           body.Name,
+		MaxCollections: body.MaxCollections,
+		MaxDocuments:   body.MaxDocuments,
+		MaxVectorBytes: body.MaxVectorBytes,
+	}
+	if err := namespaces.Create(c.UserContext(), namespace); err != nil {
+		return namespaceError(err)
+	}
+
+	logging.Ctx(c).Info("namespace created", "namespace", namespace.Name)
+	return c.Status(fiber.StatusCreated).JSON(namespace)
+}
+
+func get(c *fiber.Ctx) error {
+	namespace, err := namespaces.Get(c.UserContext(), c.Params(namespaceParam))
+	if err != nil {
+		return namespaceError(err)
+	}
+
+	usage, err := namespaces.GetUsage(c.UserContext(), namespace)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(namespaceUsage{Namespace: namespace, Usage: usage})
+}
+
+func updateQuotas(c *fiber.Ctx) error {
+	var body quotasRequest
+	if err := models.BindBody(c, &body); err != nil {
+		return err
+	}
+
+	namespace, err := namespaces.Get(c.UserContext(), c.Params(namespaceParam))
+	if err != nil {
+		return namespaceError(err)
+	}
+
+	namespace.MaxCollections = body.MaxCollections
+	namespace.MaxDocuments = body.MaxDocuments
+	namespace.MaxVectorBytes = body.MaxVectorBytes
+	if err := namespaces.UpdateQuotas(c.UserContext(), namespace); err != nil {
+		return err
+	}
+
+	logging.Ctx(c).Info("namespace quotas updated", "namespace", namespace.Name)
+	return c.JSON(namespace)
+}
+
+func remove(c *fiber.Ctx) error {
+	namespace, err := namespaces.Get(c.UserContext(), c.Params(namespaceParam))
+	if err != nil {
+		return namespaceError(err)
+	}
+	if namespace.Name == models.DefaultNamespace {
+		return fiber.NewError(fiber.StatusBadRequest, "the default namespace cannot be deleted")
+	}
+
+	if err := namespaces.Delete(c.UserContext(), namespace); err != nil {
+		return namespaceError(err)
+	}
+
+	logging.Ctx(c).Info("namespace deleted", "namespace", namespace.Name)
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// map service errors onto HTTP errors
+func namespaceError(err error) error {
+	switch {
+	case errors.Is(err, namespaces.ErrNamespaceNotFound):
+		return fiber.NewError(fiber.StatusNotFound, err.Error())
+	case errors.Is(err, namespaces.ErrNamespaceExists), errors.Is(err, namespaces.ErrNamespaceNotEmpty):
+		return fiber.NewError(fiber.StatusConflict, err.Error())
+	}
+	return err
+}
@@ -0,0 +1,40 @@
+package nodes
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/christian-nickerson/pangolin/control/internal/auth"
+	"github.com/christian-nickerson/pangolin/control/internal/nodes"
+)
+
+// Register mounts index node membership routes, restricted to the cluster admin
+func Register(router fiber.Router, registry *nodes.Registry) {
+	group := router.Group("/nodes", auth.RequireCluster)
+	group.Get("/", list(registry))
+	group.Get("/:node", get(registry))
+}
+
+func list(registry *nodes.Registry) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		all, err := registry.List(c.UserContext())
+		if err != nil {
+			return err
+		}
+		return c.JSON(all)
+	}
+}
+
+func get(registry *nodes.Registry) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		node, err := registry.Get(c.UserContext(), c.Params("node"))
+		if errors.Is(err, nodes.ErrNodeNotFound) {
+			return fiber.NewError(fiber.StatusNotFound, err.Error())
+		}
+		if err != nil {
+			return err
+		}
+		return c.JSON(node)
+	}
+}
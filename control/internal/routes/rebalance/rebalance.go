@@ -0,0 +1,73 @@
+package rebalance
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/christian-nickerson/pangolin/control/internal/auth"
+	"github.com/christian-nickerson/pangolin/control/internal/logging"
+	"github.com/christian-nickerson/pangolin/control/internal/models"
+	"github.com/christian-nickerson/pangolin/control/internal/shards"
+)
+
+type startRequest struct {
+	// DryRun returns the plan without moving any shards
+	DryRun bool `json:"dry_run"`
+}
+
+// Register mounts shard rebalancing routes, restricted to the cluster admin
+func Register(router fiber.Router, rebalancer *shards.Rebalancer) {
+	group := router.Group("/admin/rebalance", auth.RequireCluster)
+	group.Get("/", status(rebalancer))
+	group.Get("/plan", plan)
+	group.Post("/", start(rebalancer))
+	group.Post("/cancel", cancel(rebalancer))
+}
+
+func status(rebalancer *shards.Rebalancer) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		return c.JSON(rebalancer.Status())
+	}
+}
+
+func plan(c *fiber.Ctx) error {
+	planned, err := shards.PlanRebalance(c.UserContext())
+	if err != nil {
+		return err
+	}
+	return c.JSON(planned)
+}
+
+func start(rebalancer *shards.Rebalancer) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var body startRequest
+		if len(c.Body()) > 0 {
+			if err := models.BindBody(c, &body); err != nil {
+				return err
+			}
+		}
+
+		if body.DryRun {
+			return plan(c)
+		}
+
+		planned, err := rebalancer.Start(c.UserContext())
+		if errors.Is(err, shards.ErrRebalancing) {
+			return fiber.NewError(fiber.StatusConflict, err.Error())
+		}
+		if err != nil {
+			return err
+		}
+
+		logging.Ctx(c).Info("rebalance started", "moves", len(planned.Moves))
+		return c.Status(fiber.StatusAccepted).JSON(rebalancer.Status())
+	}
+}
+
+func cancel(rebalancer *shards.Rebalancer) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		rebalancer.Cancel()
+		return c.JSON(rebalancer.Status())
+	}
+}
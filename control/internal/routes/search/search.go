@@ -0,0 +1,50 @@
+package search
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/christian-nickerson/pangolin/control/internal/auth"
+	"github.com/christian-nickerson/pangolin/control/internal/collections"
+	embeddings "github.com/christian-nickerson/pangolin/control/internal/embedding"
+	"github.com/christian-nickerson/pangolin/control/internal/models"
+	"github.com/christian-nickerson/pangolin/control/internal/search"
+	"github.com/christian-nickerson/pangolin/control/internal/shards"
+)
+
+type searchRequest struct {
+	Query string `json:"query" validate:"required"`
+	K     int    `json:"k" validate:"omitempty,min=1,max=100"`
+}
+
+// Register mounts collection search routes, queries are embedded with embed
+func Register(router fiber.Router, embed embeddings.Embedder) {
+	router.Post("/collections/:collection/search", auth.Require(models.ScopeRead), query(embed))
+}
+
+func query(embed embeddings.Embedder) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		body := searchRequest{K: 10}
+		if err := models.BindBody(c, &body); err != nil {
+			return err
+		}
+
+		collection, err := collections.Get(c.UserContext(), auth.Namespace(c), c.Params(models.CollectionParam))
+		if errors.Is(err, collections.ErrCollectionNotFound) {
+			return fiber.NewError(fiber.StatusNotFound, err.Error())
+		}
+		if err != nil {
+			return err
+		}
+
+		response, err := search.Search(c.UserContext(), embed, collection, body.Query, body.K)
+		if errors.Is(err, shards.ErrInsufficientCoverage) {
+			return fiber.NewError(fiber.StatusServiceUnavailable, err.Error())
+		}
+		if err != nil {
+			return err
+		}
+		return c.JSON(response)
+	}
+}
@@ -0,0 +1,206 @@
+package snapshots
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/christian-nickerson/pangolin/control/internal/auth"
+	"github.com/christian-nickerson/pangolin/control/internal/collections"
+	"github.com/christian-nickerson/pangolin/control/internal/configs"
+	"github.com/christian-nickerson/pangolin/control/internal/logging"
+	"github.com/christian-nickerson/pangolin/control/internal/models"
+	"github.com/christian-nickerson/pangolin/control/internal/shards"
+	"github.com/christian-nickerson/pangolin/control/internal/snapshots"
+	"github.com/christian-nickerson/pangolin/control/internal/storage"
+)
+
+const (
+	snapshotParam = "snapshot"
+	prefix        = "/admin/snapshots"
+
+	// UploadPath receives archives, which are streamed rather than held
+	// to the API body limit
+	UploadPath = prefix + "/upload"
+)
+
+type createRequest struct {
+	Collection string `json:"collection" validate:"required"`
+}
+
+type restoreRequest struct {
+	// Name of the restored collection, the snapshotted name when empty
+	Name string `json:"name" validate:"omitempty,max=64,hostname_rfc1123"`
+}
+
+// Register mounts admin snapshot routes. Snapshots are kept in store within
+// the namespace of the request, and may be downloaded and uploaded to
+// restore on another deployment, within the archive limits of config.
+func Register(router fiber.Router, store storage.BlobStore, config configs.Snapshots) {
+	group := router.Group(prefix, auth.Require(models.ScopeAdmin))
+	group.Get("/", list(store))
+	group.Post("/", create(store))
+	group.Post(strings.TrimPrefix(UploadPath, prefix), upload(store, config))
+	group.Get("/:snapshot", download(store))
+	group.Post("/:snapshot/restore", restore(store, config))
+	group.Delete("/:snapshot", remove(store))
+}
+
+func list(store storage.BlobStore) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		all, err := snapshots.List(c.UserContext(), store, auth.Namespace(c))
+		if err != nil {
+			return err
+		}
+
+		// only list snapshots of collections the key may administer
+		visible := make([]snapshots.Manifest, 0, len(all))
+		for _, manifest := range all {
+			if auth.Key(c).Allows(models.ScopeAdmin, manifest.Collection) {
+				visible = append(visible, manifest)
+			}
+		}
+		return c.JSON(visible)
+	}
+}
+
+func create(store storage.BlobStore) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var body createRequest
+		if err := models.BindBody(c, &body); err != nil {
+			return err
+		}
+		if !auth.Key(c).Allows(models.ScopeAdmin, body.Collection) {
+			return fiber.NewError(fiber.StatusForbidden, "api key lacks admin permission")
+		}
+
+		collection, err := collections.Get(c.UserContext(), auth.Namespace(c), body.Collection)
+		if err != nil {
+			return snapshotError(err)
+		}
+
+		manifest, err := snapshots.Create(c.UserContext(), store, auth.Namespace(c), collection)
+		if err != nil {
+			return snapshotError(err)
+		}
+
+		logging.Ctx(c).Info("snapshot created", "snapshot_id", manifest.ID, "collection", collection.Name, "size", manifest.Size)
+		return c.Status(fiber.StatusCreated).JSON(manifest)
+	}
+}
+
+func upload(store storage.BlobStore, config configs.Snapshots) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		// the body is only buffered when the server does not stream it
+		var archive io.Reader = c.Context().RequestBodyStream()
+		if archive == nil {
+			archive = bytes.NewReader(c.Body())
+		}
+		manifest, err := snapshots.Import(c.UserContext(), store, auth.Namespace(c), archive, config)
+		if err != nil {
+			return snapshotError(err)
+		}
+
+		logging.Ctx(c).Info("snapshot uploaded", "snapshot_id", manifest.ID, "collection", manifest.Collection, "size", manifest.Size)
+		return c.Status(fiber.StatusCreated).JSON(manifest)
+	}
+}
+
+func download(store storage.BlobStore) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		manifest, err := lookup(c, store)
+		if err != nil {
+			return err
+		}
+
+		archive, err := snapshots.Open(c.UserContext(), store, auth.Namespace(c), manifest.ID)
+		if err != nil {
+			return snapshotError(err)
+		}
+
+		c.Set(fiber.HeaderContentType, "application/gzip")
+		c.Attachment(manifest.Collection + "-" + manifest.ID + ".tar.gz")
+		return c.SendStream(archive, int(manifest.Size))
+	}
+}
+
+func restore(store storage.BlobStore, config configs.Snapshots) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var body restoreRequest
+		if err := models.BindBody(c, &body); err != nil {
+			return err
+		}
+
+		manifest, err := lookup(c, store)
+		if err != nil {
+			return err
+		}
+		if body.Name == "" {
+			body.Name = manifest.Collection
+		}
+		if !auth.Key(c).Allows(models.ScopeAdmin, body.Name) {
+			return fiber.NewError(fiber.StatusForbidden, "api key lacks admin permission")
+		}
+
+		archive, err := snapshots.Open(c.UserContext(), store, auth.Namespace(c), manifest.ID)
+		if err != nil {
+			return snapshotError(err)
+		}
+		defer archive.Close()
+
+		collection, err := snapshots.Restore(c.UserContext(), auth.Namespace(c), archive, body.Name, config)
+		if err != nil {
+			return snapshotError(err)
+		}
+
+		logging.Ctx(c).Info("snapshot restored", "snapshot_id", manifest.ID, "collection", collection.Name)
+		return c.Status(fiber.StatusCreated).JSON(collection)
+	}
+}
+
+func remove(store storage.BlobStore) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		manifest, err := lookup(c, store)
+		if err != nil {
+			return err
+		}
+		if err := snapshots.Delete(c.UserContext(), store, auth.Namespace(c), manifest.ID); err != nil {
+			return snapshotError(err)
+		}
+
+		logging.Ctx(c).Info("snapshot deleted", "snapshot_id", manifest.ID)
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+}
+
+// resolve the snapshot in the route, checking the key may administer its collection
+func lookup(c *fiber.Ctx, store storage.BlobStore) (*snapshots.Manifest, error) {
+	manifest, err := snapshots.Get(c.UserContext(), store, auth.Namespace(c), c.Params(snapshotParam))
+	if err != nil {
+		return nil, snapshotError(err)
+	}
+	if !auth.Key(c).Allows(models.ScopeAdmin, manifest.Collection) {
+		return nil, fiber.NewError(fiber.StatusForbidden, "api key lacks admin permission")
+	}
+	return manifest, nil
+}
+
+// map service errors onto HTTP errors
+func snapshotError(err error) error {
+	switch {
+	case errors.Is(err, snapshots.ErrSnapshotNotFound), errors.Is(err, collections.ErrCollectionNotFound):
+		return fiber.NewError(fiber.StatusNotFound, err.Error())
+	case errors.Is(err, collections.ErrCollectionExists), errors.Is(err, snapshots.ErrCollectionChanged):
+		return fiber.NewError(fiber.StatusConflict, err.Error())
+	case errors.Is(err, shards.ErrNoNodes), errors.Is(err, shards.ErrNoTransport), errors.Is(err, shards.ErrUnplaced):
+		return fiber.NewError(fiber.StatusServiceUnavailable, err.Error())
+	case errors.Is(err, snapshots.ErrSnapshotTooLarge):
+		return fiber.NewError(fiber.StatusRequestEntityTooLarge, err.Error())
+	case errors.Is(err, snapshots.ErrInvalidSnapshot):
+		return fiber.NewError(fiber.StatusUnprocessableEntity, err.Error())
+	}
+	return err
+}
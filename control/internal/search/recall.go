@@ -0,0 +1,129 @@
+package search
+
+import (
+	"context"
+	"math/rand/v2"
+	"strconv"
+	"sync"
+
+	"github.com/charmbracelet/log"
+
+	"github.com/christian-nickerson/pangolin/control/internal/configs"
+	"github.com/christian-nickerson/pangolin/control/internal/index"
+	"github.com/christian-nickerson/pangolin/control/internal/metrics"
+	"github.com/christian-nickerson/pangolin/control/internal/models"
+	"github.com/christian-nickerson/pangolin/control/internal/shards"
+)
+
+// sample is a served search awaiting a recall check
+type sample struct {
+	collection models.Collection
+	query      models.Vector
+	hits       []index.Hit
+}
+
+var (
+	// one sample waits while another is checked, the rest are dropped so
+	// checks never queue behind each other
+	samples    = make(chan sample, 1)
+	sampleRate float64
+	stopRecall context.CancelFunc
+	checks     sync.WaitGroup
+)
+
+// StartRecall checks a fraction of served searches in the background
+// against a brute force search of every vector of the collection,
+// observing their recall. Checks run one at a time.
+func StartRecall(config configs.Search) {
+	sampleRate = config.RecallSampleRate
+	if sampleRate <= 0 {
+		return
+	}
+
+	var ctx context.Context
+	ctx, stopRecall = context.WithCancel(context.Background())
+	checks.Add(1)
+	go func() {
+		defer checks.Done()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case s := <-samples:
+				recall, err := checkRecall(ctx, s)
+				if err != nil {
+					if ctx.Err() == nil {
+						log.Warn("search recall check failed", "collection_id", s.collection.ID, "err", err)
+					}
+					continue
+				}
+				metrics.SearchRecall.WithLabelValues(strconv.FormatUint(uint64(s.collection.ID), 10)).Observe(recall)
+			}
+		}
+	}()
+}
+
+// StopRecall stops checking samples, waiting for a running check to finish
+func StopRecall(ctx context.Context) error {
+	if stopRecall == nil {
+		return nil
+	}
+	stopRecall()
+
+	done := make(chan struct{})
+	go func() {
+		checks.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// offer a served search for a recall check at the sample rate
+func sampleRecall(collection *models.Collection, query models.Vector, hits []index.Hit) {
+	if sampleRate <= 0 || len(hits) == 0 || rand.Float64() >= sampleRate {
+		return
+	}
+	select {
+	case samples <- sample{collection: *collection, query: query, hits: hits}:
+	default:
+	}
+}
+
+// the fraction of the exact nearest vectors a served search returned,
+// streaming every shard through a brute force ranking
+func checkRecall(ctx context.Context, s sample) (float64, error) {
+	ranker := index.NewRanker(s.collection.Metric, s.query, len(s.hits))
+	for shard := range max(s.collection.Shards, 1) {
+		err := shards.Segment(ctx, &s.collection, shard, func(entries []index.Entry) error {
+			for _, entry := range entries {
+				ranker.Add(entry.ID, entry.Vector)
+			}
+			return nil
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	exact := ranker.Hits()
+	if len(exact) == 0 {
+		return 1, nil
+	}
+	served := make(map[uint64]bool, len(s.hits))
+	for _, hit := range s.hits {
+		served[hit.ID] = true
+	}
+	var found int
+	for _, hit := range exact {
+		if served[hit.ID] {
+			found++
+		}
+	}
+	return float64(found) / float64(len(exact)), nil
+}
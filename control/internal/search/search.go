@@ -0,0 +1,137 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+
+	"github.com/christian-nickerson/pangolin/control/internal/database"
+	embeddings "github.com/christian-nickerson/pangolin/control/internal/embedding"
+	"github.com/christian-nickerson/pangolin/control/internal/index"
+	"github.com/christian-nickerson/pangolin/control/internal/metrics"
+	"github.com/christian-nickerson/pangolin/control/internal/models"
+	"github.com/christian-nickerson/pangolin/control/internal/shards"
+	"github.com/christian-nickerson/pangolin/control/internal/tracing"
+)
+
+var ErrEmptyQuery = errors.New("query has no embeddable text")
+
+// Result is a chunk matching a query, higher scores are closer
+type Result struct {
+	ChunkID    uint              `json:"chunk_id"`
+	DocumentID string            `json:"document_id"`
+	Version    int               `json:"version"`
+	Position   int               `json:"position"`
+	Text       string            `json:"text"`
+	Score      float64           `json:"score"`
+	Metadata   map[string]string `json:"metadata,omitempty"`
+}
+
+// Response holds the results of a search, partial when shards of a
+// sharded collection failed to answer
+type Response struct {
+	Results []Result `json:"results"`
+	Partial bool     `json:"partial"`
+}
+
+// Search embeds query with the collection's model and returns its k nearest chunks
+func Search(ctx context.Context, embed embeddings.Embedder, collection *models.Collection, query string, k int) (*Response, error) {
+	vectors, err := embed(ctx, []string{query}, collection.Model)
+	if err != nil {
+		return nil, err
+	}
+	if len(vectors) == 0 {
+		return nil, ErrEmptyQuery
+	}
+
+	hits, partial, err := searchIndex(ctx, collection, vectors[0], k)
+	if err != nil {
+		return nil, err
+	}
+	response := &Response{Results: []Result{}, Partial: partial}
+	if len(hits) == 0 {
+		return response, nil
+	}
+
+	response.Results, err = resolve(ctx, collection, hits)
+	return response, err
+}
+
+// search the collection index or shards, timing and tracing the lookup
+func searchIndex(ctx context.Context, collection *models.Collection, query models.Vector, k int) ([]index.Hit, bool, error) {
+	ctx, span := tracing.Tracer.Start(ctx, "index.search")
+	defer span.End()
+	span.SetAttributes(
+		attribute.Int("pangolin.collection_id", int(collection.ID)),
+		attribute.Int("pangolin.k", k),
+		attribute.Int("pangolin.shards", collection.Shards),
+	)
+
+	start := time.Now()
+	hits, partial, err := shards.Search(ctx, collection, query, k)
+	metrics.SearchLatency.WithLabelValues(strconv.FormatUint(uint64(collection.ID), 10)).Observe(time.Since(start).Seconds())
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	} else {
+		sampleRecall(collection, query, hits)
+	}
+	span.SetAttributes(attribute.Bool("pangolin.partial", partial))
+	return hits, partial, err
+}
+
+// load the chunks and documents behind index hits, keeping hit order
+func resolve(ctx context.Context, collection *models.Collection, hits []index.Hit) ([]Result, error) {
+	ids := make([]uint, len(hits))
+	for i, hit := range hits {
+		ids[i] = uint(hit.ID)
+	}
+
+	var chunks []models.Chunk
+	err := database.DB.WithContext(ctx).
+		Where("collection_id = ? AND id IN ?", collection.ID, ids).
+		Find(&chunks).Error
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[uint]models.Chunk, len(chunks))
+	documentIDs := make([]uint, 0, len(chunks))
+	for _, chunk := range chunks {
+		byID[chunk.ID] = chunk
+		documentIDs = append(documentIDs, chunk.DocumentID)
+	}
+
+	var documents []models.Document
+	if err := database.DB.WithContext(ctx).Where("id IN ?", documentIDs).Find(&documents).Error; err != nil {
+		return nil, err
+	}
+	byDocument := make(map[uint]models.Document, len(documents))
+	for _, document := range documents {
+		byDocument[document.ID] = document
+	}
+
+	results := make([]Result, 0, len(hits))
+	for _, hit := range hits {
+		// skip vectors whose chunk was deleted since the search
+		chunk, ok := byID[uint(hit.ID)]
+		if !ok {
+			continue
+		}
+		document := byDocument[chunk.DocumentID]
+		results = append(results, Result{
+			ChunkID:    chunk.ID,
+			DocumentID: document.ExternalID,
+			Version:    document.Version,
+			Position:   chunk.Position,
+			Text:       chunk.Text,
+			Score:      hit.Score,
+			Metadata:   document.Metadata,
+		})
+	}
+	return results, nil
+}
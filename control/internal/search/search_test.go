@@ -0,0 +1,90 @@
+package search
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/christian-nickerson/pangolin/control/internal/configs"
+	"github.com/christian-nickerson/pangolin/control/internal/database"
+	"github.com/christian-nickerson/pangolin/control/internal/index"
+	"github.com/christian-nickerson/pangolin/control/internal/metrics"
+	"github.com/christian-nickerson/pangolin/control/internal/models"
+)
+
+// embed every text onto the x axis
+func embedX(_ context.Context, texts []string, _ string) ([]models.Vector, error) {
+	vectors := make([]models.Vector, len(texts))
+	for i := range texts {
+		vectors[i] = models.Vector{1, 0}
+	}
+	return vectors, nil
+}
+
+// assert search returns the nearest chunks with their document metadata
+func TestSearch(t *testing.T) {
+	config := configs.DatabaseConfig{Type: "sqlite", DBName: filepath.Join(t.TempDir(), "test")}
+	require.NoError(t, database.Connect(config))
+	require.NoError(t, database.Migrate())
+
+	collection := &models.Collection{Name: "docs", Model: "test", ChunkSize: 8, Metric: models.MetricCosine}
+	require.NoError(t, database.DB.Create(collection).Error)
+	index.Drop(collection.ID)
+
+	document := &models.Document{CollectionID: collection.ID, ExternalID: "doc", Version: 1, Metadata: map[string]string{"source": "a"}}
+	require.NoError(t, database.DB.Create(document).Error)
+	chunks := []models.Chunk{
+		{CollectionID: collection.ID, DocumentID: document.ID, Position: 0, Text: "near"},
+		{CollectionID: collection.ID, DocumentID: document.ID, Position: 1, Text: "far"},
+	}
+	require.NoError(t, database.DB.Create(&chunks).Error)
+
+	idx := index.For(collection)
+	require.NoError(t, idx.Upsert(uint64(chunks[0].ID), models.Vector{1, 0.1}))
+	require.NoError(t, idx.Upsert(uint64(chunks[1].ID), models.Vector{0, 1}))
+	// a vector whose chunk no longer exists is skipped
+	require.NoError(t, idx.Upsert(999, models.Vector{1, 0}))
+
+	response, err := Search(context.Background(), embedX, collection, "query", 3)
+	require.NoError(t, err)
+	assert.False(t, response.Partial)
+	results := response.Results
+	require.Len(t, results, 2)
+	assert.Equal(t, "near", results[0].Text)
+	assert.Equal(t, "far", results[1].Text)
+	assert.Equal(t, "doc", results[0].DocumentID)
+	assert.Equal(t, "a", results[0].Metadata["source"])
+	assert.Greater(t, results[0].Score, results[1].Score)
+}
+
+// assert sampled searches are checked against a brute force search
+func TestRecall(t *testing.T) {
+	config := configs.DatabaseConfig{Type: "sqlite", DBName: filepath.Join(t.TempDir(), "test")}
+	require.NoError(t, database.Connect(config))
+	require.NoError(t, database.Migrate())
+
+	collection := &models.Collection{Name: "docs", Model: "test", ChunkSize: 8, Metric: models.MetricDot}
+	require.NoError(t, database.DB.Create(collection).Error)
+	index.Drop(collection.ID)
+	idx := index.For(collection)
+	for id := range uint64(4) {
+		require.NoError(t, idx.Upsert(id, models.Vector{float64(id), 0}))
+	}
+
+	// a search missing one of the two nearest vectors has half recall
+	served := sample{collection: *collection, query: models.Vector{1, 0}, hits: []index.Hit{{ID: 3, Score: 3}, {ID: 1, Score: 1}}}
+	recall, err := checkRecall(context.Background(), served)
+	require.NoError(t, err)
+	assert.Equal(t, 0.5, recall)
+
+	StartRecall(configs.Search{RecallSampleRate: 1})
+	t.Cleanup(func() { StopRecall(context.Background()) })
+	_, err = Search(context.Background(), embedX, collection, "query", 2)
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool { return testutil.CollectAndCount(metrics.SearchRecall) == 1 }, time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,499 @@
+package shards
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"gorm.io/gorm"
+
+	"github.com/christian-nickerson/pangolin/control/internal/configs"
+	"github.com/christian-nickerson/pangolin/control/internal/consensus"
+	"github.com/christian-nickerson/pangolin/control/internal/database"
+	"github.com/christian-nickerson/pangolin/control/internal/index"
+	"github.com/christian-nickerson/pangolin/control/internal/models"
+)
+
+// rounds of catching up on logged writes before pausing writes to flip
+const catchUpRounds = 3
+
+var ErrRebalancing = errors.New("a rebalance is already running")
+
+// Move relocates a shard replica from one node to another
+type Move struct {
+	Shard models.ShardRef `json:"shard"`
+	From  string          `json:"from"`
+	To    string          `json:"to"`
+}
+
+// Plan is the target number of shard replicas on each alive node and
+// the fewest moves reaching it from the current placement
+type Plan struct {
+	Current map[string]int `json:"current"`
+	Target  map[string]int `json:"target"`
+	Moves   []Move         `json:"moves"`
+}
+
+// PlanRebalance plans moves spreading shard replicas over alive nodes in
+// proportion to their memory, within their shard limits. Only shards with
+// every replica ready are moved, the rest are left to repair.
+func PlanRebalance(ctx context.Context) (*Plan, error) {
+	nodes, _, err := candidates(ctx)
+	if err != nil {
+		return nil, err
+	}
+	alive := make(map[string]bool, len(nodes))
+	for _, node := range nodes {
+		alive[node.ID] = true
+	}
+
+	var placements []models.ShardPlacement
+	if err := database.DB.WithContext(ctx).Order("collection_id, shard, node_id").Find(&placements).Error; err != nil {
+		return nil, err
+	}
+
+	unready := map[models.ShardRef]bool{}
+	for _, placement := range placements {
+		if placement.State != models.ReplicaReady || !alive[placement.NodeID] {
+			unready[placement.Ref()] = true
+		}
+	}
+
+	plan := &Plan{Current: map[string]int{}, Moves: []Move{}}
+	hosted := map[string]map[models.ShardRef]bool{}
+	movable := map[string][]models.ShardRef{}
+	for _, node := range nodes {
+		plan.Current[node.ID] = 0
+		hosted[node.ID] = map[models.ShardRef]bool{}
+	}
+	total := 0
+	for _, placement := range placements {
+		if !alive[placement.NodeID] {
+			continue
+		}
+		total++
+		plan.Current[placement.NodeID]++
+		hosted[placement.NodeID][placement.Ref()] = true
+		if !unready[placement.Ref()] {
+			movable[placement.NodeID] = append(movable[placement.NodeID], placement.Ref())
+		}
+	}
+	plan.Target = targets(nodes, total)
+
+	current := make(map[string]int, len(plan.Current))
+	for id, count := range plan.Current {
+		current[id] = count
+	}
+	for {
+		move, ok := nextMove(nodes, current, plan.Target, hosted, movable)
+		if !ok {
+			break
+		}
+		plan.Moves = append(plan.Moves, move)
+		current[move.From]--
+		current[move.To]++
+		delete(hosted[move.From], move.Shard)
+		hosted[move.To][move.Shard] = true
+	}
+	return plan, nil
+}
+
+// share total replicas between nodes by weight, one at a time to the node
+// furthest below its share which still has room
+func targets(nodes []models.Node, total int) map[string]int {
+	var known uint64
+	var reporting int
+	for _, node := range nodes {
+		if node.MemoryBytes > 0 {
+			known += node.MemoryBytes
+			reporting++
+		}
+	}
+	weight := func(node models.Node) float64 {
+		switch {
+		case node.MemoryBytes > 0:
+			return float64(node.MemoryBytes)
+		case reporting > 0:
+			// nodes not reporting memory are assumed average
+			return float64(known) / float64(reporting)
+		}
+		return 1
+	}
+
+	target := make(map[string]int, len(nodes))
+	for _, node := range nodes {
+		target[node.ID] = 0
+	}
+	for n := 0; n < total; n++ {
+		best, bestScore := -1, 0.0
+		for i, node := range nodes {
+			if node.MaxShards > 0 && target[node.ID] >= node.MaxShards {
+				continue
+			}
+			score := float64(target[node.ID]+1) / weight(node)
+			if best < 0 || score < bestScore {
+				best, bestScore = i, score
+			}
+		}
+		if best < 0 {
+			break
+		}
+		target[nodes[best].ID]++
+	}
+	return target
+}
+
+// the next move from the node most over its target to the node most
+// under it which does not already host the shard
+func nextMove(nodes []models.Node, current, target map[string]int, hosted map[string]map[models.ShardRef]bool, movable map[string][]models.ShardRef) (Move, bool) {
+	ids := make([]string, len(nodes))
+	for i, node := range nodes {
+		ids[i] = node.ID
+	}
+	over := append([]string(nil), ids...)
+	sort.SliceStable(over, func(a, b int) bool {
+		return current[over[a]]-target[over[a]] > current[over[b]]-target[over[b]]
+	})
+	under := append([]string(nil), ids...)
+	sort.SliceStable(under, func(a, b int) bool {
+		return target[under[a]]-current[under[a]] > target[under[b]]-current[under[b]]
+	})
+
+	for _, from := range over {
+		if current[from] <= target[from] {
+			break
+		}
+		for _, to := range under {
+			if current[to] >= target[to] {
+				break
+			}
+			for i, ref := range movable[from] {
+				if hosted[to][ref] {
+					continue
+				}
+				movable[from] = append(movable[from][:i:i], movable[from][i+1:]...)
+				return Move{Shard: ref, From: from, To: to}, true
+			}
+		}
+	}
+	return Move{}, false
+}
+
+// moveLog holds writes to a shard made while it is copied
+type moveLog struct {
+	batches []index.Batch
+}
+
+// log a write to a shard being moved
+func logMove(ref models.ShardRef, batch index.Batch) {
+	mu.Lock()
+	defer mu.Unlock()
+	if logged, ok := moving[ref]; ok {
+		logged.batches = append(logged.batches, batch)
+	}
+}
+
+// start logging writes to a shard, once writes in flight have finished
+func startLog(ref models.ShardRef) {
+	writes.Lock()
+	defer writes.Unlock()
+	mu.Lock()
+	defer mu.Unlock()
+	moving[ref] = &moveLog{}
+}
+
+// take the writes logged so far
+func takeLog(ref models.ShardRef) []index.Batch {
+	mu.Lock()
+	defer mu.Unlock()
+	logged := moving[ref]
+	batches := logged.batches
+	logged.batches = nil
+	return batches
+}
+
+// stop logging writes to a shard
+func stopLog(ref models.ShardRef) {
+	mu.Lock()
+	defer mu.Unlock()
+	delete(moving, ref)
+}
+
+// move a shard replica while writes continue. The target is copied from
+// the source replica, then catches up on writes logged since the copy
+// started, which are idempotent as vectors never change under an id.
+// Writes pause while the last logged writes are applied and ownership
+// flips to the target in one transaction.
+func moveReplica(ctx context.Context, move Move, config configs.Rebalance) error {
+	maintenance.Lock()
+	defer maintenance.Unlock()
+
+	var collection models.Collection
+	if err := database.DB.WithContext(ctx).First(&collection, move.Shard.CollectionID).Error; err != nil {
+		return err
+	}
+	replicas, err := replicasOf(ctx, collection.ID)
+	if err != nil {
+		return err
+	}
+
+	var source *replica
+	for _, replica := range replicas[move.Shard.Shard] {
+		if replica.NodeID == move.To {
+			return fmt.Errorf("node %v already hosts the shard", move.To)
+		}
+		if replica.NodeID == move.From && replica.live() && replica.State == models.ReplicaReady {
+			source = &replica
+		}
+	}
+	if source == nil {
+		return fmt.Errorf("replica on node %v is no longer ready", move.From)
+	}
+
+	var target models.Node
+	if err := database.DB.WithContext(ctx).Where("id = ? AND status = ?", move.To, models.NodeAlive).First(&target).Error; err != nil {
+		return fmt.Errorf("node %v is not alive, %w", move.To, err)
+	}
+
+	ref := move.Shard
+	startLog(ref)
+	defer stopLog(ref)
+
+	if err := create(ctx, &target, ref, collection.Metric); err != nil {
+		return err
+	}
+	if err := transfer(ctx, *source, &target, ref, config); err != nil {
+		drop(ctx, &target, ref)
+		return err
+	}
+
+	placement := models.ShardPlacement{CollectionID: ref.CollectionID, Shard: ref.Shard, NodeID: target.ID, State: models.ReplicaReady}
+	writes.Lock()
+	err = apply(ctx, &target, ref, takeLog(ref))
+	if err == nil {
+		err = database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&placement).Error; err != nil {
+				return err
+			}
+			if err := tx.Delete(&source.ShardPlacement).Error; err != nil {
+				return err
+			}
+			return consensus.Publish(tx, &placement, &source.ShardPlacement)
+		})
+	}
+	writes.Unlock()
+	if err != nil {
+		drop(ctx, &target, ref)
+		return err
+	}
+
+	drop(ctx, source.node, ref)
+	log.Info("shard replica moved", "shard", ref, "from", move.From, "to", move.To)
+	return nil
+}
+
+// copy a shard from source to target at the throttled rate, writing each
+// streamed chunk as it arrives, then catch up on writes logged meanwhile
+func transfer(ctx context.Context, source replica, target *models.Node, ref models.ShardRef, config configs.Rebalance) error {
+	err := segment(ctx, source, ref, func(entries []index.Entry) error {
+		if err := apply(ctx, target, ref, []index.Batch{{Upserts: entries}}); err != nil {
+			return err
+		}
+		if config.VectorsPerSecond > 0 {
+			pause := time.Duration(len(entries)) * time.Second / time.Duration(config.VectorsPerSecond)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(pause):
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for round := 0; round < catchUpRounds; round++ {
+		pending := takeLog(ref)
+		if len(pending) == 0 {
+			break
+		}
+		if err := apply(ctx, target, ref, pending); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// apply batches to a shard on node in order
+func apply(ctx context.Context, node *models.Node, ref models.ShardRef, batches []index.Batch) error {
+	for _, batch := range batches {
+		if err := call(node, func(client Node) error { return client.Write(ctx, ref, batch) }); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RebalanceStatus reports the progress of the latest rebalance. The state
+// is idle, running, succeeded, failed or cancelled, and each move is
+// pending, copying, done or failed.
+type RebalanceStatus struct {
+	State      string       `json:"state"`
+	StartedAt  *time.Time   `json:"started_at,omitempty"`
+	FinishedAt *time.Time   `json:"finished_at,omitempty"`
+	Moves      []MoveStatus `json:"moves"`
+}
+
+// MoveStatus is the progress of one move
+type MoveStatus struct {
+	Move
+	State string `json:"state"`
+	Error string `json:"error,omitempty"`
+}
+
+// Rebalancer runs one planned rebalance at a time in the background
+type Rebalancer struct {
+	config configs.Rebalance
+
+	mu     sync.Mutex
+	status RebalanceStatus
+	stop   chan struct{}
+	abort  context.CancelFunc
+	done   chan struct{}
+}
+
+// NewRebalancer creates an idle rebalancer throttled as configured
+func NewRebalancer(config configs.Rebalance) *Rebalancer {
+	return &Rebalancer{config: config, status: RebalanceStatus{State: "idle", Moves: []MoveStatus{}}}
+}
+
+// Start plans a rebalance and runs its moves in the background
+func (r *Rebalancer) Start(ctx context.Context) (*Plan, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.status.State == "running" {
+		return nil, ErrRebalancing
+	}
+
+	plan, err := PlanRebalance(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	r.status = RebalanceStatus{State: "running", StartedAt: &now, Moves: make([]MoveStatus, len(plan.Moves))}
+	for i, move := range plan.Moves {
+		r.status.Moves[i] = MoveStatus{Move: move, State: "pending"}
+	}
+
+	var runCtx context.Context
+	runCtx, r.abort = context.WithCancel(context.Background())
+	r.stop, r.done = make(chan struct{}), make(chan struct{})
+	go r.run(runCtx, plan.Moves, r.stop, r.done)
+
+	log.Info("rebalance started", "moves", len(plan.Moves))
+	return plan, nil
+}
+
+// run moves in order, pausing between them. Closing stop skips the moves
+// not yet started, cancelling ctx also aborts the current move.
+func (r *Rebalancer) run(ctx context.Context, moves []Move, stop, done chan struct{}) {
+	defer close(done)
+
+	state := "succeeded"
+	for i, move := range moves {
+		if i > 0 && r.config.Pause > 0 {
+			select {
+			case <-ctx.Done():
+			case <-stop:
+			case <-time.After(time.Duration(r.config.Pause) * time.Second):
+			}
+		}
+		if ctx.Err() != nil || closed(stop) {
+			state = "cancelled"
+			break
+		}
+
+		r.setMove(i, "copying", nil)
+		err := moveReplica(ctx, move, r.config)
+		if ctx.Err() != nil {
+			state = "cancelled"
+		} else if err != nil {
+			log.Warn("shard move failed", "shard", move.Shard, "from", move.From, "to", move.To, "err", err)
+			state = "failed"
+		}
+		r.setMove(i, "done", err)
+	}
+
+	now := time.Now()
+	r.mu.Lock()
+	r.status.State, r.status.FinishedAt = state, &now
+	r.mu.Unlock()
+	log.Info("rebalance finished", "state", state, "moves", len(moves))
+}
+
+// record the progress of a move
+func (r *Rebalancer) setMove(i int, state string, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.status.Moves[i].State = state
+	if err != nil {
+		r.status.Moves[i].State, r.status.Moves[i].Error = "failed", err.Error()
+	}
+}
+
+// Status returns the progress of the latest rebalance
+func (r *Rebalancer) Status() RebalanceStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	status := r.status
+	status.Moves = append([]MoveStatus(nil), r.status.Moves...)
+	return status
+}
+
+// Cancel stops a running rebalance after its current move, which
+// completes, later moves stay pending
+func (r *Rebalancer) Cancel() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.stop != nil && !closed(r.stop) {
+		close(r.stop)
+	}
+}
+
+// whether a channel is closed
+func closed(stop chan struct{}) bool {
+	select {
+	case <-stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// Stop aborts a running rebalance, including its current move, and waits
+// for it to finish
+func (r *Rebalancer) Stop(ctx context.Context) error {
+	r.mu.Lock()
+	if r.abort != nil {
+		r.abort()
+	}
+	done := r.done
+	r.mu.Unlock()
+	if done == nil {
+		return nil
+	}
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
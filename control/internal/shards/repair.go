@@ -0,0 +1,297 @@
+package shards
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"gorm.io/gorm"
+
+	"github.com/christian-nickerson/pangolin/control/internal/configs"
+	"github.com/christian-nickerson/pangolin/control/internal/consensus"
+	"github.com/christian-nickerson/pangolin/control/internal/database"
+	"github.com/christian-nickerson/pangolin/control/internal/index"
+	"github.com/christian-nickerson/pangolin/control/internal/models"
+)
+
+var (
+	wake        = make(chan struct{}, 1)
+	stopRepairs context.CancelFunc
+	repairs     sync.WaitGroup
+)
+
+// Start repairs under-replicated shards every repair interval, and
+// whenever Trigger is called. Replicas left syncing by a previous
+// process are copied again.
+func Start(ctx context.Context) error {
+	err := database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.ShardPlacement{}).
+			Where("state = ?", models.ReplicaSyncing).
+			Update("state", models.ReplicaStale).Error
+		if err != nil {
+			return err
+		}
+		return consensus.PublishTable(tx, &models.ShardPlacement{})
+	})
+	if err != nil {
+		return err
+	}
+
+	mu.Lock()
+	interval := time.Duration(settings.RepairInterval) * time.Second
+	mu.Unlock()
+	if interval <= 0 {
+		interval = time.Minute
+	}
+
+	var repairCtx context.Context
+	repairCtx, stopRepairs = context.WithCancel(context.Background())
+	repairs.Add(1)
+	go func() {
+		defer repairs.Done()
+		repairLoop(repairCtx, interval)
+	}()
+	return nil
+}
+
+// Stop stops repairing, waiting for a running repair to finish
+func Stop(ctx context.Context) error {
+	if stopRepairs == nil {
+		return nil
+	}
+	stopRepairs()
+
+	done := make(chan struct{})
+	go func() {
+		repairs.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Trigger requests a repair, such as when a node is lost
+func Trigger() {
+	select {
+	case wake <- struct{}{}:
+	default:
+	}
+}
+
+// repair each interval or when woken
+func repairLoop(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-wake:
+		}
+		if err := Repair(ctx); err != nil && ctx.Err() == nil {
+			log.Error("shard repair failed", "err", err)
+		}
+	}
+}
+
+// Repair restores the replication factor of every sharded collection.
+// Replicas on dead or departed nodes are forgotten, stale replicas are
+// copied again from a ready replica, and missing replicas are copied to
+// the least loaded alive nodes not already hosting the shard.
+func Repair(ctx context.Context) error {
+	maintenance.Lock()
+	defer maintenance.Unlock()
+
+	var collections []models.Collection
+	if err := database.DB.WithContext(ctx).Where("shards > 0").Order("id").Find(&collections).Error; err != nil {
+		return err
+	}
+
+	var errs error
+	for i := range collections {
+		collection := &collections[i]
+		replicas, err := replicasOf(ctx, collection.ID)
+		if err != nil {
+			errs = errors.Join(errs, err)
+			continue
+		}
+		for shard := 0; shard < collection.Shards; shard++ {
+			if err := repairShard(ctx, collection, shard, replicas[shard]); err != nil {
+				errs = errors.Join(errs, err)
+			}
+		}
+	}
+	return errs
+}
+
+// repair the replicas of one shard
+func repairShard(ctx context.Context, collection *models.Collection, shard int, replicas []replica) error {
+	ref := models.ShardRef{CollectionID: collection.ID, Shard: shard}
+
+	var kept, stale []replica
+	for _, replica := range replicas {
+		switch {
+		case !replica.live():
+			err := database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+				if err := tx.Delete(&replica.ShardPlacement).Error; err != nil {
+					return err
+				}
+				return consensus.Publish(tx, &replica.ShardPlacement)
+			})
+			if err != nil {
+				return err
+			}
+			log.Warn("shard replica lost with its node", "shard", ref, "node_id", replica.NodeID)
+		case replica.State == models.ReplicaStale:
+			stale = append(stale, replica)
+		default:
+			kept = append(kept, replica)
+		}
+	}
+
+	sources := readable(kept)
+	if len(stale) == 0 && len(kept) >= collection.ReplicationFactor() {
+		return nil
+	}
+	if len(sources) == 0 {
+		return fmt.Errorf("shard %v has no ready replica to copy from", ref)
+	}
+
+	var errs error
+	for _, target := range stale {
+		if err := resync(ctx, collection, sources[0], target); err != nil {
+			errs = errors.Join(errs, fmt.Errorf("unable to resync shard %v on node %v, %w", ref, target.NodeID, err))
+		}
+	}
+
+	// stale replicas count whether or not they resynced, failures are retried
+	missing := collection.ReplicationFactor() - len(kept) - len(stale)
+	if missing <= 0 {
+		return errs
+	}
+
+	nodes, load, err := candidates(ctx)
+	if err != nil {
+		return errors.Join(errs, err)
+	}
+	hosting := map[string]bool{}
+	for _, replica := range replicas {
+		hosting[replica.NodeID] = true
+	}
+	for ; missing > 0; missing-- {
+		node := leastLoaded(nodes, load, hosting)
+		if node == nil {
+			log.Warn("shard under-replicated, no node has capacity", "shard", ref, "missing", missing)
+			break
+		}
+		load[node.ID]++
+		hosting[node.ID] = true
+
+		if err := replicate(ctx, collection, sources[0], node, ref); err != nil {
+			errs = errors.Join(errs, fmt.Errorf("unable to replicate shard %v to node %v, %w", ref, node.ID, err))
+		}
+	}
+	return errs
+}
+
+// copy a shard from source to a new replica on node
+func replicate(ctx context.Context, collection *models.Collection, source replica, node *models.Node, ref models.ShardRef) error {
+	if err := create(ctx, node, ref, collection.Metric); err != nil {
+		return err
+	}
+
+	// receive writes while copying
+	placement := models.ShardPlacement{CollectionID: ref.CollectionID, Shard: ref.Shard, NodeID: node.ID, State: models.ReplicaSyncing}
+	err := database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&placement).Error; err != nil {
+			return err
+		}
+		return consensus.Publish(tx, &placement)
+	})
+	if err != nil {
+		drop(ctx, node, ref)
+		return err
+	}
+	target := replica{ShardPlacement: placement, node: node}
+
+	if err := copyShard(ctx, source, target); err != nil {
+		setState(ctx, placement, models.ReplicaSyncing, models.ReplicaStale)
+		return err
+	}
+	if err := setState(ctx, placement, models.ReplicaSyncing, models.ReplicaReady); err != nil {
+		return err
+	}
+
+	log.Info("shard replicated", "shard", ref, "source_node_id", source.NodeID, "node_id", node.ID)
+	return nil
+}
+
+// copy a shard again onto a stale replica
+func resync(ctx context.Context, collection *models.Collection, source, target replica) error {
+	ref := target.Ref()
+	drop(ctx, target.node, ref)
+	if err := create(ctx, target.node, ref, collection.Metric); err != nil {
+		return err
+	}
+
+	if err := setState(ctx, target.ShardPlacement, models.ReplicaStale, models.ReplicaSyncing); err != nil {
+		return err
+	}
+	if err := copyShard(ctx, source, target); err != nil {
+		setState(ctx, target.ShardPlacement, models.ReplicaSyncing, models.ReplicaStale)
+		return err
+	}
+	if err := setState(ctx, target.ShardPlacement, models.ReplicaSyncing, models.ReplicaReady); err != nil {
+		return err
+	}
+
+	log.Info("shard replica resynced", "shard", ref, "source_node_id", source.NodeID, "node_id", target.NodeID)
+	return nil
+}
+
+// copy a shard's vectors from source to a syncing target, which already
+// receives writes. Writes made since the copy started are logged and
+// applied again after it in order, so a vector deleted while the copy was
+// in flight stays deleted. Writes pause while the last are applied.
+func copyShard(ctx context.Context, source, target replica) error {
+	ref := target.Ref()
+	startLog(ref)
+	defer stopLog(ref)
+
+	if err := transfer(ctx, source, target.node, ref, configs.Rebalance{}); err != nil {
+		return err
+	}
+	writes.Lock()
+	defer writes.Unlock()
+	return apply(ctx, target.node, ref, takeLog(ref))
+}
+
+// stream a shard's vectors from a replica to fn, a failure of fn is not
+// counted against the replica's node
+func segment(ctx context.Context, source replica, ref models.ShardRef, fn func([]index.Entry) error) error {
+	var failed error
+	err := call(source.node, func(node Node) error {
+		err := node.Segment(ctx, ref, func(entries []index.Entry) error {
+			failed = fn(entries)
+			return failed
+		})
+		if failed != nil {
+			return nil
+		}
+		return err
+	})
+	if failed != nil {
+		return failed
+	}
+	return err
+}
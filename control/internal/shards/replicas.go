@@ -0,0 +1,183 @@
+package shards
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+
+	"gorm.io/gorm"
+
+	"github.com/christian-nickerson/pangolin/control/internal/consensus"
+	"github.com/christian-nickerson/pangolin/control/internal/database"
+	"github.com/christian-nickerson/pangolin/control/internal/models"
+)
+
+// a dialled node, redialled when the node registers a new address
+type client struct {
+	address string
+	node    Node
+}
+
+// replica is a shard placement and the node hosting it, nil when the
+// node is no longer registered
+type replica struct {
+	models.ShardPlacement
+	node *models.Node
+}
+
+// live reports whether the replica's node is expected to answer
+func (r replica) live() bool {
+	return r.node != nil && (r.node.Status == models.NodeAlive || r.node.Status == models.NodeSuspect)
+}
+
+// the replicas of a collection's shards by shard
+func replicasOf(ctx context.Context, collectionID uint) (map[int][]replica, error) {
+	placements, err := Placements(ctx, collectionID)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, len(placements))
+	for i, placement := range placements {
+		ids[i] = placement.NodeID
+	}
+	var nodes []models.Node
+	if err := database.DB.WithContext(ctx).Where("id IN ?", ids).Find(&nodes).Error; err != nil {
+		return nil, err
+	}
+	byID := make(map[string]*models.Node, len(nodes))
+	for i := range nodes {
+		byID[nodes[i].ID] = &nodes[i]
+	}
+
+	replicas := map[int][]replica{}
+	for _, placement := range placements {
+		replicas[placement.Shard] = append(replicas[placement.Shard], replica{ShardPlacement: placement, node: byID[placement.NodeID]})
+	}
+	return replicas, nil
+}
+
+// ready replicas on live nodes, healthiest first. Alive nodes come before
+// suspect ones, then those with the fewest recent failures.
+func readable(replicas []replica) []replica {
+	var ready []replica
+	for _, replica := range replicas {
+		if replica.live() && replica.State == models.ReplicaReady {
+			ready = append(ready, replica)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	sort.SliceStable(ready, func(a, b int) bool {
+		aliveA, aliveB := ready[a].node.Status == models.NodeAlive, ready[b].node.Status == models.NodeAlive
+		if aliveA != aliveB {
+			return aliveA
+		}
+		return failures[ready[a].NodeID] < failures[ready[b].NodeID]
+	})
+	return ready
+}
+
+// alive nodes and the number of shard replicas placed on each
+func candidates(ctx context.Context) ([]models.Node, map[string]int, error) {
+	var nodes []models.Node
+	err := database.DB.WithContext(ctx).
+		Where("status = ?", models.NodeAlive).
+		Order("id").
+		Find(&nodes).Error
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var counts []struct {
+		NodeID string
+		Count  int
+	}
+	err = database.DB.WithContext(ctx).
+		Model(&models.ShardPlacement{}).
+		Select("node_id, count(*) as count").
+		Group("node_id").
+		Scan(&counts).Error
+	if err != nil {
+		return nil, nil, err
+	}
+
+	load := make(map[string]int, len(counts))
+	for _, count := range counts {
+		load[count.NodeID] = count.Count
+	}
+	return nodes, load, nil
+}
+
+// the node with the fewest shards and room for another, skipping
+// excluded nodes, nil when none has room
+func leastLoaded(nodes []models.Node, load map[string]int, exclude map[string]bool) *models.Node {
+	var best *models.Node
+	for i := range nodes {
+		node := &nodes[i]
+		if exclude[node.ID] || (node.MaxShards > 0 && load[node.ID] >= node.MaxShards) {
+			continue
+		}
+		if best == nil || load[node.ID] < load[best.ID] {
+			best = node
+		}
+	}
+	return best
+}
+
+// call a node, recording its failures to rank replica health
+func call(node *models.Node, fn func(Node) error) error {
+	client, err := clientOf(node)
+	if err != nil {
+		return err
+	}
+	err = fn(client)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if err != nil {
+		failures[node.ID]++
+	} else {
+		delete(failures, node.ID)
+	}
+	return err
+}
+
+// the client of a node, dialled on first use
+func clientOf(node *models.Node) (Node, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if dial == nil {
+		return nil, ErrNoTransport
+	}
+	cached, ok := clients[node.ID]
+	if ok && cached.address == node.Address {
+		return cached.node, nil
+	}
+	if closer, isCloser := cached.node.(io.Closer); ok && isCloser {
+		closer.Close()
+	}
+
+	dialled, err := dial(node)
+	if err != nil {
+		return nil, fmt.Errorf("unable to dial node %v, %w", node.ID, err)
+	}
+	clients[node.ID] = client{address: node.Address, node: dialled}
+	return dialled, nil
+}
+
+// move a replica between states, only from the state it was read in
+func setState(ctx context.Context, placement models.ShardPlacement, from, to models.ReplicaState) error {
+	return database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.ShardPlacement{}).
+			Where("collection_id = ? AND shard = ? AND node_id = ? AND state = ?", placement.CollectionID, placement.Shard, placement.NodeID, from).
+			Update("state", to).Error
+		if err != nil {
+			return err
+		}
+		return consensus.Publish(tx, &placement)
+	})
+}
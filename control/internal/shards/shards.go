@@ -0,0 +1,436 @@
+package shards
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"gorm.io/gorm"
+
+	"github.com/christian-nickerson/pangolin/control/internal/configs"
+	"github.com/christian-nickerson/pangolin/control/internal/consensus"
+	"github.com/christian-nickerson/pangolin/control/internal/database"
+	"github.com/christian-nickerson/pangolin/control/internal/index"
+	"github.com/christian-nickerson/pangolin/control/internal/models"
+)
+
+var (
+	ErrNoNodes              = errors.New("no index node has capacity for the shard")
+	ErrNoTransport          = errors.New("no transport to index nodes is set up")
+	ErrUnplaced             = errors.New("shard has no ready replica")
+	ErrNoQuorum             = errors.New("too few shard replicas acknowledged the write")
+	ErrInsufficientCoverage = errors.New("too few shards answered the search")
+)
+
+// Node is the shard API of an index node
+type Node interface {
+	CreateShard(ctx context.Context, ref models.ShardRef, metric models.Metric) error
+	DropShard(ctx context.Context, ref models.ShardRef) error
+	Write(ctx context.Context, ref models.ShardRef, batch index.Batch) error
+	// Get returns vectors by id, omitting missing ids
+	Get(ctx context.Context, ref models.ShardRef, ids []uint64) ([]index.Entry, error)
+	Search(ctx context.Context, ref models.ShardRef, query models.Vector, k int) ([]index.Hit, error)
+	// Segment streams a shard's vectors to fn in chunks, fn may see a
+	// vector again when the stream is retried
+	Segment(ctx context.Context, ref models.ShardRef, fn func([]index.Entry) error) error
+}
+
+// Dialer returns the client of a registered node
+type Dialer func(node *models.Node) (Node, error)
+
+var (
+	mu       sync.Mutex
+	dial     Dialer
+	settings configs.Cluster
+	clients  = map[string]client{}
+	failures = map[string]int{}
+
+	// writes are held shared by every write and exclusively while a move
+	// starts logging a shard and while it flips ownership
+	writes sync.RWMutex
+	moving = map[models.ShardRef]*moveLog{}
+
+	// maintenance serialises repairs and moves, which both change placements
+	maintenance sync.Mutex
+)
+
+// Setup reaches index nodes through dialer, sharded collections
+// cannot be created or used until it is set
+func Setup(config configs.Cluster, dialer Dialer) {
+	mu.Lock()
+	defer mu.Unlock()
+	settings, dial = config, dialer
+	clients, failures = map[string]client{}, map[string]int{}
+}
+
+// Key returns the shard holding a document's chunks, by a hash of its id
+func Key(collection *models.Collection, documentID string) int {
+	if !collection.Sharded() {
+		return 0
+	}
+	hash := fnv.New32a()
+	hash.Write([]byte(documentID))
+	return int(hash.Sum32() % uint32(collection.Shards))
+}
+
+// Batches groups index writes by shard
+type Batches map[int]*index.Batch
+
+// Add appends deletes and upserts to a shard's batch
+func (b Batches) Add(shard int, deletes []uint64, upserts []index.Entry) {
+	batch, ok := b[shard]
+	if !ok {
+		batch = &index.Batch{}
+		b[shard] = batch
+	}
+	batch.Deletes = append(batch.Deletes, deletes...)
+	batch.Upserts = append(batch.Upserts, upserts...)
+}
+
+// Place assigns the replicas of each shard of a new collection to
+// distinct alive nodes, those hosting the fewest shards first, and
+// creates the shards on their nodes
+func Place(ctx context.Context, collection *models.Collection) error {
+	if !collection.Sharded() {
+		return nil
+	}
+
+	nodes, load, err := candidates(ctx)
+	if err != nil {
+		return err
+	}
+
+	var placements []models.ShardPlacement
+	var hosts []*models.Node
+	for shard := 0; shard < collection.Shards; shard++ {
+		chosen := map[string]bool{}
+		for replica := 0; replica < collection.ReplicationFactor(); replica++ {
+			node := leastLoaded(nodes, load, chosen)
+			if node == nil {
+				return ErrNoNodes
+			}
+			load[node.ID]++
+			chosen[node.ID] = true
+			placements = append(placements, models.ShardPlacement{
+				CollectionID: collection.ID,
+				Shard:        shard,
+				NodeID:       node.ID,
+				State:        models.ReplicaReady,
+			})
+			hosts = append(hosts, node)
+		}
+	}
+
+	for i := range placements {
+		err := create(ctx, hosts[i], placements[i].Ref(), collection.Metric)
+		if err != nil {
+			for j := range placements[:i] {
+				drop(ctx, hosts[j], placements[j].Ref())
+			}
+			return fmt.Errorf("unable to create shard %v on node %v, %w", placements[i].Ref(), hosts[i].ID, err)
+		}
+	}
+
+	err = database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&placements).Error; err != nil {
+			return err
+		}
+		records := make([]any, len(placements))
+		for i := range placements {
+			records[i] = &placements[i]
+		}
+		return consensus.Publish(tx, records...)
+	})
+	if err != nil {
+		for i := range placements {
+			drop(ctx, hosts[i], placements[i].Ref())
+		}
+		return err
+	}
+	log.Info("collection shards placed", "collection_id", collection.ID, "shards", collection.Shards, "replicas", collection.ReplicationFactor())
+	return nil
+}
+
+// Drop deletes the shards of a collection from their nodes and forgets
+// their placements. Unreachable nodes are skipped, a node which lost a
+// shard is not asked for it again.
+func Drop(ctx context.Context, collection *models.Collection) error {
+	if !collection.Sharded() {
+		index.Drop(collection.ID)
+		return nil
+	}
+
+	replicas, err := replicasOf(ctx, collection.ID)
+	if err != nil {
+		return err
+	}
+	var records []any
+	for _, shard := range replicas {
+		for _, replica := range shard {
+			if replica.node != nil {
+				drop(ctx, replica.node, replica.Ref())
+			}
+			records = append(records, &replica.ShardPlacement)
+		}
+	}
+
+	return database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("collection_id = ?", collection.ID).Delete(&models.ShardPlacement{}).Error
+		if err != nil {
+			return err
+		}
+		return consensus.Publish(tx, records...)
+	})
+}
+
+// Write applies batches to the shards owning them
+func Write(ctx context.Context, collection *models.Collection, batches Batches) error {
+	if !collection.Sharded() {
+		var merged index.Batch
+		for _, batch := range batches {
+			merged.Deletes = append(merged.Deletes, batch.Deletes...)
+			merged.Upserts = append(merged.Upserts, batch.Upserts...)
+		}
+		if err := index.For(collection).Write(ctx, merged); err != nil {
+			return err
+		}
+		index.Observe(collection.ID)
+		return nil
+	}
+
+	writes.RLock()
+	defer writes.RUnlock()
+
+	replicas, err := replicasOf(ctx, collection.ID)
+	if err != nil {
+		return err
+	}
+
+	var errs error
+	for shard, batch := range batches {
+		ref := models.ShardRef{CollectionID: collection.ID, Shard: shard}
+		logMove(ref, *batch)
+		if err := writeShard(ctx, collection, ref, replicas[shard], *batch); err != nil {
+			errs = errors.Join(errs, fmt.Errorf("unable to write shard %v, %w", ref, err))
+		}
+	}
+	return errs
+}
+
+// write a batch to every live replica of a shard, succeeding once a
+// quorum of ready replicas acknowledge it. Replicas which fail the write
+// are marked stale to be copied again.
+func writeShard(ctx context.Context, collection *models.Collection, ref models.ShardRef, replicas []replica, batch index.Batch) error {
+	var targets []replica
+	for _, replica := range replicas {
+		if replica.live() && replica.State != models.ReplicaStale {
+			targets = append(targets, replica)
+		}
+	}
+
+	errs := make([]error, len(targets))
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = call(target.node, func(node Node) error { return node.Write(ctx, ref, batch) })
+		}()
+	}
+	wg.Wait()
+
+	acks := 0
+	var failed error
+	for i, err := range errs {
+		if err == nil {
+			if targets[i].State == models.ReplicaReady {
+				acks++
+			}
+			continue
+		}
+		failed = errors.Join(failed, err)
+		log.Warn("shard replica write failed", "shard", ref, "node_id", targets[i].NodeID, "err", err)
+		if err := setState(ctx, targets[i].ShardPlacement, targets[i].State, models.ReplicaStale); err != nil {
+			log.Error("unable to mark shard replica stale", "shard", ref, "node_id", targets[i].NodeID, "err", err)
+		}
+		Trigger()
+	}
+
+	quorum := collection.ReplicationFactor()/2 + 1
+	if acks < quorum {
+		return fmt.Errorf("%w, %v of %v needed, %w", ErrNoQuorum, acks, quorum, failed)
+	}
+	return nil
+}
+
+// Search returns the collection's k nearest vectors to query. Sharded
+// collections search every shard and merge their top k, reporting the
+// results as partial when shards failed to answer. Searches answered by
+// fewer than the minimum coverage of shards fail.
+func Search(ctx context.Context, collection *models.Collection, query models.Vector, k int) ([]index.Hit, bool, error) {
+	if !collection.Sharded() {
+		hits, err := index.For(collection).Search(query, k)
+		return hits, false, err
+	}
+
+	replicas, err := replicasOf(ctx, collection.ID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	mu.Lock()
+	timeout, minCoverage := time.Duration(settings.ShardTimeout)*time.Second, settings.MinCoverage
+	mu.Unlock()
+
+	results := make([][]index.Hit, collection.Shards)
+	errs := make([]error, collection.Shards)
+	var wg sync.WaitGroup
+	for shard := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx := ctx
+			if timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, timeout)
+				defer cancel()
+			}
+			ref := models.ShardRef{CollectionID: collection.ID, Shard: shard}
+			results[shard], errs[shard] = searchShard(ctx, ref, replicas[shard], query, k)
+		}()
+	}
+	wg.Wait()
+
+	var hits []index.Hit
+	var failed error
+	answered := 0
+	for shard, err := range errs {
+		if err != nil {
+			log.Warn("shard search failed", "shard", models.ShardRef{CollectionID: collection.ID, Shard: shard}, "err", err)
+			failed = errors.Join(failed, err)
+			continue
+		}
+		answered++
+		hits = append(hits, results[shard]...)
+	}
+
+	coverage := float64(answered) / float64(collection.Shards)
+	if answered == 0 || coverage < minCoverage {
+		return nil, false, fmt.Errorf("%w, %v of %v shards, %w", ErrInsufficientCoverage, answered, collection.Shards, failed)
+	}
+
+	sort.SliceStable(hits, func(a, b int) bool { return hits[a].Score > hits[b].Score })
+	if len(hits) > k {
+		hits = hits[:k]
+	}
+	return hits, answered < collection.Shards, nil
+}
+
+// search the healthiest ready replica of a shard, failing over to the
+// next replica on error
+func searchShard(ctx context.Context, ref models.ShardRef, replicas []replica, query models.Vector, k int) ([]index.Hit, error) {
+	err := ErrUnplaced
+	for _, replica := range readable(replicas) {
+		var hits []index.Hit
+		err = call(replica.node, func(node Node) error {
+			var err error
+			hits, err = node.Search(ctx, ref, query, k)
+			return err
+		})
+		if err == nil {
+			return hits, nil
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, err
+}
+
+// Get returns vectors of the collection by id from the shard holding
+// them, omitting missing ids, failing over between ready replicas
+func Get(ctx context.Context, collection *models.Collection, shard int, ids []uint64) ([]index.Entry, error) {
+	if !collection.Sharded() {
+		idx := index.For(collection)
+		entries := make([]index.Entry, 0, len(ids))
+		for _, id := range ids {
+			if vector, ok := idx.Get(id); ok {
+				entries = append(entries, index.Entry{ID: id, Vector: vector})
+			}
+		}
+		return entries, nil
+	}
+
+	replicas, err := replicasOf(ctx, collection.ID)
+	if err != nil {
+		return nil, err
+	}
+	ref := models.ShardRef{CollectionID: collection.ID, Shard: shard}
+	err = ErrUnplaced
+	for _, replica := range readable(replicas[shard]) {
+		var entries []index.Entry
+		err = call(replica.node, func(node Node) error {
+			var err error
+			entries, err = node.Get(ctx, ref, ids)
+			return err
+		})
+		if err == nil {
+			return entries, nil
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, err
+}
+
+// Segment streams a shard's vectors to fn in chunks, failing over between
+// ready replicas, so fn may see a vector again. The vectors of unsharded
+// collections are passed in one chunk.
+func Segment(ctx context.Context, collection *models.Collection, shard int, fn func([]index.Entry) error) error {
+	if !collection.Sharded() {
+		return fn(index.For(collection).Entries())
+	}
+
+	replicas, err := replicasOf(ctx, collection.ID)
+	if err != nil {
+		return err
+	}
+	ref := models.ShardRef{CollectionID: collection.ID, Shard: shard}
+	err = ErrUnplaced
+	for _, replica := range readable(replicas[shard]) {
+		err = segment(ctx, replica, ref, fn)
+		if err == nil || ctx.Err() != nil {
+			break
+		}
+	}
+	return err
+}
+
+// Placements returns the placements of a collection's shard replicas
+func Placements(ctx context.Context, collectionID uint) ([]models.ShardPlacement, error) {
+	var placements []models.ShardPlacement
+	err := database.DB.WithContext(ctx).
+		Where("collection_id = ?", collectionID).
+		Order("shard, node_id").
+		Find(&placements).Error
+	return placements, err
+}
+
+// create a shard on a node
+func create(ctx context.Context, node *models.Node, ref models.ShardRef, metric models.Metric) error {
+	return call(node, func(client Node) error { return client.CreateShard(ctx, ref, metric) })
+}
+
+// drop a shard from a node, logging failures
+func drop(ctx context.Context, node *models.Node, ref models.ShardRef) {
+	err := call(node, func(client Node) error { return client.DropShard(ctx, ref) })
+	if err != nil {
+		log.Warn("unable to drop shard", "shard", ref, "node_id", node.ID, "err", err)
+	}
+}
@@ -0,0 +1,461 @@
+package shards
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/christian-nickerson/pangolin/control/internal/configs"
+	"github.com/christian-nickerson/pangolin/control/internal/database"
+	"github.com/christian-nickerson/pangolin/control/internal/index"
+	"github.com/christian-nickerson/pangolin/control/internal/indexnode"
+	"github.com/christian-nickerson/pangolin/control/internal/models"
+)
+
+var errDown = errors.New("node down")
+
+// fakeNode is an in-process index node which can be taken down
+type fakeNode struct {
+	*indexnode.Host
+	down bool
+	// run in turn once each segment has been read
+	segmented []func()
+}
+
+func (f *fakeNode) Write(ctx context.Context, ref models.ShardRef, batch index.Batch) error {
+	if f.down {
+		return errDown
+	}
+	return f.Host.Write(ctx, ref, batch)
+}
+
+func (f *fakeNode) Get(ctx context.Context, ref models.ShardRef, ids []uint64) ([]index.Entry, error) {
+	if f.down {
+		return nil, errDown
+	}
+	return f.Host.Get(ctx, ref, ids)
+}
+
+func (f *fakeNode) Search(ctx context.Context, ref models.ShardRef, query models.Vector, k int) ([]index.Hit, error) {
+	if f.down {
+		return nil, errDown
+	}
+	return f.Host.Search(ctx, ref, query, k)
+}
+
+func (f *fakeNode) Segment(ctx context.Context, ref models.ShardRef, fn func([]index.Entry) error) error {
+	if f.down {
+		return errDown
+	}
+	var entries []index.Entry
+	err := f.Host.Segment(ctx, ref, func(chunk []index.Entry) error {
+		entries = append(entries, chunk...)
+		return nil
+	})
+	if len(f.segmented) > 0 {
+		next := f.segmented[0]
+		f.segmented = f.segmented[1:]
+		next()
+	}
+	if err != nil {
+		return err
+	}
+	return fn(entries)
+}
+
+type ShardsSuite struct {
+	suite.Suite
+	nodes map[string]*fakeNode
+}
+
+// register three alive nodes, dialled in process
+func (s *ShardsSuite) SetupTest() {
+	config := configs.DatabaseConfig{Type: "sqlite", DBName: filepath.Join(s.T().TempDir(), "test")}
+	s.Require().NoError(database.Connect(config))
+	s.Require().NoError(database.Migrate())
+
+	s.nodes = map[string]*fakeNode{}
+	for i := 0; i < 3; i++ {
+		node := &models.Node{ID: fmt.Sprintf("node-%v", i), Address: fmt.Sprintf("node-%v:50060", i), Status: models.NodeAlive}
+		s.Require().NoError(database.DB.Create(node).Error)
+		s.nodes[node.ID] = &fakeNode{Host: indexnode.NewHost()}
+	}
+
+	Setup(configs.Cluster{MinCoverage: 0.5}, func(node *models.Node) (Node, error) {
+		return s.nodes[node.ID], nil
+	})
+	s.T().Cleanup(func() { Setup(configs.Cluster{}, nil) })
+}
+
+// create a collection and place its shards
+func (s *ShardsSuite) collection(shards, replicas int) *models.Collection {
+	collection := &models.Collection{Name: "docs", Model: "test", ChunkSize: 8, Metric: models.MetricDot, Shards: shards, Replicas: replicas}
+	s.Require().NoError(database.DB.Create(collection).Error)
+	s.Require().NoError(Place(context.Background(), collection))
+	return collection
+}
+
+// write vectors 1 to n, each as its own document
+func (s *ShardsSuite) write(collection *models.Collection, n int) {
+	batches := Batches{}
+	for i := 1; i <= n; i++ {
+		id := fmt.Sprintf("doc-%v", i)
+		batches.Add(Key(collection, id), nil, []index.Entry{{ID: uint64(i), Vector: models.Vector{float64(i)}}})
+	}
+	s.Require().NoError(Write(context.Background(), collection, batches))
+}
+
+// the state of each replica of a shard by node
+func (s *ShardsSuite) states(collection *models.Collection, shard int) map[string]models.ReplicaState {
+	placements, err := Placements(context.Background(), collection.ID)
+	s.Require().NoError(err)
+	states := map[string]models.ReplicaState{}
+	for _, placement := range placements {
+		if placement.Shard == shard {
+			states[placement.NodeID] = placement.State
+		}
+	}
+	return states
+}
+
+// Test shards are spread over nodes within their capacity
+func (s *ShardsSuite) TestPlace() {
+	ctx := context.Background()
+	s.Require().NoError(database.DB.Model(&models.Node{}).Where("id = ?", "node-2").Update("max_shards", 1).Error)
+	collection := s.collection(5, 1)
+
+	placements, err := Placements(ctx, collection.ID)
+	s.Require().NoError(err)
+	s.Require().Len(placements, 5)
+	load := map[string]int{}
+	for _, placement := range placements {
+		load[placement.NodeID]++
+	}
+	s.Equal(map[string]int{"node-0": 2, "node-1": 2, "node-2": 1}, load)
+	s.Len(s.nodes["node-0"].Shards(), 2)
+
+	// dead nodes and full nodes take no shards
+	s.Require().NoError(database.DB.Model(&models.Node{}).Where("id <> ?", "node-2").Update("status", models.NodeDead).Error)
+	other := &models.Collection{Name: "other", Model: "test", ChunkSize: 8, Metric: models.MetricDot, Shards: 1}
+	s.Require().NoError(database.DB.Create(other).Error)
+	s.ErrorIs(Place(ctx, other), ErrNoNodes)
+	s.Len(s.nodes["node-2"].Shards(), 1)
+
+	// shards created before their placements fail to store are dropped
+	s.Require().NoError(database.DB.Model(&models.Node{}).Where("1 = 1").Updates(map[string]any{"status": models.NodeAlive, "max_shards": 0}).Error)
+	for id := range s.nodes {
+		s.Require().NoError(database.DB.Create(&models.ShardPlacement{CollectionID: other.ID, Shard: 0, NodeID: id}).Error)
+	}
+	s.Error(Place(ctx, other))
+	s.Len(s.nodes["node-0"].Shards(), 2)
+	s.Len(s.nodes["node-1"].Shards(), 2)
+	s.Len(s.nodes["node-2"].Shards(), 1)
+}
+
+// Test writes reach the owning shard and searches merge every shard's top k
+func (s *ShardsSuite) TestWriteSearch() {
+	ctx := context.Background()
+	collection := s.collection(3, 1)
+	s.write(collection, 30)
+	for _, node := range s.nodes {
+		shards := node.Shards()
+		s.Require().Len(shards, 1)
+		s.NotZero(shards[0].Vectors)
+	}
+
+	hits, partial, err := Search(ctx, collection, models.Vector{1}, 3)
+	s.Require().NoError(err)
+	s.False(partial)
+	s.Equal([]uint64{30, 29, 28}, []uint64{hits[0].ID, hits[1].ID, hits[2].ID})
+
+	// a failed shard leaves the remaining results partial
+	s.nodes["node-0"].down = true
+	hits, partial, err = Search(ctx, collection, models.Vector{1}, 30)
+	s.Require().NoError(err)
+	s.True(partial)
+	s.Len(hits, 30-int(s.nodes["node-0"].Shards()[0].Vectors))
+
+	// below minimum coverage the search fails
+	s.nodes["node-1"].down = true
+	_, _, err = Search(ctx, collection, models.Vector{1}, 3)
+	s.ErrorIs(err, ErrInsufficientCoverage)
+}
+
+// Test replicas of a shard are placed on distinct nodes
+func (s *ShardsSuite) TestPlaceReplicas() {
+	collection := s.collection(2, 3)
+	for shard := 0; shard < 2; shard++ {
+		s.Len(s.states(collection, shard), 3)
+	}
+	for _, node := range s.nodes {
+		s.Len(node.Shards(), 2)
+	}
+
+	other := &models.Collection{Name: "other", Model: "test", ChunkSize: 8, Metric: models.MetricDot, Shards: 1, Replicas: 4}
+	s.Require().NoError(database.DB.Create(other).Error)
+	s.ErrorIs(Place(context.Background(), other), ErrNoNodes)
+}
+
+// Test writes succeed on a quorum of replicas, marking failed replicas stale
+func (s *ShardsSuite) TestQuorum() {
+	collection := s.collection(1, 3)
+	s.nodes["node-0"].down = true
+	s.write(collection, 3)
+	s.Equal(map[string]models.ReplicaState{
+		"node-0": models.ReplicaStale,
+		"node-1": models.ReplicaReady,
+		"node-2": models.ReplicaReady,
+	}, s.states(collection, 0))
+
+	s.nodes["node-1"].down = true
+	batches := Batches{}
+	batches.Add(0, []uint64{1}, nil)
+	s.ErrorIs(Write(context.Background(), collection, batches), ErrNoQuorum)
+}
+
+// Test searches and reads fail over to another replica, skipping stale replicas
+func (s *ShardsSuite) TestFailover() {
+	ctx := context.Background()
+	collection := s.collection(1, 2)
+	s.write(collection, 5)
+
+	for id, node := range s.nodes {
+		node.down = true
+		hits, partial, err := Search(ctx, collection, models.Vector{1}, 5)
+		if _, hosting := s.states(collection, 0)[id]; hosting {
+			s.Require().NoError(err)
+			s.False(partial)
+			s.Len(hits, 5)
+
+			entries, err := Get(ctx, collection, 0, []uint64{1, 2, 99})
+			s.Require().NoError(err)
+			s.Len(entries, 2)
+		}
+		node.down = false
+	}
+}
+
+// Test lost and stale replicas are copied again from a ready replica
+func (s *ShardsSuite) TestRepair() {
+	ctx := context.Background()
+	collection := s.collection(1, 2)
+	s.write(collection, 10)
+
+	var lost, spare string
+	for id := range s.nodes {
+		if _, hosting := s.states(collection, 0)[id]; hosting && lost == "" {
+			lost = id
+		} else if !hosting {
+			spare = id
+		}
+	}
+	s.Require().NoError(database.DB.Model(&models.Node{}).Where("id = ?", lost).Update("status", models.NodeDead).Error)
+	s.Require().NoError(Repair(ctx))
+
+	states := s.states(collection, 0)
+	s.Len(states, 2)
+	s.NotContains(states, lost)
+	s.Equal(models.ReplicaReady, states[spare])
+	s.Equal(10, int(s.nodes[spare].Shards()[0].Vectors))
+
+	// a replica missing a write, which two replicas cannot reach quorum
+	// without, is resynced
+	s.nodes[spare].down = true
+	batches := Batches{}
+	batches.Add(0, nil, []index.Entry{{ID: 11, Vector: models.Vector{11}}, {ID: 12, Vector: models.Vector{12}}})
+	s.ErrorIs(Write(ctx, collection, batches), ErrNoQuorum)
+	s.Equal(models.ReplicaStale, s.states(collection, 0)[spare])
+	s.nodes[spare].down = false
+	s.Require().NoError(Repair(ctx))
+	s.Equal(models.ReplicaReady, s.states(collection, 0)[spare])
+	s.Equal(12, int(s.nodes[spare].Shards()[0].Vectors))
+}
+
+// Test a vector written and deleted while a shard is copied is not
+// copied back onto the new replica
+func (s *ShardsSuite) TestRepairRace() {
+	ctx := context.Background()
+	collection := s.collection(1, 3)
+	s.write(collection, 10)
+	s.join("node-3")
+
+	lost, source, spare := "node-0", "node-1", "node-3"
+	s.Require().NoError(database.DB.Model(&models.Node{}).Where("id = ?", lost).Update("status", models.NodeDead).Error)
+
+	// the vector is written once the copy has read the shard, and deleted
+	// straight after the next read if there is one
+	key := Key(collection, "doc-11")
+	upsert, remove := Batches{}, Batches{}
+	upsert.Add(key, nil, []index.Entry{{ID: 11, Vector: models.Vector{11}}})
+	remove.Add(key, []uint64{11}, nil)
+	s.nodes[source].segmented = []func(){
+		func() { s.Require().NoError(Write(ctx, collection, upsert)) },
+		func() { s.Require().NoError(Write(ctx, collection, remove)) },
+	}
+	s.Require().NoError(Repair(ctx))
+	for _, next := range s.nodes[source].segmented {
+		next()
+	}
+
+	s.Equal(models.ReplicaReady, s.states(collection, 0)[spare])
+	entries, err := s.nodes[spare].Get(ctx, models.ShardRef{CollectionID: collection.ID}, []uint64{10, 11})
+	s.Require().NoError(err)
+	s.Equal([]index.Entry{{ID: 10, Vector: models.Vector{10}}}, entries)
+}
+
+// add an alive node, dialled in process
+func (s *ShardsSuite) join(id string) {
+	node := &models.Node{ID: id, Address: id + ":50060", Status: models.NodeAlive}
+	s.Require().NoError(database.DB.Create(node).Error)
+	s.nodes[id] = &fakeNode{Host: indexnode.NewHost()}
+}
+
+// Test a joining node is planned an even share in as few moves as possible
+func (s *ShardsSuite) TestPlanRebalance() {
+	ctx := context.Background()
+	s.collection(8, 1)
+
+	plan, err := PlanRebalance(ctx)
+	s.Require().NoError(err)
+	s.Empty(plan.Moves)
+
+	s.join("node-3")
+	plan, err = PlanRebalance(ctx)
+	s.Require().NoError(err)
+	s.Equal(map[string]int{"node-0": 3, "node-1": 3, "node-2": 2, "node-3": 0}, plan.Current)
+	s.Equal(map[string]int{"node-0": 2, "node-1": 2, "node-2": 2, "node-3": 2}, plan.Target)
+	s.Require().Len(plan.Moves, 2)
+	s.Equal("node-3", plan.Moves[0].To)
+	s.Equal("node-3", plan.Moves[1].To)
+	s.NotEqual(plan.Moves[0].From, plan.Moves[1].From)
+
+	// larger nodes are planned more shards
+	s.Require().NoError(database.DB.Model(&models.Node{}).Where("id = ?", "node-3").Update("memory_bytes", 1<<30).Error)
+	s.Require().NoError(database.DB.Model(&models.Node{}).Where("id <> ?", "node-3").Update("memory_bytes", 1<<29).Error)
+	plan, err = PlanRebalance(ctx)
+	s.Require().NoError(err)
+	s.Equal(map[string]int{"node-0": 2, "node-1": 2, "node-2": 1, "node-3": 3}, plan.Target)
+	s.Len(plan.Moves, 3)
+}
+
+// Test a moved shard keeps writes made during the move and flips owner
+func (s *ShardsSuite) TestRebalance() {
+	ctx := context.Background()
+	collection := s.collection(4, 1)
+	s.write(collection, 100)
+	s.join("node-3")
+
+	// keep writing while the shard is copied
+	stop, written, failed := make(chan struct{}), make(chan int, 1), make(chan error, 1)
+	go func() {
+		for n := 101; ; n++ {
+			select {
+			case <-stop:
+				written <- n - 1
+				return
+			default:
+			}
+			batches := Batches{}
+			batches.Add(Key(collection, fmt.Sprint(n)), nil, []index.Entry{{ID: uint64(n), Vector: models.Vector{float64(n)}}})
+			if err := Write(ctx, collection, batches); err != nil {
+				failed <- err
+				return
+			}
+		}
+	}()
+
+	rebalancer := NewRebalancer(configs.Rebalance{VectorsPerSecond: 500})
+	plan, err := rebalancer.Start(ctx)
+	s.Require().NoError(err)
+	s.Require().Len(plan.Moves, 1)
+	move := plan.Moves[0]
+	_, err = rebalancer.Start(ctx)
+	s.ErrorIs(err, ErrRebalancing)
+
+	s.Eventually(func() bool { return rebalancer.Status().State != "running" }, 5*time.Second, 10*time.Millisecond)
+	close(stop)
+	var n int
+	select {
+	case n = <-written:
+	case err := <-failed:
+		s.Require().NoError(err)
+	}
+
+	status := rebalancer.Status()
+	s.Equal("succeeded", status.State)
+	s.Equal("done", status.Moves[0].State)
+	s.Equal(map[string]models.ReplicaState{"node-3": models.ReplicaReady}, s.states(collection, move.Shard.Shard))
+	for _, hosted := range s.nodes[move.From].Shards() {
+		s.NotEqual(move.Shard.Shard, hosted.Shard)
+	}
+
+	// every write, before, during and after the move, is searchable
+	var total int
+	for _, node := range s.nodes {
+		for _, hosted := range node.Shards() {
+			total += int(hosted.Vectors)
+		}
+	}
+	s.Equal(n, total)
+	s.Greater(n, 100)
+	hits, partial, err := Search(ctx, collection, models.Vector{1}, n+1)
+	s.Require().NoError(err)
+	s.False(partial)
+	s.Len(hits, n)
+	s.Require().NoError(rebalancer.Stop(ctx))
+}
+
+// Test cancelling a rebalance completes the current move and skips the rest
+func (s *ShardsSuite) TestRebalanceCancel() {
+	ctx := context.Background()
+	collection := s.collection(8, 1)
+	s.write(collection, 400)
+	s.join("node-3")
+
+	rebalancer := NewRebalancer(configs.Rebalance{VectorsPerSecond: 500})
+	plan, err := rebalancer.Start(ctx)
+	s.Require().NoError(err)
+	s.Require().Len(plan.Moves, 2)
+
+	s.Eventually(func() bool { return rebalancer.Status().Moves[0].State == "copying" }, 5*time.Second, time.Millisecond)
+	rebalancer.Cancel()
+	s.Eventually(func() bool { return rebalancer.Status().State != "running" }, 5*time.Second, 10*time.Millisecond)
+
+	status := rebalancer.Status()
+	s.Equal("cancelled", status.State)
+	s.Equal("done", status.Moves[0].State)
+	s.Equal("pending", status.Moves[1].State)
+	s.Equal(map[string]models.ReplicaState{"node-3": models.ReplicaReady}, s.states(collection, plan.Moves[0].Shard.Shard))
+	s.Require().NoError(rebalancer.Stop(ctx))
+}
+
+// Test dropping a collection drops its shards from their nodes
+func (s *ShardsSuite) TestDrop() {
+	ctx := context.Background()
+	collection := s.collection(3, 2)
+	s.Require().NoError(Drop(ctx, collection))
+
+	for _, node := range s.nodes {
+		s.Empty(node.Shards())
+	}
+	placements, err := Placements(ctx, collection.ID)
+	s.Require().NoError(err)
+	s.Empty(placements)
+}
+
+// Test documents hash to a stable shard, unsharded collections use one
+func (s *ShardsSuite) TestKey() {
+	collection := &models.Collection{Shards: 4}
+	s.Equal(Key(collection, "a"), Key(collection, "a"))
+	s.Less(Key(collection, "a"), 4)
+	s.Zero(Key(&models.Collection{}, "a"))
+}
+
+func TestShardsSuite(t *testing.T) {
+	suite.Run(t, new(ShardsSuite))
+}
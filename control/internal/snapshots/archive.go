@@ -0,0 +1,264 @@
+package snapshots
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"time"
+
+	"github.com/goccy/go-json"
+
+	"github.com/christian-nickerson/pangolin/control/internal/models"
+)
+
+// FormatVersion is the archive layout written by this version
+const FormatVersion = 1
+
+// files of an archive, the manifest then the files it lists
+const (
+	manifestFile   = "manifest.json"
+	collectionFile = "collection.json"
+	documentsFile  = "documents.jsonl"
+	chunksFile     = "chunks.jsonl"
+	vectorsFile    = "index/vectors.seg"
+)
+
+// files every manifest lists, in archive order
+var listed = []string{collectionFile, documentsFile, chunksFile, vectorsFile}
+
+var (
+	ErrInvalidSnapshot  = errors.New("invalid snapshot archive")
+	ErrSnapshotTooLarge = errors.New("snapshot archive too large")
+)
+
+// Manifest describes a snapshot and the checksum of each file in its archive
+type Manifest struct {
+	Version    int       `json:"version"`
+	ID         string    `json:"id"`
+	Collection string    `json:"collection"`
+	CreatedAt  time.Time `json:"created_at"`
+	Documents  int       `json:"documents"`
+	Chunks     int       `json:"chunks"`
+	Dimensions int       `json:"dimensions"`
+	Size       int64     `json:"size"`
+	Files      []File    `json:"files"`
+}
+
+// File is a file in a snapshot archive
+type File struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// settings of the snapshotted collection, its name and counts are set on restore
+type collection struct {
+	Model        string        `json:"model"`
+	ChunkSize    int           `json:"chunk_size"`
+	ChunkOverlap int           `json:"chunk_overlap"`
+	Metric       models.Metric `json:"metric"`
+	Shards       int           `json:"shards,omitempty"`
+	Replicas     int           `json:"replicas,omitempty"`
+}
+
+// document row, ids are those of the source database
+type document struct {
+	ID          uint              `json:"id"`
+	ExternalID  string            `json:"external_id"`
+	ContentHash string            `json:"content_hash"`
+	Version     int               `json:"version"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+	ChunkCount  int               `json:"chunk_count"`
+	VectorBytes int64             `json:"vector_bytes"`
+	CreatedAt   time.Time         `json:"created_at"`
+	UpdatedAt   time.Time         `json:"updated_at"`
+}
+
+// chunk row, its id keys its vector in the vectors file
+type chunk struct {
+	ID         uint   `json:"id"`
+	DocumentID uint   `json:"document_id"`
+	Position   int    `json:"position"`
+	Hash       string `json:"hash"`
+	Text       string `json:"text"`
+}
+
+// file contents keyed by name
+type contents map[string][]byte
+
+// write a gzipped tar archive of the manifest followed by each file it lists
+func write(w io.Writer, manifest *Manifest, files contents) error {
+	manifest.Files = manifest.Files[:0]
+	for _, name := range listed {
+		sum := sha256.Sum256(files[name])
+		manifest.Files = append(manifest.Files, File{Name: name, Size: int64(len(files[name])), SHA256: hex.EncodeToString(sum[:])})
+	}
+	header, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	compressed := gzip.NewWriter(w)
+	archive := tar.NewWriter(compressed)
+	add := func(name string, data []byte) error {
+		if err := archive.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(data)), ModTime: manifest.CreatedAt}); err != nil {
+			return err
+		}
+		_, err := archive.Write(data)
+		return err
+	}
+
+	if err := add(manifestFile, header); err != nil {
+		return err
+	}
+	for _, file := range manifest.Files {
+		if err := add(file.Name, files[file.Name]); err != nil {
+			return err
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return err
+	}
+	return compressed.Close()
+}
+
+// largest manifest read from an archive
+const maxManifest = 1 << 20
+
+// read an archive, verifying every file against the manifest and holding
+// their contents, which may expand to at most limit bytes in total
+func read(r io.Reader, limit int64) (*Manifest, contents, error) {
+	buffers := map[string]*bytes.Buffer{}
+	manifest, err := scan(r, limit, func(file File) io.Writer {
+		buffers[file.Name] = bytes.NewBuffer(make([]byte, 0, file.Size))
+		return buffers[file.Name]
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	files := contents{}
+	for name, buf := range buffers {
+		files[name] = buf.Bytes()
+	}
+	return manifest, files, nil
+}
+
+// verify an archive without holding its contents
+func verify(r io.Reader, limit int64) (*Manifest, error) {
+	return scan(r, limit, func(File) io.Writer { return io.Discard })
+}
+
+// scan an archive, which must start with its manifest, copying each file
+// to the writer keep returns while checking its size and checksum. Only
+// listed files are read, so a file cannot expand beyond its listed size.
+func scan(r io.Reader, limit int64, keep func(File) io.Writer) (*Manifest, error) {
+	compressed, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w, %v", ErrInvalidSnapshot, err)
+	}
+	archive := tar.NewReader(compressed)
+
+	header, err := archive.Next()
+	if err != nil {
+		return nil, fmt.Errorf("%w, %v", ErrInvalidSnapshot, err)
+	}
+	if header.Name != manifestFile || header.Size > maxManifest {
+		return nil, fmt.Errorf("%w, archive must start with its manifest", ErrInvalidSnapshot)
+	}
+	var raw bytes.Buffer
+	if _, err := io.Copy(io.MultiWriter(&raw, keep(File{Name: manifestFile, Size: header.Size})), io.LimitReader(archive, maxManifest)); err != nil {
+		return nil, fmt.Errorf("%w, %v", ErrInvalidSnapshot, err)
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(raw.Bytes(), &manifest); err != nil {
+		return nil, fmt.Errorf("%w, manifest %v", ErrInvalidSnapshot, err)
+	}
+	if manifest.Version != FormatVersion {
+		return nil, fmt.Errorf("%w, unsupported version %v", ErrInvalidSnapshot, manifest.Version)
+	}
+
+	// every file restored from must be listed, or it would skip its checksum
+	checksums := map[string]File{}
+	var total int64
+	for _, file := range manifest.Files {
+		if !slices.Contains(listed, file.Name) || file.Size < 0 {
+			return nil, fmt.Errorf("%w, unexpected file %v", ErrInvalidSnapshot, file.Name)
+		}
+		checksums[file.Name] = file
+		total += file.Size
+	}
+	for _, name := range listed {
+		if _, ok := checksums[name]; !ok {
+			return nil, fmt.Errorf("%w, %v not listed in the manifest", ErrInvalidSnapshot, name)
+		}
+	}
+	if total > limit {
+		return nil, fmt.Errorf("%w, files expand to %v bytes, at most %v are accepted", ErrSnapshotTooLarge, total, limit)
+	}
+
+	for {
+		header, err := archive.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w, %v", ErrInvalidSnapshot, err)
+		}
+		file, ok := checksums[header.Name]
+		if !ok {
+			return nil, fmt.Errorf("%w, unexpected or repeated file %v", ErrInvalidSnapshot, header.Name)
+		}
+		delete(checksums, header.Name)
+		if header.Size != file.Size {
+			return nil, fmt.Errorf("%w, %v size mismatch", ErrInvalidSnapshot, file.Name)
+		}
+
+		hash := sha256.New()
+		copied, err := io.Copy(io.MultiWriter(hash, keep(file)), io.LimitReader(archive, file.Size))
+		if err != nil {
+			return nil, fmt.Errorf("%w, %v", ErrInvalidSnapshot, err)
+		}
+		if copied != file.Size || hex.EncodeToString(hash.Sum(nil)) != file.SHA256 {
+			return nil, fmt.Errorf("%w, %v checksum mismatch", ErrInvalidSnapshot, file.Name)
+		}
+	}
+	for name := range checksums {
+		return nil, fmt.Errorf("%w, %v missing", ErrInvalidSnapshot, name)
+	}
+	return &manifest, nil
+}
+
+// encode rows as JSON lines
+func encodeLines[T any](rows []T) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, row := range rows {
+		if err := encoder.Encode(row); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// decode JSON lines into rows
+func decodeLines[T any](data []byte) ([]T, error) {
+	var rows []T
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, len(data)+1)
+	for scanner.Scan() {
+		var row T
+		if err := json.Unmarshal(scanner.Bytes(), &row); err != nil {
+			return nil, fmt.Errorf("%w, %v", ErrInvalidSnapshot, err)
+		}
+		rows = append(rows, row)
+	}
+	return rows, scanner.Err()
+}
@@ -0,0 +1,447 @@
+package snapshots
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/christian-nickerson/pangolin/control/internal/collections"
+	"github.com/christian-nickerson/pangolin/control/internal/configs"
+	"github.com/christian-nickerson/pangolin/control/internal/database"
+	"github.com/christian-nickerson/pangolin/control/internal/index"
+	"github.com/christian-nickerson/pangolin/control/internal/models"
+	"github.com/christian-nickerson/pangolin/control/internal/namespaces"
+	"github.com/christian-nickerson/pangolin/control/internal/shards"
+	"github.com/christian-nickerson/pangolin/control/internal/storage"
+)
+
+const (
+	// rows inserted per statement and vectors per write on restore
+	batchSize = 500
+	// bounds on the layout of a restored collection, as on creation
+	maxShards   = 256
+	maxReplicas = 5
+)
+
+var (
+	ErrSnapshotNotFound  = errors.New("snapshot not found")
+	ErrCollectionChanged = errors.New("collection changed while being snapshotted, retry")
+)
+
+// Write archives a collection's settings, documents, chunks and vectors.
+// The vectors of sharded collections are streamed from each shard.
+func Write(ctx context.Context, source *models.Collection, w io.Writer) (*Manifest, error) {
+	var documents []models.Document
+	var chunks []models.Chunk
+	err := database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("collection_id = ?", source.ID).Order("id").Find(&documents).Error; err != nil {
+			return err
+		}
+		return tx.Where("collection_id = ?", source.ID).Order("id").Find(&chunks).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	vectors := make(map[uint64]models.Vector, len(chunks))
+	for _, row := range chunks {
+		vectors[uint64(row.ID)] = nil
+	}
+	for shard := range max(source.Shards, 1) {
+		err := shards.Segment(ctx, source, shard, func(entries []index.Entry) error {
+			for _, entry := range entries {
+				if _, ok := vectors[entry.ID]; ok {
+					vectors[entry.ID] = entry.Vector
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// chunks written since the rows were read have no vector yet
+	var dims int
+	entries := make([]index.Entry, len(chunks))
+	for i, row := range chunks {
+		vector := vectors[uint64(row.ID)]
+		if vector == nil {
+			return nil, ErrCollectionChanged
+		}
+		entries[i] = index.Entry{ID: uint64(row.ID), Vector: vector}
+		dims = len(vector)
+	}
+
+	files, err := encodeRows(source, documents, chunks)
+	if err != nil {
+		return nil, err
+	}
+	files[vectorsFile] = index.Encode(dims, entries)
+
+	manifest := &Manifest{
+		Version:    FormatVersion,
+		ID:         uuid.NewString(),
+		Collection: source.Name,
+		CreatedAt:  time.Now().UTC(),
+		Documents:  len(documents),
+		Chunks:     len(chunks),
+		Dimensions: dims,
+	}
+	return manifest, write(w, manifest, files)
+}
+
+// encode the collection, document and chunk files
+func encodeRows(source *models.Collection, documents []models.Document, chunks []models.Chunk) (contents, error) {
+	settings, err := json.Marshal(collection{
+		Model:        source.Model,
+		ChunkSize:    source.ChunkSize,
+		ChunkOverlap: source.ChunkOverlap,
+		Metric:       source.Metric,
+		Shards:       source.Shards,
+		Replicas:     source.Replicas,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	documentRows := make([]document, len(documents))
+	for i, d := range documents {
+		documentRows[i] = document{
+			ID:          d.ID,
+			ExternalID:  d.ExternalID,
+			ContentHash: d.ContentHash,
+			Version:     d.Version,
+			Metadata:    d.Metadata,
+			ChunkCount:  d.ChunkCount,
+			VectorBytes: d.VectorBytes,
+			CreatedAt:   d.CreatedAt,
+			UpdatedAt:   d.UpdatedAt,
+		}
+	}
+	chunkRows := make([]chunk, len(chunks))
+	for i, c := range chunks {
+		chunkRows[i] = chunk{ID: c.ID, DocumentID: c.DocumentID, Position: c.Position, Hash: c.Hash, Text: c.Text}
+	}
+
+	files := contents{collectionFile: settings}
+	if files[documentsFile], err = encodeLines(documentRows); err != nil {
+		return nil, err
+	}
+	if files[chunksFile], err = encodeLines(chunkRows); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// Restore creates a collection named name in the namespace from an archive,
+// keeping document ids and versions. Quotas apply as to any other write.
+func Restore(ctx context.Context, namespace *models.Namespace, r io.Reader, name string, config configs.Snapshots) (*models.Collection, error) {
+	_, files, err := read(r, config.MaxExpandedSize)
+	if err != nil {
+		return nil, err
+	}
+
+	var settings collection
+	if err := json.Unmarshal(files[collectionFile], &settings); err != nil {
+		return nil, fmt.Errorf("%w, %v", ErrInvalidSnapshot, err)
+	}
+	if settings.Shards < 0 || settings.Shards > maxShards || settings.Replicas < 0 || settings.Replicas > maxReplicas {
+		return nil, fmt.Errorf("%w, %v shards of %v replicas", ErrInvalidSnapshot, settings.Shards, settings.Replicas)
+	}
+	documentRows, err := decodeLines[document](files[documentsFile])
+	if err != nil {
+		return nil, err
+	}
+	chunkRows, err := decodeLines[chunk](files[chunksFile])
+	if err != nil {
+		return nil, err
+	}
+	_, entries, err := index.Decode(files[vectorsFile])
+	if err != nil {
+		return nil, fmt.Errorf("%w, %v", ErrInvalidSnapshot, err)
+	}
+
+	var vectorBytes int64
+	for _, row := range documentRows {
+		vectorBytes += row.VectorBytes
+	}
+	if err := namespaces.CheckWrite(ctx, namespace, int64(len(documentRows)), vectorBytes); err != nil {
+		return nil, err
+	}
+
+	restored := &models.Collection{
+		Name:          name,
+		Model:         settings.Model,
+		ChunkSize:     settings.ChunkSize,
+		ChunkOverlap:  settings.ChunkOverlap,
+		Metric:        settings.Metric,
+		Shards:        settings.Shards,
+		Replicas:      settings.Replicas,
+		DocumentCount: int64(len(documentRows)),
+		VectorBytes:   vectorBytes,
+	}
+	if err := collections.Create(ctx, namespace, restored); err != nil {
+		return nil, err
+	}
+
+	// rows get new ids, so vectors are rekeyed to the restored chunks
+	chunkIDs, err := insertRows(ctx, restored, documentRows, chunkRows)
+	if err == nil {
+		err = writeVectors(ctx, restored, entries, chunkIDs, shardsOf(restored, documentRows, chunkRows))
+	}
+	if err != nil {
+		if cleanup := collections.Delete(ctx, restored); cleanup != nil {
+			err = errors.Join(err, cleanup)
+		}
+		return nil, err
+	}
+	return restored, nil
+}
+
+// insert documents and chunks, returning the new id of each snapshot chunk id
+func insertRows(ctx context.Context, restored *models.Collection, documentRows []document, chunkRows []chunk) (map[uint]uint, error) {
+	chunkIDs := make(map[uint]uint, len(chunkRows))
+	err := database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		restoredDocuments := make([]models.Document, len(documentRows))
+		for i, row := range documentRows {
+			restoredDocuments[i] = models.Document{
+				CollectionID: restored.ID,
+				ExternalID:   row.ExternalID,
+				ContentHash:  row.ContentHash,
+				Version:      row.Version,
+				Metadata:     row.Metadata,
+				ChunkCount:   row.ChunkCount,
+				VectorBytes:  row.VectorBytes,
+				CreatedAt:    row.CreatedAt,
+				UpdatedAt:    row.UpdatedAt,
+			}
+		}
+		if len(restoredDocuments) > 0 {
+			if err := tx.CreateInBatches(restoredDocuments, batchSize).Error; err != nil {
+				return err
+			}
+		}
+
+		documentIDs := make(map[uint]uint, len(documentRows))
+		for i, row := range documentRows {
+			documentIDs[row.ID] = restoredDocuments[i].ID
+		}
+
+		restoredChunks := make([]models.Chunk, len(chunkRows))
+		for i, row := range chunkRows {
+			documentID, ok := documentIDs[row.DocumentID]
+			if !ok {
+				return fmt.Errorf("%w, chunk %v has no document", ErrInvalidSnapshot, row.ID)
+			}
+			restoredChunks[i] = models.Chunk{
+				CollectionID: restored.ID,
+				DocumentID:   documentID,
+				Position:     row.Position,
+				Hash:         row.Hash,
+				Text:         row.Text,
+			}
+		}
+		if len(restoredChunks) > 0 {
+			if err := tx.CreateInBatches(restoredChunks, batchSize).Error; err != nil {
+				return err
+			}
+		}
+
+		for i, row := range chunkRows {
+			chunkIDs[row.ID] = restoredChunks[i].ID
+		}
+		return nil
+	})
+	return chunkIDs, err
+}
+
+// the shard of each snapshot chunk id in the restored collection, keyed
+// by the id of its document as any write is
+func shardsOf(restored *models.Collection, documentRows []document, chunkRows []chunk) map[uint]int {
+	documentShards := make(map[uint]int, len(documentRows))
+	for _, row := range documentRows {
+		documentShards[row.ID] = shards.Key(restored, row.ExternalID)
+	}
+	chunkShards := make(map[uint]int, len(chunkRows))
+	for _, row := range chunkRows {
+		chunkShards[row.ID] = documentShards[row.DocumentID]
+	}
+	return chunkShards
+}
+
+// write snapshot vectors to the restored collection's index or shards, in
+// batches of batchSize vectors
+func writeVectors(ctx context.Context, restored *models.Collection, entries []index.Entry, chunkIDs map[uint]uint, chunkShards map[uint]int) error {
+	if len(entries) != len(chunkIDs) {
+		return fmt.Errorf("%w, %v vectors for %v chunks", ErrInvalidSnapshot, len(entries), len(chunkIDs))
+	}
+
+	for start := 0; start < len(entries); start += batchSize {
+		batches := shards.Batches{}
+		for _, entry := range entries[start:min(start+batchSize, len(entries))] {
+			id, ok := chunkIDs[uint(entry.ID)]
+			if !ok {
+				return fmt.Errorf("%w, vector %v has no chunk", ErrInvalidSnapshot, entry.ID)
+			}
+			batches.Add(chunkShards[uint(entry.ID)], nil, []index.Entry{{ID: uint64(id), Vector: entry.Vector}})
+		}
+		if err := shards.Write(ctx, restored, batches); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// prefix of a namespace's snapshots in the blob store
+func prefix(namespace *models.Namespace) string {
+	return storage.Key("snapshots", strconv.FormatUint(uint64(namespace.ID), 10))
+}
+
+func archiveKey(namespace *models.Namespace, id string) string {
+	return storage.Key(prefix(namespace), id+".tar.gz")
+}
+
+func manifestKey(namespace *models.Namespace, id string) string {
+	return storage.Key(prefix(namespace), id+".json")
+}
+
+// Create snapshots a collection into the blob store
+func Create(ctx context.Context, store storage.BlobStore, namespace *models.Namespace, source *models.Collection) (*Manifest, error) {
+	var archive bytes.Buffer
+	manifest, err := Write(ctx, source, &archive)
+	if err != nil {
+		return nil, err
+	}
+	return manifest, save(ctx, store, namespace, manifest, bytes.NewReader(archive.Bytes()), int64(archive.Len()))
+}
+
+// Import verifies an archive made elsewhere and keeps it in the blob store.
+// The archive is spooled to a temporary file rather than held in memory.
+func Import(ctx context.Context, store storage.BlobStore, namespace *models.Namespace, r io.Reader, config configs.Snapshots) (*Manifest, error) {
+	spool, err := os.CreateTemp("", "snapshot-*.tar.gz")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		spool.Close()
+		os.Remove(spool.Name())
+	}()
+
+	size, err := io.Copy(spool, io.LimitReader(r, config.MaxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if size > config.MaxSize {
+		return nil, fmt.Errorf("%w, at most %v bytes are accepted", ErrSnapshotTooLarge, config.MaxSize)
+	}
+
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	manifest, err := verify(spool, config.MaxExpandedSize)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := uuid.Parse(manifest.ID); err != nil {
+		return nil, fmt.Errorf("%w, invalid id %v", ErrInvalidSnapshot, manifest.ID)
+	}
+
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return manifest, save(ctx, store, namespace, manifest, spool, size)
+}
+
+// write the archive, then its manifest which lists it
+func save(ctx context.Context, store storage.BlobStore, namespace *models.Namespace, manifest *Manifest, archive io.Reader, size int64) error {
+	manifest.Size = size
+	if _, err := store.Put(ctx, archiveKey(namespace, manifest.ID), archive, size, storage.Condition{}); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	_, err = store.Put(ctx, manifestKey(namespace, manifest.ID), bytes.NewReader(data), int64(len(data)), storage.Condition{})
+	return err
+}
+
+// List returns the snapshots of a namespace, newest first
+func List(ctx context.Context, store storage.BlobStore, namespace *models.Namespace) ([]Manifest, error) {
+	blobs, err := store.List(ctx, prefix(namespace)+"/")
+	if err != nil {
+		return nil, err
+	}
+
+	manifests := []Manifest{}
+	for _, blob := range blobs {
+		if !strings.HasSuffix(blob.Key, ".json") {
+			continue
+		}
+		data, err := storage.ReadAll(ctx, store, blob.Key)
+		if err != nil {
+			return nil, err
+		}
+		var manifest Manifest
+		if err := json.Unmarshal(data, &manifest); err != nil {
+			return nil, err
+		}
+		manifests = append(manifests, manifest)
+	}
+
+	sort.Slice(manifests, func(a, b int) bool { return manifests[a].CreatedAt.After(manifests[b].CreatedAt) })
+	return manifests, nil
+}
+
+// Get returns a snapshot's manifest
+func Get(ctx context.Context, store storage.BlobStore, namespace *models.Namespace, id string) (*Manifest, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, ErrSnapshotNotFound
+	}
+	data, err := storage.ReadAll(ctx, store, manifestKey(namespace, id))
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, ErrSnapshotNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var manifest Manifest
+	return &manifest, json.Unmarshal(data, &manifest)
+}
+
+// Open reads a snapshot's archive
+func Open(ctx context.Context, store storage.BlobStore, namespace *models.Namespace, id string) (io.ReadCloser, error) {
+	if _, err := Get(ctx, store, namespace, id); err != nil {
+		return nil, err
+	}
+	reader, _, err := store.Get(ctx, archiveKey(namespace, id))
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, ErrSnapshotNotFound
+	}
+	return reader, err
+}
+
+// Delete removes a snapshot's manifest, then its archive
+func Delete(ctx context.Context, store storage.BlobStore, namespace *models.Namespace, id string) error {
+	if _, err := Get(ctx, store, namespace, id); err != nil {
+		return err
+	}
+	if err := store.Delete(ctx, manifestKey(namespace, id)); err != nil {
+		return err
+	}
+	return store.Delete(ctx, archiveKey(namespace, id))
+}
@@ -0,0 +1,275 @@
+package snapshots
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/goccy/go-json"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/christian-nickerson/pangolin/control/internal/collections"
+	"github.com/christian-nickerson/pangolin/control/internal/configs"
+	"github.com/christian-nickerson/pangolin/control/internal/database"
+	"github.com/christian-nickerson/pangolin/control/internal/documents"
+	"github.com/christian-nickerson/pangolin/control/internal/index"
+	"github.com/christian-nickerson/pangolin/control/internal/indexnode"
+	"github.com/christian-nickerson/pangolin/control/internal/models"
+	"github.com/christian-nickerson/pangolin/control/internal/namespaces"
+	"github.com/christian-nickerson/pangolin/control/internal/shards"
+	"github.com/christian-nickerson/pangolin/control/internal/storage"
+)
+
+// archive limits the tests accept
+var limits = configs.Snapshots{MaxSize: 1 << 20, MaxExpandedSize: 1 << 20}
+
+type SnapshotsSuite struct {
+	suite.Suite
+	namespace  *models.Namespace
+	collection *models.Collection
+	store      storage.BlobStore
+}
+
+// set up a collection holding documents "a" and "b"
+func (s *SnapshotsSuite) SetupTest() {
+	ctx := context.Background()
+	config := configs.DatabaseConfig{Type: "sqlite", DBName: filepath.Join(s.T().TempDir(), "test")}
+	s.Require().NoError(database.Connect(config))
+	s.Require().NoError(database.Migrate())
+
+	var err error
+	s.namespace, err = namespaces.EnsureDefault(ctx, configs.Quotas{})
+	s.Require().NoError(err)
+	s.store, err = storage.NewFilesystem(s.T().TempDir())
+	s.Require().NoError(err)
+
+	s.collection = &models.Collection{Name: "docs", Model: "test", ChunkSize: 2, Metric: models.MetricCosine}
+	s.fill(s.collection)
+}
+
+// create a collection holding documents "a" and "b"
+func (s *SnapshotsSuite) fill(collection *models.Collection) {
+	ctx := context.Background()
+	s.Require().NoError(collections.Create(ctx, s.namespace, collection))
+	index.Drop(collection.ID)
+
+	var plans []*documents.Plan
+	for _, upsert := range []documents.Upsert{
+		{ID: "a", Text: "one two three", Metadata: map[string]string{"source": "x"}},
+		{ID: "b", Text: "four"},
+	} {
+		plan, err := documents.Prepare(ctx, collection, upsert)
+		s.Require().NoError(err)
+		for i, text := range plan.Chunks {
+			plan.Vectors[i] = models.Vector{float64(len(text)), 1}
+		}
+		plans = append(plans, plan)
+	}
+	s.Require().NoError(documents.Apply(ctx, collection, plans))
+	s.Require().NoError(database.DB.First(collection, collection.ID).Error)
+}
+
+// snapshot the collection into an archive
+func (s *SnapshotsSuite) archive() []byte {
+	var archive bytes.Buffer
+	manifest, err := Write(context.Background(), s.collection, &archive)
+	s.Require().NoError(err)
+	s.Equal(2, manifest.Documents)
+	s.Equal(3, manifest.Chunks)
+	s.Equal(2, manifest.Dimensions)
+	return archive.Bytes()
+}
+
+// Test a restored collection holds the same documents, chunks and vectors
+func (s *SnapshotsSuite) TestRestore() {
+	ctx := context.Background()
+	restored, err := Restore(ctx, s.namespace, bytes.NewReader(s.archive()), "copy", limits)
+	s.Require().NoError(err)
+	s.T().Cleanup(func() { index.Drop(restored.ID) })
+
+	s.Equal("copy", restored.Name)
+	s.Equal(s.collection.ChunkSize, restored.ChunkSize)
+	s.Equal(s.collection.DocumentCount, restored.DocumentCount)
+	s.Equal(s.collection.VectorBytes, restored.VectorBytes)
+
+	document, err := documents.Get(ctx, restored, "a")
+	s.Require().NoError(err)
+	s.Equal("x", document.Metadata["source"])
+	s.Equal(1, document.Version)
+
+	chunks, err := documents.Chunks(ctx, document)
+	s.Require().NoError(err)
+	s.Require().Len(chunks, 2)
+	s.Equal("three", chunks[1].Text)
+	vector, ok := index.For(restored).Get(uint64(chunks[1].ID))
+	s.Require().True(ok)
+	s.Equal(models.Vector{5, 1}, vector)
+	s.Equal(3, index.For(restored).Len())
+
+	_, err = Restore(ctx, s.namespace, bytes.NewReader(s.archive()), "docs", limits)
+	s.ErrorIs(err, collections.ErrCollectionExists)
+}
+
+// Test a sharded collection is snapshotted from its shards and restored
+// onto new shards
+func (s *SnapshotsSuite) TestSharded() {
+	ctx := context.Background()
+	hosts := map[string]*indexnode.Host{}
+	for i := range 2 {
+		node := &models.Node{ID: fmt.Sprintf("node-%v", i), Address: fmt.Sprintf("node-%v:50060", i), Status: models.NodeAlive}
+		s.Require().NoError(database.DB.Create(node).Error)
+		hosts[node.ID] = indexnode.NewHost()
+	}
+	shards.Setup(configs.Cluster{}, func(node *models.Node) (shards.Node, error) { return hosts[node.ID], nil })
+	s.T().Cleanup(func() { shards.Setup(configs.Cluster{}, nil) })
+
+	s.collection = &models.Collection{Name: "sharded", Model: "test", ChunkSize: 2, Metric: models.MetricCosine, Shards: 2}
+	s.fill(s.collection)
+	restored, err := Restore(ctx, s.namespace, bytes.NewReader(s.archive()), "copy", limits)
+	s.Require().NoError(err)
+	s.Equal(2, restored.Shards)
+
+	var vectors int
+	for _, host := range hosts {
+		for _, hosted := range host.Shards() {
+			if hosted.CollectionID == restored.ID {
+				vectors += int(hosted.Vectors)
+			}
+		}
+	}
+	s.Equal(3, vectors)
+
+	document, err := documents.Get(ctx, restored, "a")
+	s.Require().NoError(err)
+	chunks, err := documents.Chunks(ctx, document)
+	s.Require().NoError(err)
+	s.Require().Len(chunks, 2)
+	entries, err := shards.Get(ctx, restored, shards.Key(restored, "a"), []uint64{uint64(chunks[1].ID)})
+	s.Require().NoError(err)
+	s.Equal([]index.Entry{{ID: uint64(chunks[1].ID), Vector: models.Vector{5, 1}}}, entries)
+}
+
+// Test restores are refused when they would exceed the namespace quota
+func (s *SnapshotsSuite) TestRestoreQuota() {
+	s.namespace.MaxDocuments = 3
+	_, err := Restore(context.Background(), s.namespace, bytes.NewReader(s.archive()), "copy", limits)
+	var quota *models.QuotaError
+	s.ErrorAs(err, &quota)
+	s.Equal("max_documents", quota.Quota)
+}
+
+// Test tampered archives fail verification
+func (s *SnapshotsSuite) TestTampered() {
+	_, files, err := read(bytes.NewReader(s.archive()), limits.MaxExpandedSize)
+	s.Require().NoError(err)
+
+	files[chunksFile] = bytes.Replace(files[chunksFile], []byte("three"), []byte("THREE"), 1)
+	_, err = Restore(context.Background(), s.namespace, bytes.NewReader(s.pack(files)), "copy", limits)
+	s.ErrorIs(err, ErrInvalidSnapshot)
+
+	// a manifest listing no files cannot skip their checksums
+	manifest, files, err := read(bytes.NewReader(s.archive()), limits.MaxExpandedSize)
+	s.Require().NoError(err)
+	manifest.Files = nil
+	files[manifestFile], err = json.Marshal(manifest)
+	s.Require().NoError(err)
+	_, err = Restore(context.Background(), s.namespace, bytes.NewReader(s.pack(files)), "copy", limits)
+	s.ErrorIs(err, ErrInvalidSnapshot)
+
+	_, err = Restore(context.Background(), s.namespace, bytes.NewReader([]byte("not an archive")), "copy", limits)
+	s.ErrorIs(err, ErrInvalidSnapshot)
+
+	_, err = collections.Get(context.Background(), s.namespace, "copy")
+	s.ErrorIs(err, collections.ErrCollectionNotFound)
+}
+
+// Test archives are refused beyond the upload and expanded size limits
+func (s *SnapshotsSuite) TestLimits() {
+	ctx := context.Background()
+	archive := s.archive()
+	_, err := Import(ctx, s.store, s.namespace, bytes.NewReader(archive), configs.Snapshots{MaxSize: int64(len(archive)) - 1, MaxExpandedSize: 1 << 20})
+	s.ErrorIs(err, ErrSnapshotTooLarge)
+	_, err = Import(ctx, s.store, s.namespace, bytes.NewReader(archive), configs.Snapshots{MaxSize: 1 << 20, MaxExpandedSize: 64})
+	s.ErrorIs(err, ErrSnapshotTooLarge)
+	_, err = Restore(ctx, s.namespace, bytes.NewReader(archive), "copy", configs.Snapshots{MaxSize: 1 << 20, MaxExpandedSize: 64})
+	s.ErrorIs(err, ErrSnapshotTooLarge)
+
+	// files larger than listed, or not listed at all, are not read
+	_, files, err := read(bytes.NewReader(archive), limits.MaxExpandedSize)
+	s.Require().NoError(err)
+	files[chunksFile] = append(files[chunksFile], make([]byte, 1<<16)...)
+	_, err = Import(ctx, s.store, s.namespace, bytes.NewReader(s.pack(files)), limits)
+	s.ErrorIs(err, ErrInvalidSnapshot)
+
+	_, files, err = read(bytes.NewReader(archive), limits.MaxExpandedSize)
+	s.Require().NoError(err)
+	files["padding"] = make([]byte, 1<<16)
+	_, err = Import(ctx, s.store, s.namespace, bytes.NewReader(s.pack(files)), limits)
+	s.ErrorIs(err, ErrInvalidSnapshot)
+
+	listed, err := List(ctx, s.store, s.namespace)
+	s.Require().NoError(err)
+	s.Empty(listed)
+}
+
+// Test snapshots are kept in the blob store and can be imported elsewhere
+func (s *SnapshotsSuite) TestStore() {
+	ctx := context.Background()
+	manifest, err := Create(ctx, s.store, s.namespace, s.collection)
+	s.Require().NoError(err)
+
+	listed, err := List(ctx, s.store, s.namespace)
+	s.Require().NoError(err)
+	s.Require().Len(listed, 1)
+	s.Equal(manifest.ID, listed[0].ID)
+	s.Equal(manifest.Size, listed[0].Size)
+
+	reader, err := Open(ctx, s.store, s.namespace, manifest.ID)
+	s.Require().NoError(err)
+	archive, err := io.ReadAll(reader)
+	reader.Close()
+	s.Require().NoError(err)
+
+	other, err := storage.NewFilesystem(s.T().TempDir())
+	s.Require().NoError(err)
+	imported, err := Import(ctx, other, s.namespace, bytes.NewReader(archive), limits)
+	s.Require().NoError(err)
+	s.Equal(manifest.ID, imported.ID)
+
+	s.Require().NoError(Delete(ctx, s.store, s.namespace, manifest.ID))
+	_, err = Get(ctx, s.store, s.namespace, manifest.ID)
+	s.ErrorIs(err, ErrSnapshotNotFound)
+	_, err = Get(ctx, s.store, s.namespace, "../escape")
+	s.ErrorIs(err, ErrSnapshotNotFound)
+}
+
+// archive files as given, manifest first, without updating the manifest
+func (s *SnapshotsSuite) pack(files contents) []byte {
+	var buf bytes.Buffer
+	compressed := gzip.NewWriter(&buf)
+	archive := tar.NewWriter(compressed)
+	names := []string{manifestFile}
+	for name := range files {
+		if name != manifestFile {
+			names = append(names, name)
+		}
+	}
+	for _, name := range names {
+		data := files[name]
+		s.Require().NoError(archive.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(data))}))
+		_, err := archive.Write(data)
+		s.Require().NoError(err)
+	}
+	s.Require().NoError(archive.Close())
+	s.Require().NoError(compressed.Close())
+	return buf.Bytes()
+}
+
+func TestSnapshotsSuite(t *testing.T) {
+	suite.Run(t, new(SnapshotsSuite))
+}
@@ -0,0 +1,328 @@
+package storage
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+const uploadsDir = ".uploads"
+
+// Filesystem stores blobs as files beneath a root directory. Each blob has
+// a hidden sidecar holding its etag. Conditional writes are serialised
+// within the process, so a root must not be shared between processes.
+type Filesystem struct {
+	root string
+	mu   sync.RWMutex
+}
+
+// NewFilesystem creates a store rooted at root, creating it if needed
+func NewFilesystem(root string) (*Filesystem, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, err
+	}
+	return &Filesystem{root: root}, nil
+}
+
+// file path of a key
+func (f *Filesystem) path(key string) string {
+	return filepath.Join(f.root, filepath.FromSlash(key))
+}
+
+// sidecar path holding the etag of a key
+func (f *Filesystem) etagPath(key string) string {
+	dir, name := filepath.Split(f.path(key))
+	return filepath.Join(dir, "."+name+".etag")
+}
+
+func (f *Filesystem) Put(ctx context.Context, key string, r io.Reader, size int64, condition Condition) (Info, error) {
+	if err := validKey(key); err != nil {
+		return Info{}, err
+	}
+
+	temp, etag, err := f.spool(key, r, size, md5.New())
+	if err != nil {
+		return Info{}, err
+	}
+	defer os.Remove(temp)
+
+	return f.commit(key, temp, etag, condition)
+}
+
+// write r to a temporary file beside the key, returning its path and hash
+func (f *Filesystem) spool(key string, r io.Reader, size int64, digest hash.Hash) (string, string, error) {
+	dir := filepath.Dir(f.path(key))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", "", err
+	}
+
+	file, err := os.CreateTemp(dir, ".tmp-*")
+	if err != nil {
+		return "", "", err
+	}
+	defer file.Close()
+
+	written, err := io.Copy(io.MultiWriter(file, digest), r)
+	if err == nil && size >= 0 && written != size {
+		err = fmt.Errorf("wrote %v bytes, expected %v", written, size)
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return "", "", err
+	}
+	return file.Name(), hex.EncodeToString(digest.Sum(nil)), nil
+}
+
+// move a spooled file into place once the condition holds
+func (f *Filesystem) commit(key, temp, etag string, condition Condition) (Info, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	current, err := f.stat(key)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		if condition.IfMatch != "" {
+			return Info{}, ErrPreconditionFailed
+		}
+	case err != nil:
+		return Info{}, err
+	case condition.IfNoneMatch, condition.IfMatch != "" && condition.IfMatch != current.ETag:
+		return Info{}, ErrPreconditionFailed
+	}
+
+	if err := os.Rename(temp, f.path(key)); err != nil {
+		return Info{}, err
+	}
+	if err := os.WriteFile(f.etagPath(key), []byte(etag), 0o644); err != nil {
+		return Info{}, err
+	}
+	return f.stat(key)
+}
+
+func (f *Filesystem) Get(ctx context.Context, key string) (io.ReadCloser, Info, error) {
+	if err := validKey(key); err != nil {
+		return nil, Info{}, err
+	}
+
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	info, err := f.stat(key)
+	if err != nil {
+		return nil, Info{}, err
+	}
+	file, err := os.Open(f.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, Info{}, ErrNotFound
+	}
+	return file, info, err
+}
+
+func (f *Filesystem) Stat(ctx context.Context, key string) (Info, error) {
+	if err := validKey(key); err != nil {
+		return Info{}, err
+	}
+
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.stat(key)
+}
+
+// stat a key, the caller holds the lock
+func (f *Filesystem) stat(key string) (Info, error) {
+	stat, err := os.Stat(f.path(key))
+	if errors.Is(err, fs.ErrNotExist) || (err == nil && stat.IsDir()) {
+		return Info{}, ErrNotFound
+	}
+	if err != nil {
+		return Info{}, err
+	}
+
+	etag, err := os.ReadFile(f.etagPath(key))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return Info{}, err
+	}
+	return Info{Key: key, Size: stat.Size(), ETag: string(etag), ModTime: stat.ModTime()}, nil
+}
+
+func (f *Filesystem) List(ctx context.Context, prefix string) ([]Info, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	var blobs []Info
+	err := filepath.WalkDir(f.root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == f.root {
+			return nil
+		}
+		if strings.HasPrefix(entry.Name(), ".") {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if entry.IsDir() {
+			return nil
+		}
+
+		relative, err := filepath.Rel(f.root, path)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(relative)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+
+		info, err := f.stat(key)
+		if err != nil {
+			return err
+		}
+		blobs = append(blobs, info)
+		return nil
+	})
+
+	slices.SortFunc(blobs, func(a, b Info) int { return strings.Compare(a.Key, b.Key) })
+	return blobs, err
+}
+
+func (f *Filesystem) Delete(ctx context.Context, key string) error {
+	if err := validKey(key); err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, path := range []string{f.path(key), f.etagPath(key)} {
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+
+	// tidy directories left empty, stopping at the root
+	for dir := filepath.Dir(f.path(key)); dir != f.root; dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			break
+		}
+	}
+	return nil
+}
+
+func (f *Filesystem) NewUpload(ctx context.Context, key string) (Upload, error) {
+	if err := validKey(key); err != nil {
+		return nil, err
+	}
+
+	dir := filepath.Join(f.root, uploadsDir, uuid.NewString())
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &filesystemUpload{store: f, key: key, dir: dir}, nil
+}
+
+// filesystemUpload stages parts in a hidden directory until completed
+type filesystemUpload struct {
+	store *Filesystem
+	key   string
+	dir   string
+}
+
+func (u *filesystemUpload) part(number int) string {
+	return filepath.Join(u.dir, strconv.Itoa(number))
+}
+
+func (u *filesystemUpload) WritePart(ctx context.Context, number int, r io.Reader, size int64) (Part, error) {
+	if number < 1 {
+		return Part{}, fmt.Errorf("invalid part number %v", number)
+	}
+
+	file, err := os.CreateTemp(u.dir, ".tmp-*")
+	if err != nil {
+		return Part{}, err
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	digest := md5.New()
+	written, err := io.Copy(io.MultiWriter(file, digest), r)
+	if err == nil && size >= 0 && written != size {
+		err = fmt.Errorf("wrote %v bytes, expected %v", written, size)
+	}
+	if err != nil {
+		return Part{}, err
+	}
+
+	if err := os.Rename(file.Name(), u.part(number)); err != nil {
+		return Part{}, err
+	}
+	return Part{Number: number, ETag: hex.EncodeToString(digest.Sum(nil))}, nil
+}
+
+func (u *filesystemUpload) Complete(ctx context.Context, parts []Part) (Info, error) {
+	if len(parts) == 0 {
+		return Info{}, errors.New("upload has no parts")
+	}
+	parts = slices.Clone(parts)
+	slices.SortFunc(parts, func(a, b Part) int { return a.Number - b.Number })
+
+	// join parts in order, the etag follows the S3 multipart convention
+	readers := make([]io.Reader, len(parts))
+	digests := md5.New()
+	for i, part := range parts {
+		file, err := os.Open(u.part(part.Number))
+		if errors.Is(err, fs.ErrNotExist) {
+			return Info{}, fmt.Errorf("%w, part %v", ErrNotFound, part.Number)
+		}
+		if err != nil {
+			return Info{}, err
+		}
+		defer file.Close()
+
+		sum, err := hex.DecodeString(part.ETag)
+		if err != nil {
+			return Info{}, fmt.Errorf("invalid etag for part %v", part.Number)
+		}
+		digests.Write(sum)
+		readers[i] = file
+	}
+
+	temp, _, err := u.store.spool(u.key, io.MultiReader(readers...), -1, md5.New())
+	if err != nil {
+		return Info{}, err
+	}
+	defer os.Remove(temp)
+
+	etag := fmt.Sprintf("%x-%v", digests.Sum(nil), len(parts))
+	info, err := u.store.commit(u.key, temp, etag, Condition{})
+	if err != nil {
+		return Info{}, err
+	}
+	return info, os.RemoveAll(u.dir)
+}
+
+func (u *filesystemUpload) Abort(ctx context.Context) error {
+	return os.RemoveAll(u.dir)
+}
@@ -0,0 +1,183 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+
+	"github.com/christian-nickerson/pangolin/control/internal/configs"
+)
+
+// S3 stores blobs in a bucket of an S3 compatible object store. Conditional
+// writes rely on the store honouring If-Match and If-None-Match on put.
+type S3 struct {
+	client *minio.Client
+	core   minio.Core
+	bucket string
+}
+
+// NewS3 connects to the object store, creating the bucket if missing
+func NewS3(config configs.S3Config) (*S3, error) {
+	client, err := minio.New(config.Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(config.AccessKey, config.SecretKey, ""),
+		Secure: config.Secure,
+		Region: config.Region,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	exists, err := client.BucketExists(ctx, config.Bucket)
+	if err != nil {
+		return nil, s3Error(err)
+	}
+	if !exists {
+		err := client.MakeBucket(ctx, config.Bucket, minio.MakeBucketOptions{Region: config.Region})
+		if err != nil {
+			return nil, s3Error(err)
+		}
+	}
+
+	return &S3{client: client, core: minio.Core{Client: client}, bucket: config.Bucket}, nil
+}
+
+func (s *S3) Put(ctx context.Context, key string, r io.Reader, size int64, condition Condition) (Info, error) {
+	if err := validKey(key); err != nil {
+		return Info{}, err
+	}
+
+	var options minio.PutObjectOptions
+	if condition.IfMatch != "" {
+		options.SetMatchETag(condition.IfMatch)
+	}
+	if condition.IfNoneMatch {
+		options.SetMatchETagExcept("*")
+	}
+
+	uploaded, err := s.client.PutObject(ctx, s.bucket, key, r, size, options)
+	if err != nil {
+		return Info{}, s3Error(err)
+	}
+	return Info{Key: key, Size: uploaded.Size, ETag: uploaded.ETag, ModTime: uploaded.LastModified}, nil
+}
+
+func (s *S3) Get(ctx context.Context, key string) (io.ReadCloser, Info, error) {
+	if err := validKey(key); err != nil {
+		return nil, Info{}, err
+	}
+
+	object, err := s.client.GetObject(ctx, s.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, Info{}, s3Error(err)
+	}
+
+	// the object is fetched lazily, stat surfaces missing keys
+	stat, err := object.Stat()
+	if err != nil {
+		object.Close()
+		return nil, Info{}, s3Error(err)
+	}
+	return object, objectInfo(stat), nil
+}
+
+func (s *S3) Stat(ctx context.Context, key string) (Info, error) {
+	if err := validKey(key); err != nil {
+		return Info{}, err
+	}
+
+	stat, err := s.client.StatObject(ctx, s.bucket, key, minio.StatObjectOptions{})
+	if err != nil {
+		return Info{}, s3Error(err)
+	}
+	return objectInfo(stat), nil
+}
+
+func (s *S3) List(ctx context.Context, prefix string) ([]Info, error) {
+	var blobs []Info
+	objects := s.client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: true})
+	for object := range objects {
+		if object.Err != nil {
+			return nil, s3Error(object.Err)
+		}
+		blobs = append(blobs, objectInfo(object))
+	}
+	return blobs, nil
+}
+
+func (s *S3) Delete(ctx context.Context, key string) error {
+	if err := validKey(key); err != nil {
+		return err
+	}
+	return s3Error(s.client.RemoveObject(ctx, s.bucket, key, minio.RemoveObjectOptions{}))
+}
+
+func (s *S3) NewUpload(ctx context.Context, key string) (Upload, error) {
+	if err := validKey(key); err != nil {
+		return nil, err
+	}
+
+	id, err := s.core.NewMultipartUpload(ctx, s.bucket, key, minio.PutObjectOptions{})
+	if err != nil {
+		return nil, s3Error(err)
+	}
+	return &s3Upload{store: s, key: key, id: id}, nil
+}
+
+// s3Upload is a native multipart upload
+type s3Upload struct {
+	store *S3
+	key   string
+	id    string
+}
+
+func (u *s3Upload) WritePart(ctx context.Context, number int, r io.Reader, size int64) (Part, error) {
+	part, err := u.store.core.PutObjectPart(ctx, u.store.bucket, u.key, u.id, number, r, size, minio.PutObjectPartOptions{})
+	if err != nil {
+		return Part{}, s3Error(err)
+	}
+	return Part{Number: part.PartNumber, ETag: part.ETag}, nil
+}
+
+func (u *s3Upload) Complete(ctx context.Context, parts []Part) (Info, error) {
+	complete := make([]minio.CompletePart, len(parts))
+	for i, part := range parts {
+		complete[i] = minio.CompletePart{PartNumber: part.Number, ETag: part.ETag}
+	}
+
+	uploaded, err := u.store.core.CompleteMultipartUpload(ctx, u.store.bucket, u.key, u.id, complete, minio.PutObjectOptions{})
+	if err != nil {
+		return Info{}, s3Error(err)
+	}
+	return u.store.Stat(ctx, uploaded.Key)
+}
+
+func (u *s3Upload) Abort(ctx context.Context) error {
+	return s3Error(u.store.core.AbortMultipartUpload(ctx, u.store.bucket, u.key, u.id))
+}
+
+func objectInfo(object minio.ObjectInfo) Info {
+	return Info{Key: object.Key, Size: object.Size, ETag: object.ETag, ModTime: object.LastModified}
+}
+
+// map object store errors onto storage errors
+func s3Error(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	response := minio.ToErrorResponse(err)
+	switch {
+	case response.Code == "NoSuchKey" || response.Code == "NoSuchUpload" || response.StatusCode == http.StatusNotFound:
+		return ErrNotFound
+	case response.Code == "PreconditionFailed" || response.StatusCode == http.StatusPreconditionFailed:
+		return ErrPreconditionFailed
+	}
+	return err
+}
@@ -0,0 +1,119 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/christian-nickerson/pangolin/control/internal/configs"
+)
+
+var (
+	ErrNotFound           = errors.New("blob not found")
+	ErrPreconditionFailed = errors.New("blob precondition failed")
+	ErrInvalidKey         = errors.New("invalid blob key")
+)
+
+// Info describes a stored blob, the etag changes whenever it is rewritten
+type Info struct {
+	Key     string    `json:"key"`
+	Size    int64     `json:"size"`
+	ETag    string    `json:"etag"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+// Condition restricts a write to the current state of the key, an empty
+// condition always writes
+type Condition struct {
+	// IfMatch writes only if the stored blob has this etag
+	IfMatch string
+	// IfNoneMatch writes only if no blob is stored under the key
+	IfNoneMatch bool
+}
+
+// Part is an uploaded part of a multipart upload
+type Part struct {
+	Number int
+	ETag   string
+}
+
+// Upload is an in progress multipart upload, the blob only becomes
+// visible once completed
+type Upload interface {
+	// WritePart uploads a numbered part, parts may arrive in any order
+	WritePart(ctx context.Context, number int, r io.Reader, size int64) (Part, error)
+	// Complete joins the parts in number order into the blob
+	Complete(ctx context.Context, parts []Part) (Info, error)
+	// Abort discards the upload and its parts
+	Abort(ctx context.Context) error
+}
+
+// BlobStore persists blobs under slash separated keys
+type BlobStore interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64, condition Condition) (Info, error)
+	Get(ctx context.Context, key string) (io.ReadCloser, Info, error)
+	Stat(ctx context.Context, key string) (Info, error)
+	// List returns blobs with keys starting with prefix, ordered by key
+	List(ctx context.Context, prefix string) ([]Info, error)
+	// Delete removes a blob, deleting a missing blob is not an error
+	Delete(ctx context.Context, key string) error
+	NewUpload(ctx context.Context, key string) (Upload, error)
+}
+
+// New creates the configured blob store
+func New(config configs.Storage) (BlobStore, error) {
+	switch config.Backend {
+	case "filesystem":
+		return NewFilesystem(config.Path)
+	case "s3":
+		return NewS3(config.S3)
+	}
+	return nil, fmt.Errorf("unknown storage backend %q", config.Backend)
+}
+
+// Key joins parts into a blob key
+func Key(parts ...string) string {
+	return strings.Join(parts, "/")
+}
+
+// DeletePrefix removes every blob with keys starting with prefix
+func DeletePrefix(ctx context.Context, store BlobStore, prefix string) error {
+	blobs, err := store.List(ctx, prefix)
+	if err != nil {
+		return err
+	}
+	for _, blob := range blobs {
+		if err := store.Delete(ctx, blob.Key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadAll returns the content of a blob
+func ReadAll(ctx context.Context, store BlobStore, key string) ([]byte, error) {
+	reader, _, err := store.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
+
+// check a key is a clean relative path without hidden segments,
+// which the filesystem store reserves for its own files
+func validKey(key string) error {
+	if key == "" || path.Clean(key) != key || strings.HasPrefix(key, "/") {
+		return fmt.Errorf("%w %q", ErrInvalidKey, key)
+	}
+	for _, segment := range strings.Split(key, "/") {
+		if strings.HasPrefix(segment, ".") {
+			return fmt.Errorf("%w %q", ErrInvalidKey, key)
+		}
+	}
+	return nil
+}
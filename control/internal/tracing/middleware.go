@@ -0,0 +1,60 @@
+package tracing
+
+import (
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RequestIDKey is the span attribute correlating spans with the requestid middleware
+const RequestIDKey = attribute.Key("pangolin.request_id")
+
+// Middleware starts a server span for each request, continuing any trace
+// propagated by the caller. Must be registered after the requestid middleware.
+func Middleware(c *fiber.Ctx) error {
+	headers := http.Header{}
+	for key, values := range c.GetReqHeaders() {
+		for _, value := range values {
+			headers.Add(key, value)
+		}
+	}
+	ctx := otel.GetTextMapPropagator().Extract(c.UserContext(), propagation.HeaderCarrier(headers))
+
+	ctx, span := Tracer.Start(ctx, c.Method(), trace.WithSpanKind(trace.SpanKindServer))
+	defer span.End()
+
+	span.SetAttributes(
+		semconv.HTTPRequestMethodKey.String(c.Method()),
+		semconv.URLPath(c.Path()),
+	)
+	if requestID, ok := c.Locals("requestid").(string); ok {
+		span.SetAttributes(RequestIDKey.String(requestID))
+	}
+
+	c.SetUserContext(ctx)
+	err := c.Next()
+
+	// route is only known once the router has matched
+	route := c.Route().Path
+	status := c.Response().StatusCode()
+	if e, ok := err.(*fiber.Error); ok {
+		status = e.Code
+	}
+	span.SetName(c.Method() + " " + route)
+	span.SetAttributes(semconv.HTTPRoute(route), semconv.HTTPResponseStatusCode(status))
+
+	if err != nil {
+		span.RecordError(err)
+	}
+	if status >= fiber.StatusInternalServerError {
+		span.SetStatus(codes.Error, http.StatusText(status))
+	}
+
+	return err
+}
@@ -0,0 +1,78 @@
+package tracing
+
+import (
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// in-memory span recorder installed as the global provider, shared
+// as Tracer binds to the first provider installed
+var spans = tracetest.NewSpanRecorder()
+
+func TestMain(m *testing.M) {
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	os.Exit(m.Run())
+}
+
+// find the last ended span with the given name
+func endedSpan(t *testing.T, name string) sdktrace.ReadOnlySpan {
+	ended := spans.Ended()
+	for i := len(ended) - 1; i >= 0; i-- {
+		if ended[i].Name() == name {
+			return ended[i]
+		}
+	}
+	t.Fatalf("no span named %v", name)
+	return nil
+}
+
+// assert request spans are named by route, tagged with the
+// request id and continue the caller's trace
+func TestMiddleware(t *testing.T) {
+	app := fiber.New()
+	app.Use(requestid.New())
+	app.Use(Middleware)
+	app.Get("/collections/:id", func(c *fiber.Ctx) error { return c.SendStatus(fiber.StatusOK) })
+
+	request := httptest.NewRequest("GET", "/collections/1", nil)
+	request.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	response, _ := app.Test(request)
+	assert.Equal(t, 200, response.StatusCode)
+
+	span := endedSpan(t, "GET /collections/:id")
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.SpanContext().TraceID().String())
+
+	var requestID string
+	for _, attr := range span.Attributes() {
+		if attr.Key == RequestIDKey {
+			requestID = attr.Value.AsString()
+		}
+	}
+	assert.Equal(t, response.Header.Get(fiber.HeaderXRequestID), requestID)
+}
+
+// assert GORM queries produce spans
+func TestGormPlugin(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.Use(GormPlugin{}))
+
+	var result int
+	require.NoError(t, db.Raw("SELECT 1").Scan(&result).Error)
+
+	span := endedSpan(t, "gorm.row")
+	assert.Equal(t, "sqlite", span.Attributes()[0].Value.AsString())
+}
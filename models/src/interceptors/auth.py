@@ -0,0 +1,40 @@
+import hmac
+from typing import Any, Callable
+
+from grpc import ServicerContext
+from grpc_interceptor import ServerInterceptor
+from grpc_interceptor.exceptions import Unauthenticated
+
+HEALTH_SERVICE = "/grpc.health.v1.Health/"
+
+
+class TokenAuthInterceptor(ServerInterceptor):
+    def __init__(self, token: str):
+        """Rejects RPCs that do not carry the shared secret as bearer authorization.
+        Health checks stay open so orchestrators can probe the server.
+
+        :param token: shared secret expected from the control plane
+        """
+        self.__expected = f"Bearer {token}".encode()
+
+    def intercept(
+        self,
+        method: Callable[..., Any],
+        request_or_iterator: Any,
+        context: ServicerContext,
+        method_name: str,
+    ) -> Any:
+        """Check the authorization metadata before calling the method
+
+        :param method: The next interceptor, or method implementation.
+        :param request_or_iterator: The RPC request, as a protobuf message.
+        :param context: The ServicerContext pass by gRPC to the service.
+        :param method_name: A string of the form "/protobuf.package.Service/Method"
+        :return: the result of method(request_or_iterator, context)
+        """
+        if not method_name.startswith(HEALTH_SERVICE):
+            metadata = dict(context.invocation_metadata())
+            provided = metadata.get("authorization", "").encode()
+            if not hmac.compare_digest(provided, self.__expected):
+                raise Unauthenticated("invalid or missing token")
+        return method(request_or_iterator, context)
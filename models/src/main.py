@@ -1,6 +1,7 @@
 import proto.embedding_pb2_grpc as embeddings
 from config import settings
 from logger import create_logger
+from service.credentials import server_credentials
 from service.models import EmbeddingsService
 from service.server import Server
 
@@ -15,12 +16,15 @@ def add_services(server: Server) -> None:
 
 if __name__ == "__main__":
     loggger = create_logger(settings.server.embeddings.name)
+    tls = settings.server.embeddings.tls
 
     server = Server(
         address="[::]",
         port=settings.server.embeddings.port,
         shutdown_period=settings.server.embeddings.shutdown_period,
         max_worker_threads=settings.server.embeddings.worker_threads,
+        credentials=server_credentials(tls.mode, tls.cert_file, tls.key_file, tls.ca_file),
+        token=settings.server.embeddings.token,
     )
 
     add_services(server)
@@ -0,0 +1,43 @@
+from typing import Optional
+
+import grpc
+
+
+def read_file(path: str) -> bytes:
+    """Read a PEM file
+
+    :param path: path to the file
+    :return: file contents
+    """
+    with open(path, "rb") as file:
+        return file.read()
+
+
+def server_credentials(
+    mode: str = "insecure",
+    cert_file: str = "",
+    key_file: str = "",
+    ca_file: str = "",
+) -> Optional[grpc.ServerCredentials]:
+    """Build transport credentials for the configured TLS mode
+
+    :param mode: insecure, tls or mtls
+    :param cert_file: server certificate, required for tls and mtls
+    :param key_file: server private key, required for tls and mtls
+    :param ca_file: CA verifying client certificates, required for mtls
+    :return: server credentials, or None when insecure
+    """
+    if mode == "insecure":
+        return None
+    if mode not in ("tls", "mtls"):
+        raise ValueError(f"unsupported tls mode {mode}")
+
+    key_pair = (read_file(key_file), read_file(cert_file))
+    if mode == "tls":
+        return grpc.ssl_server_credentials([key_pair])
+
+    return grpc.ssl_server_credentials(
+        [key_pair],
+        root_certificates=read_file(ca_file),
+        require_client_auth=True,
+    )
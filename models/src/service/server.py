@@ -1,10 +1,12 @@
 import logging
 import signal
 from concurrent import futures
+from typing import Optional
 
 import grpc
 from config import settings
 from grpc_health.v1 import health, health_pb2, health_pb2_grpc
+from interceptors.auth import TokenAuthInterceptor
 from interceptors.logging import ExceptionLoggingInterceptor
 
 logger = logging.getLogger(settings.server.embeddings.name)
@@ -17,6 +19,8 @@ class Server:
         port=50051,
         max_worker_threads: int = 10,
         shutdown_period: int = 5,
+        credentials: Optional[grpc.ServerCredentials] = None,
+        token: Optional[str] = None,
     ):
         """GRPC Server for running a gRPC API server
 
@@ -24,13 +28,21 @@ class Server:
         :param port: Port to handle requests from, defaults to 50051
         :param max_worker_threads: Number of threads to assign to server
         :param shutdown_period: Seconds to wait for RPC processes to finish before shutdown
+        :param credentials: TLS credentials to serve with, serves insecurely if None
+        :param token: shared secret required from clients on every RPC, if set
         """
         self.__address = address
         self.__port = port
         self.__shutdown_period = shutdown_period
+        self.__credentials = credentials
+
+        interceptors = [ExceptionLoggingInterceptor()]
+        if token:
+            interceptors.append(TokenAuthInterceptor(token))
+
         self.__server = grpc.server(
             thread_pool=futures.ThreadPoolExecutor(max_workers=max_worker_threads),
-            interceptors=[ExceptionLoggingInterceptor()],
+            interceptors=interceptors,
         )
 
         self.__health_check_config(max_worker_threads)
@@ -42,7 +54,10 @@ class Server:
         :param wait_for_termination: enter server into wait for termination. Primarily used for test purposes.
         """
         endpoint = f"{self.__address}:{self.__port}"
-        self.__server.add_insecure_port(endpoint)
+        if self.__credentials is None:
+            self.__server.add_insecure_port(endpoint)
+        else:
+            self.__server.add_secure_port(endpoint, self.__credentials)
         self.__health.set("", health_pb2.HealthCheckResponse.SERVING)
         self.__server.start()
         logger.info(f"serving on {self.__address} port {self.__port}")
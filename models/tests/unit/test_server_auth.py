@@ -0,0 +1,37 @@
+from typing import Iterator
+
+import grpc
+import pytest
+from main import add_services
+from proto.embedding_pb2 import ModelListRequest  # type: ignore[attr-defined]
+from proto.embedding_pb2_grpc import EmbeddingsStub
+from service.server import Server
+
+TOKEN = "secret"
+
+
+@pytest.fixture(scope="module")
+def token_address() -> Iterator[str]:
+    """start grpc server requiring a token, returns address"""
+    server = Server(port=50052, shutdown_period=5, token=TOKEN)
+    add_services(server)
+    server.start(False)
+    yield "localhost:50052"
+    server.stop()
+
+
+def test_token_required(token_address) -> None:
+    """test RPCs without the token are rejected"""
+    with grpc.insecure_channel(token_address) as channel:
+        stub = EmbeddingsStub(channel)
+        with pytest.raises(grpc.RpcError) as e:
+            _ = stub.ModelList(ModelListRequest())
+        assert e.value.code() == grpc.StatusCode.UNAUTHENTICATED
+
+
+def test_token_accepted(token_address) -> None:
+    """test RPCs with the token are served"""
+    with grpc.insecure_channel(token_address) as channel:
+        stub = EmbeddingsStub(channel)
+        response = stub.ModelList(ModelListRequest(), metadata=[("authorization", f"Bearer {TOKEN}")])
+    assert len(response.model_names) > 0